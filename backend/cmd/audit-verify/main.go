@@ -0,0 +1,97 @@
+// audit-verify 离线校验审计记录的Merkle包含证明
+//
+// 用法:
+//
+//	curl -H "Authorization: Bearer $TOKEN" http://localhost:8000/api/audit/entries/42/proof > proof.json
+//	go run ./cmd/audit-verify -proof proof.json [-root <链上读取的Merkle根>]
+//
+// 校验内容：记录哈希与记录内容一致、叶子哈希与记录哈希一致、包含证明能还原出Merkle根。
+// 指定 -root 时还会检查还原出的根与从区块链上独立读取的根一致。
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+func main() {
+	proofPath := flag.String("proof", "", "证明文件路径（GET /api/audit/entries/:sequence/proof 的响应），为空时从标准输入读取")
+	anchoredRoot := flag.String("root", "", "从区块链上读取的Merkle根（十六进制），为空时使用证明中的根")
+	flag.Parse()
+
+	var content []byte
+	var err error
+	if *proofPath == "" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(*proofPath)
+	}
+	if err != nil {
+		fail("读取证明失败: %v", err)
+	}
+
+	var proof models.AuditProof
+	if err := json.Unmarshal(content, &proof); err != nil {
+		fail("解析证明失败: %v", err)
+	}
+
+	// 1. 记录内容与记录哈希
+	if proof.Entry.ComputeHash() != proof.Entry.Hash {
+		fail("记录内容与记录哈希不一致: 序号=%d", proof.Entry.Sequence)
+	}
+
+	// 2. 记录哈希与叶子哈希
+	entryHash, err := hex.DecodeString(proof.Entry.Hash)
+	if err != nil {
+		fail("记录哈希格式无效: %v", err)
+	}
+	leafHash := utils.MerkleLeafHash(entryHash)
+	if hex.EncodeToString(leafHash) != proof.LeafHash {
+		fail("叶子哈希与记录哈希不一致")
+	}
+
+	// 3. 包含证明
+	root := proof.MerkleRoot
+	if *anchoredRoot != "" {
+		root = strings.TrimPrefix(strings.ToLower(*anchoredRoot), "0x")
+		if root != proof.MerkleRoot {
+			fail("证明中的Merkle根与链上的根不一致")
+		}
+	}
+	rootBytes, err := hex.DecodeString(root)
+	if err != nil {
+		fail("Merkle根格式无效: %v", err)
+	}
+
+	path := make([][]byte, 0, len(proof.Proof))
+	for _, node := range proof.Proof {
+		nodeBytes, err := hex.DecodeString(node)
+		if err != nil {
+			fail("证明节点格式无效: %v", err)
+		}
+		path = append(path, nodeBytes)
+	}
+
+	if !utils.VerifyMerkleProof(leafHash, proof.LeafIndex, proof.TreeSize, path, rootBytes) {
+		fail("包含证明校验失败")
+	}
+
+	fmt.Printf("校验通过: 审计记录 %d 包含在检查点 %s 中（Merkle根 %s）\n", proof.Entry.Sequence, proof.Checkpoint.ID, root)
+	for chain, anchor := range proof.Checkpoint.Anchors {
+		fmt.Printf("  %s: 状态=%s, 交易=%s\n", chain, anchor.Status, anchor.TxHash)
+	}
+}
+
+// 输出错误并退出
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "校验失败: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,42 @@
+// keygen 生成JWT签名密钥库
+//
+// 用法:
+//
+//	go run ./cmd/keygen -dir ./keys -alg EdDSA
+//
+// 在目录中写入PKCS#8 PEM私钥和 keys.json 清单。目录中已有密钥库时拒绝覆盖，
+// 运行中的服务请使用 POST /api/admin/keys/rotate 轮换密钥。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"medcross/utils"
+)
+
+func main() {
+	dir := flag.String("dir", "./keys", "密钥库目录")
+	alg := flag.String("alg", utils.SigningAlgEdDSA, "签名算法: EdDSA 或 RS256")
+	flag.Parse()
+
+	if _, err := os.Stat(filepath.Join(*dir, "keys.json")); err == nil {
+		fmt.Fprintf(os.Stderr, "密钥库已存在: %s\n", *dir)
+		os.Exit(1)
+	}
+
+	key, err := utils.GenerateSigningKey(*alg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "生成密钥失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := utils.SaveNewKeyStore(*dir, key); err != nil {
+		fmt.Fprintf(os.Stderr, "保存密钥库失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("已生成签名密钥: kid=%s, alg=%s, 目录=%s\n", key.Kid, key.Alg, *dir)
+}
@@ -0,0 +1,359 @@
+// mock-idp 本地测试用的OpenID Connect身份提供方
+//
+// 用法:
+//
+//	go run ./cmd/mock-idp -addr :9000 -issuer http://localhost:9000
+//
+// 支持发现文档、授权码模式（必须使用PKCE S256）和JWKS，ID令牌使用RS256签名。
+// 授权端点不校验密码：带 login_hint 参数时直接以该用户登录，否则显示用户列表供选择。
+// 可以用 -users 指定用户文件（JSON数组，字段见 mockUser），默认内置几个测试用户。
+// 后端配置示例见 oidc_providers.example.json。
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"medcross/utils"
+)
+
+// mockUser 测试用户及其ID令牌声明
+type mockUser struct {
+	Subject    string   `json:"sub"`
+	Username   string   `json:"preferred_username"`
+	Name       string   `json:"name"`
+	Hospital   string   `json:"hospital,omitempty"`
+	Department string   `json:"department,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+	PatientID  string   `json:"patient_id,omitempty"`
+	AMR        []string `json:"amr,omitempty"`
+}
+
+// authorizationCode 已签发尚未兑换的授权码
+type authorizationCode struct {
+	user          *mockUser
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+// idp 身份提供方状态
+type idp struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+	kid          string
+	users        []*mockUser
+
+	mu    sync.Mutex
+	codes map[string]*authorizationCode
+}
+
+var defaultUsers = []*mockUser{
+	{Subject: "1001", Username: "dr.wang", Name: "王医生", Hospital: "协和医院", Department: "心内科", Roles: []string{"physician"}, AMR: []string{"pwd"}},
+	{Subject: "1002", Username: "dr.li", Name: "李医生", Hospital: "协和医院", Department: "肿瘤科", Roles: []string{"physician"}, AMR: []string{"pwd", "otp", "mfa"}},
+	{Subject: "2001", Username: "res.zhao", Name: "赵研究员", Hospital: "协和医院", Department: "临床研究中心", Roles: []string{"research"}, AMR: []string{"pwd"}},
+	{Subject: "3001", Username: "pat.chen", Name: "陈患者", Roles: []string{"patient"}, PatientID: "P3001", AMR: []string{"pwd"}},
+	{Subject: "9001", Username: "it.admin", Name: "信息科管理员", Hospital: "协和医院", Roles: []string{"it-admin"}, AMR: []string{"pwd"}},
+}
+
+var chooserTemplate = template.Must(template.New("chooser").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Mock IdP</title></head>
+<body>
+<h3>选择登录用户</h3>
+<ul>
+{{range .Users}}<li><a href="{{$.BaseURL}}&login_hint={{.Username}}">{{.Name}}（{{.Username}}）</a></li>
+{{end}}</ul>
+</body></html>`))
+
+func main() {
+	addr := flag.String("addr", ":9000", "监听地址")
+	issuer := flag.String("issuer", "http://localhost:9000", "签发方地址，必须与后端配置的issuer一致")
+	clientID := flag.String("client-id", "medcross", "允许的客户端ID")
+	clientSecret := flag.String("client-secret", "medcross-secret", "客户端密钥，为空时不校验")
+	usersPath := flag.String("users", "", "用户文件路径，为空时使用内置测试用户")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("生成签名密钥失败: %v", err)
+	}
+
+	users := defaultUsers
+	if *usersPath != "" {
+		content, err := os.ReadFile(*usersPath)
+		if err != nil {
+			log.Fatalf("读取用户文件失败: %v", err)
+		}
+		users = nil
+		if err := json.Unmarshal(content, &users); err != nil {
+			log.Fatalf("解析用户文件失败: %v", err)
+		}
+	}
+
+	kidSum := sha256.Sum256(key.PublicKey.N.Bytes())
+	server := &idp{
+		issuer:       strings.TrimSuffix(*issuer, "/"),
+		clientID:     *clientID,
+		clientSecret: *clientSecret,
+		key:          key,
+		kid:          base64.RawURLEncoding.EncodeToString(kidSum[:8]),
+		users:        users,
+		codes:        make(map[string]*authorizationCode),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", server.handleDiscovery)
+	mux.HandleFunc("/jwks", server.handleJWKS)
+	mux.HandleFunc("/authorize", server.handleAuthorize)
+	mux.HandleFunc("/token", server.handleToken)
+
+	log.Printf("Mock IdP 启动在 %s，issuer=%s，client_id=%s", *addr, server.issuer, server.clientID)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// 发现文档
+func (p *idp) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"jwks_uri":                              p.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_post", "client_secret_basic"},
+	})
+}
+
+// 公钥集合
+func (p *idp) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, utils.JWKSet{Keys: []utils.JWK{{
+		Kty: "RSA",
+		Kid: p.kid,
+		Alg: "RS256",
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(p.key.PublicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.PublicKey.E)).Bytes()),
+	}}})
+}
+
+// 授权端点
+func (p *idp) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI := query.Get("redirect_uri")
+
+	if query.Get("client_id") != p.clientID || redirectURI == "" {
+		http.Error(w, "invalid client_id or redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if query.Get("response_type") != "code" {
+		redirectError(w, r, redirectURI, query.Get("state"), "unsupported_response_type")
+		return
+	}
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		redirectError(w, r, redirectURI, query.Get("state"), "invalid_request")
+		return
+	}
+
+	hint := query.Get("login_hint")
+	if hint == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := chooserTemplate.Execute(w, map[string]interface{}{
+			"Users":   p.users,
+			"BaseURL": p.issuer + "/authorize?" + query.Encode(),
+		}); err != nil {
+			log.Printf("渲染用户列表失败: %v", err)
+		}
+		return
+	}
+
+	user := p.findUser(hint)
+	if user == nil {
+		redirectError(w, r, redirectURI, query.Get("state"), "access_denied")
+		return
+	}
+
+	code := randomString()
+	p.mu.Lock()
+	p.codes[code] = &authorizationCode{
+		user:          user,
+		clientID:      p.clientID,
+		redirectURI:   redirectURI,
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := target.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	target.RawQuery = params.Encode()
+
+	log.Printf("已签发授权码: 用户=%s", user.Username)
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// 令牌端点
+func (p *idp) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request", "无法解析请求")
+		return
+	}
+
+	clientID, clientSecret, hasBasic := r.BasicAuth()
+	if !hasBasic {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+	if clientID != p.clientID || (p.clientSecret != "" && subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.clientSecret)) != 1) {
+		tokenError(w, "invalid_client", "客户端认证失败")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "仅支持authorization_code")
+		return
+	}
+
+	// 授权码只能使用一次
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	issued, exists := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+
+	if !exists || time.Now().After(issued.expiresAt) || issued.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, "invalid_grant", "授权码无效或已过期")
+		return
+	}
+	if utils.PKCEChallengeS256(r.PostForm.Get("code_verifier")) != issued.codeChallenge {
+		tokenError(w, "invalid_grant", "PKCE校验失败")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                p.issuer,
+		"sub":                issued.user.Subject,
+		"aud":                issued.clientID,
+		"azp":                issued.clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"auth_time":          now.Unix(),
+		"nonce":              issued.nonce,
+		"preferred_username": issued.user.Username,
+		"name":               issued.user.Name,
+	}
+	if issued.user.Hospital != "" {
+		claims["hospital"] = issued.user.Hospital
+	}
+	if issued.user.Department != "" {
+		claims["department"] = issued.user.Department
+	}
+	if issued.user.PatientID != "" {
+		claims["patient_id"] = issued.user.PatientID
+	}
+	if len(issued.user.Roles) > 0 {
+		claims["realm_access"] = map[string]interface{}{"roles": issued.user.Roles}
+	}
+	if len(issued.user.AMR) > 0 {
+		claims["amr"] = issued.user.AMR
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.kid
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		tokenError(w, "server_error", "签名失败")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// 按用户名查找用户
+func (p *idp) findUser(username string) *mockUser {
+	for _, user := range p.users {
+		if user.Username == username {
+			return user
+		}
+	}
+	return nil
+}
+
+// 重定向回客户端并携带错误码
+func redirectError(w http.ResponseWriter, r *http.Request, redirectURI, state, code string) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := target.Query()
+	params.Set("error", code)
+	params.Set("state", state)
+	target.RawQuery = params.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// 令牌端点的错误响应
+func tokenError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// 输出JSON响应
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("输出响应失败: %v", err)
+	}
+}
+
+// 生成随机字符串，用于授权码和访问令牌
+func randomString() string {
+	token, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		panic(fmt.Sprintf("生成随机数失败: %v", err))
+	}
+	return token
+}
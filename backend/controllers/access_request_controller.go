@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// AccessRequestController 处理数据访问申请相关请求
+type AccessRequestController struct {
+	accessRequestService *services.AccessRequestService
+}
+
+// NewAccessRequestController 创建新的访问申请控制器
+func NewAccessRequestController(accessRequestService *services.AccessRequestService) *AccessRequestController {
+	return &AccessRequestController{
+		accessRequestService: accessRequestService,
+	}
+}
+
+// CreateRequest 创建数据访问申请
+func (ac *AccessRequestController) CreateRequest(c *gin.Context) {
+	var req models.AccessRequestCreate
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	request, err := ac.accessRequestService.CreateRequest(userID.(string), req)
+	if err != nil {
+		respondAccessRequestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+// ApproveRequest 批准数据访问申请
+func (ac *AccessRequestController) ApproveRequest(c *gin.Context) {
+	ac.decide(c, true)
+}
+
+// DenyRequest 拒绝数据访问申请
+func (ac *AccessRequestController) DenyRequest(c *gin.Context) {
+	ac.decide(c, false)
+}
+
+// 处理审批请求
+func (ac *AccessRequestController) decide(c *gin.Context, approve bool) {
+	var decision models.AccessRequestDecision
+
+	// 审批理由可选，请求体可以为空
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&decision); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+			return
+		}
+	}
+
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	var request *models.AccessRequest
+	var err error
+	if approve {
+		request, err = ac.accessRequestService.Approve(userID.(string), c.Param("id"), decision.Reason)
+	} else {
+		request, err = ac.accessRequestService.Deny(userID.(string), c.Param("id"), decision.Reason)
+	}
+	if err != nil {
+		respondAccessRequestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// ListMyRequests 获取当前用户提交的和待其审批的访问申请
+func (ac *AccessRequestController) ListMyRequests(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	submitted, received := ac.accessRequestService.ListByUser(userID.(string))
+
+	c.JSON(http.StatusOK, gin.H{
+		"submitted": submitted,
+		"received":  received,
+	})
+}
+
+// ListRecordRequests 获取某条数据的访问申请历史
+func (ac *AccessRequestController) ListRecordRequests(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	requests := ac.accessRequestService.ListByRecord(userID.(string), c.Param("id"))
+
+	c.JSON(http.StatusOK, gin.H{
+		"records": requests,
+		"total":   len(requests),
+	})
+}
+
+// 将访问申请服务的错误转换为HTTP响应
+func respondAccessRequestError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAccessRequestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAccessRequestForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// AccountController 处理个人资料、密码和平台管理员的用户管理请求
+type AccountController struct {
+	accountService *services.AccountService
+}
+
+// NewAccountController 创建新的账户管理控制器
+func NewAccountController(accountService *services.AccountService) *AccountController {
+	return &AccountController{
+		accountService: accountService,
+	}
+}
+
+// UpdateProfile 修改当前用户的个人资料
+func (ac *AccountController) UpdateProfile(c *gin.Context) {
+	var req models.ProfileUpdate
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		return
+	}
+	c.Set("auditRecordID", userID)
+
+	user, err := ac.accountService.UpdateProfile(userID, req)
+	if err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	response := gin.H{"user": newUserResponse(user)}
+	if user.Status == models.UserStatusPending {
+		response["message"] = "所属医院已修改，等待新医院的管理员审核，请重新登录"
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ChangePassword 验证当前密码后修改密码，保留当前会话，其他会话失效
+func (ac *AccountController) ChangePassword(c *gin.Context) {
+	var req models.PasswordChangeRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	userID, sessionID, ok := currentSession(c)
+	if !ok {
+		return
+	}
+	c.Set("auditRecordID", userID)
+
+	if err := ac.accountService.ChangePassword(userID, sessionID, req.CurrentPassword, req.NewPassword); err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "密码已修改"})
+}
+
+// ForgotPassword 申请重置密码，无论用户是否存在都返回相同的结果
+func (ac *AccountController) ForgotPassword(c *gin.Context) {
+	var req models.PasswordResetRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	c.Set("auditActor", req.Username)
+
+	if err := ac.accountService.RequestPasswordReset(req.Username); err != nil {
+		log.Printf("申请重置密码失败: 用户名=%s, 错误=%v", req.Username, err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "如果该用户存在且可以重置密码，重置令牌已发送到用户的联系方式"})
+}
+
+// ResetPassword 使用重置令牌设置新密码
+func (ac *AccountController) ResetPassword(c *gin.Context) {
+	var req models.PasswordResetConfirm
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	if err := ac.accountService.ResetPassword(req.Token, req.NewPassword); err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "密码已重置，请重新登录"})
+}
+
+// RecoverWallet 重置密码后使用旧密码恢复密钥库
+func (ac *AccountController) RecoverWallet(c *gin.Context) {
+	var req models.WalletRecoverRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	expiresAt, err := ac.accountService.RecoverWallet(userID, req.OldPassword, req.Password)
+	if err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "密钥库已改用当前密码加密并解锁",
+		"expiresAt": expiresAt,
+	})
+}
+
+// ListUsers 按条件分页查询用户
+func (ac *AccountController) ListUsers(c *gin.Context) {
+	var query models.UserListQuery
+
+	// 绑定查询参数
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的查询参数"})
+		return
+	}
+
+	users, total := ac.accountService.ListUsers(query)
+	items := make([]models.UserResponse, 0, len(users))
+	for _, user := range users {
+		items = append(items, newUserResponse(user))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users": items,
+		"total": total,
+	})
+}
+
+// SetRole 调整用户角色
+func (ac *AccountController) SetRole(c *gin.Context) {
+	var req models.UserRoleUpdate
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	userID := c.Param("id")
+	c.Set("auditRecordID", userID)
+
+	user, err := ac.accountService.SetRole(c.GetString("userID"), userID, req.Role)
+	if err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// DisableUser 停用用户
+func (ac *AccountController) DisableUser(c *gin.Context) {
+	var req models.UserDisableRequest
+
+	// 停用原因可选，请求体可以为空
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+			return
+		}
+	}
+
+	userID := c.Param("id")
+	c.Set("auditRecordID", userID)
+
+	user, err := ac.accountService.Disable(c.GetString("userID"), userID, req.Reason)
+	if err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// EnableUser 重新启用被停用的用户
+func (ac *AccountController) EnableUser(c *gin.Context) {
+	userID := c.Param("id")
+	c.Set("auditRecordID", userID)
+
+	user, err := ac.accountService.Enable(c.GetString("userID"), userID)
+	if err != nil {
+		respondAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// 将账户管理错误映射为HTTP状态码
+func respondAccountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrIdentityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPasswordIncorrect), errors.Is(err, services.ErrWalletLocked):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPasswordTooShort), errors.Is(err, services.ErrResetTokenInvalid),
+		errors.Is(err, services.ErrRoleNotAssignable), errors.Is(err, services.ErrEmailInvalid),
+		errors.Is(err, services.ErrHospitalNotRegistered), errors.Is(err, services.ErrDepartmentNotFound),
+		errors.Is(err, services.ErrTenantRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCannotModifySelf), errors.Is(err, services.ErrProfileHospitalLocked),
+		errors.Is(err, services.ErrProfileDepartmentLocked), errors.Is(err, services.ErrNoLocalPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUserNotDisabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("账户管理操作失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "账户管理操作失败"})
+	}
+}
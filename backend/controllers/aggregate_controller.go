@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// AggregateController 处理差分隐私聚合统计请求
+type AggregateController struct {
+	dataService    *services.DataService
+	privacyService *services.PrivacyService
+	tenantService  *services.TenantService
+}
+
+// NewAggregateController 创建新的聚合统计控制器
+func NewAggregateController(dataService *services.DataService, privacyService *services.PrivacyService, tenantService *services.TenantService) *AggregateController {
+	return &AggregateController{
+		dataService:    dataService,
+		privacyService: privacyService,
+		tenantService:  tenantService,
+	}
+}
+
+// QueryAggregate 处理差分隐私聚合查询
+func (ac *AggregateController) QueryAggregate(c *gin.Context) {
+	var query models.AggregateQuery
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	// 默认只统计本租户的数据
+	records := ac.tenantService.FilterTenant(userID.(string), models.PurposeResearch, ac.dataService.ListData(), query.Shared)
+
+	// 执行查询
+	result, err := ac.privacyService.Aggregate(userID.(string), query, records)
+	if err != nil {
+		if errors.Is(err, services.ErrPrivacyBudgetExhausted) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrNoiseUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetPrivacyBudget 获取当前用户的隐私预算
+func (ac *AggregateController) GetPrivacyBudget(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	budget := ac.privacyService.GetBudget(userID.(string))
+
+	c.JSON(http.StatusOK, gin.H{
+		"userId":     budget.UserID,
+		"total":      budget.Total,
+		"spent":      budget.Spent,
+		"remaining":  budget.Remaining(),
+		"queryCount": budget.QueryCount,
+		"updatedAt":  budget.UpdatedAt,
+	})
+}
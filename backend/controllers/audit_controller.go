@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// AuditController 处理审计日志查询相关请求
+type AuditController struct {
+	auditService       *services.AuditService
+	auditAnchorService *services.AuditAnchorService
+	dataService        *services.DataService
+	userService        *services.UserService
+}
+
+// NewAuditController 创建新的审计日志控制器
+func NewAuditController(auditService *services.AuditService, auditAnchorService *services.AuditAnchorService, dataService *services.DataService, userService *services.UserService) *AuditController {
+	return &AuditController{
+		auditService:       auditService,
+		auditAnchorService: auditAnchorService,
+		dataService:        dataService,
+		userService:        userService,
+	}
+}
+
+// QueryAuditLog 查询审计日志
+// 拥有 audit:read 权限的用户可以查询全部记录，患者只能查询与本人数据相关的记录
+func (ac *AuditController) QueryAuditLog(c *gin.Context) {
+	var query models.AuditQuery
+
+	// 绑定查询参数
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的查询参数"})
+		return
+	}
+
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	filter, err := ac.entryFilter(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ac.auditService.Query(query, filter))
+}
+
+// GetProof 获取审计记录的Merkle包含证明
+func (ac *AuditController) GetProof(c *gin.Context) {
+	sequence, err := strconv.ParseInt(c.Param("sequence"), 10, 64)
+	if err != nil || sequence <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的审计记录序号"})
+		return
+	}
+
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	filter, err := ac.entryFilter(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	proof, err := ac.auditAnchorService.GetProof(sequence)
+	if err != nil {
+		respondAuditError(c, err)
+		return
+	}
+
+	// 无权查看的记录按不存在处理
+	if filter != nil && !filter(proof.Entry) {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrAuditEntryNotFound.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, proof)
+}
+
+// ListCheckpoints 获取审计检查点及其上链状态
+func (ac *AuditController) ListCheckpoints(c *gin.Context) {
+	checkpoints := ac.auditAnchorService.ListCheckpoints()
+
+	c.JSON(http.StatusOK, gin.H{
+		"checkpoints": checkpoints,
+		"total":       len(checkpoints),
+	})
+}
+
+// CreateCheckpoint 立即为新增审计记录创建检查点并上链
+func (ac *AuditController) CreateCheckpoint(c *gin.Context) {
+	checkpoint, err := ac.auditAnchorService.CreateCheckpoint()
+	if err != nil {
+		respondAuditError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, checkpoint)
+}
+
+// VerifyAuditLog 校验审计日志哈希链
+func (ac *AuditController) VerifyAuditLog(c *gin.Context) {
+	c.JSON(http.StatusOK, ac.auditService.Verify())
+}
+
+// 返回用户可见的审计记录过滤函数
+// 拥有 audit:read 权限时返回nil（不过滤），否则只保留与本人数据相关的记录
+func (ac *AuditController) entryFilter(userID string) (func(entry models.AuditEntry) bool, error) {
+	user, err := ac.userService.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if models.HasPermission(user.Role, models.PermAuditRead) {
+		return nil, nil
+	}
+
+	// 患者只能看到本人数据的访问记录
+	ownRecords := make(map[string]bool)
+	if user.PatientID != "" {
+		for _, record := range ac.dataService.ListDataByPatient(user.PatientID) {
+			ownRecords[record.ID] = true
+		}
+	}
+
+	return func(entry models.AuditEntry) bool {
+		return ownRecords[entry.RecordID]
+	}, nil
+}
+
+// 将审计服务的错误转换为HTTP响应
+func respondAuditError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAuditEntryNotFound), errors.Is(err, services.ErrAuditEntryNotAnchored):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNoNewAuditEntries):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "处理审计检查点失败"})
+	}
+}
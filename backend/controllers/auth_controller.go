@@ -1,7 +1,12 @@
 package controllers
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"net/mail"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
@@ -12,13 +17,25 @@ import (
 
 // AuthController 处理认证相关请求
 type AuthController struct {
-	userService *services.UserService
+	userService     *services.UserService
+	sessionService  *services.SessionService
+	mfaService      *services.MFAService
+	loginThrottle   *services.LoginThrottleService
+	identityService *services.IdentityService
+	didService      *services.DIDService
+	onboarding      *services.OnboardingService
 }
 
 // NewAuthController 创建新的认证控制器
-func NewAuthController(userService *services.UserService) *AuthController {
+func NewAuthController(userService *services.UserService, sessionService *services.SessionService, mfaService *services.MFAService, loginThrottle *services.LoginThrottleService, identityService *services.IdentityService, didService *services.DIDService, onboarding *services.OnboardingService) *AuthController {
 	return &AuthController{
-		userService: userService,
+		userService:     userService,
+		sessionService:  sessionService,
+		mfaService:      mfaService,
+		loginThrottle:   loginThrottle,
+		identityService: identityService,
+		didService:      didService,
+		onboarding:      onboarding,
 	}
 }
 
@@ -31,6 +48,31 @@ func (ac *AuthController) Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
 		return
 	}
+	c.Set("auditActor", registerData.Username)
+
+	// 管理员等角色不允许自助注册
+	if !models.SelfRegistrableRoles[registerData.Role] {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不允许注册该角色"})
+		return
+	}
+
+	// 医生和研究人员必须填写所属医院，填写的医院必须已入驻平台
+	if models.CredentialRequiredRoles[registerData.Role] && registerData.Hospital == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请填写所属医院"})
+		return
+	}
+	if registerData.Hospital != "" && !ac.onboarding.IsInstitutionRegistered(registerData.Hospital) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrHospitalNotRegistered.Error()})
+		return
+	}
+
+	// 邮箱可选，用于接收密码重置等通知
+	if registerData.Email != "" {
+		if _, err := mail.ParseAddress(registerData.Email); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrEmailInvalid.Error()})
+			return
+		}
+	}
 
 	// 检查用户名是否已存在
 	exists := ac.userService.UsernameExists(registerData.Username)
@@ -39,16 +81,56 @@ func (ac *AuthController) Register(c *gin.Context) {
 		return
 	}
 
+	// 检查患者标识是否已被绑定
+	if registerData.Role == models.RolePatient && registerData.PatientID != "" && ac.userService.PatientIDExists(registerData.PatientID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "患者标识已被绑定"})
+		return
+	}
+
 	// 创建用户
 	userID, err := ac.userService.CreateUser(registerData)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建用户失败"})
 		return
 	}
+	c.Set("userID", userID)
+
+	// 创建区块链身份，私钥使用注册密码加密保存；失败时回滚用户
+	user, err := ac.userService.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建用户失败"})
+		return
+	}
+	identity, err := ac.identityService.CreateIdentity(user, registerData.Password)
+	if err == nil {
+		err = ac.userService.SetBlockchainIdentity(userID, identity.EthereumAddress, identity.FabricID)
+	}
+	if err != nil {
+		log.Printf("创建区块链身份失败: 用户=%s, 错误=%v", userID, err)
+		ac.userService.DeleteUser(userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建区块链身份失败"})
+		return
+	}
+
+	// 分配DID，数据上链时作为所有者
+	if updated, err := ac.userService.GetUserByID(userID); err == nil {
+		user = updated
+	}
+	did, err := ac.didService.AssignUserDID(user)
+	if err != nil {
+		log.Printf("分配DID失败: 用户=%s, 错误=%v", userID, err)
+	}
+
+	// 新注册的用户等待审核，通知所属医院的管理员
+	ac.onboarding.SubmitRegistration(user)
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "用户注册成功",
-		"userId":  userID,
+		"message":         "注册成功，等待医院管理员审核",
+		"userId":          userID,
+		"status":          user.Status,
+		"ethereumAddress": identity.EthereumAddress,
+		"fabricId":        identity.FabricID,
+		"did":             did,
 	})
 }
 
@@ -61,38 +143,294 @@ func (ac *AuthController) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
 		return
 	}
+	c.Set("auditActor", loginData.Username)
+
+	// 账户或来源IP连续失败过多时要求等待，等待期间不校验密码
+	if wait, err := ac.loginThrottle.Check(loginData.Username, c.ClientIP()); err != nil {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 验证用户凭据
 	user, err := ac.userService.VerifyUser(loginData.Username, loginData.Password)
 	if err != nil {
+		ac.loginThrottle.RecordFailure(loginData.Username, c.ClientIP())
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
 		return
 	}
+	ac.loginThrottle.RecordSuccess(loginData.Username)
+	c.Set("userID", user.ID)
+
+	user = ac.openWallet(user, loginData.Password)
+
+	ac.startSession(c, user, models.AuthMethodPassword, false)
+}
+
+// 密码登录时解锁用户的托管密钥库，之后上传数据时使用用户私钥签名交易
+// 还没有区块链身份的用户（如内置测试用户和初始管理员）先补建身份，失败不影响登录；
+// 返回补建身份后的用户
+func (ac *AuthController) openWallet(user *models.User, password string) *models.User {
+	if user.EthereumAddress == "" {
+		identity, err := ac.identityService.GetIdentity(user.ID)
+		if errors.Is(err, services.ErrIdentityNotFound) {
+			identity, err = ac.identityService.CreateIdentity(user, password)
+		}
+		if err != nil {
+			log.Printf("补建区块链身份失败: 用户=%s, 错误=%v", user.ID, err)
+			return user
+		}
+
+		if err := ac.userService.SetBlockchainIdentity(user.ID, identity.EthereumAddress, identity.FabricID); err != nil {
+			log.Printf("记录区块链身份失败: 用户=%s, 错误=%v", user.ID, err)
+			return user
+		}
+		if updated, err := ac.userService.GetUserByID(user.ID); err == nil {
+			user = updated
+		}
+
+		// 初始管理员补建身份后登记为链码审计员
+		if models.HasPermission(user.Role, models.PermAuditRead) {
+			if err := ac.identityService.SyncAuditor(user); err != nil {
+				log.Printf("登记链码审计员失败: 用户=%s, 错误=%v", user.ID, err)
+			}
+		}
+	}
+
+	if _, err := ac.identityService.OpenWallet(user.ID, password); err != nil {
+		log.Printf("解锁密钥库失败: 用户=%s, 错误=%v", user.ID, err)
+	}
+	return user
+}
+
+// 第一因素认证通过后继续登录流程
+// 已启用双因素认证时返回登录挑战；角色要求双因素认证但未启用时签发受限令牌。
+// mfaSatisfied表示用户已在外部身份提供方完成多因素认证
+func (ac *AuthController) startSession(c *gin.Context, user *models.User, authMethod string, mfaSatisfied bool) {
+	// 已启用双因素认证时先返回登录挑战，验证码通过后再创建会话
+	if !mfaSatisfied && ac.mfaService.IsEnabled(user.ID) {
+		mfaToken, ttl, err := ac.mfaService.CreateChallenge(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建登录挑战失败"})
+			return
+		}
+		c.JSON(http.StatusOK, models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+			ExpiresIn:   int64(ttl.Seconds()),
+		})
+		return
+	}
+
+	// 角色要求双因素认证但尚未启用时，只签发用于启用双因素认证的受限令牌
+	session := models.Session{AuthMethod: authMethod, MFA: mfaSatisfied}
+	if !mfaSatisfied && ac.mfaService.IsRequired(user.Role) {
+		session.Scope = models.TokenScopeMFAEnroll
+	}
+
+	ac.completeLogin(c, user, session)
+}
+
+// LoginMFA 处理登录第二步，校验TOTP验证码或恢复码
+func (ac *AuthController) LoginMFA(c *gin.Context) {
+	var req models.MFALoginRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	userID, err := ac.mfaService.CompleteChallenge(req.MFAToken, req.Code)
+	if err != nil {
+		if errors.Is(err, services.ErrMFAChallengeInvalid) || errors.Is(err, services.ErrMFAInvalidCode) || errors.Is(err, services.ErrMFANotEnabled) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "双因素认证失败"})
+		return
+	}
+	c.Set("userID", userID)
+
+	user, err := ac.userService.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		return
+	}
+	c.Set("auditActor", user.Username)
+
+	ac.completeLogin(c, user, models.Session{AuthMethod: models.AuthMethodPassword, MFA: true})
+}
+
+// 创建登录会话并返回访问令牌和刷新令牌
+func (ac *AuthController) completeLogin(c *gin.Context, user *models.User, template models.Session) {
+	// 审核未通过和被停用的用户不能登录，待审核的用户只签发读取本人资料的受限令牌
+	switch user.Status {
+	case models.UserStatusRejected:
+		c.JSON(http.StatusForbidden, gin.H{"error": "账户审核未通过"})
+		return
+	case models.UserStatusDisabled:
+		c.JSON(http.StatusForbidden, gin.H{"error": "账户已被停用"})
+		return
+	case models.UserStatusPending:
+		template.Scope = models.TokenScopePending
+	}
+
+	template.UserID = user.ID
+	template.UserAgent = c.Request.UserAgent()
+	template.ClientIP = c.ClientIP()
+
+	// 创建登录会话
+	session, refreshToken, err := ac.sessionService.CreateSession(template)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建会话失败"})
+		return
+	}
+
+	// 还没有DID的用户（早期用户、单点登录用户）登录时分配，失败不影响登录
+	if _, err := ac.didService.AssignUserDID(user); err != nil {
+		log.Printf("分配DID失败: 用户=%s, 错误=%v", user.ID, err)
+	}
 
 	// 生成JWT令牌
-	token, err := utils.GenerateJWT(user)
+	token, err := utils.GenerateJWT(user, session.ID, session.Scope)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
 		return
 	}
 
-	// 构建响应
-	userResponse := models.UserResponse{
-		ID:         user.ID,
-		Username:   user.Username,
-		Name:       user.Name,
-		Role:       user.Role,
-		Hospital:   user.Hospital,
-		Department: user.Department,
-		CreatedAt:  user.CreatedAt,
+	c.JSON(http.StatusOK, models.LoginResponse{
+		Token:                 token,
+		RefreshToken:          refreshToken,
+		ExpiresIn:             int64(utils.AccessTokenTTL().Seconds()),
+		User:                  newUserResponse(user),
+		MFAEnrollmentRequired: session.Scope == models.TokenScopeMFAEnroll,
+		ApprovalPending:       session.Scope == models.TokenScopePending,
+	})
+}
+
+// RefreshToken 使用刷新令牌换取新的访问令牌和刷新令牌
+func (ac *AuthController) RefreshToken(c *gin.Context) {
+	var req models.TokenRefreshRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	session, refreshToken, err := ac.sessionService.Refresh(req.RefreshToken, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, services.ErrSessionInvalid) || errors.Is(err, services.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "刷新令牌失败"})
+		return
 	}
+	c.Set("userID", session.UserID)
 
-	c.JSON(http.StatusOK, models.LoginResponse{
-		Token: token,
-		User:  userResponse,
+	user, err := ac.userService.GetUserByID(session.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	if user.Status == models.UserStatusRejected {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "账户审核未通过"})
+		return
+	}
+	if user.Status == models.UserStatusDisabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "账户已被停用"})
+		return
+	}
+
+	// 角色后来被要求双因素认证时，未启用的用户刷新后只能拿到受限令牌
+	scope := session.Scope
+	if user.Status == models.UserStatusPending {
+		scope = models.TokenScopePending
+	} else if scope == "" && !session.MFA && ac.mfaService.IsRequired(user.Role) && !ac.mfaService.IsEnabled(user.ID) {
+		scope = models.TokenScopeMFAEnroll
+	}
+
+	token, err := utils.GenerateJWT(user, session.ID, scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(utils.AccessTokenTTL().Seconds()),
+	})
+}
+
+// Logout 注销当前会话
+func (ac *AuthController) Logout(c *gin.Context) {
+	userID, sessionID, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	if err := ac.sessionService.Revoke(userID, sessionID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已退出登录"})
+}
+
+// LogoutAll 注销当前用户的全部会话
+func (ac *AuthController) LogoutAll(c *gin.Context) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	count, err := ac.sessionService.RevokeAll(userID, "logout_all")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注销会话失败"})
+		return
+	}
+	ac.identityService.CloseWallet(userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已退出全部会话",
+		"revoked": count,
 	})
 }
 
+// ListSessions 获取当前用户的有效会话
+func (ac *AuthController) ListSessions(c *gin.Context) {
+	userID, sessionID, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	sessions := ac.sessionService.ListActive(userID, sessionID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": sessions,
+		"total":    len(sessions),
+	})
+}
+
+// RevokeSession 注销当前用户的指定会话
+func (ac *AuthController) RevokeSession(c *gin.Context) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	if err := ac.sessionService.Revoke(userID, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "会话已注销"})
+}
+
 // GetCurrentUser 获取当前用户信息
 func (ac *AuthController) GetCurrentUser(c *gin.Context) {
 	// 从上下文中获取用户ID
@@ -109,18 +447,18 @@ func (ac *AuthController) GetCurrentUser(c *gin.Context) {
 		return
 	}
 
-	// 构建响应
-	userResponse := models.UserResponse{
-		ID:         user.ID,
-		Username:   user.Username,
-		Name:       user.Name,
-		Role:       user.Role,
-		Hospital:   user.Hospital,
-		Department: user.Department,
-		CreatedAt:  user.CreatedAt,
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// 从上下文中获取当前用户ID和会话ID
+func currentSession(c *gin.Context) (string, string, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return "", "", false
 	}
 
-	c.JSON(http.StatusOK, userResponse)
+	return userID.(string), c.GetString("sessionID"), true
 }
 
 // 使用utils包中的GenerateJWT函数
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// CohortController 处理队列可行性查询请求
+type CohortController struct {
+	cohortService *services.CohortService
+	accessService *services.AccessService
+	tenantService *services.TenantService
+}
+
+// NewCohortController 创建新的队列查询控制器
+func NewCohortController(cohortService *services.CohortService, accessService *services.AccessService, tenantService *services.TenantService) *CohortController {
+	return &CohortController{
+		cohortService: cohortService,
+		accessService: accessService,
+		tenantService: tenantService,
+	}
+}
+
+// CountCohort 统计满足条件的患者数量
+func (cc *CohortController) CountCohort(c *gin.Context) {
+	var query models.CohortQuery
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	// 只有有权以科研目的访问的记录才返回ID
+	canAccess := func(data models.MedicalData) bool {
+		return cc.accessService.CanRead(userID.(string), models.PurposeResearch, data)
+	}
+
+	// 默认只统计本租户的记录
+	inScope := func(data models.MedicalData) bool {
+		return cc.tenantService.InTenant(userID.(string), data) || (query.Shared && cc.tenantService.CanAccess(userID.(string), models.PurposeResearch, data))
+	}
+
+	result, err := cc.cohortService.CountPatients(query, inScope, canAccess)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// ConsentController 处理患者知情同意相关请求
+type ConsentController struct {
+	consentService *services.ConsentService
+	userService    *services.UserService
+}
+
+// NewConsentController 创建新的知情同意控制器
+func NewConsentController(consentService *services.ConsentService, userService *services.UserService) *ConsentController {
+	return &ConsentController{
+		consentService: consentService,
+		userService:    userService,
+	}
+}
+
+// GrantConsent 授予知情同意
+func (cc *ConsentController) GrantConsent(c *gin.Context) {
+	var req models.ConsentGrantRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	actor, ok := cc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	// 检查被授权用户是否存在
+	if _, err := cc.userService.GetUserByID(req.GranteeID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "被授权用户不存在"})
+		return
+	}
+
+	consent, err := cc.consentService.Grant(actor, req)
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, consent)
+}
+
+// RevokeConsent 撤销知情同意
+func (cc *ConsentController) RevokeConsent(c *gin.Context) {
+	actor, ok := cc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	consent, err := cc.consentService.Revoke(actor, c.Param("id"))
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, consent)
+}
+
+// ListConsents 获取与当前用户相关的知情同意
+func (cc *ConsentController) ListConsents(c *gin.Context) {
+	actor, ok := cc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	consents := cc.consentService.ListForUser(actor)
+	if consents == nil {
+		consents = []models.Consent{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"consents": consents,
+		"total":    len(consents),
+	})
+}
+
+// AddProxy 添加代理人
+func (cc *ConsentController) AddProxy(c *gin.Context) {
+	var req models.ConsentProxyRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	actor, ok := cc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	// 检查代理人是否存在
+	if _, err := cc.userService.GetUserByID(req.ProxyUserID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "代理人用户不存在"})
+		return
+	}
+
+	proxy, err := cc.consentService.AddProxy(actor, req.ProxyUserID)
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, proxy)
+}
+
+// RemoveProxy 移除代理人
+func (cc *ConsentController) RemoveProxy(c *gin.Context) {
+	actor, ok := cc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := cc.consentService.RemoveProxy(actor, c.Param("userId")); err != nil {
+		respondConsentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "代理人已移除"})
+}
+
+// ListProxies 获取当前患者的代理人列表
+func (cc *ConsentController) ListProxies(c *gin.Context) {
+	actor, ok := cc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	proxies := []models.ConsentProxy{}
+	if actor.PatientID != "" {
+		proxies = append(proxies, cc.consentService.ListProxies(actor.PatientID)...)
+	}
+
+	c.JSON(http.StatusOK, proxies)
+}
+
+// 获取当前登录用户，失败时直接写入错误响应
+func (cc *ConsentController) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return nil, false
+	}
+
+	user, err := cc.userService.GetUserByID(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		return nil, false
+	}
+
+	return user, true
+}
+
+// 将知情同意服务的错误转换为HTTP响应
+func respondConsentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrConsentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrConsentForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// CredentialController 处理执业凭证签发、撤销和验证请求
+type CredentialController struct {
+	credentialService *services.CredentialService
+}
+
+// NewCredentialController 创建新的执业凭证控制器
+func NewCredentialController(credentialService *services.CredentialService) *CredentialController {
+	return &CredentialController{
+		credentialService: credentialService,
+	}
+}
+
+// IssueCredential 以用户所属医院的身份签发执业凭证
+func (cc *CredentialController) IssueCredential(c *gin.Context) {
+	var req models.CredentialIssueRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil || req.ValidDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	credential, err := cc.credentialService.Issue(req, c.GetString("userID"))
+	if err != nil {
+		respondCredentialError(c, err)
+		return
+	}
+	c.Set("auditRecordID", credential.ID)
+
+	c.JSON(http.StatusCreated, credential)
+}
+
+// ListCredentials 获取执业凭证，可按userId筛选，医院管理员只能看到本医院的凭证
+func (cc *CredentialController) ListCredentials(c *gin.Context) {
+	credentials := cc.credentialService.ListCredentials(c.Query("userId"), cc.credentialService.ManagedHospital(c.GetString("userID")))
+
+	c.JSON(http.StatusOK, gin.H{
+		"credentials": credentials,
+		"total":       len(credentials),
+	})
+}
+
+// RevokeCredential 撤销执业凭证
+func (cc *CredentialController) RevokeCredential(c *gin.Context) {
+	var req models.CredentialRevokeRequest
+
+	// 撤销原因可选，请求体可以为空
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+			return
+		}
+	}
+
+	id := credentialID(c.Param("id"))
+	c.Set("auditRecordID", id)
+	credential, err := cc.credentialService.Revoke(id, c.GetString("userID"), req.Reason)
+	if err != nil {
+		respondCredentialError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, credential)
+}
+
+// ListMyCredentials 获取当前用户的执业凭证，用户可将凭证出示给其他部署验证
+func (cc *CredentialController) ListMyCredentials(c *gin.Context) {
+	credentials := cc.credentialService.ListCredentials(c.GetString("userID"), "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"credentials": credentials,
+		"total":       len(credentials),
+	})
+}
+
+// VerifyCredential 验证JWT格式的可验证凭证，包括其他部署签发的凭证
+func (cc *CredentialController) VerifyCredential(c *gin.Context) {
+	var req models.CredentialVerifyRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	c.JSON(http.StatusOK, cc.credentialService.Verify(strings.TrimSpace(req.Credential)))
+}
+
+// GetStatusList 发布医院的凭证状态列表，公开访问
+func (cc *CredentialController) GetStatusList(c *gin.Context) {
+	statusList, err := cc.credentialService.StatusListCredential(c.Param("slug"))
+	if err != nil {
+		respondCredentialError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "application/vc+jwt", []byte(statusList))
+}
+
+// 路径中的凭证ID可以省略urn:uuid:前缀
+func credentialID(id string) string {
+	if strings.HasPrefix(id, "urn:uuid:") {
+		return id
+	}
+	return "urn:uuid:" + id
+}
+
+// 将执业凭证错误映射为HTTP状态码
+func respondCredentialError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCredentialNotFound), errors.Is(err, services.ErrCredentialUserNotFound), errors.Is(err, services.ErrDIDNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCredentialRoleExempt), errors.Is(err, services.ErrCredentialNoHospital):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCredentialNoDID), errors.Is(err, services.ErrStatusListFull), errors.Is(err, services.ErrCredentialUserInactive), errors.Is(err, services.ErrHospitalNotRegistered):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCredentialForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		log.Printf("执业凭证操作失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "执业凭证操作失败"})
+	}
+}
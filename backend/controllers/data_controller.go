@@ -1,7 +1,9 @@
 package controllers
 
 import (
+	"errors"
 	"log"
+	"mime"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,19 +13,30 @@ import (
 
 	"medcross/models"
 	"medcross/services"
+	"medcross/utils"
 )
 
 // DataController 处理医疗数据相关请求
 type DataController struct {
 	dataService    *services.DataService
 	gatewayService *services.GatewayService
+	accessService  *services.AccessService
+	signingService *services.SigningService
+	didService     *services.DIDService
+	tenantService  *services.TenantService
+	userService    *services.UserService
 }
 
 // NewDataController 创建新的数据控制器
-func NewDataController(dataService *services.DataService, gatewayService *services.GatewayService) *DataController {
+func NewDataController(dataService *services.DataService, gatewayService *services.GatewayService, accessService *services.AccessService, signingService *services.SigningService, didService *services.DIDService, tenantService *services.TenantService, userService *services.UserService) *DataController {
 	return &DataController{
 		dataService:    dataService,
 		gatewayService: gatewayService,
+		accessService:  accessService,
+		signingService: signingService,
+		didService:     didService,
+		tenantService:  tenantService,
+		userService:    userService,
 	}
 }
 
@@ -44,6 +57,20 @@ func (dc *DataController) QueryData(c *gin.Context) {
 	if query.PageSize <= 0 {
 		query.PageSize = 10
 	}
+	if query.Purpose == "" {
+		query.Purpose = models.PurposeTreatment
+	}
+	if !models.ValidPurposes[query.Purpose] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的使用目的"})
+		return
+	}
+
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
 
 	// 调用跨链网关服务进行查询
 	result, err := dc.gatewayService.QueryData(query)
@@ -52,6 +79,28 @@ func (dc *DataController) QueryData(c *gin.Context) {
 		return
 	}
 
+	// 默认只返回本租户的数据，并且只返回用户有权访问的数据
+	// 先过滤再分页，总数只统计可读的记录，不暴露用户无权访问的记录数量
+	scoped := dc.tenantService.FilterTenant(userID.(string), query.Purpose, result.Data, query.Shared)
+	readable := dc.accessService.FilterReadable(userID.(string), query.Purpose, scoped)
+	result.TotalCount = len(readable)
+	result.Data = []models.MedicalData{}
+	if start := (query.Page - 1) * query.PageSize; start < len(readable) {
+		end := start + query.PageSize
+		if end > len(readable) {
+			end = len(readable)
+		}
+		result.Data = readable[start:end]
+	}
+
+	// 记录返回的数据，便于患者查看谁检索过其数据
+	recordIDs := make([]string, 0, len(result.Data))
+	for _, record := range result.Data {
+		recordIDs = append(recordIDs, record.ID)
+	}
+	c.Set("auditRecordIDs", recordIDs)
+	c.Set("auditChain", query.Chain)
+
 	c.JSON(http.StatusOK, result)
 }
 
@@ -72,16 +121,23 @@ func (dc *DataController) UploadData(c *gin.Context) {
 		return
 	}
 
-	// 生成唯一ID
-	dataID := uuid.New().String()
+	// 确定交易签名方式
+	signingMode, err := dc.signingService.ResolveSigningMode(userID.(string), uploadData.SigningMode)
+	if err != nil {
+		respondSigningError(c, err)
+		return
+	}
 
-	// 处理文件上传
-	dataHash, err := dc.dataService.StoreFile(uploadData.File, uploadData.FileName)
+	// 数据所有者为用户的DID，两条链上记录相同的所有者
+	ownerDID, err := dc.didService.OwnerDID(userID.(string))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "存储文件失败"})
+		respondSigningError(c, err)
 		return
 	}
 
+	// 生成唯一ID
+	dataID := uuid.New().String()
+
 	// 准备元数据
 	metadata := map[string]string{
 		"fileName":    uploadData.FileName,
@@ -89,25 +145,85 @@ func (dc *DataController) UploadData(c *gin.Context) {
 		"uploadedBy":  userID.(string),
 		"fileSize":    strconv.Itoa(len(uploadData.File)),
 	}
+	if uploadData.PatientID != "" {
+		metadata["patientId"] = uploadData.PatientID
+	}
+
+	// 记录上传者的医院和科室，用于按医院、科室统计（上传到Fabric时写入私有数据集合）
+	if user, err := dc.userService.GetUserByID(userID.(string)); err == nil {
+		if user.Hospital != "" {
+			metadata["hospital"] = user.Hospital
+		}
+		if user.Department != "" {
+			metadata["department"] = user.Department
+		}
+	}
+
+	// 数据归属上传者所在的租户，租户写入元数据随数据上链
+	// 受租户隔离限制的用户所属医院尚未入驻时不能上传，避免产生无法确定租户的数据
+	tenantID := dc.tenantService.UserTenant(userID.(string))
+	if tenantID != "" {
+		metadata["tenantId"] = tenantID
+	} else if dc.tenantService.TenantScoped(userID.(string)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "所属医院尚未入驻平台，不能上传数据"})
+		return
+	}
 
 	// 创建医疗数据记录
 	medicalData := models.MedicalData{
 		ID:        dataID,
-		Owner:     userID.(string),
-		DataHash:  dataHash,
+		Owner:     ownerDID,
 		DataType:  uploadData.DataType,
 		Metadata:  dc.dataService.MapToJSON(metadata),
 		Timestamp: time.Now(),
 		Keywords:  uploadData.Keywords,
 		Chain:     uploadData.TargetChain,
+		TenantID:  tenantID,
+	}
+	c.Set("auditRecordID", dataID)
+	c.Set("auditChain", uploadData.TargetChain)
+
+	// 检查访问策略
+	if !dc.accessService.CanUpload(userID.(string), medicalData) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "访问策略禁止上传该数据"})
+		return
 	}
 
-	// 上传到区块链
-	err = dc.gatewayService.UploadData(medicalData)
+	// 处理文件上传
+	dataHash, err := dc.dataService.StoreFile(uploadData.File, uploadData.FileName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "上传到区块链失败"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "存储文件失败"})
 		return
 	}
+	medicalData.DataHash = dataHash
+
+	// 上传到区块链：有区块链身份的用户使用自己的私钥签名，链上所有者为用户的地址或Fabric身份
+	var txHash string
+	switch signingMode {
+	case models.SigningModeCustodial:
+		result, err := dc.signingService.SubmitUpload(userID.(string), &medicalData)
+		if err != nil {
+			respondSigningError(c, err)
+			return
+		}
+		txHash = result.TransactionHash
+	case models.SigningModeClient:
+		// 返回待签名交易，客户端签名后提交到 /api/upload/:id/signature
+		unsigned, err := dc.signingService.PrepareUpload(userID.(string), medicalData)
+		if err != nil {
+			respondSigningError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, unsigned)
+		return
+	default:
+		// 单点登录用户和服务账户没有区块链身份，由网关账户代为提交
+		err = dc.gatewayService.UploadData(medicalData)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "上传到区块链失败"})
+			return
+		}
+	}
 
 	// 保存到本地数据库
 	err = dc.dataService.SaveData(medicalData)
@@ -117,13 +233,75 @@ func (dc *DataController) UploadData(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusCreated, models.UploadResponse{
-		ID:       dataID,
-		Message:  "数据上传成功",
-		DataHash: dataHash,
-		Chain:    uploadData.TargetChain,
+		ID:              dataID,
+		Message:         "数据上传成功",
+		DataHash:        dataHash,
+		Chain:           uploadData.TargetChain,
+		Owner:           medicalData.Owner,
+		SigningMode:     signingMode,
+		TransactionHash: txHash,
 	})
 }
 
+// SubmitUploadSignature 提交客户端对待签名上传交易的签名，校验后转发上链
+func (dc *DataController) SubmitUploadSignature(c *gin.Context) {
+	var req models.TransactionSignatureRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	data, result, err := dc.signingService.CompleteUpload(userID.(string), c.Param("id"), req.Signature)
+	if err != nil {
+		respondSigningError(c, err)
+		return
+	}
+	c.Set("auditRecordID", data.ID)
+	c.Set("auditChain", data.Chain)
+
+	// 保存到本地数据库
+	if err := dc.dataService.SaveData(*data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存数据失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.UploadResponse{
+		ID:              data.ID,
+		Message:         "数据上传成功",
+		DataHash:        data.DataHash,
+		Chain:           data.Chain,
+		Owner:           data.Owner,
+		SigningMode:     models.SigningModeClient,
+		TransactionHash: result.TransactionHash,
+	})
+}
+
+// 将交易签名错误映射为HTTP状态码
+func respondSigningError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrWalletNotOpen), errors.Is(err, services.ErrDIDUnavailable):
+		c.JSON(http.StatusLocked, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPendingTxNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTxSignatureInvalid), errors.Is(err, services.ErrUnsupportedTxChain), errors.Is(err, services.ErrSigningModeInvalid), errors.Is(err, services.ErrGatewaySigningDenied):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNoBlockchainAccount):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("用户签名上链失败: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "上传到区块链失败"})
+	}
+}
+
 // GetDataTypes 获取数据类型列表
 func (dc *DataController) GetDataTypes(c *gin.Context) {
 	// 返回预定义的数据类型列表
@@ -147,6 +325,20 @@ func (dc *DataController) GetDataDetail(c *gin.Context) {
 		return
 	}
 
+	// 获取使用目的
+	purpose := c.DefaultQuery("purpose", models.PurposeTreatment)
+	if !models.ValidPurposes[purpose] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的使用目的"})
+		return
+	}
+
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
 	// 从本地数据库获取数据
 	data, err := dc.dataService.GetDataByID(dataID)
 	if err != nil {
@@ -158,6 +350,14 @@ func (dc *DataController) GetDataDetail(c *gin.Context) {
 		}
 	}
 
+	c.Set("auditChain", data.Chain)
+
+	// 校验访问授权或知情同意
+	if !dc.accessService.CanRead(userID.(string), purpose, *data) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "未获得访问授权"})
+		return
+	}
+
 	// 获取文件内容信息
 	fileInfo, err := dc.dataService.GetFileInfo(data.DataHash)
 	if err != nil {
@@ -188,10 +388,129 @@ func (dc *DataController) GetDataDetail(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// GetStatistics 获取统计数据
+// DownloadFile 下载数据对应的文件
+func (dc *DataController) DownloadFile(c *gin.Context) {
+	// 获取数据ID
+	dataID := c.Param("id")
+
+	// 获取使用目的
+	purpose := c.DefaultQuery("purpose", models.PurposeTreatment)
+	if !models.ValidPurposes[purpose] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的使用目的"})
+		return
+	}
+
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	// 从本地数据库获取数据
+	data, err := dc.dataService.GetDataByID(dataID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "数据不存在"})
+		return
+	}
+
+	c.Set("auditChain", data.Chain)
+
+	// 校验访问授权
+	if !dc.accessService.CanRead(userID.(string), purpose, *data) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "未获得访问授权"})
+		return
+	}
+
+	fileName, content, err := dc.dataService.GetFile(data.DataHash)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	c.Data(http.StatusOK, "application/octet-stream", content)
+}
+
+// TransferData 处理跨链转移请求
+func (dc *DataController) TransferData(c *gin.Context) {
+	var transferReq models.TransferRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&transferReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	c.Set("auditRecordID", transferReq.DataID)
+	c.Set("auditChain", transferReq.SourceChain+"->"+transferReq.TargetChain)
+
+	// 获取源数据
+	data, err := dc.dataService.GetDataByID(transferReq.DataID)
+	if err != nil {
+		data, err = dc.gatewayService.GetDataByID(transferReq.DataID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "数据不存在"})
+			return
+		}
+	}
+
+	if data.Chain != transferReq.SourceChain {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "源区块链与数据所在链不一致"})
+		return
+	}
+
+	// 只有数据所有者或管理员可以转移数据
+	if !dc.accessService.CanTransfer(userID.(string), *data) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权转移该数据"})
+		return
+	}
+
+	// 执行跨链转移
+	transferID := uuid.New().String()
+	result, err := dc.gatewayService.CrossChainTransfer(*data, transferReq.TargetChain)
+	if errors.Is(err, utils.ErrUnknownChainIdentity) {
+		log.Printf("跨链转移失败: ID=%s, 错误=%v", transferID, err)
+		c.JSON(http.StatusConflict, gin.H{"error": utils.ErrUnknownChainIdentity.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("跨链转移失败: ID=%s, 错误=%v", transferID, err)
+		c.JSON(http.StatusBadGateway, models.TransferResponse{
+			TransferID: transferID,
+			SourceID:   data.ID,
+			Status:     "failed",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TransferResponse{
+		TransferID: transferID,
+		SourceID:   data.ID,
+		TargetID:   result.ID,
+		Status:     "completed",
+	})
+}
+
+// GetStatistics 获取统计数据，受租户隔离限制的用户只统计本租户的数据
 func (dc *DataController) GetStatistics(c *gin.Context) {
+	userID := c.GetString("userID")
+	var inScope func(models.MedicalData) bool
+	if dc.tenantService.TenantScoped(userID) {
+		inScope = func(data models.MedicalData) bool {
+			return dc.tenantService.InTenant(userID, data)
+		}
+	}
+
 	// 获取统计数据
-	stats, err := dc.dataService.GetStatistics()
+	stats, err := dc.dataService.GetStatistics(inScope)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取统计数据失败"})
 		return
@@ -218,8 +537,14 @@ func (dc *DataController) SearchData(c *gin.Context) {
 		pageSize = 10
 	}
 
+	// 只搜索本租户的数据
+	userID := c.GetString("userID")
+	inScope := func(data models.MedicalData) bool {
+		return dc.tenantService.InTenant(userID, data)
+	}
+
 	// 调用服务进行搜索
-	result, err := dc.dataService.SearchDataByKeyword(keyword, dataType, chain, page, pageSize)
+	result, err := dc.dataService.SearchDataByKeyword(keyword, dataType, chain, page, pageSize, inScope)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索数据失败"})
 		return
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/services"
+)
+
+// DIDController 处理DID文档发布和解析请求
+type DIDController struct {
+	didService *services.DIDService
+}
+
+// NewDIDController 创建新的DID控制器
+func NewDIDController(didService *services.DIDService) *DIDController {
+	return &DIDController{
+		didService: didService,
+	}
+}
+
+// GetHospitalDocument 发布医院的did:web文档，公开访问
+func (dc *DIDController) GetHospitalDocument(c *gin.Context) {
+	document, err := dc.didService.HospitalDocument(c.Param("slug"))
+	if err != nil {
+		respondDIDError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/did+json")
+	c.JSON(http.StatusOK, document)
+}
+
+// GetUserDocument 发布没有区块链身份的用户的did:web文档，公开访问
+func (dc *DIDController) GetUserDocument(c *gin.Context) {
+	document, err := dc.didService.UserDocument(c.Param("id"))
+	if err != nil {
+		respondDIDError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/did+json")
+	c.JSON(http.StatusOK, document)
+}
+
+// Resolve 解析did:key或did:web，返回DID文档
+func (dc *DIDController) Resolve(c *gin.Context) {
+	did := c.Query("did")
+	if did == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少did参数"})
+		return
+	}
+
+	result, err := dc.didService.Resolve(did)
+	if err != nil {
+		respondDIDError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListHospitals 获取本平台发布的医院DID
+func (dc *DIDController) ListHospitals(c *gin.Context) {
+	c.JSON(http.StatusOK, dc.didService.ListHospitals())
+}
+
+// 将DID服务错误映射为HTTP状态码
+func respondDIDError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrDIDNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDIDInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDIDResolution):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDIDUnavailable):
+		c.JSON(http.StatusLocked, gin.H{"error": err.Error()})
+	default:
+		log.Printf("DID操作失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "DID操作失败"})
+	}
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// KeyController 处理JWT签名密钥相关请求
+type KeyController struct {
+	keyStore *utils.KeyStore
+}
+
+// NewKeyController 创建新的签名密钥控制器
+func NewKeyController(keyStore *utils.KeyStore) *KeyController {
+	return &KeyController{
+		keyStore: keyStore,
+	}
+}
+
+// GetJWKS 返回用于验证访问令牌的公钥集合
+// 网关和其他医院系统可以据此独立验证令牌
+func (kc *KeyController) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, kc.keyStore.JWKS())
+}
+
+// ListKeys 获取签名密钥元数据
+func (kc *KeyController) ListKeys(c *gin.Context) {
+	keys := kc.keyStore.ListKeys()
+
+	c.JSON(http.StatusOK, gin.H{
+		"keys":  keys,
+		"total": len(keys),
+	})
+}
+
+// RotateKey 轮换签名密钥
+func (kc *KeyController) RotateKey(c *gin.Context) {
+	var req models.KeyRotateRequest
+
+	// 请求体可以为空
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+			return
+		}
+	}
+	if req.Alg == "" {
+		req.Alg = utils.SigningAlgEdDSA
+	}
+	if req.Alg != utils.SigningAlgEdDSA && req.Alg != utils.SigningAlgRS256 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的签名算法"})
+		return
+	}
+
+	key, err := kc.keyStore.Rotate(req.Alg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "轮换签名密钥失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "签名密钥已轮换",
+		"kid":     key.Kid,
+		"alg":     key.Alg,
+	})
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+	"medcross/utils"
+)
+
+// MFAController 处理双因素认证相关请求
+type MFAController struct {
+	mfaService     *services.MFAService
+	userService    *services.UserService
+	sessionService *services.SessionService
+}
+
+// NewMFAController 创建新的双因素认证控制器
+func NewMFAController(mfaService *services.MFAService, userService *services.UserService, sessionService *services.SessionService) *MFAController {
+	return &MFAController{
+		mfaService:     mfaService,
+		userService:    userService,
+		sessionService: sessionService,
+	}
+}
+
+// GetStatus 获取当前用户的双因素认证状态
+func (mc *MFAController) GetStatus(c *gin.Context) {
+	user, ok := mc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, mc.mfaService.Status(user))
+}
+
+// Setup 生成TOTP密钥，用户在身份验证器中添加后调用Confirm确认
+func (mc *MFAController) Setup(c *gin.Context) {
+	user, ok := mc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	setup, err := mc.mfaService.BeginSetup(user)
+	if err != nil {
+		respondMFAError(c, err, "生成双因素认证密钥失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// Confirm 使用验证码确认启用双因素认证，返回恢复码
+// 使用受限令牌确认时同时将会话升级为完整权限并返回新的访问令牌
+func (mc *MFAController) Confirm(c *gin.Context) {
+	var req models.MFACodeRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	user, ok := mc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := mc.mfaService.Confirm(user.ID, req.Code)
+	if err != nil {
+		respondMFAError(c, err, "启用双因素认证失败")
+		return
+	}
+
+	response := models.MFAConfirmResponse{RecoveryCodes: codes}
+
+	if c.GetString("tokenScope") == models.TokenScopeMFAEnroll {
+		sessionID := c.GetString("sessionID")
+		if err := mc.sessionService.MarkMFAVerified(sessionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新会话失败"})
+			return
+		}
+
+		token, err := utils.GenerateJWT(user, sessionID, "")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+			return
+		}
+		response.Token = token
+		response.ExpiresIn = int64(utils.AccessTokenTTL().Seconds())
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Disable 关闭双因素认证
+func (mc *MFAController) Disable(c *gin.Context) {
+	var req models.MFACodeRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	user, ok := mc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := mc.mfaService.Disable(user, req.Code); err != nil {
+		respondMFAError(c, err, "关闭双因素认证失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "双因素认证已关闭"})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+func (mc *MFAController) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.MFACodeRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	user, ok := mc.currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := mc.mfaService.RegenerateRecoveryCodes(user.ID, req.Code)
+	if err != nil {
+		respondMFAError(c, err, "生成恢复码失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// GetPolicy 获取双因素认证策略
+func (mc *MFAController) GetPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, mc.mfaService.GetPolicy())
+}
+
+// UpdatePolicy 设置要求双因素认证的角色
+func (mc *MFAController) UpdatePolicy(c *gin.Context) {
+	var policy models.MFAPolicy
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	if err := mc.mfaService.SetPolicy(policy); err != nil {
+		respondMFAError(c, err, "更新双因素认证策略失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, mc.mfaService.GetPolicy())
+}
+
+// 获取当前登录用户
+func (mc *MFAController) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		return nil, false
+	}
+
+	user, err := mc.userService.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		return nil, false
+	}
+	return user, true
+}
+
+// 将双因素认证错误映射为HTTP状态码
+func respondMFAError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrMFAInvalidCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFAAlreadyEnabled), errors.Is(err, services.ErrMFANotEnabled), errors.Is(err, services.ErrMFANotSetup):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFARequiredForRole):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFAPolicyInvalidRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
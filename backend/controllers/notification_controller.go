@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/services"
+)
+
+// NotificationController 处理站内通知相关请求
+type NotificationController struct {
+	notificationService *services.NotificationService
+}
+
+// NewNotificationController 创建新的站内通知控制器
+func NewNotificationController(notificationService *services.NotificationService) *NotificationController {
+	return &NotificationController{
+		notificationService: notificationService,
+	}
+}
+
+// ListNotifications 获取当前用户的站内通知
+func (nc *NotificationController) ListNotifications(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	unreadOnly := c.Query("unread") == "true"
+	c.JSON(http.StatusOK, nc.notificationService.ListForUser(userID.(string), unreadOnly))
+}
+
+// MarkRead 将通知标记为已读
+func (nc *NotificationController) MarkRead(c *gin.Context) {
+	// 获取用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	if err := nc.notificationService.MarkRead(userID.(string), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "通知已标记为已读"})
+}
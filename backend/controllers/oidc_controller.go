@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// OIDCController 处理OpenID Connect单点登录请求
+type OIDCController struct {
+	oidcService    *services.OIDCService
+	userService    *services.UserService
+	tenantService  *services.TenantService
+	authController *AuthController
+}
+
+// NewOIDCController 创建新的单点登录控制器
+// 登录成功后的会话创建和双因素认证流程与密码登录共用AuthController
+func NewOIDCController(oidcService *services.OIDCService, userService *services.UserService, tenantService *services.TenantService, authController *AuthController) *OIDCController {
+	return &OIDCController{
+		oidcService:    oidcService,
+		userService:    userService,
+		tenantService:  tenantService,
+		authController: authController,
+	}
+}
+
+// ListProviders 获取可用的身份提供方
+func (oc *OIDCController) ListProviders(c *gin.Context) {
+	providers := oc.oidcService.ListProviders()
+
+	c.JSON(http.StatusOK, gin.H{
+		"providers": providers,
+		"total":     len(providers),
+	})
+}
+
+// Authorize 生成身份提供方的授权地址，前端跳转到该地址完成登录
+func (oc *OIDCController) Authorize(c *gin.Context) {
+	response, err := oc.oidcService.AuthorizationURL(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		respondOIDCError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Callback 使用授权码完成单点登录
+// 首次登录时按身份提供方的声明自动创建用户
+func (oc *OIDCController) Callback(c *gin.Context) {
+	var req models.OIDCCallbackRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	identity, err := oc.oidcService.Exchange(c.Request.Context(), req.Code, req.State)
+	if err != nil {
+		respondOIDCError(c, err)
+		return
+	}
+	c.Set("auditActor", identity.ProviderID+":"+identity.Subject)
+
+	// 声明的医院必须已入驻，科室必须是该医院租户的科室
+	if err := oc.tenantService.ValidateAffiliation(identity.Hospital, identity.Department); err != nil {
+		switch {
+		case errors.Is(err, services.ErrTenantNotFound):
+			c.JSON(http.StatusForbidden, gin.H{"error": "所属医院尚未入驻"})
+		case errors.Is(err, services.ErrDepartmentNotFound):
+			c.JSON(http.StatusForbidden, gin.H{"error": "所属科室不属于该医院"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "单点登录失败"})
+		}
+		return
+	}
+
+	user, _, err := oc.userService.UpsertExternalUser(*identity)
+	if err != nil {
+		if errors.Is(err, services.ErrOIDCProvisioningDisabled) || errors.Is(err, services.ErrOIDCHospitalMismatch) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.Set("userID", user.ID)
+
+	oc.authController.startSession(c, user, models.AuthMethodOIDC+":"+identity.ProviderID, externalMFA(identity.AMR))
+}
+
+// 检查身份提供方返回的认证方式（RFC 8176）是否包含多因素认证
+func externalMFA(amr []string) bool {
+	for _, method := range amr {
+		switch method {
+		case "mfa", "otp", "hwk", "swk", "sms":
+			return true
+		}
+	}
+	return false
+}
+
+// 将单点登录错误映射为HTTP状态码
+func respondOIDCError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrOIDCProviderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOIDCStateInvalid), errors.Is(err, services.ErrOIDCTokenInvalid):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOIDCRoleNotAllowed), errors.Is(err, services.ErrOIDCHospitalNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOIDCUnavailable):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "单点登录失败"})
+	}
+}
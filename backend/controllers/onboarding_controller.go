@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// OnboardingController 处理医院入驻和注册审核请求
+type OnboardingController struct {
+	onboardingService *services.OnboardingService
+}
+
+// NewOnboardingController 创建新的入驻审核控制器
+func NewOnboardingController(onboardingService *services.OnboardingService) *OnboardingController {
+	return &OnboardingController{
+		onboardingService: onboardingService,
+	}
+}
+
+// RegisterInstitution 登记入驻医院
+func (oc *OnboardingController) RegisterInstitution(c *gin.Context) {
+	var req models.InstitutionRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	if oc.onboardingService.IsInstitutionRegistered(req.Name) {
+		c.JSON(http.StatusConflict, gin.H{"error": "医院已入驻"})
+		return
+	}
+
+	hospital, err := oc.onboardingService.RegisterInstitution(req.Name)
+	if err != nil {
+		respondOnboardingError(c, err)
+		return
+	}
+	c.Set("auditRecordID", hospital.Slug)
+
+	c.JSON(http.StatusCreated, hospital)
+}
+
+// ListInstitutions 获取已入驻的医院，注册时从中选择所属医院
+func (oc *OnboardingController) ListInstitutions(c *gin.Context) {
+	hospitals := oc.onboardingService.ListInstitutions()
+
+	c.JSON(http.StatusOK, gin.H{
+		"institutions": hospitals,
+		"total":        len(hospitals),
+	})
+}
+
+// CreateHospitalAdmin 为入驻医院创建医院管理员
+func (oc *OnboardingController) CreateHospitalAdmin(c *gin.Context) {
+	var req models.HospitalAdminRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	user, err := oc.onboardingService.CreateHospitalAdmin(c.Param("slug"), req)
+	if err != nil {
+		respondOnboardingError(c, err)
+		return
+	}
+	c.Set("auditRecordID", user.ID)
+
+	c.JSON(http.StatusCreated, newUserResponse(user))
+}
+
+// ListRegistrations 获取注册用户，默认只返回待审核的用户
+func (oc *OnboardingController) ListRegistrations(c *gin.Context) {
+	status := c.DefaultQuery("status", models.UserStatusPending)
+	users := oc.onboardingService.ListRegistrations(c.GetString("userID"), status)
+
+	registrations := make([]models.UserResponse, 0, len(users))
+	for _, user := range users {
+		registrations = append(registrations, newUserResponse(user))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"registrations": registrations,
+		"total":         len(registrations),
+	})
+}
+
+// ApproveRegistration 审核通过注册用户
+func (oc *OnboardingController) ApproveRegistration(c *gin.Context) {
+	var req models.RegistrationApproval
+
+	// 请求体可选，为空时保留用户注册时填写的角色和科室
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+			return
+		}
+	}
+
+	userID := c.Param("id")
+	c.Set("auditRecordID", userID)
+	user, credential, err := oc.onboardingService.Approve(c.GetString("userID"), userID, req)
+	if err != nil {
+		respondOnboardingError(c, err)
+		return
+	}
+
+	response := gin.H{
+		"message": "审核通过",
+		"user":    newUserResponse(user),
+	}
+	if credential != nil {
+		response["credential"] = credential
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RejectRegistration 拒绝注册用户
+func (oc *OnboardingController) RejectRegistration(c *gin.Context) {
+	var req models.RegistrationRejection
+
+	// 拒绝原因可选，请求体可以为空
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+			return
+		}
+	}
+
+	userID := c.Param("id")
+	c.Set("auditRecordID", userID)
+	user, err := oc.onboardingService.Reject(c.GetString("userID"), userID, req.Reason)
+	if err != nil {
+		respondOnboardingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已拒绝",
+		"user":    newUserResponse(user),
+	})
+}
+
+// 构建返回给客户端的用户信息
+func newUserResponse(user *models.User) models.UserResponse {
+	return models.UserResponse{
+		ID:         user.ID,
+		Username:   user.Username,
+		Name:       user.Name,
+		Role:       user.Role,
+		Hospital:   user.Hospital,
+		Department: user.Department,
+		TenantID:   user.TenantID,
+		PatientID:  user.PatientID,
+		Email:      user.Email,
+		Status:     user.Status,
+		CreatedAt:  user.CreatedAt,
+
+		EthereumAddress: user.EthereumAddress,
+		FabricID:        user.FabricID,
+		DID:             user.DID,
+
+		PendingPatientID: user.PendingPatientID,
+	}
+}
+
+// 将入驻审核错误映射为HTTP状态码
+func respondOnboardingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRegistrationNotFound), errors.Is(err, services.ErrHospitalNotRegistered):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRegistrationReviewed), errors.Is(err, services.ErrUsernameTaken),
+		errors.Is(err, services.ErrPatientIDBound):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRegistrationForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrApprovalRoleInvalid), errors.Is(err, services.ErrDepartmentNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("入驻审核操作失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "入驻审核操作失败"})
+	}
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// PolicyController 处理访问策略管理相关请求
+type PolicyController struct {
+	policyService  *services.PolicyService
+	userService    *services.UserService
+	dataService    *services.DataService
+	gatewayService *services.GatewayService
+}
+
+// NewPolicyController 创建新的访问策略控制器
+func NewPolicyController(policyService *services.PolicyService, userService *services.UserService, dataService *services.DataService, gatewayService *services.GatewayService) *PolicyController {
+	return &PolicyController{
+		policyService:  policyService,
+		userService:    userService,
+		dataService:    dataService,
+		gatewayService: gatewayService,
+	}
+}
+
+// ListPolicies 获取当前生效的访问策略
+func (pc *PolicyController) ListPolicies(c *gin.Context) {
+	policies := pc.policyService.ListPolicies()
+
+	c.JSON(http.StatusOK, gin.H{
+		"policies": policies,
+		"total":    len(policies),
+	})
+}
+
+// ReloadPolicies 重新加载策略文件
+func (pc *PolicyController) ReloadPolicies(c *gin.Context) {
+	if err := pc.policyService.Reload(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "访问策略已重新加载",
+		"total":   len(pc.policyService.ListPolicies()),
+	})
+}
+
+// ExplainPolicy 试运行策略评估，返回决策和每条策略的评估过程
+func (pc *PolicyController) ExplainPolicy(c *gin.Context) {
+	var req models.PolicyExplainRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	if req.Action != models.PolicyActionRead && req.Action != models.PolicyActionUpload && req.Action != models.PolicyActionTransfer {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的操作"})
+		return
+	}
+
+	policyReq := models.PolicyRequest{
+		Action:   req.Action,
+		Subject:  map[string]string{},
+		Resource: map[string]string{},
+	}
+
+	// 以指定用户构建主体属性
+	if req.SubjectID != "" {
+		user, err := pc.userService.GetUserByID(req.SubjectID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+		policyReq.Subject = services.SubjectAttributes(user)
+	}
+
+	// 以指定数据构建资源属性
+	if req.RecordID != "" {
+		data, err := pc.dataService.GetDataByID(req.RecordID)
+		if err != nil {
+			data, err = pc.gatewayService.GetDataByID(req.RecordID)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "数据不存在"})
+				return
+			}
+		}
+		policyReq.Resource = services.ResourceAttributes(*data)
+	}
+
+	at := time.Now()
+	if req.Time != nil {
+		at = *req.Time
+	}
+	policyReq.Environment = services.EnvironmentAttributes(at, req.Purpose)
+
+	// 请求中显式给出的属性覆盖自动生成的属性
+	for key, value := range req.Subject {
+		policyReq.Subject[key] = value
+	}
+	for key, value := range req.Resource {
+		policyReq.Resource[key] = value
+	}
+	for key, value := range req.Environment {
+		policyReq.Environment[key] = value
+	}
+
+	decision := pc.policyService.Evaluate(policyReq)
+
+	c.JSON(http.StatusOK, gin.H{
+		"decision":   decision,
+		"attributes": policyReq,
+	})
+}
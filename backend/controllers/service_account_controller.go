@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// ServiceAccountController 处理服务账户和API密钥管理请求
+type ServiceAccountController struct {
+	apiKeyService *services.APIKeyService
+}
+
+// NewServiceAccountController 创建新的服务账户控制器
+func NewServiceAccountController(apiKeyService *services.APIKeyService) *ServiceAccountController {
+	return &ServiceAccountController{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// ListServiceAccounts 获取全部服务账户
+func (sc *ServiceAccountController) ListServiceAccounts(c *gin.Context) {
+	accounts := sc.apiKeyService.ListServiceAccounts()
+
+	c.JSON(http.StatusOK, gin.H{
+		"serviceAccounts": accounts,
+		"total":           len(accounts),
+	})
+}
+
+// CreateServiceAccount 创建服务账户
+func (sc *ServiceAccountController) CreateServiceAccount(c *gin.Context) {
+	var req models.ServiceAccountRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	account, err := sc.apiKeyService.CreateServiceAccount(req, c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建服务账户失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, account)
+}
+
+// DisableServiceAccount 停用服务账户并吊销其全部API密钥
+func (sc *ServiceAccountController) DisableServiceAccount(c *gin.Context) {
+	if err := sc.apiKeyService.DisableServiceAccount(c.Param("id")); err != nil {
+		respondServiceAccountError(c, err, "停用服务账户失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "服务账户已停用"})
+}
+
+// ListKeys 获取服务账户的API密钥
+func (sc *ServiceAccountController) ListKeys(c *gin.Context) {
+	keys, err := sc.apiKeyService.ListKeys(c.Param("id"))
+	if err != nil {
+		respondServiceAccountError(c, err, "获取API密钥失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"keys":  keys,
+		"total": len(keys),
+	})
+}
+
+// CreateKey 为服务账户创建API密钥，完整密钥只在响应中返回一次
+func (sc *ServiceAccountController) CreateKey(c *gin.Context) {
+	var req models.APIKeyRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	key, info, err := sc.apiKeyService.CreateKey(c.Param("id"), req, c.GetString("userID"))
+	if err != nil {
+		respondServiceAccountError(c, err, "创建API密钥失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIKeyCreateResponse{
+		Key:    key,
+		APIKey: *info,
+	})
+}
+
+// RevokeKey 吊销API密钥
+func (sc *ServiceAccountController) RevokeKey(c *gin.Context) {
+	if err := sc.apiKeyService.RevokeKey(c.Param("id"), c.Param("keyId")); err != nil {
+		respondServiceAccountError(c, err, "吊销API密钥失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API密钥已吊销"})
+}
+
+// 将服务账户错误映射为HTTP状态码
+func respondServiceAccountError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrServiceAccountNotFound), errors.Is(err, services.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrServiceAccountDisabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAPIKeyInvalidScope):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// TenantController 处理租户的科室、用户和共享授权管理请求
+// 医院管理员管理本租户，平台管理员通过 tenant 查询参数指定租户
+type TenantController struct {
+	tenantService     *services.TenantService
+	credentialService *services.CredentialService
+}
+
+// NewTenantController 创建新的租户控制器
+func NewTenantController(tenantService *services.TenantService, credentialService *services.CredentialService) *TenantController {
+	return &TenantController{
+		tenantService:     tenantService,
+		credentialService: credentialService,
+	}
+}
+
+// ListTenants 获取所有租户
+func (tc *TenantController) ListTenants(c *gin.Context) {
+	tenants := tc.tenantService.ListTenants()
+
+	c.JSON(http.StatusOK, gin.H{
+		"tenants": tenants,
+		"total":   len(tenants),
+	})
+}
+
+// GetTenant 获取管理的租户及其科室
+func (tc *TenantController) GetTenant(c *gin.Context) {
+	tenantID, ok := tc.managedTenant(c)
+	if !ok {
+		return
+	}
+
+	tenant, err := tc.tenantService.GetTenant(tenantID)
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+// AddDepartment 新增科室
+func (tc *TenantController) AddDepartment(c *gin.Context) {
+	var req models.DepartmentRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	tenantID, ok := tc.managedTenant(c)
+	if !ok {
+		return
+	}
+	c.Set("auditRecordID", tenantID)
+
+	tenant, err := tc.tenantService.AddDepartment(tenantID, req.Name)
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, tenant)
+}
+
+// RemoveDepartment 删除科室
+func (tc *TenantController) RemoveDepartment(c *gin.Context) {
+	tenantID, ok := tc.managedTenant(c)
+	if !ok {
+		return
+	}
+	c.Set("auditRecordID", tenantID)
+
+	tenant, err := tc.tenantService.RemoveDepartment(tenantID, c.Param("name"))
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+// ListUsers 获取租户的用户
+func (tc *TenantController) ListUsers(c *gin.Context) {
+	tenantID, ok := tc.managedTenant(c)
+	if !ok {
+		return
+	}
+
+	members := tc.tenantService.ListMembers(tenantID)
+	users := make([]models.UserResponse, 0, len(members))
+	for _, user := range members {
+		users = append(users, newUserResponse(user))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users": users,
+		"total": len(users),
+	})
+}
+
+// AssignDepartment 调整租户用户的科室
+// 执业凭证包含科室，医生和研究人员调整科室后以新科室重新签发凭证，旧凭证随之撤销
+func (tc *TenantController) AssignDepartment(c *gin.Context) {
+	var req models.TenantUserUpdate
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	tenantID, ok := tc.managedTenant(c)
+	if !ok {
+		return
+	}
+	c.Set("auditRecordID", c.Param("id"))
+
+	user, err := tc.tenantService.AssignDepartment(tenantID, c.Param("id"), req.Department)
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+
+	response := gin.H{"user": newUserResponse(user)}
+	if models.CredentialRequiredRoles[user.Role] && user.Status == models.UserStatusActive && user.DID != "" {
+		credential, err := tc.credentialService.Issue(models.CredentialIssueRequest{UserID: user.ID}, c.GetString("userID"))
+		if err != nil {
+			log.Printf("调整科室后签发执业凭证失败: 用户=%s, 错误=%v", user.ID, err)
+			response["message"] = "科室已调整，执业凭证签发失败，请重新签发"
+		} else {
+			response["credential"] = credential
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ListGrants 获取租户授予的和收到的共享授权
+func (tc *TenantController) ListGrants(c *gin.Context) {
+	tenantID, ok := tc.managedTenant(c)
+	if !ok {
+		return
+	}
+
+	granted, received := tc.tenantService.ListGrants(tenantID)
+	c.JSON(http.StatusOK, gin.H{
+		"granted":  granted,
+		"received": received,
+	})
+}
+
+// CreateGrant 授予其他租户读取本租户数据的共享授权
+func (tc *TenantController) CreateGrant(c *gin.Context) {
+	var req models.TenantGrantRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	tenantID, ok := tc.managedTenant(c)
+	if !ok {
+		return
+	}
+
+	grant, err := tc.tenantService.CreateGrant(tenantID, req, c.GetString("userID"))
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+	c.Set("auditRecordID", grant.ID)
+
+	c.JSON(http.StatusCreated, grant)
+}
+
+// RevokeGrant 撤销本租户授予的共享授权
+func (tc *TenantController) RevokeGrant(c *gin.Context) {
+	var req models.TenantGrantRevokeRequest
+
+	// 撤销说明可选，请求体可以为空
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+			return
+		}
+	}
+
+	tenantID, ok := tc.managedTenant(c)
+	if !ok {
+		return
+	}
+	c.Set("auditRecordID", c.Param("id"))
+
+	grant, err := tc.tenantService.RevokeGrant(tenantID, c.Param("id"), c.GetString("userID"), req.Note)
+	if err != nil {
+		respondTenantError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, grant)
+}
+
+// 确定当前用户管理的租户，失败时写入错误响应
+func (tc *TenantController) managedTenant(c *gin.Context) (string, bool) {
+	tenantID, err := tc.tenantService.ManagedTenant(c.GetString("userID"), c.Query("tenant"))
+	if err != nil {
+		respondTenantError(c, err)
+		return "", false
+	}
+	return tenantID, true
+}
+
+// 将租户管理错误映射为HTTP状态码
+func respondTenantError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTenantNotFound), errors.Is(err, services.ErrDepartmentNotFound), errors.Is(err, services.ErrTenantGrantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTenantRequired), errors.Is(err, services.ErrTenantGrantInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDepartmentExists), errors.Is(err, services.ErrDepartmentInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTenantForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		log.Printf("租户管理操作失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "租户管理操作失败"})
+	}
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// WalletController 处理用户托管密钥库相关请求
+type WalletController struct {
+	identityService  *services.IdentityService
+	identityRegistry *services.IdentityRegistry
+}
+
+// NewWalletController 创建新的密钥库控制器
+func NewWalletController(identityService *services.IdentityService, identityRegistry *services.IdentityRegistry) *WalletController {
+	return &WalletController{
+		identityService:  identityService,
+		identityRegistry: identityRegistry,
+	}
+}
+
+// GetWallet 获取当前用户的区块链身份和密钥库解锁状态
+func (wc *WalletController) GetWallet(c *gin.Context) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	identity, err := wc.identityService.GetIdentity(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := models.WalletStatus{Identity: identity}
+	if link, err := wc.identityRegistry.Resolve(identity.EthereumAddress); err == nil {
+		status.Link = link
+	}
+	if _, expiresAt, open := wc.identityService.OpenedWallet(userID); open {
+		status.Unlocked = true
+		status.ExpiresAt = &expiresAt
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Unlock 使用登录密码解锁密钥库，解锁后上传数据时使用用户私钥签名交易
+// 密码登录时会自动解锁，密钥库过期或服务重启后需要调用该接口
+func (wc *WalletController) Unlock(c *gin.Context) {
+	var req models.WalletUnlockRequest
+
+	// 绑定请求数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	userID, _, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	expiresAt, err := wc.identityService.OpenWallet(userID, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWalletLocked):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrIdentityNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "解锁密钥库失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "密钥库已解锁",
+		"expiresAt": expiresAt,
+	})
+}
+
+// Lock 锁定密钥库，清除内存中的私钥
+func (wc *WalletController) Lock(c *gin.Context) {
+	userID, _, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	wc.identityService.CloseWallet(userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "密钥库已锁定"})
+}
+
+// ResolveIdentityLink 根据以太坊地址或Fabric身份标识查询跨链身份关联
+// 返回关联声明和两个签名，调用方可以自行验证；不返回平台用户ID
+// 平台管理员可以查询任意身份，其他用户只能查询自己的身份，查询他人的身份与身份不存在的结果相同
+func (wc *WalletController) ResolveIdentityLink(c *gin.Context) {
+	chainIdentity := c.Query("id")
+	if chainIdentity == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少链上身份参数id"})
+		return
+	}
+
+	link, err := wc.identityRegistry.Resolve(chainIdentity)
+	if err == nil && link.UserID != c.GetString("userID") && !models.HasPermission(c.GetString("userRole"), models.PermUserAdmin) {
+		err = services.ErrIdentityLinkNotFound
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	link.UserID = ""
+
+	c.JSON(http.StatusOK, link)
+}
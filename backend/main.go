@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"strings"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -10,7 +11,9 @@ import (
 
 	"medcross/controllers"
 	"medcross/middleware"
+	"medcross/models"
 	"medcross/services"
+	"medcross/utils"
 )
 
 func main() {
@@ -26,20 +29,101 @@ func main() {
 	// 创建Gin引擎
 	r := gin.Default()
 
+	// 配置可信代理，限流和登录保护依赖客户端IP
+	configureTrustedProxies(r)
+
 	// 配置CORS
 	configureCors(r)
 
+	// 加载JWT签名密钥，release模式下必须配置密钥
+	keyStore, err := utils.InitKeyStore(gin.Mode() != gin.ReleaseMode)
+	if err != nil {
+		log.Fatalf("加载JWT签名密钥失败: %v", err)
+	}
+
 	// 初始化服务
 	userService := services.NewUserService()
-	dataService := services.NewDataService()
+	sessionService := services.NewSessionService()
+	// TOTP密钥加密密钥，release模式下必须配置
+	mfaService, err := services.NewMFAService(gin.Mode() != gin.ReleaseMode)
+	if err != nil {
+		log.Fatalf("初始化双因素认证失败: %v", err)
+	}
+	loginThrottle := services.NewLoginThrottleService()
+	apiKeyService := services.NewAPIKeyService(userService)
+	oidcService, err := services.NewOIDCService()
+	if err != nil {
+		log.Fatalf("加载身份提供方配置失败: %v", err)
+	}
+	fabricCA, err := services.NewFabricCAFromEnv()
+	if err != nil {
+		log.Fatalf("初始化Fabric CA失败: %v", err)
+	}
 	gatewayService := services.NewGatewayService()
+	identityRegistry := services.NewIdentityRegistry(gatewayService)
+	gatewayService.SetOwnerResolver(identityRegistry)
+	go identityRegistry.AnchorPending()
+	identityService := services.NewIdentityService(fabricCA, identityRegistry)
+	didService := services.NewDIDService(identityService, userService)
+	credentialService := services.NewCredentialService(didService, userService)
+	auditService := services.NewAuditService()
+	dataService := services.NewDataService()
+	signingService := services.NewSigningService(identityService, gatewayService)
+	auditAnchorService := services.NewAuditAnchorService(auditService, gatewayService)
+	auditAnchorService.Start()
+	tenantService := services.NewTenantService(userService)
+	privacyService := services.NewPrivacyService(tenantService)
+	cohortService := services.NewCohortService(dataService, gatewayService, tenantService)
+	consentService := services.NewConsentService(gatewayService)
+	notificationService := services.NewNotificationService()
+	accessRequestService := services.NewAccessRequestService(dataService, gatewayService, userService, notificationService)
+	policyService, err := services.NewPolicyService()
+	if err != nil {
+		log.Fatalf("加载访问策略失败: %v", err)
+	}
+	accessService := services.NewAccessService(userService, consentService, accessRequestService, policyService, tenantService)
+	onboardingService := services.NewOnboardingService(userService, didService, sessionService, credentialService, notificationService, tenantService)
+	resetNotifier, err := services.NewNotifierFromEnv(userService)
+	if err != nil {
+		log.Fatalf("初始化通知发送器失败: %v", err)
+	}
+	accountService := services.NewAccountService(userService, sessionService, identityService, tenantService, onboardingService, notificationService, resetNotifier)
+
+	// 各路由组共用的认证、执业凭证和限流中间件
+	mw := routeMiddlewares{
+		authRequired:       middleware.AuthMiddleware(sessionService, apiKeyService),
+		credentialRequired: middleware.CredentialMiddleware(credentialService),
+		loginRateLimit:     rateLimitFromEnv("RATE_LIMIT_LOGIN", "20/m"),
+		uploadRateLimit:    rateLimitFromEnv("RATE_LIMIT_UPLOAD", "30/m"),
+		queryRateLimit:     rateLimitFromEnv("RATE_LIMIT_QUERY", "120/m"),
+	}
 
 	// 初始化控制器
-	authController := controllers.NewAuthController(userService)
-	dataController := controllers.NewDataController(dataService, gatewayService)
+	authController := controllers.NewAuthController(userService, sessionService, mfaService, loginThrottle, identityService, didService, onboardingService)
+	handlers := routeControllers{
+		auth:           authController,
+		data:           controllers.NewDataController(dataService, gatewayService, accessService, signingService, didService, tenantService, userService),
+		aggregate:      controllers.NewAggregateController(dataService, privacyService, tenantService),
+		cohort:         controllers.NewCohortController(cohortService, accessService, tenantService),
+		consent:        controllers.NewConsentController(consentService, userService),
+		accessRequest:  controllers.NewAccessRequestController(accessRequestService),
+		notification:   controllers.NewNotificationController(notificationService),
+		policy:         controllers.NewPolicyController(policyService, userService, dataService, gatewayService),
+		key:            controllers.NewKeyController(keyStore),
+		mfa:            controllers.NewMFAController(mfaService, userService, sessionService),
+		serviceAccount: controllers.NewServiceAccountController(apiKeyService),
+		oidc:           controllers.NewOIDCController(oidcService, userService, tenantService, authController),
+		wallet:         controllers.NewWalletController(identityService, identityRegistry),
+		did:            controllers.NewDIDController(didService),
+		credential:     controllers.NewCredentialController(credentialService),
+		onboarding:     controllers.NewOnboardingController(onboardingService),
+		tenant:         controllers.NewTenantController(tenantService, credentialService),
+		account:        controllers.NewAccountController(accountService),
+		audit:          controllers.NewAuditController(auditService, auditAnchorService, dataService, userService),
+	}
 
 	// 注册路由
-	setupRoutes(r, authController, dataController)
+	setupRoutes(r, auditService, mw, handlers)
 
 	// 获取端口
 	port := getEnv("PORT", "8000")
@@ -51,20 +135,85 @@ func main() {
 	}
 }
 
+// 根据环境变量创建限流中间件，配置格式见 middleware.NewRateLimiter
+func rateLimitFromEnv(key, defaultValue string) gin.HandlerFunc {
+	limiter, err := middleware.NewRateLimiter(getEnv(key, defaultValue))
+	if err != nil {
+		log.Fatalf("%s配置无效: %v", key, err)
+	}
+	return middleware.RateLimitMiddleware(limiter)
+}
+
 // 配置CORS中间件
 func configureCors(r *gin.Engine) {
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowOrigins = []string{getEnv("CORS_ALLOW_ORIGINS", "*")}
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
-	corsConfig.ExposeHeaders = []string{"Content-Length"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key"}
+	corsConfig.ExposeHeaders = []string{"Content-Length", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"}
 	corsConfig.AllowCredentials = true
 
 	r.Use(cors.New(corsConfig))
 }
 
+// 配置可信代理，默认不信任任何代理，直接使用连接的来源地址
+// 部署在反向代理之后时通过TRUSTED_PROXIES（逗号分隔的IP或CIDR）指定代理地址
+func configureTrustedProxies(r *gin.Engine) {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		log.Fatalf("配置可信代理失败: %v", err)
+	}
+}
+
+// routeControllers 注册路由所需的全部控制器
+type routeControllers struct {
+	auth           *controllers.AuthController
+	data           *controllers.DataController
+	aggregate      *controllers.AggregateController
+	cohort         *controllers.CohortController
+	consent        *controllers.ConsentController
+	accessRequest  *controllers.AccessRequestController
+	notification   *controllers.NotificationController
+	policy         *controllers.PolicyController
+	audit          *controllers.AuditController
+	key            *controllers.KeyController
+	mfa            *controllers.MFAController
+	serviceAccount *controllers.ServiceAccountController
+	oidc           *controllers.OIDCController
+	wallet         *controllers.WalletController
+	did            *controllers.DIDController
+	credential     *controllers.CredentialController
+	onboarding     *controllers.OnboardingController
+	tenant         *controllers.TenantController
+	account        *controllers.AccountController
+}
+
+// routeMiddlewares 各路由组共用的中间件
+type routeMiddlewares struct {
+	authRequired       gin.HandlerFunc // 认证
+	credentialRequired gin.HandlerFunc // 执业凭证，放在临床操作的权限检查之后
+	loginRateLimit     gin.HandlerFunc // 登录、注册等接口的限流
+	uploadRateLimit    gin.HandlerFunc // 上传接口的限流
+	queryRateLimit     gin.HandlerFunc // 查询接口的限流
+}
+
 // 设置路由
-func setupRoutes(r *gin.Engine, authController *controllers.AuthController, dataController *controllers.DataController) {
+func setupRoutes(r *gin.Engine, auditService *services.AuditService, mw routeMiddlewares, rc routeControllers) {
+	// 公开的令牌验证公钥
+	r.GET("/.well-known/jwks.json", rc.key.GetJWKS)
+
+	// 公开的did:web文档
+	setupDIDDocumentRoutes(r, rc.did)
+
+	// 公开的凭证状态列表
+	r.GET("/credentials/status/:slug", rc.credential.GetStatusList)
+
 	// API版本组
 	api := r.Group("/api")
 	{
@@ -77,46 +226,422 @@ func setupRoutes(r *gin.Engine, authController *controllers.AuthController, data
 		})
 
 		// 注册认证路由
-		setupAuthRoutes(api, authController)
+		setupAuthRoutes(api, auditService, mw, rc.auth)
+
+		// 注册单点登录路由
+		setupOIDCRoutes(api, auditService, mw, rc.oidc)
 
 		// 注册数据路由
-		setupDataRoutes(api, dataController)
+		setupDataRoutes(api, auditService, mw, rc.data)
+
+		// 注册聚合统计路由
+		setupAggregateRoutes(api, auditService, mw, rc.aggregate)
+
+		// 注册队列查询路由
+		setupCohortRoutes(api, auditService, mw, rc.cohort)
+
+		// 注册知情同意路由
+		setupConsentRoutes(api, auditService, mw, rc.consent)
+
+		// 注册访问申请路由
+		setupAccessRequestRoutes(api, auditService, mw, rc.accessRequest)
+
+		// 注册站内通知路由
+		setupNotificationRoutes(api, mw, rc.notification)
+
+		// 注册访问策略管理路由
+		setupPolicyRoutes(api, mw, rc.policy)
+
+		// 注册审计日志路由
+		setupAuditRoutes(api, mw, rc.audit)
+
+		// 注册签名密钥管理路由
+		setupKeyRoutes(api, mw, rc.key)
+
+		// 注册双因素认证路由
+		setupMFARoutes(api, auditService, mw, rc.mfa)
+
+		// 注册服务账户管理路由
+		setupServiceAccountRoutes(api, auditService, mw, rc.serviceAccount)
+
+		// 注册密钥库路由
+		setupWalletRoutes(api, auditService, mw, rc.wallet)
+
+		// 注册DID解析路由
+		setupDIDRoutes(api, mw, rc.did)
+
+		// 注册执业凭证路由
+		setupCredentialRoutes(api, auditService, mw, rc.credential)
+
+		// 医院入驻和注册审核路由
+		setupOnboardingRoutes(api, auditService, mw, rc.onboarding)
+
+		// 租户管理路由
+		setupTenantRoutes(api, auditService, mw, rc.tenant)
+
+		// 个人资料、密码和用户管理路由
+		setupAccountRoutes(api, auditService, mw, rc.account)
 	}
 }
 
 // 设置认证相关路由
-func setupAuthRoutes(rg *gin.RouterGroup, authController *controllers.AuthController) {
+func setupAuthRoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, authController *controllers.AuthController) {
 	auth := rg.Group("/")
 	{
 		// 登录
-		auth.POST("/login", authController.Login)
+		auth.POST("/login", middleware.AuditMiddleware(auditService, "auth.login"), mw.loginRateLimit, authController.Login)
+
+		// 登录第二步：校验双因素认证验证码
+		auth.POST("/login/mfa", middleware.AuditMiddleware(auditService, "auth.login_mfa"), mw.loginRateLimit, authController.LoginMFA)
 
 		// 注册
-		auth.POST("/register", authController.Register)
+		auth.POST("/register", middleware.AuditMiddleware(auditService, "auth.register"), mw.loginRateLimit, authController.Register)
+
+		// 刷新令牌（刷新令牌本身即凭据，无需访问令牌）
+		auth.POST("/token/refresh", middleware.AuditMiddleware(auditService, "auth.refresh"), mw.loginRateLimit, authController.RefreshToken)
 
 		// 获取用户信息（需要认证）
-		auth.GET("/user", middleware.AuthMiddleware(), authController.GetCurrentUser)
+		auth.GET("/user", middleware.AuditMiddleware(auditService, "auth.profile"), mw.authRequired, middleware.PermissionMiddleware(models.PermProfileRead), authController.GetCurrentUser)
+
+		// 注销当前会话和全部会话
+		auth.POST("/logout", middleware.AuditMiddleware(auditService, "auth.logout"), mw.authRequired, authController.Logout)
+		auth.POST("/logout/all", middleware.AuditMiddleware(auditService, "auth.logout_all"), mw.authRequired, authController.LogoutAll)
+
+		// 会话管理
+		auth.GET("/sessions", middleware.AuditMiddleware(auditService, "auth.sessions"), mw.authRequired, authController.ListSessions)
+		auth.DELETE("/sessions/:id", middleware.AuditMiddleware(auditService, "auth.session_revoke"), mw.authRequired, authController.RevokeSession)
+	}
+}
+
+// 设置单点登录路由
+func setupOIDCRoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, oidcController *controllers.OIDCController) {
+	oidc := rg.Group("/oidc")
+	{
+		// 获取可用的身份提供方
+		oidc.GET("/providers", oidcController.ListProviders)
+
+		// 生成身份提供方的授权地址
+		oidc.GET("/:provider/authorize", mw.loginRateLimit, oidcController.Authorize)
+
+		// 使用授权码完成登录
+		oidc.POST("/callback", middleware.AuditMiddleware(auditService, "auth.oidc_login"), mw.loginRateLimit, oidcController.Callback)
 	}
 }
 
 // 设置数据相关路由
-func setupDataRoutes(rg *gin.RouterGroup, dataController *controllers.DataController) {
+func setupDataRoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, dataController *controllers.DataController) {
 	data := rg.Group("/")
 	{
-		// 数据查询
-		data.GET("/query", dataController.QueryData)
+		// 获取数据类型列表（公开）
+		data.GET("/data-types", middleware.AuditMiddleware(auditService, "data.types"), dataController.GetDataTypes)
+	}
+
+	// 审计中间件在认证之前执行，认证失败的请求也会被记录
+	authed := rg.Group("/")
+	{
+		// 数据查询（只返回已获授权的数据）
+		authed.GET("/query", middleware.AuditMiddleware(auditService, "data.query"), mw.authRequired, mw.queryRateLimit, middleware.PermissionMiddleware(models.PermDataReadOwn, models.PermDataReadGranted), mw.credentialRequired, dataController.QueryData)
 
-		// 数据上传（需要认证）
-		data.POST("/upload", middleware.AuthMiddleware(), dataController.UploadData)
+		// 数据上传
+		authed.POST("/upload", middleware.AuditMiddleware(auditService, "data.upload"), mw.authRequired, mw.uploadRateLimit, middleware.PermissionMiddleware(models.PermDataUpload), mw.credentialRequired, dataController.UploadData)
 
-		// 获取数据类型列表
-		data.GET("/data-types", dataController.GetDataTypes)
+		// 提交客户端签名的上传交易
+		authed.POST("/upload/:id/signature", middleware.AuditMiddleware(auditService, "data.upload_signed"), mw.authRequired, mw.uploadRateLimit, middleware.PermissionMiddleware(models.PermDataUpload), mw.credentialRequired, dataController.SubmitUploadSignature)
 
-		// 获取数据详情
-		data.GET("/data/:id", dataController.GetDataDetail)
+		// 获取数据详情（需要患者授权）
+		authed.GET("/data/:id", middleware.AuditMiddleware(auditService, "data.view"), mw.authRequired, mw.queryRateLimit, middleware.PermissionMiddleware(models.PermDataReadOwn, models.PermDataReadGranted), mw.credentialRequired, dataController.GetDataDetail)
+
+		// 下载数据文件（需要访问授权）
+		authed.GET("/data/:id/file", middleware.AuditMiddleware(auditService, "data.download"), mw.authRequired, mw.queryRateLimit, middleware.PermissionMiddleware(models.PermDataReadOwn, models.PermDataReadGranted), mw.credentialRequired, dataController.DownloadFile)
+
+		// 跨链转移
+		authed.POST("/transfer", middleware.AuditMiddleware(auditService, "data.transfer"), mw.authRequired, middleware.PermissionMiddleware(models.PermTransferCreate), mw.credentialRequired, dataController.TransferData)
 
 		// 获取统计数据
-		data.GET("/statistics", dataController.GetStatistics)
+		authed.GET("/statistics", middleware.AuditMiddleware(auditService, "data.statistics"), mw.authRequired, mw.queryRateLimit, middleware.PermissionMiddleware(models.PermStatisticsRead), mw.credentialRequired, dataController.GetStatistics)
+	}
+}
+
+// 设置差分隐私聚合统计路由
+func setupAggregateRoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, aggregateController *controllers.AggregateController) {
+	stats := rg.Group("/statistics")
+	{
+		// 差分隐私聚合查询
+		stats.POST("/aggregate", middleware.AuditMiddleware(auditService, "statistics.aggregate"), mw.authRequired, mw.queryRateLimit, middleware.PermissionMiddleware(models.PermStatisticsAggregate), mw.credentialRequired, aggregateController.QueryAggregate)
+
+		// 获取当前用户的隐私预算
+		stats.GET("/budget", middleware.AuditMiddleware(auditService, "statistics.budget"), mw.authRequired, mw.queryRateLimit, middleware.PermissionMiddleware(models.PermStatisticsAggregate), mw.credentialRequired, aggregateController.GetPrivacyBudget)
+	}
+}
+
+// 设置队列可行性查询路由
+func setupCohortRoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, cohortController *controllers.CohortController) {
+	cohort := rg.Group("/cohort")
+	{
+		// 跨链患者数量统计
+		cohort.POST("/count", middleware.AuditMiddleware(auditService, "cohort.count"), mw.authRequired, mw.queryRateLimit, middleware.PermissionMiddleware(models.PermCohortQuery), mw.credentialRequired, cohortController.CountCohort)
+	}
+}
+
+// 设置知情同意路由
+func setupConsentRoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, consentController *controllers.ConsentController) {
+	consents := rg.Group("/consents")
+	{
+		// 获取与当前用户相关的知情同意
+		consents.GET("", middleware.AuditMiddleware(auditService, "consent.list"), mw.authRequired, middleware.PermissionMiddleware(models.PermConsentRead), consentController.ListConsents)
+
+		// 授予知情同意（患者本人或代理人）
+		consents.POST("", middleware.AuditMiddleware(auditService, "consent.grant"), mw.authRequired, middleware.PermissionMiddleware(models.PermConsentManage), consentController.GrantConsent)
+
+		// 撤销知情同意
+		consents.DELETE("/:id", middleware.AuditMiddleware(auditService, "consent.revoke"), mw.authRequired, middleware.PermissionMiddleware(models.PermConsentManage), consentController.RevokeConsent)
+
+		// 代理人管理
+		consents.GET("/proxies", middleware.AuditMiddleware(auditService, "consent.proxy_list"), mw.authRequired, middleware.PermissionMiddleware(models.PermConsentManage), consentController.ListProxies)
+		consents.POST("/proxies", middleware.AuditMiddleware(auditService, "consent.proxy_add"), mw.authRequired, middleware.PermissionMiddleware(models.PermConsentManage), consentController.AddProxy)
+		consents.DELETE("/proxies/:userId", middleware.AuditMiddleware(auditService, "consent.proxy_remove"), mw.authRequired, middleware.PermissionMiddleware(models.PermConsentManage), consentController.RemoveProxy)
+	}
+}
+
+// 设置数据访问申请路由
+func setupAccessRequestRoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, accessRequestController *controllers.AccessRequestController) {
+	requests := rg.Group("/access-requests")
+	{
+		// 获取当前用户提交的和待其审批的申请
+		requests.GET("", middleware.AuditMiddleware(auditService, "access_request.list"), mw.authRequired, middleware.PermissionMiddleware(models.PermAccessRequest, models.PermAccessReview), accessRequestController.ListMyRequests)
+
+		// 提交访问申请
+		requests.POST("", middleware.AuditMiddleware(auditService, "access_request.create"), mw.authRequired, middleware.PermissionMiddleware(models.PermAccessRequest), mw.credentialRequired, accessRequestController.CreateRequest)
+
+		// 获取某条数据的申请历史
+		requests.GET("/record/:id", middleware.AuditMiddleware(auditService, "access_request.history"), mw.authRequired, middleware.PermissionMiddleware(models.PermAccessRequest, models.PermAccessReview), accessRequestController.ListRecordRequests)
+
+		// 审批访问申请
+		requests.POST("/:id/approve", middleware.AuditMiddleware(auditService, "access_request.approve"), mw.authRequired, middleware.PermissionMiddleware(models.PermAccessReview), mw.credentialRequired, accessRequestController.ApproveRequest)
+		requests.POST("/:id/deny", middleware.AuditMiddleware(auditService, "access_request.deny"), mw.authRequired, middleware.PermissionMiddleware(models.PermAccessReview), mw.credentialRequired, accessRequestController.DenyRequest)
+	}
+}
+
+// 设置站内通知路由
+func setupNotificationRoutes(rg *gin.RouterGroup, mw routeMiddlewares, notificationController *controllers.NotificationController) {
+	notifications := rg.Group("/notifications", mw.authRequired, middleware.PermissionMiddleware(models.PermProfileRead))
+	{
+		// 获取站内通知
+		notifications.GET("", notificationController.ListNotifications)
+
+		// 标记通知为已读
+		notifications.POST("/:id/read", notificationController.MarkRead)
+	}
+}
+
+// 设置访问策略管理路由
+func setupPolicyRoutes(rg *gin.RouterGroup, mw routeMiddlewares, policyController *controllers.PolicyController) {
+	policies := rg.Group("/admin/policies", mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin))
+	{
+		// 获取当前生效的策略
+		policies.GET("", policyController.ListPolicies)
+
+		// 重新加载策略文件
+		policies.POST("/reload", policyController.ReloadPolicies)
+
+		// 试运行策略评估
+		policies.POST("/explain", policyController.ExplainPolicy)
+	}
+}
+
+// 设置审计日志路由
+func setupAuditRoutes(rg *gin.RouterGroup, mw routeMiddlewares, auditController *controllers.AuditController) {
+	audit := rg.Group("/audit", mw.authRequired)
+	{
+		// 查询审计日志（管理员查询全部，患者查询本人数据的访问记录）
+		audit.GET("", middleware.PermissionMiddleware(models.PermAuditRead, models.PermAuditReadOwn), auditController.QueryAuditLog)
+
+		// 校验哈希链
+		audit.GET("/verify", middleware.PermissionMiddleware(models.PermAuditRead), auditController.VerifyAuditLog)
+
+		// 获取审计记录相对于链上检查点的Merkle包含证明
+		audit.GET("/entries/:sequence/proof", middleware.PermissionMiddleware(models.PermAuditRead, models.PermAuditReadOwn), auditController.GetProof)
+
+		// 检查点管理
+		audit.GET("/checkpoints", middleware.PermissionMiddleware(models.PermAuditRead, models.PermAuditReadOwn), auditController.ListCheckpoints)
+		audit.POST("/checkpoints", middleware.PermissionMiddleware(models.PermAuditRead), auditController.CreateCheckpoint)
+	}
+}
+
+// 设置签名密钥管理路由
+func setupKeyRoutes(rg *gin.RouterGroup, mw routeMiddlewares, keyController *controllers.KeyController) {
+	keys := rg.Group("/admin/keys", mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin))
+	{
+		// 获取签名密钥元数据
+		keys.GET("", keyController.ListKeys)
+
+		// 轮换签名密钥
+		keys.POST("/rotate", keyController.RotateKey)
+	}
+}
+
+// 设置双因素认证路由
+// 个人设置接口不经过权限中间件，使受限令牌也能完成启用流程
+func setupMFARoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, mfaController *controllers.MFAController) {
+	mfa := rg.Group("/mfa")
+	{
+		// 获取双因素认证状态
+		mfa.GET("", mw.authRequired, mfaController.GetStatus)
+
+		// 生成密钥并确认启用
+		mfa.POST("/setup", middleware.AuditMiddleware(auditService, "mfa.setup"), mw.authRequired, mfaController.Setup)
+		mfa.POST("/confirm", middleware.AuditMiddleware(auditService, "mfa.confirm"), mw.authRequired, mfaController.Confirm)
+
+		// 关闭双因素认证
+		mfa.DELETE("", middleware.AuditMiddleware(auditService, "mfa.disable"), mw.authRequired, mfaController.Disable)
+
+		// 重新生成恢复码
+		mfa.POST("/recovery-codes", middleware.AuditMiddleware(auditService, "mfa.recovery_codes"), mw.authRequired, mfaController.RegenerateRecoveryCodes)
+	}
+
+	admin := rg.Group("/admin/mfa")
+	{
+		// 获取和设置要求双因素认证的角色
+		admin.GET("/policy", mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), mfaController.GetPolicy)
+		admin.PUT("/policy", middleware.AuditMiddleware(auditService, "mfa.policy_update"), mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), mfaController.UpdatePolicy)
+	}
+}
+
+// 设置服务账户管理路由
+func setupServiceAccountRoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, serviceAccountController *controllers.ServiceAccountController) {
+	accounts := rg.Group("/admin/service-accounts")
+	{
+		// 服务账户
+		accounts.GET("", mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), serviceAccountController.ListServiceAccounts)
+		accounts.POST("", middleware.AuditMiddleware(auditService, "service_account.create"), mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), serviceAccountController.CreateServiceAccount)
+		accounts.DELETE("/:id", middleware.AuditMiddleware(auditService, "service_account.disable"), mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), serviceAccountController.DisableServiceAccount)
+
+		// API密钥
+		accounts.GET("/:id/keys", mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), serviceAccountController.ListKeys)
+		accounts.POST("/:id/keys", middleware.AuditMiddleware(auditService, "api_key.create"), mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), serviceAccountController.CreateKey)
+		accounts.DELETE("/:id/keys/:keyId", middleware.AuditMiddleware(auditService, "api_key.revoke"), mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), serviceAccountController.RevokeKey)
+	}
+}
+
+// 设置用户密钥库路由
+func setupWalletRoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, walletController *controllers.WalletController) {
+	wallet := rg.Group("/wallet")
+	{
+		// 获取区块链身份和解锁状态
+		wallet.GET("", mw.authRequired, middleware.PermissionMiddleware(models.PermProfileRead), walletController.GetWallet)
+
+		// 解锁和锁定密钥库（解锁需要校验密码，与登录共用限流）
+		wallet.POST("/unlock", middleware.AuditMiddleware(auditService, "wallet.unlock"), mw.loginRateLimit, mw.authRequired, middleware.PermissionMiddleware(models.PermProfileRead), walletController.Unlock)
+		wallet.DELETE("/unlock", middleware.AuditMiddleware(auditService, "wallet.lock"), mw.authRequired, middleware.PermissionMiddleware(models.PermProfileRead), walletController.Lock)
+	}
+
+	// 按以太坊地址或Fabric身份标识查询跨链身份关联，非管理员只能查询自己的身份
+	rg.GET("/identity-links", mw.authRequired, middleware.PermissionMiddleware(models.PermProfileRead), walletController.ResolveIdentityLink)
+}
+
+// 设置did:web文档路由，路径按did:web规范由DID推导
+func setupDIDDocumentRoutes(r *gin.Engine, didController *controllers.DIDController) {
+	r.GET("/hospitals/:slug/did.json", didController.GetHospitalDocument)
+	r.GET("/users/:id/did.json", didController.GetUserDocument)
+}
+
+// 设置DID解析路由
+func setupDIDRoutes(rg *gin.RouterGroup, mw routeMiddlewares, didController *controllers.DIDController) {
+	dids := rg.Group("/dids")
+	{
+		// 解析did:key或did:web
+		dids.GET("/resolve", mw.authRequired, middleware.PermissionMiddleware(models.PermProfileRead), didController.Resolve)
+
+		// 获取本平台发布的医院DID
+		dids.GET("/hospitals", mw.authRequired, middleware.PermissionMiddleware(models.PermProfileRead), didController.ListHospitals)
+	}
+}
+
+// 设置执业凭证路由
+func setupCredentialRoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, credentialController *controllers.CredentialController) {
+	credentials := rg.Group("/credentials")
+	{
+		// 获取当前用户的执业凭证
+		credentials.GET("", mw.authRequired, middleware.PermissionMiddleware(models.PermProfileRead), credentialController.ListMyCredentials)
+
+		// 验证可验证凭证
+		credentials.POST("/verify", mw.authRequired, middleware.PermissionMiddleware(models.PermProfileRead), credentialController.VerifyCredential)
+	}
+
+	admin := rg.Group("/admin/credentials")
+	{
+		// 签发、查询和撤销执业凭证
+		admin.POST("", middleware.AuditMiddleware(auditService, "credential.issue"), mw.authRequired, middleware.PermissionMiddleware(models.PermCredentialIssue), credentialController.IssueCredential)
+		admin.GET("", mw.authRequired, middleware.PermissionMiddleware(models.PermCredentialIssue), credentialController.ListCredentials)
+		admin.POST("/:id/revoke", middleware.AuditMiddleware(auditService, "credential.revoke"), mw.authRequired, middleware.PermissionMiddleware(models.PermCredentialIssue), credentialController.RevokeCredential)
+	}
+}
+
+// 设置医院入驻和注册审核路由
+func setupOnboardingRoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, onboardingController *controllers.OnboardingController) {
+	// 获取已入驻的医院，注册时选择所属医院，公开访问
+	rg.GET("/institutions", onboardingController.ListInstitutions)
+
+	institutions := rg.Group("/admin/institutions")
+	{
+		// 登记入驻医院和创建医院管理员
+		institutions.POST("", middleware.AuditMiddleware(auditService, "institution.register"), mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), onboardingController.RegisterInstitution)
+		institutions.GET("", mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), onboardingController.ListInstitutions)
+		institutions.POST("/:slug/admins", middleware.AuditMiddleware(auditService, "institution.admin_create"), mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), onboardingController.CreateHospitalAdmin)
+	}
+
+	registrations := rg.Group("/admin/registrations")
+	{
+		// 审核注册用户，医院管理员只能审核本医院的用户
+		registrations.GET("", mw.authRequired, middleware.PermissionMiddleware(models.PermUserApprove), onboardingController.ListRegistrations)
+		registrations.POST("/:id/approve", middleware.AuditMiddleware(auditService, "registration.approve"), mw.authRequired, middleware.PermissionMiddleware(models.PermUserApprove), onboardingController.ApproveRegistration)
+		registrations.POST("/:id/reject", middleware.AuditMiddleware(auditService, "registration.reject"), mw.authRequired, middleware.PermissionMiddleware(models.PermUserApprove), onboardingController.RejectRegistration)
+	}
+}
+
+// 设置租户管理路由
+func setupTenantRoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, tenantController *controllers.TenantController) {
+	// 平台管理员查看所有租户
+	rg.GET("/admin/tenants", mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), tenantController.ListTenants)
+
+	tenant := rg.Group("/tenant")
+	{
+		// 租户信息和科室
+		tenant.GET("", mw.authRequired, middleware.PermissionMiddleware(models.PermTenantManage), tenantController.GetTenant)
+		tenant.POST("/departments", middleware.AuditMiddleware(auditService, "tenant.department_add"), mw.authRequired, middleware.PermissionMiddleware(models.PermTenantManage), tenantController.AddDepartment)
+		tenant.DELETE("/departments/:name", middleware.AuditMiddleware(auditService, "tenant.department_remove"), mw.authRequired, middleware.PermissionMiddleware(models.PermTenantManage), tenantController.RemoveDepartment)
+
+		// 租户用户
+		tenant.GET("/users", mw.authRequired, middleware.PermissionMiddleware(models.PermTenantManage), tenantController.ListUsers)
+		tenant.PUT("/users/:id/department", middleware.AuditMiddleware(auditService, "tenant.user_department"), mw.authRequired, middleware.PermissionMiddleware(models.PermTenantManage), tenantController.AssignDepartment)
+
+		// 租户间的数据共享授权
+		tenant.GET("/grants", mw.authRequired, middleware.PermissionMiddleware(models.PermTenantManage), tenantController.ListGrants)
+		tenant.POST("/grants", middleware.AuditMiddleware(auditService, "tenant.grant_create"), mw.authRequired, middleware.PermissionMiddleware(models.PermTenantManage), tenantController.CreateGrant)
+		tenant.POST("/grants/:id/revoke", middleware.AuditMiddleware(auditService, "tenant.grant_revoke"), mw.authRequired, middleware.PermissionMiddleware(models.PermTenantManage), tenantController.RevokeGrant)
+	}
+}
+
+// 设置个人资料、密码和用户管理路由
+func setupAccountRoutes(rg *gin.RouterGroup, auditService *services.AuditService, mw routeMiddlewares, accountController *controllers.AccountController) {
+	// 个人资料和修改密码
+	rg.PUT("/user", middleware.AuditMiddleware(auditService, "account.profile_update"), mw.authRequired, middleware.PermissionMiddleware(models.PermProfileRead), accountController.UpdateProfile)
+	rg.POST("/user/password", middleware.AuditMiddleware(auditService, "account.password_change"), mw.authRequired, middleware.PermissionMiddleware(models.PermProfileRead), accountController.ChangePassword)
+
+	// 通过一次性令牌重置密码，无需登录，与登录共用限流
+	rg.POST("/password/forgot", middleware.AuditMiddleware(auditService, "account.password_forgot"), mw.loginRateLimit, accountController.ForgotPassword)
+	rg.POST("/password/reset", middleware.AuditMiddleware(auditService, "account.password_reset"), mw.loginRateLimit, accountController.ResetPassword)
+
+	// 重置密码后使用旧密码恢复密钥库
+	rg.POST("/wallet/recover", middleware.AuditMiddleware(auditService, "wallet.recover"), mw.loginRateLimit, mw.authRequired, middleware.PermissionMiddleware(models.PermProfileRead), accountController.RecoverWallet)
+
+	// 平台管理员管理用户
+	users := rg.Group("/admin/users")
+	{
+		users.GET("", mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), accountController.ListUsers)
+		users.PUT("/:id/role", middleware.AuditMiddleware(auditService, "account.role_update"), mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), accountController.SetRole)
+		users.POST("/:id/disable", middleware.AuditMiddleware(auditService, "account.disable"), mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), accountController.DisableUser)
+		users.POST("/:id/enable", middleware.AuditMiddleware(auditService, "account.enable"), mw.authRequired, middleware.PermissionMiddleware(models.PermUserAdmin), accountController.EnableUser)
 	}
 }
 
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"medcross/models"
+	"medcross/services"
+)
+
+// AuditMiddleware 审计中间件
+// 在请求处理完成后记录操作者、操作、数据ID、区块链、结果和客户端IP。
+// 需要放在认证中间件之前，这样认证失败的请求也会被记录。
+// 处理函数可以通过上下文键 auditRecordID、auditChain、auditActor 补充路由参数中没有的信息，
+// 查询类操作可以通过 auditRecordIDs 为每条返回的数据各记录一条
+func AuditMiddleware(auditService *services.AuditService, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startedAt := time.Now()
+
+		c.Next()
+
+		entry := models.AuditEntry{
+			Timestamp:  startedAt,
+			ActorID:    c.GetString("userID"),
+			ActorName:  c.GetString("userName"),
+			Action:     action,
+			RecordID:   c.Param("id"),
+			StatusCode: c.Writer.Status(),
+			ClientIP:   c.ClientIP(),
+		}
+		if actor := c.GetString("auditActor"); actor != "" {
+			entry.ActorName = actor
+		}
+		if recordID := c.GetString("auditRecordID"); recordID != "" {
+			entry.RecordID = recordID
+		}
+		entry.Chain = c.GetString("auditChain")
+
+		switch status := c.Writer.Status(); {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			entry.Outcome = models.AuditOutcomeDenied
+		case status >= http.StatusBadRequest:
+			entry.Outcome = models.AuditOutcomeFailure
+		default:
+			entry.Outcome = models.AuditOutcomeSuccess
+		}
+
+		// 审计写入失败不影响已完成的响应，错误已由审计服务记录
+		recordIDs, _ := c.Get("auditRecordIDs")
+		if ids, ok := recordIDs.([]string); ok && len(ids) > 0 {
+			for _, id := range ids {
+				entry.RecordID = id
+				_ = auditService.Record(entry)
+			}
+			return
+		}
+		_ = auditService.Record(entry)
+	}
+}
@@ -8,12 +8,22 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 
+	"medcross/models"
+	"medcross/services"
 	"medcross/utils"
 )
 
-// AuthMiddleware JWT认证中间件
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware 认证中间件
+// 接受JWT访问令牌和服务账户的API密钥（X-API-Key头，或以mcx_开头的Bearer凭据）。
+// JWT除校验签名和有效期外，还检查令牌关联的登录会话是否已被撤销
+func AuthMiddleware(sessionService *services.SessionService, apiKeyService *services.APIKeyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// 服务账户使用API密钥认证
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, apiKeyService, apiKey)
+			return
+		}
+
 		// 获取Authorization头
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -32,6 +42,10 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		// 获取令牌部分
 		tokenString := parts[1]
+		if services.IsAPIKey(tokenString) {
+			authenticateAPIKey(c, apiKeyService, tokenString)
+			return
+		}
 
 		// 解析JWT令牌
 		token, err := parseJWT(tokenString)
@@ -43,10 +57,22 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		// 验证令牌
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+			// 检查会话是否已注销或撤销
+			sessionID, _ := claims["sid"].(string)
+			if sessionID == "" || !sessionService.IsActive(sessionID) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "会话已失效，请重新登录"})
+				c.Abort()
+				return
+			}
+
 			// 将用户信息存储在上下文中
 			c.Set("userID", claims["sub"])
 			c.Set("userName", claims["name"])
 			c.Set("userRole", claims["role"])
+			c.Set("sessionID", sessionID)
+			if scope, _ := claims["scope"].(string); scope != "" {
+				c.Set("tokenScope", scope)
+			}
 		} else {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的令牌声明"})
 			c.Abort()
@@ -57,6 +83,25 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// 校验API密钥，通过后以服务账户身份继续处理请求
+// 密钥的权限范围写入上下文，由权限中间件进一步限制
+func authenticateAPIKey(c *gin.Context, apiKeyService *services.APIKeyService, credential string) {
+	account, key, err := apiKeyService.Authenticate(credential, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.Set("userID", account.ID)
+	c.Set("userName", account.Name)
+	c.Set("userRole", models.RoleService)
+	c.Set("apiKeyID", key.ID)
+	c.Set("apiKeyScopes", key.Scopes)
+
+	c.Next()
+}
+
 // RoleMiddleware 角色验证中间件
 func RoleMiddleware(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -87,6 +132,81 @@ func RoleMiddleware(roles ...string) gin.HandlerFunc {
 	}
 }
 
+// PermissionMiddleware 权限验证中间件
+// 用户角色拥有所列权限中的任意一项即可通过
+func PermissionMiddleware(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 获取用户角色
+		userRole, exists := c.Get("userRole")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+			c.Abort()
+			return
+		}
+
+		// 受限令牌只能用于启用双因素认证
+		if c.GetString("tokenScope") == models.TokenScopeMFAEnroll {
+			c.JSON(http.StatusForbidden, gin.H{"error": "需要先启用双因素认证"})
+			c.Abort()
+			return
+		}
+
+		// 待审核用户的令牌只能读取本人资料
+		if c.GetString("tokenScope") == models.TokenScopePending && !containsPermission(permissions, models.PermProfileRead) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "账户尚未通过审核"})
+			c.Abort()
+			return
+		}
+
+		// 检查角色是否拥有所需权限，API密钥还必须在密钥的权限范围内
+		role, _ := userRole.(string)
+		scopes, scoped := c.Get("apiKeyScopes")
+		hasPermission := false
+		for _, permission := range permissions {
+			if scoped && !containsPermission(scopes.([]string), permission) {
+				continue
+			}
+			if models.HasPermission(role, permission) {
+				hasPermission = true
+				break
+			}
+		}
+
+		if !hasPermission {
+			c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// CredentialMiddleware 执业凭证验证中间件
+// 医生和研究人员的角色由用户自行声明，执行临床操作前必须持有所属医院签发的有效执业凭证，
+// 需要放在PermissionMiddleware之后
+func CredentialMiddleware(credentialService *services.CredentialService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !credentialService.HasValidCredential(c.GetString("userID"), c.GetString("userRole")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": services.ErrCredentialRequired.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// 检查权限列表中是否包含指定权限
+func containsPermission(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
 // 使用utils包中的ParseJWT函数
 func parseJWT(tokenString string) (*jwt.Token, error) {
 	return utils.ParseJWT(tokenString)
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenBucket 单个调用方的令牌桶
+type tokenBucket struct {
+	tokens   float64
+	updated  time.Time
+	lastSeen time.Time
+}
+
+// RateLimiter 令牌桶限流器
+// 每个调用方最多积累burst个令牌，按rate个/秒的速度补充
+type RateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     int
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+// NewRateLimiter 根据配置创建限流器
+// 配置格式为 "次数/单位"，单位可以是 s、m、h，例如 "10/m" 表示每分钟10次、最多连续10次；
+// 配置为空、"0" 或 "off" 时返回nil，表示不限流
+func NewRateLimiter(spec string) (*RateLimiter, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "0" || spec == "off" {
+		return nil, nil
+	}
+
+	countStr, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return nil, fmt.Errorf("无效的限流配置: %s", spec)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(countStr))
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("无效的限流次数: %s", spec)
+	}
+
+	var period time.Duration
+	switch strings.TrimSpace(unit) {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return nil, fmt.Errorf("无效的限流时间单位: %s", spec)
+	}
+
+	return &RateLimiter{
+		rate:    float64(count) / period.Seconds(),
+		burst:   count,
+		buckets: make(map[string]*tokenBucket),
+	}, nil
+}
+
+// Allow 尝试为调用方消耗一个令牌
+// 返回是否允许、剩余令牌数，以及桶补满（允许时）或下一个令牌可用（拒绝时）前的等待时间
+func (l *RateLimiter) Allow(key string) (bool, int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.pruneLocked(now)
+
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(l.burst), updated: now}
+		l.buckets[key] = bucket
+	}
+	bucket.lastSeen = now
+
+	// 按经过的时间补充令牌
+	bucket.tokens = math.Min(float64(l.burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+		return false, 0, wait
+	}
+
+	bucket.tokens--
+	reset := time.Duration((float64(l.burst) - bucket.tokens) / l.rate * float64(time.Second))
+	return true, int(bucket.tokens), reset
+}
+
+// 清理长时间未访问、令牌已补满的桶，每分钟最多一次，调用方需持有锁
+func (l *RateLimiter) pruneLocked(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+
+	fill := time.Duration(float64(l.burst) / l.rate * float64(time.Second))
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) > fill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimitMiddleware 限流中间件
+// 已登录的请求按用户限流，未登录的请求按来源IP限流；limiter为nil时不限流。
+// 响应头 X-RateLimit-Limit/Remaining/Reset 告知客户端当前配额，超限时返回429和Retry-After
+func RateLimitMiddleware(limiter *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		key := "ip:" + c.ClientIP()
+		if userID := c.GetString("userID"); userID != "" {
+			key = "user:" + userID
+		}
+
+		allowed, remaining, wait := limiter.Allow(key)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limiter.burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(wait)))
+
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(wait)))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁，请稍后再试"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// 将等待时间向上取整为秒，用于Retry-After等响应头
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
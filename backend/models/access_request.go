@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+)
+
+// 访问申请状态
+const (
+	AccessRequestPending  = "pending"
+	AccessRequestApproved = "approved"
+	AccessRequestDenied   = "denied"
+)
+
+// AccessRequest 数据访问申请
+type AccessRequest struct {
+	ID            string     `json:"id"`
+	RecordID      string     `json:"recordId"`               // 申请访问的数据ID
+	RecordChain   string     `json:"recordChain"`            // 数据所在区块链
+	RequesterID   string     `json:"requesterId"`            // 申请人用户ID
+	OwnerID       string     `json:"ownerId"`                // 数据所有者用户ID
+	PatientID     string     `json:"patientId,omitempty"`    // 数据对应的患者标识
+	Purpose       string     `json:"purpose"`                // 使用目的
+	DurationHours int        `json:"durationHours"`          // 申请的访问时长（小时）
+	Message       string     `json:"message,omitempty"`      // 申请说明
+	Status        string     `json:"status"`                 // 状态: "pending", "approved", "denied"
+	DecisionBy    string     `json:"decisionBy,omitempty"`   // 审批人用户ID
+	DecisionNote  string     `json:"decisionNote,omitempty"` // 审批理由
+	DecidedAt     *time.Time `json:"decidedAt,omitempty"`    // 审批时间
+	GrantID       string     `json:"grantId,omitempty"`      // 审批通过后签发的授权ID
+	GrantExpires  *time.Time `json:"grantExpires,omitempty"` // 授权到期时间
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// AccessGrant 审批通过后签发的限时访问授权
+type AccessGrant struct {
+	ID        string    `json:"id"`
+	RequestID string    `json:"requestId"`
+	RecordID  string    `json:"recordId"`
+	GranteeID string    `json:"granteeId"`
+	Purpose   string    `json:"purpose"`
+	IssuedBy  string    `json:"issuedBy"`
+	IssuedAt  time.Time `json:"issuedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Active 检查授权在指定时间是否有效
+func (g AccessGrant) Active(at time.Time) bool {
+	return !at.Before(g.IssuedAt) && at.Before(g.ExpiresAt)
+}
+
+// AccessRequestCreate 创建访问申请请求
+type AccessRequestCreate struct {
+	RecordID      string `json:"recordId" binding:"required"`
+	Purpose       string `json:"purpose" binding:"required"`
+	DurationHours int    `json:"durationHours" binding:"required"`
+	Message       string `json:"message"`
+}
+
+// AccessRequestDecision 审批访问申请请求
+type AccessRequestDecision struct {
+	Reason string `json:"reason"`
+}
+
+// Notification 站内通知
+type Notification struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Subject   string    `json:"subject"`
+	Message   string    `json:"message"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"createdAt"`
+}
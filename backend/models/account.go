@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordMinLength 修改和重置密码时新密码的最小长度
+const PasswordMinLength = 8
+
+// ProfileUpdate 用户修改个人资料的请求，为空的字段保持不变
+type ProfileUpdate struct {
+	Name       string `json:"name,omitempty"`
+	Email      string `json:"email,omitempty"`
+	Hospital   string `json:"hospital,omitempty"`
+	Department string `json:"department,omitempty"`
+}
+
+// PasswordChangeRequest 修改密码请求，需要提供当前密码重新认证
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+// PasswordResetRequest 申请重置密码的请求
+type PasswordResetRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+// PasswordResetConfirm 使用重置令牌设置新密码的请求
+type PasswordResetConfirm struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// PasswordResetToken 密码重置令牌，只保存令牌的SHA-256哈希，使用一次后删除
+type PasswordResetToken struct {
+	UserID    string    `json:"userId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WalletRecoverRequest 重置密码后使用旧密码恢复密钥库的请求
+// 密钥库用旧密码加密，验证当前登录密码后改用当前密码加密
+type WalletRecoverRequest struct {
+	OldPassword string `json:"oldPassword" binding:"required"`
+	Password    string `json:"password" binding:"required"`
+}
+
+// UserListQuery 管理员查询用户的条件
+type UserListQuery struct {
+	Query    string `form:"q"`      // 按用户名、姓名或邮箱模糊搜索
+	Role     string `form:"role"`   // 角色筛选
+	Status   string `form:"status"` // 账户状态筛选
+	Tenant   string `form:"tenant"` // 租户筛选
+	Page     int    `form:"page"`
+	PageSize int    `form:"pageSize"`
+}
+
+// UserRoleUpdate 管理员调整用户角色的请求
+type UserRoleUpdate struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// UserDisableRequest 管理员停用用户的请求
+type UserDisableRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// AssignableRoles 管理员可以为用户设置的角色，服务账户通过服务账户接口管理
+var AssignableRoles = map[string]bool{
+	RoleDoctor:        true,
+	RoleResearcher:    true,
+	RolePatient:       true,
+	RoleHospitalAdmin: true,
+	RoleAdmin:         true,
+}
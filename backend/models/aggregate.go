@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+)
+
+// AggregateQuery 差分隐私聚合查询请求
+type AggregateQuery struct {
+	Metric    string   `json:"metric" binding:"required"` // 查询类型: "count" 或 "histogram"
+	GroupBy   []string `json:"groupBy"`                   // 分组维度: dataType, chain, hospital, department, month
+	DataType  string   `json:"dataType"`                  // 数据类型筛选
+	Chain     string   `json:"chain"`                     // 区块链筛选
+	Hospital  string   `json:"hospital"`                  // 医院筛选（按数据所属的租户确定）
+	StartDate string   `json:"startDate"`                 // 开始日期（YYYY-MM-DD）
+	EndDate   string   `json:"endDate"`                   // 结束日期（YYYY-MM-DD）
+	Epsilon   float64  `json:"epsilon"`                   // 本次查询消耗的隐私预算，为0时使用默认值
+	Shared    bool     `json:"shared"`                    // 是否包含其他租户共享给本租户的数据，默认只统计本租户
+
+	// GroupValues 各分组维度的取值范围，histogram必须为每个分组维度声明（month未声明时按startDate和endDate逐月生成）
+	// 结果只包含声明范围内的单元格，不在范围内的记录不计入
+	GroupValues map[string][]string `json:"groupValues"`
+}
+
+// AggregateCell 聚合结果中的一个单元格
+type AggregateCell struct {
+	Group map[string]string `json:"group,omitempty"` // 分组取值，count查询时为空
+	Count int               `json:"count"`           // 加噪后的计数
+}
+
+// AggregateResult 差分隐私聚合查询结果
+type AggregateResult struct {
+	Metric          string          `json:"metric"`
+	GroupBy         []string        `json:"groupBy,omitempty"`
+	Cells           []AggregateCell `json:"cells"`
+	SuppressedCells int             `json:"suppressedCells"` // 加噪计数低于阈值被隐藏的单元格数量，由加噪结果得出
+	MinCellSize     int             `json:"minCellSize"`     // 小单元格隐藏阈值
+	Epsilon         float64         `json:"epsilon"`         // 本次消耗的隐私预算
+	RemainingBudget float64         `json:"remainingBudget"` // 剩余隐私预算
+}
+
+// PrivacyBudget 用户的差分隐私预算
+type PrivacyBudget struct {
+	UserID     string    `json:"userId"`
+	Total      float64   `json:"total"`      // 总预算
+	Spent      float64   `json:"spent"`      // 已消耗预算
+	QueryCount int       `json:"queryCount"` // 已执行的查询次数
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Remaining 返回剩余预算
+func (b PrivacyBudget) Remaining() float64 {
+	remaining := b.Total - b.Spent
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// 审计结果
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeDenied  = "denied"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEntry 审计日志条目
+// 每条记录包含前一条记录的哈希，删除或修改任一条记录都会使哈希链断裂
+type AuditEntry struct {
+	Sequence   int64     `json:"sequence"`
+	Timestamp  time.Time `json:"timestamp"`
+	ActorID    string    `json:"actorId"`
+	ActorName  string    `json:"actorName"`
+	Action     string    `json:"action"`
+	RecordID   string    `json:"recordId,omitempty"`
+	Chain      string    `json:"chain,omitempty"`
+	Outcome    string    `json:"outcome"`
+	StatusCode int       `json:"statusCode"`
+	ClientIP   string    `json:"clientIp"`
+	PrevHash   string    `json:"prevHash"`
+	Hash       string    `json:"hash"`
+}
+
+// ComputeHash 计算审计记录的哈希
+// 对Hash字段置空后的JSON序列化结果取SHA-256
+func (e AuditEntry) ComputeHash() string {
+	e.Hash = ""
+	content, _ := json.Marshal(e)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditQuery 审计日志查询参数
+type AuditQuery struct {
+	ActorID  string    `form:"actorId"`
+	Action   string    `form:"action"`
+	RecordID string    `form:"recordId"`
+	Chain    string    `form:"chain"`
+	Outcome  string    `form:"outcome"`
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page     int       `form:"page"`
+	PageSize int       `form:"pageSize"`
+}
+
+// AuditQueryResult 审计日志查询结果
+type AuditQueryResult struct {
+	Entries    []AuditEntry `json:"entries"`
+	TotalCount int          `json:"totalCount"`
+	Page       int          `json:"page"`
+	PageSize   int          `json:"pageSize"`
+}
+
+// AuditVerifyResult 哈希链校验结果
+type AuditVerifyResult struct {
+	Valid       bool   `json:"valid"`
+	EntryCount  int    `json:"entryCount"`
+	BrokenAt    int64  `json:"brokenAt,omitempty"` // 第一条校验失败的记录序号
+	Reason      string `json:"reason,omitempty"`
+	LastHash    string `json:"lastHash"`
+	GenesisHash string `json:"genesisHash"`
+}
+
+// 检查点上链状态
+const (
+	AuditAnchorPending  = "pending"
+	AuditAnchorAnchored = "anchored"
+	AuditAnchorFailed   = "failed"
+)
+
+// AuditCheckpoint 审计日志检查点
+// 对一段连续审计记录的哈希计算Merkle根，并将根写入区块链
+type AuditCheckpoint struct {
+	ID           string                  `json:"id"`
+	FromSequence int64                   `json:"fromSequence"`
+	ToSequence   int64                   `json:"toSequence"`
+	TreeSize     int                     `json:"treeSize"`
+	MerkleRoot   string                  `json:"merkleRoot"` // 十六进制
+	CreatedAt    time.Time               `json:"createdAt"`
+	Anchors      map[string]*AuditAnchor `json:"anchors"` // 区块链 -> 上链记录
+}
+
+// AuditAnchor 检查点在某条区块链上的锚定记录
+type AuditAnchor struct {
+	Status     string     `json:"status"`
+	TxHash     string     `json:"txHash,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Attempts   int        `json:"attempts"`
+	AnchoredAt *time.Time `json:"anchoredAt,omitempty"`
+}
+
+// AuditProof 审计记录的Merkle包含证明
+// 校验方法：叶子哈希 = SHA-256(0x00 || entry.hash的字节)，
+// 按 proof 自底向上组合（内部节点 = SHA-256(0x01 || 左 || 右)），结果应等于检查点的 merkleRoot
+type AuditProof struct {
+	Entry      AuditEntry      `json:"entry"`
+	LeafHash   string          `json:"leafHash"`
+	LeafIndex  int             `json:"leafIndex"`
+	TreeSize   int             `json:"treeSize"`
+	Proof      []string        `json:"proof"`
+	MerkleRoot string          `json:"merkleRoot"`
+	Checkpoint AuditCheckpoint `json:"checkpoint"`
+}
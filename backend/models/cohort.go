@@ -0,0 +1,24 @@
+package models
+
+// CohortQuery 队列可行性查询条件
+type CohortQuery struct {
+	DataTypes         []string `json:"dataTypes"`         // 数据类型，满足其一即可
+	DiagnosisKeywords []string `json:"diagnosisKeywords"` // 诊断关键词，满足其一即可
+	StartDate         string   `json:"startDate"`         // 开始日期（YYYY-MM-DD）
+	EndDate           string   `json:"endDate"`           // 结束日期（YYYY-MM-DD）
+	Hospitals         []string `json:"hospitals"`         // 医院名称或租户ID，按数据所属的租户匹配，满足其一即可
+	Chains            []string `json:"chains"`            // 查询的区块链，为空时查询全部
+	Shared            bool     `json:"shared"`            // 是否包含其他租户共享给本租户的记录，默认只统计本租户
+}
+
+// CohortResult 队列可行性查询结果
+// 计数低于阈值时不返回具体数字
+type CohortResult struct {
+	PatientCount        *int            `json:"patientCount"`                  // 去重后的患者数量，低于阈值时为null
+	BelowThreshold      bool            `json:"belowThreshold"`                // 患者数量是否低于阈值
+	Threshold           int             `json:"threshold"`                     // 最小可披露数量
+	ChainCounts         map[string]*int `json:"chainCounts"`                   // 各链上的患者数量，低于阈值时为null
+	UnidentifiedRecords *int            `json:"unidentifiedRecords"`           // 缺少患者标识、无法去重的匹配记录数，不计入患者数量，低于阈值时为null
+	AccessibleRecordIDs []string        `json:"accessibleRecordIds,omitempty"` // 调用者有权访问的匹配记录ID
+	Errors              []string        `json:"errors,omitempty"`
+}
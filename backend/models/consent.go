@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+)
+
+// 数据使用目的
+const (
+	PurposeTreatment     = "treatment"      // 诊疗
+	PurposeResearch      = "research"       // 科研
+	PurposePublicHealth  = "public_health"  // 公共卫生
+	PurposeInsurance     = "insurance"      // 保险理赔
+	PurposeQualityReview = "quality_review" // 医疗质量评估
+)
+
+// ValidPurposes 支持的数据使用目的
+var ValidPurposes = map[string]bool{
+	PurposeTreatment:     true,
+	PurposeResearch:      true,
+	PurposePublicHealth:  true,
+	PurposeInsurance:     true,
+	PurposeQualityReview: true,
+}
+
+// 知情同意状态
+const (
+	ConsentStatusActive  = "active"
+	ConsentStatusRevoked = "revoked"
+)
+
+// Consent 患者知情同意记录
+type Consent struct {
+	ID           string            `json:"id"`
+	PatientID    string            `json:"patientId"`              // 患者标识（与元数据中的patientId一致）
+	GrantedBy    string            `json:"grantedBy"`              // 授权操作人的用户ID（患者本人或其代理人）
+	GranteeID    string            `json:"granteeId"`              // 被授权用户ID
+	Purpose      string            `json:"purpose"`                // 使用目的
+	DataTypes    []string          `json:"dataTypes,omitempty"`    // 允许访问的数据类型，为空表示全部类型
+	ValidFrom    time.Time         `json:"validFrom"`              // 生效时间
+	ValidUntil   time.Time         `json:"validUntil"`             // 失效时间
+	Status       string            `json:"status"`                 // 状态: "active" 或 "revoked"
+	CreatedAt    time.Time         `json:"createdAt"`              // 创建时间
+	RevokedAt    *time.Time        `json:"revokedAt,omitempty"`    // 撤销时间
+	RevokedBy    string            `json:"revokedBy,omitempty"`    // 撤销操作人的用户ID
+	ChainRecords map[string]string `json:"chainRecords,omitempty"` // 链上知情同意记录的交易哈希: "chain:action" -> txHash
+}
+
+// Covers 检查知情同意在指定时间是否覆盖给定的被授权人、目的和数据类型
+func (c Consent) Covers(granteeID, purpose, dataType string, at time.Time) bool {
+	if c.Status != ConsentStatusActive || c.GranteeID != granteeID || c.Purpose != purpose {
+		return false
+	}
+	if at.Before(c.ValidFrom) || !at.Before(c.ValidUntil) {
+		return false
+	}
+	if len(c.DataTypes) == 0 {
+		return true
+	}
+	for _, t := range c.DataTypes {
+		if t == dataType {
+			return true
+		}
+	}
+	return false
+}
+
+// ConsentGrantRequest 授予知情同意请求
+type ConsentGrantRequest struct {
+	PatientID  string    `json:"patientId"`                     // 患者标识，代理人授权时必填
+	GranteeID  string    `json:"granteeId" binding:"required"`  // 被授权用户ID
+	Purpose    string    `json:"purpose" binding:"required"`    // 使用目的
+	DataTypes  []string  `json:"dataTypes"`                     // 数据类型，为空表示全部类型
+	ValidFrom  time.Time `json:"validFrom"`                     // 生效时间，为空表示立即生效
+	ValidUntil time.Time `json:"validUntil" binding:"required"` // 失效时间
+}
+
+// ConsentProxy 患者授权代理人
+type ConsentProxy struct {
+	PatientID   string    `json:"patientId"`
+	ProxyUserID string    `json:"proxyUserId"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ConsentProxyRequest 添加代理人请求
+type ConsentProxyRequest struct {
+	ProxyUserID string `json:"proxyUserId" binding:"required"`
+}
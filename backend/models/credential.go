@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+)
+
+// 可验证凭证使用的JSON-LD上下文和类型
+var (
+	CredentialContexts = []string{
+		"https://www.w3.org/2018/credentials/v1",
+		"https://w3id.org/vc/status-list/2021/v1",
+	}
+	ClinicianCredentialTypes  = []string{"VerifiableCredential", "MedCrossClinicianCredential"}
+	StatusListCredentialTypes = []string{"VerifiableCredential", "StatusList2021Credential"}
+)
+
+// 凭证状态
+const (
+	CredentialStatusActive  = "active"
+	CredentialStatusRevoked = "revoked"
+	CredentialStatusExpired = "expired"
+)
+
+// StatusPurposeRevocation 状态列表用途：撤销
+const StatusPurposeRevocation = "revocation"
+
+// CredentialRequiredRoles 需要医院签发的执业凭证才能执行临床操作的角色
+// 这些角色可以自助注册，角色和医院由用户自行声明
+var CredentialRequiredRoles = map[string]bool{
+	RoleDoctor:     true,
+	RoleResearcher: true,
+}
+
+// CredentialStatusEntry 凭证的撤销状态（StatusList2021Entry）
+type CredentialStatusEntry struct {
+	ID                   string `json:"id"`
+	Type                 string `json:"type"`
+	StatusPurpose        string `json:"statusPurpose"`
+	StatusListIndex      string `json:"statusListIndex"`
+	StatusListCredential string `json:"statusListCredential"`
+}
+
+// VerifiableCredential JWT凭证中vc声明的内容（VC-JWT）
+// 签发者、有效期和凭证ID分别由JWT的iss、nbf/exp和jti表示
+type VerifiableCredential struct {
+	Context           []string               `json:"@context"`
+	Type              []string               `json:"type"`
+	CredentialSubject map[string]interface{} `json:"credentialSubject"` // 执业凭证包含id（用户DID）、role、hospital、department
+	CredentialStatus  *CredentialStatusEntry `json:"credentialStatus,omitempty"`
+}
+
+// IssuedCredential 本平台签发的执业凭证记录
+type IssuedCredential struct {
+	ID               string     `json:"id"` // urn:uuid:<uuid>，即JWT的jti
+	UserID           string     `json:"userId"`
+	Issuer           string     `json:"issuer"`       // 签发医院的DID
+	HospitalSlug     string     `json:"hospitalSlug"` // 签发医院在状态列表地址中的标识
+	SubjectDID       string     `json:"subjectDid"`   // 用户的DID
+	Role             string     `json:"role"`
+	Hospital         string     `json:"hospital"`
+	Department       string     `json:"department,omitempty"`
+	StatusListIndex  int        `json:"statusListIndex"`
+	IssuedBy         string     `json:"issuedBy"` // 执行签发的管理员用户ID
+	IssuedAt         time.Time  `json:"issuedAt"`
+	ExpiresAt        time.Time  `json:"expiresAt"`
+	Revoked          bool       `json:"revoked"`
+	RevokedAt        *time.Time `json:"revokedAt,omitempty"`
+	RevokedBy        string     `json:"revokedBy,omitempty"`
+	RevocationReason string     `json:"revocationReason,omitempty"`
+	JWT              string     `json:"jwt"` // 签名后的凭证，用户可出示给其他部署验证
+}
+
+// Status 获取凭证当前状态
+func (c *IssuedCredential) Status(now time.Time) string {
+	if c.Revoked {
+		return CredentialStatusRevoked
+	}
+	if !now.Before(c.ExpiresAt) {
+		return CredentialStatusExpired
+	}
+	return CredentialStatusActive
+}
+
+// CredentialIssueRequest 签发执业凭证请求
+// 角色、医院和科室取自用户当前的资料
+type CredentialIssueRequest struct {
+	UserID    string `json:"userId" binding:"required"`
+	ValidDays int    `json:"validDays,omitempty"` // 有效天数，默认使用 CREDENTIAL_VALIDITY
+}
+
+// CredentialRevokeRequest 撤销执业凭证请求
+type CredentialRevokeRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// CredentialVerifyRequest 验证凭证请求
+type CredentialVerifyRequest struct {
+	Credential string `json:"credential" binding:"required"` // JWT格式的可验证凭证
+}
+
+// CredentialVerification 凭证验证结果
+type CredentialVerification struct {
+	Valid      bool                   `json:"valid"`
+	Status     string                 `json:"status,omitempty"`
+	ID         string                 `json:"id,omitempty"`
+	Issuer     string                 `json:"issuer,omitempty"`
+	Subject    map[string]interface{} `json:"credentialSubject,omitempty"`
+	IssuedAt   *time.Time             `json:"issuedAt,omitempty"`
+	ExpiresAt  *time.Time             `json:"expiresAt,omitempty"`
+	LocalIssue bool                   `json:"localIssue"` // 是否由本平台的医院签发
+	Error      string                 `json:"error,omitempty"`
+}
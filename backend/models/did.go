@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+)
+
+// DID文档使用的JSON-LD上下文
+var DIDContexts = []string{
+	"https://www.w3.org/ns/did/v1",
+	"https://w3id.org/security/multikey/v1",
+}
+
+// DIDDocument W3C DID文档
+type DIDDocument struct {
+	Context            []string                `json:"@context"`
+	ID                 string                  `json:"id"`
+	Controller         string                  `json:"controller,omitempty"`
+	AlsoKnownAs        []string                `json:"alsoKnownAs,omitempty"`
+	VerificationMethod []DIDVerificationMethod `json:"verificationMethod,omitempty"`
+	Authentication     []string                `json:"authentication,omitempty"`
+	AssertionMethod    []string                `json:"assertionMethod,omitempty"`
+	Service            []DIDServiceEndpoint    `json:"service,omitempty"`
+}
+
+// DIDVerificationMethod DID文档中的验证方法，公钥使用Multikey格式
+type DIDVerificationMethod struct {
+	ID                  string `json:"id"`
+	Type                string `json:"type"`
+	Controller          string `json:"controller"`
+	PublicKeyMultibase  string `json:"publicKeyMultibase,omitempty"`
+	BlockchainAccountID string `json:"blockchainAccountId,omitempty"` // CAIP-10格式的链上账户
+}
+
+// DIDServiceEndpoint DID文档中的服务端点
+type DIDServiceEndpoint struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	ServiceEndpoint string `json:"serviceEndpoint"`
+}
+
+// HospitalDID 医院的did:web身份
+type HospitalDID struct {
+	Slug      string    `json:"slug"` // did:web路径中的医院标识
+	Name      string    `json:"name"`
+	DID       string    `json:"did"`
+	PublicKey string    `json:"publicKey"` // multibase编码的P-256公钥
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DIDResolutionResult DID解析结果
+type DIDResolutionResult struct {
+	DIDDocument *DIDDocument `json:"didDocument"`
+	Method      string       `json:"method"`
+	Local       bool         `json:"local"` // 是否为本平台签发的DID
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+)
+
+// BlockchainIdentity 用户在两条链上的身份（公开部分）
+type BlockchainIdentity struct {
+	UserID             string    `json:"userId"`
+	DID                string    `json:"did,omitempty"`      // 以太坊公钥对应的did:key，作为数据在两条链上的所有者
+	EthereumAddress    string    `json:"ethereumAddress"`    // EIP-55校验格式的以太坊地址
+	FabricMSPID        string    `json:"fabricMspId"`        // Fabric成员服务提供者ID
+	FabricEnrollmentID string    `json:"fabricEnrollmentId"` // 向CA登记时使用的ID
+	FabricID           string    `json:"fabricId"`           // 与链码cid.GetID解码后一致：x509::<subject>::<issuer>
+	FabricCertificate  string    `json:"fabricCertificate"`  // PEM格式的X.509证书
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+// WalletFile 用户密钥库文件，私钥使用用户密码派生的密钥加密
+type WalletFile struct {
+	Version  int                `json:"version"`
+	Identity BlockchainIdentity `json:"identity"`
+	Crypto   WalletCrypto       `json:"crypto"`
+}
+
+// WalletCrypto 密钥库加密参数
+type WalletCrypto struct {
+	KDF        string          `json:"kdf"` // 目前只支持scrypt
+	KDFParams  WalletKDFParams `json:"kdfParams"`
+	Cipher     string          `json:"cipher"` // 目前只支持aes-256-gcm
+	Nonce      string          `json:"nonce"`
+	Ciphertext string          `json:"ciphertext"`
+}
+
+// WalletKDFParams scrypt密钥派生参数
+type WalletKDFParams struct {
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt string `json:"salt"`
+}
+
+// IdentityLink 同一用户的以太坊地址与Fabric身份的关联记录
+// 关联声明由两个私钥分别签名，任何人都可以用链上记录验证两个身份属于同一用户
+type IdentityLink struct {
+	UserID            string            `json:"userId,omitempty"` // 按链上身份查询时不返回
+	EthereumAddress   string            `json:"ethereumAddress"`
+	FabricID          string            `json:"fabricId"`
+	FabricCertificate string            `json:"fabricCertificate"` // 用于验证Fabric签名的PEM证书
+	Statement         string            `json:"statement"`         // 关联声明原文
+	EthereumSignature string            `json:"ethereumSignature"` // 对声明的EIP-191签名，0x开头的65字节十六进制
+	FabricSignature   string            `json:"fabricSignature"`   // 对声明SHA-256摘要的DER格式ECDSA签名（base64）
+	CreatedAt         time.Time         `json:"createdAt"`
+	ChainRecords      map[string]string `json:"chainRecords,omitempty"` // 链 -> 上链交易哈希
+}
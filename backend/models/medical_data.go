@@ -14,6 +14,7 @@ type MedicalData struct {
 	Timestamp time.Time `json:"timestamp"`  // 上传时间戳
 	Keywords  string    `json:"keywords"`   // 关键词，用于搜索，以逗号分隔
 	Chain     string    `json:"chain"`      // 标识数据来源的区块链: "ethereum" 或 "fabric"
+	TenantID  string    `json:"tenantId,omitempty"` // 数据所属租户，同时写入元数据的tenantId随数据上链
 }
 
 // MedicalDataUpload 医疗数据上传请求
@@ -23,7 +24,9 @@ type MedicalDataUpload struct {
 	DataType    string `json:"dataType" binding:"required"`  // 数据类型
 	Description string `json:"description" binding:"required"` // 数据描述
 	Keywords    string `json:"keywords"`                     // 关键词，用逗号分隔
+	PatientID   string `json:"patientId"`                    // 患者标识，用于知情同意校验
 	TargetChain string `json:"targetChain" binding:"required"` // 目标区块链
+	SigningMode string `json:"signingMode"` // 交易签名方式：custodial（默认）、client，没有区块链身份的用户为gateway
 }
 
 // MedicalDataQuery 医疗数据查询请求
@@ -36,6 +39,8 @@ type MedicalDataQuery struct {
 	SortBy     string `form:"sortBy"`     // 排序方式
 	Page       int    `form:"page"`       // 页码
 	PageSize   int    `form:"pageSize"`   // 每页大小
+	Purpose    string `form:"purpose"`    // 数据使用目的，用于知情同意校验
+	Shared     bool   `form:"shared"`     // 是否包含其他租户共享给本租户的数据，默认只返回本租户的数据
 }
 
 // QueryResult 查询结果
@@ -47,10 +52,13 @@ type QueryResult struct {
 
 // UploadResponse 上传响应
 type UploadResponse struct {
-	ID       string `json:"id"`
-	Message  string `json:"message"`
-	DataHash string `json:"dataHash"`
-	Chain    string `json:"chain"`
+	ID              string `json:"id"`
+	Message         string `json:"message"`
+	DataHash        string `json:"dataHash"`
+	Chain           string `json:"chain"`
+	Owner           string `json:"owner"`                     // 链上数据所有者
+	SigningMode     string `json:"signingMode"`               // 交易签名方式
+	TransactionHash string `json:"transactionHash,omitempty"` // 用户签名交易的哈希
 }
 
 // Statistics 统计数据
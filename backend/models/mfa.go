@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+)
+
+// 访问令牌的权限范围
+const (
+	// TokenScopeMFAEnroll 受限令牌，只能用于启用双因素认证
+	TokenScopeMFAEnroll = "mfa_enroll"
+
+	// TokenScopePending 尚未通过审核的账户的受限令牌，只能查看个人信息
+	TokenScopePending = "pending"
+)
+
+// MFAEnrollment 用户的TOTP双因素认证设置
+type MFAEnrollment struct {
+	UserID             string     `json:"userId"`
+	Secret             string     `json:"secret"` // 加密后的TOTP密钥
+	Confirmed          bool       `json:"confirmed"`
+	RecoveryCodeHashes []string   `json:"recoveryCodeHashes,omitempty"` // 恢复码的bcrypt哈希，使用后删除
+	LastCounter        int64      `json:"lastCounter"`                  // 最近一次使用的TOTP时间步，防止验证码重放
+	CreatedAt          time.Time  `json:"createdAt"`
+	ConfirmedAt        *time.Time `json:"confirmedAt,omitempty"`
+}
+
+// MFAPolicy 双因素认证策略
+type MFAPolicy struct {
+	RequiredRoles []string `json:"requiredRoles"`
+}
+
+// MFAStatus 用户的双因素认证状态
+type MFAStatus struct {
+	Enabled                bool `json:"enabled"`
+	Required               bool `json:"required"`
+	RecoveryCodesRemaining int  `json:"recoveryCodesRemaining"`
+}
+
+// MFASetupResponse 开始启用双因素认证的响应
+type MFASetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"` // otpauth:// URI，前端编码为二维码
+}
+
+// MFACodeRequest 提交验证码的请求，code可以是TOTP验证码或恢复码
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFAConfirmResponse 确认启用双因素认证的响应
+type MFAConfirmResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`       // 只返回一次，请妥善保存
+	Token         string   `json:"token,omitempty"`     // 使用受限令牌确认时返回完整权限的访问令牌
+	ExpiresIn     int64    `json:"expiresIn,omitempty"` // 访问令牌有效期（秒）
+}
+
+// MFALoginRequest 登录第二步请求
+type MFALoginRequest struct {
+	MFAToken string `json:"mfaToken" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP验证码或恢复码
+}
+
+// MFAChallengeResponse 需要双因素认证时的登录响应
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+	ExpiresIn   int64  `json:"expiresIn"` // mfaToken有效期（秒）
+}
@@ -0,0 +1,67 @@
+package models
+
+// OIDCProviderConfig OpenID Connect身份提供方配置
+type OIDCProviderConfig struct {
+	ID           string           `json:"id"`   // 路由和用户关联使用的标识
+	Name         string           `json:"name"` // 登录页显示的名称
+	Issuer       string           `json:"issuer"`
+	ClientID     string           `json:"clientId"`
+	ClientSecret string           `json:"clientSecret,omitempty"`
+	RedirectURL  string           `json:"redirectUrl"` // 前端回调地址，前端将code和state提交给 POST /api/oidc/callback
+	Scopes       []string         `json:"scopes,omitempty"`
+	Claims       OIDCClaimMapping `json:"claims"`
+
+	// RoleMapping 将身份提供方的角色值映射为系统角色，未映射的值按系统角色名直接匹配
+	RoleMapping map[string]string `json:"roleMapping,omitempty"`
+	// DefaultRole 身份提供方没有返回可用角色时使用的角色，为空时拒绝登录
+	DefaultRole string `json:"defaultRole,omitempty"`
+	// DefaultHospital 身份提供方没有返回医院时使用的医院，通常为该身份提供方所属医院
+	DefaultHospital string `json:"defaultHospital,omitempty"`
+	// Hospitals 该身份提供方可以声明的医院，声明其他医院的用户不能登录
+	Hospitals []string `json:"hospitals"`
+	// DisableProvisioning 为true时不自动创建用户，只允许已关联的用户登录
+	DisableProvisioning bool `json:"disableProvisioning,omitempty"`
+}
+
+// OIDCClaimMapping ID令牌声明到用户字段的映射，支持用点号访问嵌套声明（如 realm_access.roles）
+type OIDCClaimMapping struct {
+	Username   string `json:"username,omitempty"` // 默认 preferred_username
+	Name       string `json:"name,omitempty"`     // 默认 name
+	Hospital   string `json:"hospital,omitempty"`
+	Department string `json:"department,omitempty"`
+	Role       string `json:"role,omitempty"`
+	PatientID  string `json:"patientId,omitempty"`
+}
+
+// OIDCProviderInfo 登录页展示的身份提供方信息
+type OIDCProviderInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// OIDCAuthorizeResponse 发起单点登录的响应
+type OIDCAuthorizeResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+	State            string `json:"state"`
+}
+
+// OIDCCallbackRequest 单点登录回调请求
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// OIDCIdentity 经过ID令牌验证并完成声明映射的外部身份
+type OIDCIdentity struct {
+	ProviderID string
+	Issuer     string
+	Subject    string
+	Username   string
+	Name       string
+	Hospital   string
+	Department string
+	Role       string
+	PatientID  string
+	AMR        []string // 认证方式，包含 mfa 等值时视为已在身份提供方完成多因素认证
+	CanCreate  bool     // 是否允许自动创建用户
+}
@@ -0,0 +1,29 @@
+package models
+
+// InstitutionRequest 医院入驻请求
+type InstitutionRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// HospitalAdminRequest 创建医院管理员请求
+type HospitalAdminRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+}
+
+// RegistrationApproval 审核通过注册用户的请求
+// 角色和科室为空时保留用户注册时填写的值
+type RegistrationApproval struct {
+	Role       string `json:"role,omitempty"`
+	Department string `json:"department,omitempty"`
+	Note       string `json:"note,omitempty"`
+
+	// 审核人已核实患者身份，启用注册时申请绑定的患者标识；为false时改用用户ID作为患者标识
+	VerifyPatientID bool `json:"verifyPatientId,omitempty"`
+}
+
+// RegistrationRejection 拒绝注册用户的请求
+type RegistrationRejection struct {
+	Reason string `json:"reason,omitempty"`
+}
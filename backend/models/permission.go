@@ -0,0 +1,121 @@
+package models
+
+// 用户角色
+const (
+	RoleDoctor        = "doctor"
+	RoleResearcher    = "researcher"
+	RolePatient       = "patient"
+	RoleAdmin         = "admin"
+	RoleHospitalAdmin = "hospital_admin" // 医院管理员，审核本医院的注册用户并签发执业凭证
+	RoleService       = "service"        // 服务账户，只能通过API密钥认证
+)
+
+// 权限
+const (
+	PermProfileRead         = "profile:read"         // 查看个人信息和站内通知
+	PermDataUpload          = "data:upload"          // 上传医疗数据
+	PermDataReadOwn         = "data:read:own"        // 读取本人上传或本人作为患者的数据
+	PermDataReadGranted     = "data:read:granted"    // 读取经知情同意或访问授权的数据
+	PermTransferCreate      = "transfer:create"      // 发起跨链转移
+	PermStatisticsRead      = "statistics:read"      // 查看平台统计
+	PermStatisticsAggregate = "statistics:aggregate" // 差分隐私聚合查询
+	PermCohortQuery         = "cohort:query"         // 队列可行性查询
+	PermConsentRead         = "consent:read"         // 查看与本人相关的知情同意
+	PermConsentManage       = "consent:manage"       // 授予和撤销知情同意
+	PermAccessRequest       = "access:request"       // 提交数据访问申请
+	PermAccessReview        = "access:review"        // 审批数据访问申请
+	PermAuditRead           = "audit:read"           // 查询全部审计日志并校验哈希链
+	PermAuditReadOwn        = "audit:read:own"       // 查询本人数据的访问审计记录
+	PermUserAdmin           = "user:admin"           // 用户与系统管理
+	PermCredentialIssue     = "credential:issue"     // 签发和撤销执业凭证
+	PermUserApprove         = "user:approve"         // 审核注册用户
+	PermTenantManage        = "tenant:manage"        // 管理本租户的科室、用户和共享授权
+)
+
+// AllPermissions 全部权限
+var AllPermissions = []string{
+	PermProfileRead,
+	PermDataUpload,
+	PermDataReadOwn,
+	PermDataReadGranted,
+	PermTransferCreate,
+	PermStatisticsRead,
+	PermStatisticsAggregate,
+	PermCohortQuery,
+	PermConsentRead,
+	PermConsentManage,
+	PermAccessRequest,
+	PermAccessReview,
+	PermAuditRead,
+	PermAuditReadOwn,
+	PermUserAdmin,
+	PermCredentialIssue,
+	PermUserApprove,
+	PermTenantManage,
+}
+
+// RolePermissions 角色到权限的映射
+var RolePermissions = map[string][]string{
+	RoleDoctor: {
+		PermProfileRead,
+		PermDataUpload,
+		PermDataReadOwn,
+		PermDataReadGranted,
+		PermTransferCreate,
+		PermStatisticsRead,
+		PermCohortQuery,
+		PermConsentRead,
+		PermAccessRequest,
+		PermAccessReview,
+	},
+	RoleResearcher: {
+		PermProfileRead,
+		PermDataReadGranted,
+		PermStatisticsRead,
+		PermStatisticsAggregate,
+		PermCohortQuery,
+		PermConsentRead,
+		PermAccessRequest,
+	},
+	RolePatient: {
+		PermProfileRead,
+		PermDataReadOwn,
+		PermConsentRead,
+		PermConsentManage,
+		PermAccessReview,
+		PermAuditReadOwn,
+	},
+	RoleAdmin: AllPermissions,
+	// 医院管理员只能管理本医院（租户）的用户
+	RoleHospitalAdmin: {
+		PermProfileRead,
+		PermUserApprove,
+		PermCredentialIssue,
+		PermTenantManage,
+	},
+	// 服务账户的权限上限，API密钥的权限范围只能从中选择
+	RoleService: {
+		PermDataUpload,
+		PermDataReadOwn,
+		PermDataReadGranted,
+		PermTransferCreate,
+		PermStatisticsRead,
+	},
+}
+
+// SelfRegistrableRoles 允许自助注册的角色，管理员只能由系统创建
+var SelfRegistrableRoles = map[string]bool{
+	RoleDoctor:     true,
+	RoleResearcher: true,
+	RolePatient:    true,
+}
+
+// HasPermission 检查角色是否拥有指定权限
+func HasPermission(role, permission string) bool {
+	for _, p := range RolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
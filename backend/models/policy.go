@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+)
+
+// 策略效果
+const (
+	PolicyEffectAllow         = "allow"
+	PolicyEffectDeny          = "deny"
+	PolicyEffectNotApplicable = "not_applicable"
+)
+
+// 策略控制的操作
+const (
+	PolicyActionRead     = "read"
+	PolicyActionUpload   = "upload"
+	PolicyActionTransfer = "transfer"
+)
+
+// Policy 基于属性的访问控制策略
+// 所有条件同时满足时策略生效，deny优先于allow
+type Policy struct {
+	ID          string            `json:"id"`
+	Description string            `json:"description"`
+	Effect      string            `json:"effect"`     // "allow" 或 "deny"
+	Actions     []string          `json:"actions"`    // 适用的操作: read, upload, transfer
+	Conditions  []PolicyCondition `json:"conditions"` // 条件列表，全部满足时策略适用
+	Source      string            `json:"source,omitempty"`
+}
+
+// PolicyCondition 策略条件
+// Attribute 形如 subject.hospital、resource.dataType、resource.metadata.project、environment.time
+type PolicyCondition struct {
+	Attribute string      `json:"attribute"`
+	Operator  string      `json:"operator"`           // eq, ne, in, not_in, contains, before, after, exists
+	Value     interface{} `json:"value,omitempty"`    // 比较的字面值
+	ValueRef  string      `json:"valueRef,omitempty"` // 比较的另一个属性，如 subject.hospital
+}
+
+// PolicyRequest 策略评估请求
+type PolicyRequest struct {
+	Action      string            `json:"action"`
+	Subject     map[string]string `json:"subject"`
+	Resource    map[string]string `json:"resource"`
+	Environment map[string]string `json:"environment"`
+}
+
+// PolicyDecision 策略评估结果
+type PolicyDecision struct {
+	Effect          string        `json:"effect"`          // allow, deny 或 not_applicable
+	MatchedPolicies []string      `json:"matchedPolicies"` // 生效的策略ID
+	Trace           []PolicyTrace `json:"trace,omitempty"` // 每条策略的评估过程
+}
+
+// PolicyTrace 单条策略的评估过程
+type PolicyTrace struct {
+	PolicyID   string           `json:"policyId"`
+	Effect     string           `json:"effect"`
+	Applicable bool             `json:"applicable"`
+	Reason     string           `json:"reason,omitempty"`
+	Conditions []ConditionTrace `json:"conditions,omitempty"`
+}
+
+// ConditionTrace 单个条件的评估过程
+type ConditionTrace struct {
+	Attribute string      `json:"attribute"`
+	Operator  string      `json:"operator"`
+	Expected  interface{} `json:"expected"`
+	Actual    string      `json:"actual"`
+	Matched   bool        `json:"matched"`
+}
+
+// PolicyExplainRequest 策略试运行请求
+// 以指定用户和数据为基础构建属性，Subject/Resource/Environment 中的值会覆盖自动生成的属性
+type PolicyExplainRequest struct {
+	Action      string            `json:"action" binding:"required"`
+	SubjectID   string            `json:"subjectId"`
+	RecordID    string            `json:"recordId"`
+	Purpose     string            `json:"purpose"`
+	Time        *time.Time        `json:"time"`
+	Subject     map[string]string `json:"subject"`
+	Resource    map[string]string `json:"resource"`
+	Environment map[string]string `json:"environment"`
+}
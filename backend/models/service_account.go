@@ -0,0 +1,93 @@
+package models
+
+import (
+	"time"
+)
+
+// APIKeyPrefix API密钥的固定前缀，完整格式为 "mcx_<标识>_<随机串>"
+const APIKeyPrefix = "mcx"
+
+// ServiceAccount 服务账户
+// 供医院HIS/PACS等系统调用接口，归属于某个医院，只能使用API密钥认证
+type ServiceAccount struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Hospital    string     `json:"hospital"` // 归属机构
+	Department  string     `json:"department,omitempty"`
+	Description string     `json:"description,omitempty"`
+	CreatedBy   string     `json:"createdBy"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	DisabledAt  *time.Time `json:"disabledAt,omitempty"`
+}
+
+// User 将服务账户映射为角色为service的用户，供访问控制和数据归属使用
+func (a *ServiceAccount) User() *User {
+	return &User{
+		ID:         a.ID,
+		Username:   "svc:" + a.ID,
+		Name:       a.Name,
+		Role:       RoleService,
+		Hospital:   a.Hospital,
+		Department: a.Department,
+		CreatedAt:  a.CreatedAt,
+		UpdatedAt:  a.CreatedAt,
+		Status:     UserStatusActive,
+	}
+}
+
+// APIKey 服务账户的API密钥，服务端只保存随机串的SHA-256哈希
+type APIKey struct {
+	ID               string     `json:"id"`
+	ServiceAccountID string     `json:"serviceAccountId"`
+	Name             string     `json:"name"`
+	Prefix           string     `json:"prefix"` // 明文保存的标识部分，用于查找和在界面上辨认密钥
+	SecretHash       string     `json:"secretHash"`
+	Scopes           []string   `json:"scopes"` // 密钥可使用的权限，不能超出service角色的权限
+	CreatedBy        string     `json:"createdBy"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt       *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIP       string     `json:"lastUsedIp,omitempty"`
+	RevokedAt        *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Active 检查密钥在指定时间是否有效
+func (k *APIKey) Active(at time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || at.Before(*k.ExpiresAt))
+}
+
+// APIKeyResponse API密钥信息（不包含哈希）
+type APIKeyResponse struct {
+	ID               string     `json:"id"`
+	ServiceAccountID string     `json:"serviceAccountId"`
+	Name             string     `json:"name"`
+	Prefix           string     `json:"prefix"`
+	Scopes           []string   `json:"scopes"`
+	CreatedBy        string     `json:"createdBy"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt       *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIP       string     `json:"lastUsedIp,omitempty"`
+	RevokedAt        *time.Time `json:"revokedAt,omitempty"`
+}
+
+// ServiceAccountRequest 创建服务账户请求
+type ServiceAccountRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Hospital    string `json:"hospital" binding:"required"`
+	Department  string `json:"department,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// APIKeyRequest 创建API密钥请求
+type APIKeyRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required"`
+	ExpiresInDays int      `json:"expiresInDays,omitempty" binding:"min=0"` // 为0时不过期
+}
+
+// APIKeyCreateResponse 创建API密钥的响应，完整密钥只返回这一次
+type APIKeyCreateResponse struct {
+	Key    string         `json:"key"`
+	APIKey APIKeyResponse `json:"apiKey"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// 会话的认证方式
+const (
+	AuthMethodPassword = "password"
+	AuthMethodOIDC     = "oidc"
+)
+
+// Session 登录会话
+// 每次登录创建一个会话，刷新令牌每次使用后轮换，服务端只保存令牌的哈希
+type Session struct {
+	ID               string     `json:"id"`
+	UserID           string     `json:"userId"`
+	RefreshTokenHash string     `json:"refreshTokenHash"`
+	UsedTokenHashes  []string   `json:"usedTokenHashes,omitempty"` // 已轮换的刷新令牌哈希，用于发现令牌重放
+	Scope            string     `json:"scope,omitempty"`           // 访问令牌的权限范围，为空表示完整权限
+	AuthMethod       string     `json:"authMethod,omitempty"`      // 认证方式，单点登录时为 oidc:<身份提供方ID>
+	MFA              bool       `json:"mfa,omitempty"`             // 是否完成了多因素认证（本地TOTP或身份提供方）
+	UserAgent        string     `json:"userAgent"`
+	ClientIP         string     `json:"clientIp"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	LastUsedAt       time.Time  `json:"lastUsedAt"`
+	ExpiresAt        time.Time  `json:"expiresAt"`
+	RevokedAt        *time.Time `json:"revokedAt,omitempty"`
+	RevokeReason     string     `json:"revokeReason,omitempty"`
+}
+
+// Active 检查会话在指定时间是否有效
+func (s *Session) Active(at time.Time) bool {
+	return s.RevokedAt == nil && at.Before(s.ExpiresAt)
+}
+
+// SessionResponse 会话信息（不包含令牌哈希）
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	AuthMethod string    `json:"authMethod,omitempty"`
+	MFA        bool      `json:"mfa"`
+	UserAgent  string    `json:"userAgent"`
+	ClientIP   string    `json:"clientIp"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+// TokenRefreshRequest 刷新令牌请求
+type TokenRefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// TokenResponse 令牌响应
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"` // 访问令牌有效期（秒）
+}
+
+// KeyRotateRequest 签名密钥轮换请求
+type KeyRotateRequest struct {
+	Alg string `json:"alg"` // EdDSA 或 RS256，默认EdDSA
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+)
+
+// TenantScopedRoles 只能访问所属租户数据的角色
+// 这些角色的用户所属医院尚未入驻（没有租户）时不能访问任何医疗数据
+var TenantScopedRoles = map[string]bool{
+	RoleDoctor:        true,
+	RoleResearcher:    true,
+	RoleHospitalAdmin: true,
+}
+
+// Tenant 租户，即入驻平台的医院或机构
+// 租户ID与医院did:web路径中的slug相同，用户和医疗数据都归属于一个租户
+type Tenant struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	DID         string    `json:"did"`
+	Departments []string  `json:"departments"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// HasDepartment 检查租户是否设置了该科室，未设置任何科室时不限制
+func (t *Tenant) HasDepartment(department string) bool {
+	if len(t.Departments) == 0 {
+		return true
+	}
+	for _, name := range t.Departments {
+		if name == department {
+			return true
+		}
+	}
+	return false
+}
+
+// TenantGrant 租户间的数据共享授权
+// 授权租户（数据所在的医院）允许被授权租户的用户读取其数据，读取时仍需满足知情同意或访问申请等记录级规则
+type TenantGrant struct {
+	ID             string     `json:"id"`
+	GrantorTenant  string     `json:"grantorTenant"`
+	GranteeTenant  string     `json:"granteeTenant"`
+	DataTypes      []string   `json:"dataTypes,omitempty"` // 为空表示所有数据类型
+	Purposes       []string   `json:"purposes,omitempty"`  // 为空表示所有使用目的
+	CreatedBy      string     `json:"createdBy"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	Revoked        bool       `json:"revoked"`
+	RevokedAt      *time.Time `json:"revokedAt,omitempty"`
+	RevokedBy      string     `json:"revokedBy,omitempty"`
+	RevocationNote string     `json:"revocationNote,omitempty"`
+}
+
+// Covers 检查授权在指定时间是否允许以该目的读取该类型的数据
+func (g *TenantGrant) Covers(dataType, purpose string, at time.Time) bool {
+	if g.Revoked || (g.ExpiresAt != nil && !at.Before(*g.ExpiresAt)) {
+		return false
+	}
+	return containsOrEmpty(g.DataTypes, dataType) && containsOrEmpty(g.Purposes, purpose)
+}
+
+// 列表为空或包含该值
+func containsOrEmpty(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// DepartmentRequest 新增科室请求
+type DepartmentRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// TenantUserUpdate 租户管理员调整用户科室的请求
+type TenantUserUpdate struct {
+	Department string `json:"department" binding:"required"`
+}
+
+// TenantGrantRequest 创建租户共享授权请求
+type TenantGrantRequest struct {
+	GranteeTenant string   `json:"granteeTenant" binding:"required"`
+	DataTypes     []string `json:"dataTypes,omitempty"`
+	Purposes      []string `json:"purposes,omitempty"`
+	ValidDays     int      `json:"validDays,omitempty"` // 有效天数，为0表示长期有效，直到撤销
+}
+
+// TenantGrantRevokeRequest 撤销租户共享授权请求
+type TenantGrantRevokeRequest struct {
+	Note string `json:"note,omitempty"`
+}
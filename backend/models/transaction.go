@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+)
+
+// 交易签名方式
+const (
+	SigningModeCustodial = "custodial" // 使用托管密钥库中的用户私钥签名
+	SigningModeClient    = "client"    // 返回待签名交易，由客户端签名后提交
+	SigningModeGateway   = "gateway"   // 没有区块链身份的用户（单点登录用户、服务账户）由网关账户代为提交
+)
+
+// EthereumTxParams 构造以太坊交易所需的链上参数，由网关提供
+type EthereumTxParams struct {
+	ChainID  int64  `json:"chainId"`
+	Nonce    uint64 `json:"nonce"`
+	GasPrice string `json:"gasPrice"` // 十进制，单位wei
+	GasLimit uint64 `json:"gasLimit"`
+	Contract string `json:"contract"` // MedicalData合约地址
+}
+
+// FabricTxParams 构造Fabric链码调用所需的参数，由网关提供
+type FabricTxParams struct {
+	Channel   string `json:"channel"`
+	Chaincode string `json:"chaincode"`
+}
+
+// FabricProposal 用户签名的Fabric链码调用
+type FabricProposal struct {
+	Channel   string   `json:"channel"`
+	Chaincode string   `json:"chaincode"`
+	Function  string   `json:"function"`
+	Args      []string `json:"args"`
+	MSPID     string   `json:"mspId"`
+	Creator   string   `json:"creator"` // 调用者的PEM证书
+	Nonce     string   `json:"nonce"`
+	Timestamp int64    `json:"timestamp"`
+
+	// 瞬态数据（键 -> base64），传给链码但不写入交易和区块，用于私有数据集合
+	Transient map[string]string `json:"transient,omitempty"`
+}
+
+// EthereumUnsignedTx 待签名的以太坊交易
+type EthereumUnsignedTx struct {
+	ChainID  int64  `json:"chainId"`
+	Nonce    uint64 `json:"nonce"`
+	GasPrice string `json:"gasPrice"`
+	GasLimit uint64 `json:"gasLimit"`
+	To       string `json:"to"`
+	Value    string `json:"value"`
+	Data     string `json:"data"` // 0x开头的ABI编码调用数据
+}
+
+// SignedTransaction 用户签名后交给网关转发的交易
+type SignedTransaction struct {
+	Chain  string `json:"chain"`
+	Signer string `json:"signer"` // 以太坊地址或Fabric身份标识
+
+	// 以太坊：0x开头的RLP编码签名交易
+	RawTransaction string `json:"rawTransaction,omitempty"`
+
+	// Fabric：提案的JSON编码（base64）和对其SHA-256摘要的DER格式ECDSA签名（base64）
+	Payload   string `json:"payload,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// RelayResponse 网关转发交易的结果
+type RelayResponse struct {
+	Success         bool   `json:"success"`
+	ID              string `json:"id"`
+	TransactionHash string `json:"transactionHash"`
+	From            string `json:"from"`
+	Message         string `json:"message"`
+}
+
+// UnsignedTransaction 客户端签名模式下返回的待签名交易
+type UnsignedTransaction struct {
+	ID          string              `json:"id"` // 待签名上传ID，提交签名时使用
+	Chain       string              `json:"chain"`
+	Signer      string              `json:"signer"`      // 必须使用该地址或身份对应的私钥签名
+	SigningHash string              `json:"signingHash"` // 以太坊为EIP-155签名哈希，Fabric为提案的SHA-256摘要
+	Ethereum    *EthereumUnsignedTx `json:"ethereum,omitempty"`
+	Payload     string              `json:"payload,omitempty"` // Fabric提案的JSON编码（base64）
+	ExpiresAt   time.Time           `json:"expiresAt"`
+}
+
+// TransactionSignatureRequest 客户端提交交易签名
+// 以太坊为65字节 r||s||v 的十六进制（v为0/1或27/28）；Fabric为DER格式ECDSA签名的base64
+type TransactionSignatureRequest struct {
+	Signature string `json:"signature" binding:"required"`
+}
+
+// WalletUnlockRequest 解锁托管密钥库
+type WalletUnlockRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// WalletStatus 当前用户的区块链身份和密钥库状态
+type WalletStatus struct {
+	Identity  *BlockchainIdentity `json:"identity,omitempty"`
+	Link      *IdentityLink       `json:"link,omitempty"` // 跨链身份关联记录，首次解锁密钥库前可能为空
+	Unlocked  bool                `json:"unlocked"`
+	ExpiresAt *time.Time          `json:"expiresAt,omitempty"`
+}
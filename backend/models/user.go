@@ -4,17 +4,56 @@ import (
 	"time"
 )
 
+// 用户账户状态
+const (
+	UserStatusPending  = "pending"  // 自助注册后等待医院管理员审核
+	UserStatusActive   = "active"   // 已审核或由系统创建
+	UserStatusRejected = "rejected" // 审核未通过，不能登录
+	UserStatusDisabled = "disabled" // 被管理员停用，不能登录
+)
+
 // User 用户模型
 type User struct {
 	ID         string    `json:"id"`
 	Username   string    `json:"username"`
 	Password   string    `json:"-"` // 密码不会在JSON中返回
 	Name       string    `json:"name"`
-	Role       string    `json:"role"` // 角色：doctor, researcher, admin等
+	Role       string    `json:"role"` // 角色：doctor, researcher, patient, admin等
 	Hospital   string    `json:"hospital,omitempty"`
 	Department string    `json:"department,omitempty"`
+	TenantID   string    `json:"tenantId,omitempty"`  // 所属租户（入驻医院），平台管理员、患者等不属于任何租户
+	PatientID  string    `json:"patientId,omitempty"` // 患者标识，仅患者用户有效，对应医疗数据元数据中的patientId
+	Email      string    `json:"email,omitempty"`     // 联系邮箱，用于接收密码重置等通知
 	CreatedAt  time.Time `json:"createdAt"`
 	UpdatedAt  time.Time `json:"updatedAt"`
+
+	// 账户审核状态，自助注册的账户审核通过前只能查看个人信息
+	Status     string     `json:"status"`
+	ReviewedBy string     `json:"reviewedBy,omitempty"`
+	ReviewedAt *time.Time `json:"reviewedAt,omitempty"`
+	ReviewNote string     `json:"reviewNote,omitempty"`
+
+	// 管理员停用账户的记录
+	DisabledAt    *time.Time `json:"disabledAt,omitempty"`
+	DisabledBy    string     `json:"disabledBy,omitempty"`
+	DisableReason string     `json:"disableReason,omitempty"`
+
+	// 最近一次修改或重置密码的时间
+	PasswordChangedAt *time.Time `json:"passwordChangedAt,omitempty"`
+
+	// 患者自助注册时申请绑定的患者标识，审核人核实身份后才转为PatientID
+	PendingPatientID string `json:"pendingPatientId,omitempty"`
+
+	// 通过单点登录创建的用户关联的外部身份，这类用户没有本地密码
+	ExternalIssuer  string `json:"externalIssuer,omitempty"`
+	ExternalSubject string `json:"externalSubject,omitempty"`
+
+	// 注册时生成的区块链身份，私钥保存在用户的加密密钥库中
+	EthereumAddress string `json:"ethereumAddress,omitempty"`
+	FabricID        string `json:"fabricId,omitempty"`
+
+	// 用户的去中心化标识符：有区块链身份时为did:key，否则为本平台的did:web
+	DID string `json:"did,omitempty"`
 }
 
 // UserLogin 用户登录请求
@@ -31,6 +70,8 @@ type UserRegister struct {
 	Role       string `json:"role" binding:"required"`
 	Hospital   string `json:"hospital,omitempty"`
 	Department string `json:"department,omitempty"`
+	PatientID  string `json:"patientId,omitempty"` // 患者标识，仅患者注册时使用
+	Email      string `json:"email,omitempty"`
 }
 
 // UserResponse 用户响应（不包含敏感信息）
@@ -41,11 +82,29 @@ type UserResponse struct {
 	Role       string    `json:"role"`
 	Hospital   string    `json:"hospital,omitempty"`
 	Department string    `json:"department,omitempty"`
+	TenantID   string    `json:"tenantId,omitempty"`
+	PatientID  string    `json:"patientId,omitempty"`
+	Email      string    `json:"email,omitempty"`
 	CreatedAt  time.Time `json:"createdAt"`
+	Status     string    `json:"status"`
+
+	EthereumAddress string `json:"ethereumAddress,omitempty"` // 以太坊账户地址
+	FabricID        string `json:"fabricId,omitempty"`        // Fabric身份标识
+	DID             string `json:"did,omitempty"`             // 去中心化标识符，作为数据在两条链上的所有者
+
+	PendingPatientID string `json:"pendingPatientId,omitempty"` // 等待审核人核实的患者标识
 }
 
 // LoginResponse 登录响应
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  UserResponse `json:"user"`
+	Token        string       `json:"token"`        // 短期访问令牌
+	RefreshToken string       `json:"refreshToken"` // 刷新令牌，使用后轮换
+	ExpiresIn    int64        `json:"expiresIn"`    // 访问令牌有效期（秒）
+	User         UserResponse `json:"user"`
+
+	// 角色要求双因素认证但用户尚未启用时为true，此时令牌只能用于启用双因素认证
+	MFAEnrollmentRequired bool `json:"mfaEnrollmentRequired,omitempty"`
+
+	// 账户尚未通过审核时为true，此时令牌只能用于查看个人信息
+	ApprovalPending bool `json:"approvalPending,omitempty"`
 }
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+var (
+	// ErrAccessRequestNotFound 访问申请不存在
+	ErrAccessRequestNotFound = errors.New("访问申请不存在")
+	// ErrAccessRequestForbidden 无权审批该访问申请
+	ErrAccessRequestForbidden = errors.New("无权审批该访问申请")
+)
+
+// accessRequestStore 访问申请的持久化结构
+type accessRequestStore struct {
+	Requests map[string]*models.AccessRequest `json:"requests"`
+	Grants   map[string]*models.AccessGrant   `json:"grants"`
+}
+
+// AccessRequestService 数据访问申请与审批服务
+// 审批通过后签发限时授权，数据详情和文件下载均以该授权为准
+type AccessRequestService struct {
+	mu             sync.RWMutex
+	store          accessRequestStore
+	storePath      string
+	maxHours       int // 单次申请允许的最长访问时长
+	dataService    *DataService
+	gatewayService *GatewayService
+	userService    *UserService
+	notifier       Notifier
+}
+
+// NewAccessRequestService 创建新的访问申请服务
+func NewAccessRequestService(dataService *DataService, gatewayService *GatewayService, userService *UserService, notifier Notifier) *AccessRequestService {
+	maxHours := 720
+	if v, err := strconv.Atoi(os.Getenv("ACCESS_GRANT_MAX_HOURS")); err == nil && v > 0 {
+		maxHours = v
+	}
+
+	service := &AccessRequestService{
+		store: accessRequestStore{
+			Requests: make(map[string]*models.AccessRequest),
+			Grants:   make(map[string]*models.AccessGrant),
+		},
+		storePath:      utils.DataFilePath("access_requests.json"),
+		maxHours:       maxHours,
+		dataService:    dataService,
+		gatewayService: gatewayService,
+		userService:    userService,
+		notifier:       notifier,
+	}
+
+	// 加载已持久化的访问申请
+	if err := utils.LoadJSONFile(service.storePath, &service.store); err != nil {
+		log.Printf("加载访问申请失败: %v", err)
+	}
+	if service.store.Requests == nil {
+		service.store.Requests = make(map[string]*models.AccessRequest)
+	}
+	if service.store.Grants == nil {
+		service.store.Grants = make(map[string]*models.AccessGrant)
+	}
+
+	return service
+}
+
+// CreateRequest 创建访问申请并通知数据所有者
+func (s *AccessRequestService) CreateRequest(requesterID string, req models.AccessRequestCreate) (*models.AccessRequest, error) {
+	if !models.ValidPurposes[req.Purpose] {
+		return nil, fmt.Errorf("不支持的使用目的: %s", req.Purpose)
+	}
+	if req.DurationHours <= 0 || req.DurationHours > s.maxHours {
+		return nil, fmt.Errorf("访问时长必须在1到%d小时之间", s.maxHours)
+	}
+
+	record, err := s.findRecord(req.RecordID)
+	if err != nil {
+		return nil, ErrAccessRequestNotFound
+	}
+	ownerID, exists := s.userService.ResolveOwner(record.Owner)
+	if !exists {
+		ownerID = record.Owner
+	}
+	if ownerID == requesterID {
+		return nil, errors.New("不能申请访问自己的数据")
+	}
+
+	patientID := metadataString(parseMetadata(record.Metadata), "patientId")
+
+	s.mu.Lock()
+	for _, existing := range s.store.Requests {
+		if existing.RecordID == req.RecordID && existing.RequesterID == requesterID && existing.Status == models.AccessRequestPending {
+			s.mu.Unlock()
+			return nil, errors.New("已有待审批的访问申请")
+		}
+	}
+
+	request := &models.AccessRequest{
+		ID:            uuid.New().String(),
+		RecordID:      record.ID,
+		RecordChain:   record.Chain,
+		RequesterID:   requesterID,
+		OwnerID:       ownerID,
+		PatientID:     patientID,
+		Purpose:       req.Purpose,
+		DurationHours: req.DurationHours,
+		Message:       req.Message,
+		Status:        models.AccessRequestPending,
+		CreatedAt:     time.Now(),
+	}
+	s.store.Requests[request.ID] = request
+
+	if err := s.saveLocked(); err != nil {
+		delete(s.store.Requests, request.ID)
+		s.mu.Unlock()
+		return nil, err
+	}
+	created := *request
+	s.mu.Unlock()
+
+	// 通知数据所有者和患者本人
+	message := fmt.Sprintf("用户 %s 申请以 %s 目的访问数据 %s，时长 %d 小时。", requesterID, req.Purpose, record.ID, req.DurationHours)
+	for _, approverID := range s.approvers(&created) {
+		if err := s.notifier.Notify(approverID, "新的数据访问申请", message); err != nil {
+			log.Printf("发送访问申请通知失败: %v", err)
+		}
+	}
+
+	log.Printf("访问申请已创建: ID=%s, 数据=%s, 申请人=%s", created.ID, created.RecordID, requesterID)
+	return &created, nil
+}
+
+// Approve 批准访问申请并签发限时授权
+func (s *AccessRequestService) Approve(approverID, requestID, reason string) (*models.AccessRequest, error) {
+	return s.decide(approverID, requestID, reason, true)
+}
+
+// Deny 拒绝访问申请，必须说明理由
+func (s *AccessRequestService) Deny(approverID, requestID, reason string) (*models.AccessRequest, error) {
+	if reason == "" {
+		return nil, errors.New("拒绝访问申请必须说明理由")
+	}
+	return s.decide(approverID, requestID, reason, false)
+}
+
+// 处理审批
+func (s *AccessRequestService) decide(approverID, requestID, reason string, approve bool) (*models.AccessRequest, error) {
+	s.mu.Lock()
+	request, exists := s.store.Requests[requestID]
+	if !exists {
+		s.mu.Unlock()
+		return nil, ErrAccessRequestNotFound
+	}
+	if !containsString(s.approvers(request), approverID) {
+		s.mu.Unlock()
+		return nil, ErrAccessRequestForbidden
+	}
+	if request.Status != models.AccessRequestPending {
+		s.mu.Unlock()
+		return nil, errors.New("访问申请已处理")
+	}
+
+	previous := *request
+	now := time.Now()
+	request.DecisionBy = approverID
+	request.DecisionNote = reason
+	request.DecidedAt = &now
+
+	var grant *models.AccessGrant
+	if approve {
+		grant = &models.AccessGrant{
+			ID:        uuid.New().String(),
+			RequestID: request.ID,
+			RecordID:  request.RecordID,
+			GranteeID: request.RequesterID,
+			Purpose:   request.Purpose,
+			IssuedBy:  approverID,
+			IssuedAt:  now,
+			ExpiresAt: now.Add(time.Duration(request.DurationHours) * time.Hour),
+		}
+		s.store.Grants[grant.ID] = grant
+		request.Status = models.AccessRequestApproved
+		request.GrantID = grant.ID
+		request.GrantExpires = &grant.ExpiresAt
+	} else {
+		request.Status = models.AccessRequestDenied
+	}
+
+	if err := s.saveLocked(); err != nil {
+		*request = previous
+		if grant != nil {
+			delete(s.store.Grants, grant.ID)
+		}
+		s.mu.Unlock()
+		return nil, err
+	}
+	decided := *request
+	s.mu.Unlock()
+
+	// 通知申请人
+	subject := "数据访问申请已被拒绝"
+	message := fmt.Sprintf("您对数据 %s 的访问申请已被拒绝，理由: %s", decided.RecordID, reason)
+	if approve {
+		subject = "数据访问申请已批准"
+		message = fmt.Sprintf("您对数据 %s 的访问申请已批准，授权有效期至 %s。", decided.RecordID, decided.GrantExpires.Format(time.RFC3339))
+	}
+	if err := s.notifier.Notify(decided.RequesterID, subject, message); err != nil {
+		log.Printf("发送审批结果通知失败: %v", err)
+	}
+
+	log.Printf("访问申请已处理: ID=%s, 状态=%s, 审批人=%s", decided.ID, decided.Status, approverID)
+	return &decided, nil
+}
+
+// HasActiveGrant 检查用户是否持有某条数据在指定目的下的有效授权
+func (s *AccessRequestService) HasActiveGrant(userID, recordID, purpose string, at time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, grant := range s.store.Grants {
+		if grant.GranteeID == userID && grant.RecordID == recordID && grant.Purpose == purpose && grant.Active(at) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ListByRecord 获取某条数据的访问申请历史
+// 数据所有者和患者本人可以看到全部申请，其他用户只能看到自己的申请
+func (s *AccessRequestService) ListByRecord(userID, recordID string) []models.AccessRequest {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	requests := []models.AccessRequest{}
+	for _, request := range s.store.Requests {
+		if request.RecordID != recordID {
+			continue
+		}
+		if request.RequesterID == userID || containsString(s.approvers(request), userID) {
+			requests = append(requests, *request)
+		}
+	}
+
+	sortAccessRequests(requests)
+	return requests
+}
+
+// ListByUser 获取用户提交的以及需要用户审批的访问申请
+func (s *AccessRequestService) ListByUser(userID string) (submitted []models.AccessRequest, received []models.AccessRequest) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	submitted = []models.AccessRequest{}
+	received = []models.AccessRequest{}
+	for _, request := range s.store.Requests {
+		if request.RequesterID == userID {
+			submitted = append(submitted, *request)
+		}
+		if containsString(s.approvers(request), userID) {
+			received = append(received, *request)
+		}
+	}
+
+	sortAccessRequests(submitted)
+	sortAccessRequests(received)
+	return submitted, received
+}
+
+// 获取有权审批申请的用户：数据所有者和患者本人
+func (s *AccessRequestService) approvers(request *models.AccessRequest) []string {
+	approvers := []string{request.OwnerID}
+	if request.PatientID == "" {
+		return approvers
+	}
+	if patient, err := s.userService.GetUserByPatientID(request.PatientID); err == nil && patient.ID != request.OwnerID {
+		approvers = append(approvers, patient.ID)
+	}
+	return approvers
+}
+
+// 查找数据，本地不存在时从区块链获取
+func (s *AccessRequestService) findRecord(recordID string) (*models.MedicalData, error) {
+	if record, err := s.dataService.GetDataByID(recordID); err == nil {
+		return record, nil
+	}
+	return s.gatewayService.GetDataByID(recordID)
+}
+
+// 持久化访问申请（调用方需持有锁）
+func (s *AccessRequestService) saveLocked() error {
+	if err := utils.SaveJSONFile(s.storePath, s.store); err != nil {
+		log.Printf("保存访问申请失败: %v", err)
+		return fmt.Errorf("保存访问申请失败: %w", err)
+	}
+	return nil
+}
+
+// 按创建时间倒序排列访问申请
+func sortAccessRequests(requests []models.AccessRequest) {
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].CreatedAt.After(requests[j].CreatedAt)
+	})
+}
@@ -0,0 +1,409 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"sync"
+	"time"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// 账户管理错误
+var (
+	ErrUserNotFound            = errors.New("用户不存在")
+	ErrPasswordIncorrect       = errors.New("当前密码错误")
+	ErrPasswordTooShort        = fmt.Errorf("新密码长度不能少于%d位", models.PasswordMinLength)
+	ErrNoLocalPassword         = errors.New("单点登录用户没有本地密码，请在身份提供方修改密码")
+	ErrResetTokenInvalid       = errors.New("重置令牌无效或已过期")
+	ErrRoleNotAssignable       = errors.New("不支持设置该角色")
+	ErrCannotModifySelf        = errors.New("不能修改自己的角色或账户状态")
+	ErrProfileHospitalLocked   = errors.New("该用户不能自行修改所属医院")
+	ErrProfileDepartmentLocked = errors.New("科室由医院管理员调整，不能自行修改")
+	ErrEmailInvalid            = errors.New("邮箱格式无效")
+	ErrUserNotDisabled         = errors.New("用户未被停用")
+)
+
+// AccountService 账户管理服务
+// 提供用户自助修改资料和密码、通过一次性令牌重置密码，以及平台管理员查询、停用和调整用户角色
+type AccountService struct {
+	mu                  sync.Mutex
+	userService         *UserService
+	sessionService      *SessionService
+	identityService     *IdentityService
+	tenantService       *TenantService
+	onboardingService   *OnboardingService
+	notificationService *NotificationService
+	resetNotifier       Notifier // 投递密码重置令牌，用户无法登录，不能使用站内通知
+	resetTTL            time.Duration
+	resetTokens         map[string]*models.PasswordResetToken // 令牌哈希 -> 令牌
+	storePath           string
+}
+
+// NewAccountService 创建新的账户管理服务
+func NewAccountService(userService *UserService, sessionService *SessionService, identityService *IdentityService, tenantService *TenantService, onboardingService *OnboardingService, notificationService *NotificationService, resetNotifier Notifier) *AccountService {
+	service := &AccountService{
+		userService:         userService,
+		sessionService:      sessionService,
+		identityService:     identityService,
+		tenantService:       tenantService,
+		onboardingService:   onboardingService,
+		notificationService: notificationService,
+		resetNotifier:       resetNotifier,
+		resetTTL:            30 * time.Minute,
+		resetTokens:         make(map[string]*models.PasswordResetToken),
+		storePath:           utils.DataFilePath("password_resets.json"),
+	}
+
+	// 从环境变量获取重置令牌有效期，默认为30分钟
+	if d, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_TTL")); err == nil && d > 0 {
+		service.resetTTL = d
+	}
+
+	// 加载未使用的重置令牌
+	if err := utils.LoadJSONFile(service.storePath, &service.resetTokens); err != nil {
+		log.Printf("加载密码重置令牌失败: %v", err)
+	}
+	if service.resetTokens == nil {
+		service.resetTokens = make(map[string]*models.PasswordResetToken)
+	}
+
+	return service
+}
+
+// UpdateProfile 用户修改个人资料
+// 科室决定访问策略和执业凭证的内容，只能由医院管理员调整，或随修改医院一起提交由新医院重新审核；
+// 修改医院需选择已入驻的医院，医生和研究人员修改医院后需由新医院重新审核
+func (s *AccountService) UpdateProfile(userID string, req models.ProfileUpdate) (*models.User, error) {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if req.Email != "" {
+		if _, err := mail.ParseAddress(req.Email); err != nil {
+			return nil, ErrEmailInvalid
+		}
+	}
+
+	hospitalChanged := req.Hospital != "" && req.Hospital != user.Hospital
+	if hospitalChanged {
+		// 医院管理员的医院由平台管理员指定，单点登录用户的医院由身份提供方同步
+		if user.Role == models.RoleHospitalAdmin || user.ExternalSubject != "" {
+			return nil, ErrProfileHospitalLocked
+		}
+		if !s.onboardingService.IsInstitutionRegistered(req.Hospital) {
+			return nil, ErrHospitalNotRegistered
+		}
+	}
+	if req.Department != "" && req.Department != user.Department &&
+		!(hospitalChanged && models.CredentialRequiredRoles[user.Role]) {
+		return nil, ErrProfileDepartmentLocked
+	}
+
+	user, err = s.userService.UpdateProfile(userID, req)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if hospitalChanged && models.CredentialRequiredRoles[user.Role] {
+		user, err = s.userService.SetStatus(userID, models.UserStatusPending, userID, "")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.sessionService.RevokeAll(userID, "hospital_changed"); err != nil {
+			log.Printf("撤销用户会话失败: 用户=%s, 错误=%v", userID, err)
+		}
+		s.onboardingService.SubmitRegistration(user)
+		log.Printf("用户修改所属医院，等待重新审核: 用户=%s, 医院=%s", userID, user.Hospital)
+	} else if hospitalChanged {
+		s.tenantService.UserTenant(userID)
+	}
+
+	return user, nil
+}
+
+// ChangePassword 验证当前密码后修改密码
+// 密钥库同时改用新密码加密；当前会话保留，其他会话全部撤销
+func (s *AccountService) ChangePassword(userID, sessionID, currentPassword, newPassword string) error {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if user.Password == "" {
+		return ErrNoLocalPassword
+	}
+	if !utils.CheckPasswordHash(currentPassword, user.Password) {
+		return ErrPasswordIncorrect
+	}
+	if len(newPassword) < models.PasswordMinLength {
+		return ErrPasswordTooShort
+	}
+
+	// 密钥库仍用重置前的旧密码加密时无法用当前密码解锁，保持不变，可通过恢复接口处理
+	err = s.identityService.ChangeWalletPassword(userID, currentPassword, newPassword)
+	switch {
+	case err == nil, errors.Is(err, ErrIdentityNotFound):
+	case errors.Is(err, ErrWalletLocked):
+		log.Printf("密钥库不是用当前密码加密，未重新加密: 用户=%s", userID)
+	default:
+		return err
+	}
+
+	if err := s.userService.SetPassword(userID, newPassword); err != nil {
+		return err
+	}
+	if _, err := s.sessionService.RevokeOthers(userID, sessionID, "password_changed"); err != nil {
+		log.Printf("撤销用户其他会话失败: 用户=%s, 错误=%v", userID, err)
+	}
+
+	log.Printf("用户修改密码: 用户=%s", userID)
+	s.notify(userID, "密码已修改", "您的密码已修改，其他设备上的登录已失效。如非本人操作，请立即联系管理员")
+	return nil
+}
+
+// RequestPasswordReset 为用户生成一次性密码重置令牌并通过站外通知投递
+// 用户不存在、已停用或没有本地密码时不做任何处理，调用方对外返回相同的结果，避免泄露用户是否存在
+func (s *AccountService) RequestPasswordReset(username string) error {
+	user, err := s.userService.GetUserByUsername(username)
+	if err != nil || user.Password == "" || user.Status == models.UserStatusDisabled || user.Status == models.UserStatusRejected {
+		return nil
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	now := time.Now()
+	expiresAt := now.Add(s.resetTTL)
+
+	s.mu.Lock()
+	// 新令牌生成后之前的令牌失效
+	for hash, existing := range s.resetTokens {
+		if existing.UserID == user.ID || now.After(existing.ExpiresAt) {
+			delete(s.resetTokens, hash)
+		}
+	}
+	s.resetTokens[hashTokenSecret(token)] = &models.PasswordResetToken{
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+	err = s.saveLocked()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("您的密码重置令牌为 %s ，%s前有效，只能使用一次。如非本人操作，请忽略本通知", token, expiresAt.Format("2006-01-02 15:04:05"))
+	if err := s.resetNotifier.Notify(user.ID, "重置密码", message); err != nil {
+		log.Printf("投递密码重置令牌失败: 用户=%s, 错误=%v", user.ID, err)
+		return err
+	}
+
+	log.Printf("生成密码重置令牌: 用户=%s, 过期时间=%s", user.ID, expiresAt.Format(time.RFC3339))
+	return nil
+}
+
+// ResetPassword 使用重置令牌设置新密码，令牌使用后立即失效，用户的全部会话被撤销
+// 密钥库在内存中已解锁时改用新密码加密，否则仍用旧密码加密，需通过恢复接口用旧密码恢复
+func (s *AccountService) ResetPassword(token, newPassword string) error {
+	if len(newPassword) < models.PasswordMinLength {
+		return ErrPasswordTooShort
+	}
+
+	hash := hashTokenSecret(token)
+
+	s.mu.Lock()
+	reset, exists := s.resetTokens[hash]
+	if exists {
+		delete(s.resetTokens, hash)
+		if err := s.saveLocked(); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	s.mu.Unlock()
+
+	if !exists || time.Now().After(reset.ExpiresAt) {
+		return ErrResetTokenInvalid
+	}
+	userID := reset.UserID
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil || user.Status == models.UserStatusDisabled {
+		return ErrResetTokenInvalid
+	}
+
+	err = s.identityService.RewrapOpenWallet(userID, newPassword)
+	switch {
+	case err == nil, errors.Is(err, ErrIdentityNotFound):
+	case errors.Is(err, ErrWalletNotOpen):
+		log.Printf("重置密码时密钥库未解锁，仍使用旧密码加密: 用户=%s", userID)
+	default:
+		return err
+	}
+
+	if err := s.userService.SetPassword(userID, newPassword); err != nil {
+		return err
+	}
+	if _, err := s.sessionService.RevokeAll(userID, "password_reset"); err != nil {
+		log.Printf("撤销用户会话失败: 用户=%s, 错误=%v", userID, err)
+	}
+
+	log.Printf("用户重置密码: 用户=%s", userID)
+	s.notify(userID, "密码已重置", "您的密码已通过重置令牌修改，所有登录已失效。如非本人操作，请立即联系管理员")
+	return nil
+}
+
+// RecoverWallet 重置密码后使用旧密码解锁密钥库，改用当前登录密码加密并保持解锁，返回过期时间
+func (s *AccountService) RecoverWallet(userID, oldPassword, password string) (time.Time, error) {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return time.Time{}, ErrUserNotFound
+	}
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return time.Time{}, ErrPasswordIncorrect
+	}
+
+	if err := s.identityService.ChangeWalletPassword(userID, oldPassword, password); err != nil {
+		return time.Time{}, err
+	}
+	return s.identityService.OpenWallet(userID, password)
+}
+
+// ListUsers 按条件分页查询用户，默认每页20条
+func (s *AccountService) ListUsers(query models.UserListQuery) ([]*models.User, int) {
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 || query.PageSize > 100 {
+		query.PageSize = 20
+	}
+	return s.userService.ListUsers(query)
+}
+
+// SetRole 平台管理员调整用户角色，调整后撤销用户的全部会话，重新登录后按新角色授权
+func (s *AccountService) SetRole(adminID, userID, role string) (*models.User, error) {
+	if !models.AssignableRoles[role] {
+		return nil, ErrRoleNotAssignable
+	}
+	user, err := s.managedUser(adminID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == role {
+		return user, nil
+	}
+	// 医院管理员只能管理本租户，必须属于已入驻的医院
+	if role == models.RoleHospitalAdmin && s.tenantService.UserTenant(userID) == "" {
+		return nil, ErrTenantRequired
+	}
+
+	previous := user.Role
+	user, err = s.userService.SetRole(userID, role)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if _, err := s.sessionService.RevokeAll(userID, "role_changed"); err != nil {
+		log.Printf("撤销用户会话失败: 用户=%s, 错误=%v", userID, err)
+	}
+	if models.HasPermission(previous, models.PermAuditRead) != models.HasPermission(role, models.PermAuditRead) {
+		s.syncAuditor(user)
+	}
+
+	log.Printf("调整用户角色: 用户=%s, %s -> %s, 操作人=%s", userID, previous, role, adminID)
+	s.notify(userID, "角色已调整", fmt.Sprintf("您的角色已由%s调整为%s，请重新登录", previous, role))
+	return user, nil
+}
+
+// Disable 平台管理员停用用户，停用后撤销全部会话并锁定密钥库，用户不能登录
+func (s *AccountService) Disable(adminID, userID, reason string) (*models.User, error) {
+	if _, err := s.managedUser(adminID, userID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.SetStatus(userID, models.UserStatusDisabled, adminID, reason)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if _, err := s.sessionService.RevokeAll(userID, "account_disabled"); err != nil {
+		log.Printf("撤销用户会话失败: 用户=%s, 错误=%v", userID, err)
+	}
+	s.identityService.CloseWallet(userID)
+	if models.HasPermission(user.Role, models.PermAuditRead) {
+		s.syncAuditor(user)
+	}
+
+	log.Printf("停用用户: 用户=%s, 操作人=%s", userID, adminID)
+	message := "您的账户已被管理员停用"
+	if reason != "" {
+		message += "，原因：" + reason
+	}
+	s.notify(userID, "账户已停用", message)
+	return user, nil
+}
+
+// Enable 平台管理员重新启用被停用的用户
+func (s *AccountService) Enable(adminID, userID string) (*models.User, error) {
+	user, err := s.managedUser(adminID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != models.UserStatusDisabled {
+		return nil, ErrUserNotDisabled
+	}
+
+	user, err = s.userService.SetStatus(userID, models.UserStatusActive, adminID, "")
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if models.HasPermission(user.Role, models.PermAuditRead) {
+		s.syncAuditor(user)
+	}
+
+	log.Printf("启用用户: 用户=%s, 操作人=%s", userID, adminID)
+	s.notify(userID, "账户已启用", "您的账户已重新启用")
+	return user, nil
+}
+
+// 获取管理员要修改的用户，不能修改自己和服务账户
+func (s *AccountService) managedUser(adminID, userID string) (*models.User, error) {
+	if adminID == userID {
+		return nil, ErrCannotModifySelf
+	}
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil || user.Role == models.RoleService {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// 同步链码的审计员登记表，失败时只记录日志，需要管理员重新调整角色或状态后重试
+func (s *AccountService) syncAuditor(user *models.User) {
+	if err := s.identityService.SyncAuditor(user); err != nil {
+		log.Printf("警告: 用户的链上审计权限未同步: 用户=%s, 错误=%v", user.ID, err)
+	}
+}
+
+// 发送站内通知，失败时只记录日志
+func (s *AccountService) notify(userID, subject, message string) {
+	if err := s.notificationService.Notify(userID, subject, message); err != nil {
+		log.Printf("发送账户通知失败: 用户=%s, 错误=%v", userID, err)
+	}
+}
+
+// 持久化重置令牌，调用方需持有锁
+func (s *AccountService) saveLocked() error {
+	if err := utils.SaveJSONFile(s.storePath, s.resetTokens); err != nil {
+		log.Printf("保存密码重置令牌失败: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"medcross/models"
+)
+
+// 记录站外通知中的密码重置令牌
+type resetTokenRecorder struct {
+	tokens []string
+}
+
+var resetTokenPattern = regexp.MustCompile(`令牌为 (\S+) ，`)
+
+func (r *resetTokenRecorder) Notify(userID, subject, message string) error {
+	if match := resetTokenPattern.FindStringSubmatch(message); match != nil {
+		r.tokens = append(r.tokens, match[1])
+	}
+	return nil
+}
+
+type accountFixture struct {
+	service        *AccountService
+	userService    *UserService
+	sessionService *SessionService
+	notifier       *resetTokenRecorder
+	userID         string
+}
+
+func newAccountFixture(t *testing.T) *accountFixture {
+	t.Helper()
+
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "Adm1n-Passw0rd")
+
+	userService := NewUserService()
+	userID, err := userService.CreateUser(models.UserRegister{Username: "patient", Password: "password123", Name: "patient", Role: models.RolePatient})
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+
+	fixture := &accountFixture{
+		userService:    userService,
+		sessionService: NewSessionService(),
+		notifier:       &resetTokenRecorder{},
+		userID:         userID,
+	}
+	fixture.service = fixture.newService()
+	return fixture
+}
+
+// 使用同一数据目录创建账户服务，模拟服务重启
+func (f *accountFixture) newService() *AccountService {
+	return NewAccountService(f.userService, f.sessionService, NewIdentityService(nil, nil), nil, nil, NewNotificationService(), f.notifier)
+}
+
+func (f *accountFixture) requestReset(t *testing.T) string {
+	t.Helper()
+
+	if err := f.service.RequestPasswordReset("patient"); err != nil {
+		t.Fatalf("申请重置密码失败: %v", err)
+	}
+	if len(f.notifier.tokens) == 0 {
+		t.Fatal("没有投递重置令牌")
+	}
+	return f.notifier.tokens[len(f.notifier.tokens)-1]
+}
+
+func TestResetPasswordTokenSingleUse(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T, f *accountFixture) string // 返回最终使用的令牌
+		wantErr     error
+		wantChanged bool
+	}{
+		{
+			name:        "首次使用",
+			setup:       func(t *testing.T, f *accountFixture) string { return f.requestReset(t) },
+			wantChanged: true,
+		},
+		{
+			name: "再次使用同一令牌",
+			setup: func(t *testing.T, f *accountFixture) string {
+				token := f.requestReset(t)
+				if err := f.service.ResetPassword(token, "first-new-password"); err != nil {
+					t.Fatalf("首次重置密码失败: %v", err)
+				}
+				return token
+			},
+			wantErr: ErrResetTokenInvalid,
+		},
+		{
+			name: "重启后再次使用同一令牌",
+			setup: func(t *testing.T, f *accountFixture) string {
+				token := f.requestReset(t)
+				if err := f.service.ResetPassword(token, "first-new-password"); err != nil {
+					t.Fatalf("首次重置密码失败: %v", err)
+				}
+				f.service = f.newService()
+				return token
+			},
+			wantErr: ErrResetTokenInvalid,
+		},
+		{
+			name: "重启后使用未使用的令牌",
+			setup: func(t *testing.T, f *accountFixture) string {
+				token := f.requestReset(t)
+				f.service = f.newService()
+				return token
+			},
+			wantChanged: true,
+		},
+		{
+			name: "新令牌使之前的令牌失效",
+			setup: func(t *testing.T, f *accountFixture) string {
+				token := f.requestReset(t)
+				f.requestReset(t)
+				return token
+			},
+			wantErr: ErrResetTokenInvalid,
+		},
+		{
+			name: "新密码过短不消耗令牌",
+			setup: func(t *testing.T, f *accountFixture) string {
+				token := f.requestReset(t)
+				if err := f.service.ResetPassword(token, "short"); !errors.Is(err, ErrPasswordTooShort) {
+					t.Fatalf("新密码过短错误 = %v, 期望 %v", err, ErrPasswordTooShort)
+				}
+				return token
+			},
+			wantChanged: true,
+		},
+		{
+			name: "令牌已过期",
+			setup: func(t *testing.T, f *accountFixture) string {
+				token := f.requestReset(t)
+				for _, reset := range f.service.resetTokens {
+					reset.ExpiresAt = time.Now().Add(-time.Minute)
+				}
+				return token
+			},
+			wantErr: ErrResetTokenInvalid,
+		},
+		{
+			name:    "未知令牌",
+			setup:   func(t *testing.T, f *accountFixture) string { return "unknown-token" },
+			wantErr: ErrResetTokenInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAccountFixture(t)
+			session, _, err := f.sessionService.CreateSession(models.Session{UserID: f.userID})
+			if err != nil {
+				t.Fatalf("创建会话失败: %v", err)
+			}
+
+			token := tt.setup(t, f)
+			if err := f.service.ResetPassword(token, "new-password-123"); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResetPassword 错误 = %v, 期望 %v", err, tt.wantErr)
+			}
+
+			_, err = f.userService.VerifyUser("patient", "new-password-123")
+			if changed := err == nil; changed != tt.wantChanged {
+				t.Errorf("密码已修改 = %v, 期望 %v", changed, tt.wantChanged)
+			}
+			if tt.wantChanged && f.sessionService.IsActive(session.ID) {
+				t.Error("重置密码后应撤销用户的全部会话")
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// 服务账户和API密钥相关错误
+var (
+	ErrServiceAccountNotFound = errors.New("服务账户不存在")
+	ErrServiceAccountDisabled = errors.New("服务账户已停用")
+	ErrAPIKeyNotFound         = errors.New("API密钥不存在")
+	ErrAPIKeyInvalid          = errors.New("API密钥无效或已过期")
+	ErrAPIKeyInvalidScope     = errors.New("API密钥权限范围无效")
+)
+
+// 最近使用时间的持久化间隔，避免每个请求都写文件
+const apiKeyLastUsedSaveInterval = time.Minute
+
+// apiKeyStore 服务账户和API密钥的持久化结构
+type apiKeyStore struct {
+	Accounts map[string]*models.ServiceAccount `json:"accounts"`
+	Keys     map[string]*models.APIKey         `json:"keys"`
+}
+
+// APIKeyService 服务账户与API密钥服务
+// API密钥格式为 "mcx_<标识>_<随机串>"，标识明文保存用于查找，随机串只保存SHA-256哈希
+type APIKeyService struct {
+	mu          sync.Mutex
+	store       apiKeyStore
+	prefixIndex map[string]string // 标识 -> 密钥ID
+	userService *UserService
+	storePath   string
+}
+
+// NewAPIKeyService 创建新的API密钥服务
+// 已持久化的服务账户会登记到用户服务中
+func NewAPIKeyService(userService *UserService) *APIKeyService {
+	service := &APIKeyService{
+		prefixIndex: make(map[string]string),
+		userService: userService,
+		storePath:   utils.DataFilePath("service_accounts.json"),
+	}
+
+	// 加载已持久化的服务账户和密钥
+	if err := utils.LoadJSONFile(service.storePath, &service.store); err != nil {
+		log.Printf("加载服务账户失败: %v", err)
+	}
+	if service.store.Accounts == nil {
+		service.store.Accounts = make(map[string]*models.ServiceAccount)
+	}
+	if service.store.Keys == nil {
+		service.store.Keys = make(map[string]*models.APIKey)
+	}
+
+	for _, account := range service.store.Accounts {
+		userService.RegisterServiceAccount(account)
+	}
+	for id, key := range service.store.Keys {
+		service.prefixIndex[key.Prefix] = id
+	}
+
+	return service
+}
+
+// IsAPIKey 检查凭据是否为API密钥格式
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, models.APIKeyPrefix+"_")
+}
+
+// CreateServiceAccount 创建服务账户
+func (s *APIKeyService) CreateServiceAccount(req models.ServiceAccountRequest, createdBy string) (*models.ServiceAccount, error) {
+	account := &models.ServiceAccount{
+		ID:          uuid.New().String(),
+		Name:        req.Name,
+		Hospital:    req.Hospital,
+		Department:  req.Department,
+		Description: req.Description,
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.store.Accounts[account.ID] = account
+	if err := s.saveLocked(); err != nil {
+		delete(s.store.Accounts, account.ID)
+		return nil, err
+	}
+	s.userService.RegisterServiceAccount(account)
+
+	log.Printf("已创建服务账户: ID=%s, 名称=%s, 机构=%s", account.ID, account.Name, account.Hospital)
+	copied := *account
+	return &copied, nil
+}
+
+// ListServiceAccounts 获取全部服务账户，按创建时间排序
+func (s *APIKeyService) ListServiceAccounts() []models.ServiceAccount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := make([]models.ServiceAccount, 0, len(s.store.Accounts))
+	for _, account := range s.store.Accounts {
+		accounts = append(accounts, *account)
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].CreatedAt.Before(accounts[j].CreatedAt)
+	})
+
+	return accounts
+}
+
+// DisableServiceAccount 停用服务账户，并吊销其全部API密钥
+func (s *APIKeyService) DisableServiceAccount(accountID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, exists := s.store.Accounts[accountID]
+	if !exists {
+		return ErrServiceAccountNotFound
+	}
+	if account.DisabledAt != nil {
+		return ErrServiceAccountDisabled
+	}
+
+	now := time.Now()
+	account.DisabledAt = &now
+	revoked := make([]*models.APIKey, 0)
+	for _, key := range s.store.Keys {
+		if key.ServiceAccountID == accountID && key.RevokedAt == nil {
+			key.RevokedAt = &now
+			revoked = append(revoked, key)
+		}
+	}
+
+	if err := s.saveLocked(); err != nil {
+		account.DisabledAt = nil
+		for _, key := range revoked {
+			key.RevokedAt = nil
+		}
+		return err
+	}
+
+	log.Printf("已停用服务账户: ID=%s, 吊销密钥=%d", accountID, len(revoked))
+	return nil
+}
+
+// CreateKey 为服务账户创建API密钥，返回完整密钥和密钥信息
+func (s *APIKeyService) CreateKey(accountID string, req models.APIKeyRequest, createdBy string) (string, *models.APIKeyResponse, error) {
+	scopes, err := validateAPIKeyScopes(req.Scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, exists := s.store.Accounts[accountID]
+	if !exists {
+		return "", nil, ErrServiceAccountNotFound
+	}
+	if account.DisabledAt != nil {
+		return "", nil, ErrServiceAccountDisabled
+	}
+
+	prefix, err := s.newPrefixLocked()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	key := &models.APIKey{
+		ID:               uuid.New().String(),
+		ServiceAccountID: accountID,
+		Name:             req.Name,
+		Prefix:           prefix,
+		SecretHash:       hashTokenSecret(secret),
+		Scopes:           scopes,
+		CreatedBy:        createdBy,
+		CreatedAt:        now,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	s.store.Keys[key.ID] = key
+	s.prefixIndex[prefix] = key.ID
+	if err := s.saveLocked(); err != nil {
+		delete(s.store.Keys, key.ID)
+		delete(s.prefixIndex, prefix)
+		return "", nil, err
+	}
+
+	log.Printf("已创建API密钥: 服务账户=%s, 标识=%s, 权限=%s", accountID, prefix, strings.Join(scopes, ","))
+	response := apiKeyResponse(key)
+	return models.APIKeyPrefix + "_" + prefix + "_" + secret, &response, nil
+}
+
+// ListKeys 获取服务账户的API密钥
+func (s *APIKeyService) ListKeys(accountID string) ([]models.APIKeyResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.store.Accounts[accountID]; !exists {
+		return nil, ErrServiceAccountNotFound
+	}
+
+	keys := make([]models.APIKeyResponse, 0)
+	for _, key := range s.store.Keys {
+		if key.ServiceAccountID == accountID {
+			keys = append(keys, apiKeyResponse(key))
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+// RevokeKey 吊销服务账户的API密钥
+func (s *APIKeyService) RevokeKey(accountID, keyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, exists := s.store.Keys[keyID]
+	if !exists || key.ServiceAccountID != accountID || key.RevokedAt != nil {
+		return ErrAPIKeyNotFound
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+	if err := s.saveLocked(); err != nil {
+		key.RevokedAt = nil
+		return err
+	}
+
+	log.Printf("已吊销API密钥: 服务账户=%s, 标识=%s", accountID, key.Prefix)
+	return nil
+}
+
+// Authenticate 校验API密钥，返回服务账户和密钥信息，并记录最近使用时间
+func (s *APIKeyService) Authenticate(credential, clientIP string) (*models.ServiceAccount, *models.APIKeyResponse, error) {
+	parts := strings.SplitN(credential, "_", 3)
+	if len(parts) != 3 || parts[0] != models.APIKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keyID, exists := s.prefixIndex[parts[1]]
+	if !exists {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+	key := s.store.Keys[keyID]
+
+	now := time.Now()
+	presented := hashTokenSecret(parts[2])
+	if subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(presented)) != 1 || !key.Active(now) {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	account, exists := s.store.Accounts[key.ServiceAccountID]
+	if !exists || account.DisabledAt != nil {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	// 记录最近使用情况，按间隔持久化
+	previous := key.LastUsedAt
+	key.LastUsedAt = &now
+	key.LastUsedIP = clientIP
+	if previous == nil || now.Sub(*previous) >= apiKeyLastUsedSaveInterval {
+		if err := s.saveLocked(); err != nil {
+			log.Printf("记录API密钥使用时间失败: %v", err)
+		}
+	}
+
+	copied := *account
+	response := apiKeyResponse(key)
+	return &copied, &response, nil
+}
+
+// 生成未被占用的密钥标识，调用方需持有锁
+func (s *APIKeyService) newPrefixLocked() (string, error) {
+	for i := 0; i < 10; i++ {
+		buf := make([]byte, 4)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		prefix := hex.EncodeToString(buf)
+		if _, exists := s.prefixIndex[prefix]; !exists {
+			return prefix, nil
+		}
+	}
+	return "", errors.New("生成API密钥标识失败")
+}
+
+// 持久化服务账户和密钥，调用方需持有锁
+func (s *APIKeyService) saveLocked() error {
+	if err := utils.SaveJSONFile(s.storePath, s.store); err != nil {
+		log.Printf("保存服务账户失败: %v", err)
+		return err
+	}
+	return nil
+}
+
+// 校验密钥权限范围不超出服务账户角色的权限，并去除重复项
+func validateAPIKeyScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, ErrAPIKeyInvalidScope
+	}
+
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !models.HasPermission(models.RoleService, scope) {
+			return nil, fmt.Errorf("%w: %s", ErrAPIKeyInvalidScope, scope)
+		}
+		if !containsString(result, scope) {
+			result = append(result, scope)
+		}
+	}
+	return result, nil
+}
+
+// 构建不包含哈希的密钥信息
+func apiKeyResponse(key *models.APIKey) models.APIKeyResponse {
+	scopes := make([]string, len(key.Scopes))
+	copy(scopes, key.Scopes)
+
+	return models.APIKeyResponse{
+		ID:               key.ID,
+		ServiceAccountID: key.ServiceAccountID,
+		Name:             key.Name,
+		Prefix:           key.Prefix,
+		Scopes:           scopes,
+		CreatedBy:        key.CreatedBy,
+		CreatedAt:        key.CreatedAt,
+		ExpiresAt:        key.ExpiresAt,
+		LastUsedAt:       key.LastUsedAt,
+		LastUsedIP:       key.LastUsedIP,
+		RevokedAt:        key.RevokedAt,
+	}
+}
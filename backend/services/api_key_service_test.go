@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"medcross/models"
+)
+
+func newAPIKeyFixture(t *testing.T) (*APIKeyService, string) {
+	t.Helper()
+
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "Adm1n-Passw0rd")
+
+	service := NewAPIKeyService(NewUserService())
+	account, err := service.CreateServiceAccount(models.ServiceAccountRequest{Name: "PACS", Hospital: "协和医院"}, "admin")
+	if err != nil {
+		t.Fatalf("创建服务账户失败: %v", err)
+	}
+	return service, account.ID
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	service, accountID := newAPIKeyFixture(t)
+
+	tests := []struct {
+		name       string
+		scopes     []string
+		wantScopes []string
+		wantErr    error
+	}{
+		{name: "服务账户权限范围内", scopes: []string{models.PermDataUpload, models.PermStatisticsRead}, wantScopes: []string{models.PermDataUpload, models.PermStatisticsRead}},
+		{name: "去除重复项", scopes: []string{models.PermDataUpload, models.PermDataUpload}, wantScopes: []string{models.PermDataUpload}},
+		{name: "没有权限范围", scopes: nil, wantErr: ErrAPIKeyInvalidScope},
+		{name: "超出服务账户权限", scopes: []string{models.PermDataUpload, models.PermUserApprove}, wantErr: ErrAPIKeyInvalidScope},
+		{name: "未知权限", scopes: []string{"data:everything"}, wantErr: ErrAPIKeyInvalidScope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, key, err := service.CreateKey(accountID, models.APIKeyRequest{Name: tt.name, Scopes: tt.scopes}, "admin")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateKey 错误 = %v, 期望 %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			// 认证后上下文中的权限范围与创建时一致
+			_, authenticated, err := service.Authenticate(secret, "127.0.0.1")
+			if err != nil {
+				t.Fatalf("认证失败: %v", err)
+			}
+			for _, scopes := range [][]string{key.Scopes, authenticated.Scopes} {
+				if strings.Join(scopes, ",") != strings.Join(tt.wantScopes, ",") {
+					t.Errorf("权限范围 = %v, 期望 %v", scopes, tt.wantScopes)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIKeyExpiryAndRevocation(t *testing.T) {
+	tests := []struct {
+		name    string
+		days    int
+		mutate  func(t *testing.T, s *APIKeyService, accountID, keyID string)
+		replace func(secret string) string
+		wantErr bool
+	}{
+		{name: "不过期的密钥"},
+		{name: "有效期内", days: 30},
+		{
+			name: "已过期",
+			days: 30,
+			mutate: func(t *testing.T, s *APIKeyService, accountID, keyID string) {
+				expired := time.Now().Add(-time.Minute)
+				s.store.Keys[keyID].ExpiresAt = &expired
+			},
+			wantErr: true,
+		},
+		{
+			name: "已吊销",
+			mutate: func(t *testing.T, s *APIKeyService, accountID, keyID string) {
+				if err := s.RevokeKey(accountID, keyID); err != nil {
+					t.Fatalf("吊销密钥失败: %v", err)
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "服务账户已停用",
+			mutate: func(t *testing.T, s *APIKeyService, accountID, keyID string) {
+				if err := s.DisableServiceAccount(accountID); err != nil {
+					t.Fatalf("停用服务账户失败: %v", err)
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "随机串错误",
+			replace: func(secret string) string { return secret[:strings.LastIndex(secret, "_")+1] + "wrong" },
+			wantErr: true,
+		},
+		{
+			name:    "格式错误",
+			replace: func(secret string) string { return strings.Replace(secret, models.APIKeyPrefix+"_", "", 1) },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, accountID := newAPIKeyFixture(t)
+			secret, key, err := service.CreateKey(accountID, models.APIKeyRequest{
+				Name:          tt.name,
+				Scopes:        []string{models.PermDataUpload},
+				ExpiresInDays: tt.days,
+			}, "admin")
+			if err != nil {
+				t.Fatalf("创建API密钥失败: %v", err)
+			}
+			if (key.ExpiresAt != nil) != (tt.days > 0) {
+				t.Errorf("过期时间 = %v, 有效天数 %d", key.ExpiresAt, tt.days)
+			}
+
+			if tt.mutate != nil {
+				tt.mutate(t, service, accountID, key.ID)
+			}
+			if tt.replace != nil {
+				secret = tt.replace(secret)
+			}
+
+			_, _, err = service.Authenticate(secret, "127.0.0.1")
+			if tt.wantErr && !errors.Is(err, ErrAPIKeyInvalid) {
+				t.Errorf("Authenticate 错误 = %v, 期望 %v", err, ErrAPIKeyInvalid)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Authenticate 失败: %v", err)
+			}
+		})
+	}
+}
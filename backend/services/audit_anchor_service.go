@@ -0,0 +1,270 @@
+package services
+
+import (
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// 审计检查点相关错误
+var (
+	ErrAuditEntryNotFound    = errors.New("审计记录不存在")
+	ErrAuditEntryNotAnchored = errors.New("审计记录尚未纳入检查点")
+	ErrNoNewAuditEntries     = errors.New("没有新的审计记录")
+)
+
+// AuditAnchorService 审计日志上链服务
+// 定期对新增审计记录计算Merkle根，并通过跨链网关写入区块链，
+// 内部人员改写本地审计日志后将无法与链上的根匹配
+type AuditAnchorService struct {
+	mu             sync.Mutex
+	auditService   *AuditService
+	gatewayService *GatewayService
+	checkpoints    []*models.AuditCheckpoint
+	chains         []string
+	interval       time.Duration
+	storePath      string
+}
+
+// NewAuditAnchorService 创建新的审计日志上链服务
+func NewAuditAnchorService(auditService *AuditService, gatewayService *GatewayService) *AuditAnchorService {
+	interval := time.Hour
+	if v := os.Getenv("AUDIT_ANCHOR_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			interval = d
+		}
+	}
+
+	chains := []string{"fabric", "ethereum"}
+	if v := os.Getenv("AUDIT_ANCHOR_CHAINS"); v != "" {
+		chains = nil
+		for _, chain := range strings.Split(v, ",") {
+			if chain = strings.TrimSpace(chain); chain != "" {
+				chains = append(chains, chain)
+			}
+		}
+	}
+
+	service := &AuditAnchorService{
+		auditService:   auditService,
+		gatewayService: gatewayService,
+		chains:         chains,
+		interval:       interval,
+		storePath:      utils.DataFilePath("audit_checkpoints.json"),
+	}
+
+	// 加载已持久化的检查点
+	if err := utils.LoadJSONFile(service.storePath, &service.checkpoints); err != nil {
+		log.Printf("加载审计检查点失败: %v", err)
+	}
+
+	return service
+}
+
+// Start 启动定期上链任务
+// AUDIT_ANCHOR_INTERVAL 为0时不启动，只能通过接口手动创建检查点
+func (s *AuditAnchorService) Start() {
+	if s.interval <= 0 {
+		log.Printf("审计日志定期上链已关闭")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.RetryFailedAnchors()
+			if _, err := s.CreateCheckpoint(); err != nil && !errors.Is(err, ErrNoNewAuditEntries) {
+				log.Printf("创建审计检查点失败: %v", err)
+			}
+		}
+	}()
+
+	log.Printf("审计日志定期上链已启动: 间隔=%s, 区块链=%s", s.interval, strings.Join(s.chains, ","))
+}
+
+// CreateCheckpoint 对上一个检查点之后的审计记录创建检查点并上链
+func (s *AuditAnchorService) CreateCheckpoint() (*models.AuditCheckpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from := int64(1)
+	if len(s.checkpoints) > 0 {
+		from = s.checkpoints[len(s.checkpoints)-1].ToSequence + 1
+	}
+	to := s.auditService.LastSequence()
+	if to < from {
+		return nil, ErrNoNewAuditEntries
+	}
+
+	leaves, err := auditLeaves(s.auditService.GetEntries(from, to))
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := &models.AuditCheckpoint{
+		ID:           uuid.New().String(),
+		FromSequence: from,
+		ToSequence:   to,
+		TreeSize:     len(leaves),
+		MerkleRoot:   hex.EncodeToString(utils.MerkleRoot(leaves)),
+		CreatedAt:    time.Now(),
+		Anchors:      make(map[string]*models.AuditAnchor),
+	}
+	for _, chain := range s.chains {
+		checkpoint.Anchors[chain] = &models.AuditAnchor{Status: models.AuditAnchorPending}
+	}
+
+	s.checkpoints = append(s.checkpoints, checkpoint)
+	if err := utils.SaveJSONFile(s.storePath, s.checkpoints); err != nil {
+		s.checkpoints = s.checkpoints[:len(s.checkpoints)-1]
+		log.Printf("保存审计检查点失败: %v", err)
+		return nil, err
+	}
+
+	log.Printf("创建审计检查点: ID=%s, 序号=%d-%d, 根=%s", checkpoint.ID, from, to, checkpoint.MerkleRoot)
+
+	s.anchorLocked(checkpoint)
+
+	return checkpoint, nil
+}
+
+// RetryFailedAnchors 重新提交上链失败的检查点
+func (s *AuditAnchorService) RetryFailedAnchors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, checkpoint := range s.checkpoints {
+		s.anchorLocked(checkpoint)
+	}
+}
+
+// ListCheckpoints 获取全部检查点，按时间倒序
+func (s *AuditAnchorService) ListCheckpoints() []models.AuditCheckpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints := make([]models.AuditCheckpoint, 0, len(s.checkpoints))
+	for i := len(s.checkpoints) - 1; i >= 0; i-- {
+		checkpoints = append(checkpoints, copyCheckpoint(s.checkpoints[i]))
+	}
+	return checkpoints
+}
+
+// GetProof 获取审计记录相对于其所在检查点Merkle根的包含证明
+func (s *AuditAnchorService) GetProof(sequence int64) (*models.AuditProof, error) {
+	entries := s.auditService.GetEntries(sequence, sequence)
+	if len(entries) == 0 {
+		return nil, ErrAuditEntryNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := sort.Search(len(s.checkpoints), func(i int) bool {
+		return s.checkpoints[i].ToSequence >= sequence
+	})
+	if index == len(s.checkpoints) {
+		return nil, ErrAuditEntryNotAnchored
+	}
+	checkpoint := s.checkpoints[index]
+
+	leaves, err := auditLeaves(s.auditService.GetEntries(checkpoint.FromSequence, checkpoint.ToSequence))
+	if err != nil {
+		return nil, err
+	}
+
+	leafIndex := int(sequence - checkpoint.FromSequence)
+	proof := make([]string, 0)
+	for _, node := range utils.MerkleProof(leaves, leafIndex) {
+		proof = append(proof, hex.EncodeToString(node))
+	}
+
+	return &models.AuditProof{
+		Entry:      entries[0],
+		LeafHash:   hex.EncodeToString(leaves[leafIndex]),
+		LeafIndex:  leafIndex,
+		TreeSize:   len(leaves),
+		Proof:      proof,
+		MerkleRoot: checkpoint.MerkleRoot,
+		Checkpoint: copyCheckpoint(checkpoint),
+	}, nil
+}
+
+// 将检查点提交到尚未成功上链的区块链，调用方需持有锁
+func (s *AuditAnchorService) anchorLocked(checkpoint *models.AuditCheckpoint) {
+	payload := map[string]interface{}{
+		"checkpointId": checkpoint.ID,
+		"merkleRoot":   checkpoint.MerkleRoot,
+		"fromSequence": checkpoint.FromSequence,
+		"toSequence":   checkpoint.ToSequence,
+		"treeSize":     checkpoint.TreeSize,
+		"createdAt":    checkpoint.CreatedAt.Unix(),
+	}
+
+	changed := false
+	for chain, anchor := range checkpoint.Anchors {
+		if anchor.Status == models.AuditAnchorAnchored {
+			continue
+		}
+
+		anchor.Attempts++
+		changed = true
+
+		txHash, err := s.gatewayService.SubmitBlockchainTransaction(chain, "anchor", payload)
+		if err != nil {
+			anchor.Status = models.AuditAnchorFailed
+			anchor.Error = err.Error()
+			log.Printf("审计检查点上链失败: ID=%s, 链=%s, 错误=%v", checkpoint.ID, chain, err)
+			continue
+		}
+
+		anchor.Status = models.AuditAnchorAnchored
+		anchor.TxHash = txHash
+		anchor.Error = ""
+		anchoredAt := time.Now()
+		anchor.AnchoredAt = &anchoredAt
+		log.Printf("审计检查点已上链: ID=%s, 链=%s, 交易=%s", checkpoint.ID, chain, txHash)
+	}
+
+	if changed {
+		if err := utils.SaveJSONFile(s.storePath, s.checkpoints); err != nil {
+			log.Printf("保存审计检查点失败: %v", err)
+		}
+	}
+}
+
+// 计算审计记录的Merkle叶子哈希，叶子数据为记录哈希的字节
+func auditLeaves(entries []models.AuditEntry) ([][]byte, error) {
+	leaves := make([][]byte, 0, len(entries))
+	for _, entry := range entries {
+		hash, err := hex.DecodeString(entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, utils.MerkleLeafHash(hash))
+	}
+	return leaves, nil
+}
+
+// 复制检查点，避免调用方在锁外读取上链状态
+func copyCheckpoint(checkpoint *models.AuditCheckpoint) models.AuditCheckpoint {
+	copied := *checkpoint
+	copied.Anchors = make(map[string]*models.AuditAnchor, len(checkpoint.Anchors))
+	for chain, anchor := range checkpoint.Anchors {
+		anchorCopy := *anchor
+		copied.Anchors[chain] = &anchorCopy
+	}
+	return copied
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// 哈希链的起始哈希
+var auditGenesisHash = strings.Repeat("0", 64)
+
+// AuditService 审计日志服务
+// 审计记录以JSON Lines格式追加写入 audit_log.jsonl，每条记录通过哈希与前一条记录链接
+type AuditService struct {
+	mu        sync.RWMutex
+	entries   []models.AuditEntry
+	lastHash  string
+	storePath string
+}
+
+// NewAuditService 创建新的审计日志服务
+func NewAuditService() *AuditService {
+	service := &AuditService{
+		lastHash:  auditGenesisHash,
+		storePath: utils.DataFilePath("audit_log.jsonl"),
+	}
+
+	// 加载已持久化的审计记录
+	err := utils.LoadJSONLines(service.storePath, func(line []byte) error {
+		var entry models.AuditEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return err
+		}
+		service.entries = append(service.entries, entry)
+		return nil
+	})
+	if err != nil {
+		log.Printf("加载审计日志失败: %v", err)
+	}
+	if len(service.entries) > 0 {
+		service.lastHash = service.entries[len(service.entries)-1].Hash
+	}
+
+	// 启动时校验哈希链
+	if result := service.Verify(); !result.Valid {
+		log.Printf("警告: 审计日志哈希链校验失败: 序号=%d, 原因=%s", result.BrokenAt, result.Reason)
+	}
+
+	return service
+}
+
+// Record 追加一条审计记录
+// 序号、前一条哈希和本条哈希由服务填写
+func (s *AuditService) Record(entry models.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.Sequence = int64(len(s.entries)) + 1
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	entry.Timestamp = entry.Timestamp.Round(0)
+	entry.PrevHash = s.lastHash
+	entry.Hash = entry.ComputeHash()
+
+	if err := utils.AppendJSONLine(s.storePath, entry); err != nil {
+		log.Printf("写入审计日志失败: %v", err)
+		return err
+	}
+
+	s.entries = append(s.entries, entry)
+	s.lastHash = entry.Hash
+
+	return nil
+}
+
+// Query 按条件查询审计记录，按时间倒序分页返回
+// recordFilter 不为nil时只返回其接受的记录
+func (s *AuditService) Query(query models.AuditQuery, recordFilter func(entry models.AuditEntry) bool) models.AuditQueryResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 || query.PageSize > 100 {
+		query.PageSize = 20
+	}
+
+	matched := make([]models.AuditEntry, 0)
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		entry := s.entries[i]
+		if !matchAuditQuery(entry, query) {
+			continue
+		}
+		if recordFilter != nil && !recordFilter(entry) {
+			continue
+		}
+		matched = append(matched, entry)
+	}
+
+	result := models.AuditQueryResult{
+		Entries:    []models.AuditEntry{},
+		TotalCount: len(matched),
+		Page:       query.Page,
+		PageSize:   query.PageSize,
+	}
+
+	start := (query.Page - 1) * query.PageSize
+	if start < len(matched) {
+		end := start + query.PageSize
+		if end > len(matched) {
+			end = len(matched)
+		}
+		result.Entries = matched[start:end]
+	}
+
+	return result
+}
+
+// LastSequence 获取最新审计记录的序号
+func (s *AuditService) LastSequence() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return int64(len(s.entries))
+}
+
+// GetEntries 获取序号在 [from, to] 范围内的审计记录
+func (s *AuditService) GetEntries(from, to int64) []models.AuditEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if from < 1 {
+		from = 1
+	}
+	if to > int64(len(s.entries)) {
+		to = int64(len(s.entries))
+	}
+	if from > to {
+		return []models.AuditEntry{}
+	}
+
+	entries := make([]models.AuditEntry, to-from+1)
+	copy(entries, s.entries[from-1:to])
+	return entries
+}
+
+// Verify 校验整条哈希链
+func (s *AuditService) Verify() models.AuditVerifyResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := models.AuditVerifyResult{
+		Valid:       true,
+		EntryCount:  len(s.entries),
+		LastHash:    s.lastHash,
+		GenesisHash: auditGenesisHash,
+	}
+
+	prevHash := auditGenesisHash
+	for i, entry := range s.entries {
+		switch {
+		case entry.Sequence != int64(i)+1:
+			result.Reason = fmt.Sprintf("序号不连续，期望 %d", i+1)
+		case entry.PrevHash != prevHash:
+			result.Reason = "前一条记录哈希不匹配"
+		case entry.Hash != entry.ComputeHash():
+			result.Reason = "记录内容哈希不匹配"
+		}
+
+		if result.Reason != "" {
+			result.Valid = false
+			result.BrokenAt = int64(i) + 1
+			return result
+		}
+		prevHash = entry.Hash
+	}
+
+	return result
+}
+
+// 检查审计记录是否满足查询条件
+func matchAuditQuery(entry models.AuditEntry, query models.AuditQuery) bool {
+	if query.ActorID != "" && entry.ActorID != query.ActorID {
+		return false
+	}
+	if query.Action != "" && entry.Action != query.Action {
+		return false
+	}
+	if query.RecordID != "" && entry.RecordID != query.RecordID {
+		return false
+	}
+	if query.Chain != "" && entry.Chain != query.Chain {
+		return false
+	}
+	if query.Outcome != "" && entry.Outcome != query.Outcome {
+		return false
+	}
+	if !query.From.IsZero() && entry.Timestamp.Before(query.From) {
+		return false
+	}
+	if !query.To.IsZero() && entry.Timestamp.After(query.To) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+func TestAuditVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name       string
+		tamper     func(entries []models.AuditEntry) []models.AuditEntry
+		wantValid  bool
+		wantBroken int64
+	}{
+		{
+			name:      "未篡改",
+			tamper:    func(entries []models.AuditEntry) []models.AuditEntry { return entries },
+			wantValid: true,
+		},
+		{
+			name: "修改记录内容",
+			tamper: func(entries []models.AuditEntry) []models.AuditEntry {
+				entries[1].Outcome = "success"
+				return entries
+			},
+			wantBroken: 2,
+		},
+		{
+			name: "修改记录内容并重新计算哈希",
+			tamper: func(entries []models.AuditEntry) []models.AuditEntry {
+				entries[1].Outcome = "success"
+				entries[1].Hash = entries[1].ComputeHash()
+				return entries
+			},
+			wantBroken: 3,
+		},
+		{
+			name: "删除中间的记录",
+			tamper: func(entries []models.AuditEntry) []models.AuditEntry {
+				return append(entries[:1], entries[2:]...)
+			},
+			wantBroken: 2,
+		},
+		{
+			name: "删除记录后重新编号",
+			tamper: func(entries []models.AuditEntry) []models.AuditEntry {
+				entries = append(entries[:1], entries[2:]...)
+				entries[1].Sequence = 2
+				entries[1].Hash = entries[1].ComputeHash()
+				return entries
+			},
+			wantBroken: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DATA_DIR", t.TempDir())
+
+			service := NewAuditService()
+			outcomes := []string{"success", "denied", "success"}
+			for _, outcome := range outcomes {
+				entry := models.AuditEntry{ActorID: "u-1", Action: "data.read", RecordID: "r-1", Outcome: outcome, StatusCode: 200}
+				if err := service.Record(entry); err != nil {
+					t.Fatalf("写入审计记录失败: %v", err)
+				}
+			}
+			if result := service.Verify(); !result.Valid {
+				t.Fatalf("写入后的哈希链校验失败: %+v", result)
+			}
+
+			// 直接改写磁盘上的审计日志后重新加载
+			entries := tt.tamper(service.GetEntries(1, service.LastSequence()))
+			var buf bytes.Buffer
+			for _, entry := range entries {
+				line, _ := json.Marshal(entry)
+				buf.Write(append(line, '\n'))
+			}
+			if err := os.WriteFile(utils.DataFilePath("audit_log.jsonl"), buf.Bytes(), 0600); err != nil {
+				t.Fatalf("改写审计日志失败: %v", err)
+			}
+
+			result := NewAuditService().Verify()
+			if result.Valid != tt.wantValid || result.BrokenAt != tt.wantBroken {
+				t.Errorf("Verify = {Valid: %v, BrokenAt: %d, Reason: %s}, 期望 {Valid: %v, BrokenAt: %d}",
+					result.Valid, result.BrokenAt, result.Reason, tt.wantValid, tt.wantBroken)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"medcross/models"
+)
+
+// 从每条链拉取记录时的最大数量
+const cohortFetchLimit = 10000
+
+// CohortService 跨链队列可行性计数服务
+// 按元数据中的患者标识去重，只返回满足最小阈值的计数。
+// 仅存在于Fabric链上的记录的患者标识写在私有数据集合中，世界状态里没有可去重的字段，
+// 这类记录单独计数，不计入患者数量
+type CohortService struct {
+	dataService    *DataService
+	gatewayService *GatewayService
+	tenantService  *TenantService
+	minCount       int // 最小可披露数量
+}
+
+// NewCohortService 创建新的队列计数服务
+func NewCohortService(dataService *DataService, gatewayService *GatewayService, tenantService *TenantService) *CohortService {
+	minCount := 10
+	if v, err := strconv.Atoi(os.Getenv("COHORT_MIN_COUNT")); err == nil && v > 0 {
+		minCount = v
+	}
+
+	return &CohortService{
+		dataService:    dataService,
+		gatewayService: gatewayService,
+		tenantService:  tenantService,
+		minCount:       minCount,
+	}
+}
+
+// CountPatients 统计满足条件的患者数量
+// inScope 不为nil时只统计满足条件的记录（如本租户和共享给本租户的记录）；
+// canAccess 用于判断调用者是否有权访问某条记录，只有有权访问的记录ID才会返回
+func (s *CohortService) CountPatients(query models.CohortQuery, inScope, canAccess func(models.MedicalData) bool) (*models.CohortResult, error) {
+	for _, date := range []string{query.StartDate, query.EndDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, fmt.Errorf("无效的日期格式: %s", date)
+		}
+	}
+
+	chains := query.Chains
+	if len(chains) == 0 {
+		chains = []string{"ethereum", "fabric"}
+	}
+	for _, chain := range chains {
+		if chain != "ethereum" && chain != "fabric" {
+			return nil, fmt.Errorf("不支持的链类型: %s", chain)
+		}
+	}
+
+	records, errs := s.collectRecords(chains)
+
+	patients := make(map[string]bool)
+	chainPatients := make(map[string]map[string]bool)
+	for _, chain := range chains {
+		chainPatients[chain] = make(map[string]bool)
+	}
+	var accessibleIDs []string
+	unidentified := 0
+
+	for _, record := range records {
+		if inScope != nil && !inScope(record) {
+			continue
+		}
+		metadata := parseMetadata(record.Metadata)
+		if !s.matchesCohortQuery(record, metadata, query) {
+			continue
+		}
+
+		if canAccess != nil && canAccess(record) {
+			accessibleIDs = append(accessibleIDs, record.ID)
+		}
+
+		// 没有患者标识的记录无法去重，单独计数
+		patientID := cohortPatientID(metadata)
+		if patientID == "" {
+			unidentified++
+			continue
+		}
+
+		patients[patientID] = true
+		if set, ok := chainPatients[record.Chain]; ok {
+			set[patientID] = true
+		}
+	}
+
+	sort.Strings(accessibleIDs)
+
+	result := &models.CohortResult{
+		PatientCount:        s.thresholdCount(len(patients)),
+		Threshold:           s.minCount,
+		ChainCounts:         make(map[string]*int, len(chains)),
+		UnidentifiedRecords: s.thresholdCount(unidentified),
+		AccessibleRecordIDs: accessibleIDs,
+		Errors:              errs,
+	}
+	result.BelowThreshold = result.PatientCount == nil
+	for chain, set := range chainPatients {
+		result.ChainCounts[chain] = s.thresholdCount(len(set))
+	}
+
+	if unidentified > 0 {
+		log.Printf("队列可行性查询: %d条匹配记录缺少患者标识，未计入患者数量", unidentified)
+	}
+	log.Printf("队列可行性查询: 链=%v, 匹配患者数低于阈值=%v", chains, result.BelowThreshold)
+	return result, nil
+}
+
+// 收集本地和各区块链上的记录，按记录ID去重
+func (s *CohortService) collectRecords(chains []string) ([]models.MedicalData, []string) {
+	seen := make(map[string]bool)
+	var records []models.MedicalData
+	var errs []string
+
+	wanted := make(map[string]bool, len(chains))
+	for _, chain := range chains {
+		wanted[chain] = true
+	}
+
+	for _, record := range s.dataService.ListData() {
+		if wanted[record.Chain] && !seen[record.ID] {
+			seen[record.ID] = true
+			records = append(records, record)
+		}
+	}
+
+	for _, chain := range chains {
+		chainRecords, err := s.gatewayService.QueryBlockchainData(chain, "", "", 1, cohortFetchLimit)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s查询错误: %v", chain, err))
+			continue
+		}
+		for _, record := range chainRecords {
+			if record.Chain == chain && !seen[record.ID] {
+				seen[record.ID] = true
+				records = append(records, record)
+			}
+		}
+	}
+
+	return records, errs
+}
+
+// 低于阈值的计数返回nil
+func (s *CohortService) thresholdCount(count int) *int {
+	if count < s.minCount {
+		return nil
+	}
+	return &count
+}
+
+// 检查记录是否满足队列查询条件
+// 医院按记录所属的租户匹配，Hospitals中可以填写租户ID或医院名称
+func (s *CohortService) matchesCohortQuery(record models.MedicalData, metadata map[string]interface{}, query models.CohortQuery) bool {
+	if len(query.DataTypes) > 0 && !containsString(query.DataTypes, record.DataType) {
+		return false
+	}
+	if len(query.Hospitals) > 0 &&
+		!containsString(query.Hospitals, s.tenantService.RecordTenant(record)) &&
+		!containsString(query.Hospitals, s.tenantService.RecordHospital(record)) {
+		return false
+	}
+
+	day := record.Timestamp.Format("2006-01-02")
+	if query.StartDate != "" && day < query.StartDate {
+		return false
+	}
+	if query.EndDate != "" && day > query.EndDate {
+		return false
+	}
+
+	if len(query.DiagnosisKeywords) == 0 {
+		return true
+	}
+
+	// 在关键词、诊断和描述中搜索诊断关键词
+	searchText := strings.ToLower(strings.Join([]string{
+		record.Keywords,
+		metadataString(metadata, "diagnosis"),
+		metadataString(metadata, "description"),
+	}, " "))
+	for _, keyword := range query.DiagnosisKeywords {
+		keyword = strings.TrimSpace(keyword)
+		if keyword != "" && strings.Contains(searchText, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 从元数据中提取患者标识
+// 以太坊记录经过跨链转换后可能只保留patientAddress（patientId的十六进制编码）
+func cohortPatientID(metadata map[string]interface{}) string {
+	if patientID := metadataString(metadata, "patientId"); patientID != "" {
+		return patientID
+	}
+
+	address := strings.TrimPrefix(metadataString(metadata, "patientAddress"), "0x")
+	if address == "" {
+		return ""
+	}
+	if decoded, err := hex.DecodeString(address); err == nil {
+		return string(decoded)
+	}
+	return address
+}
+
+// 检查字符串是否在列表中
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
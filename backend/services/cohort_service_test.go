@@ -0,0 +1,149 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"medcross/models"
+)
+
+// 队列计数测试环境：本地保存的记录带有患者标识，网关返回的Fabric记录只有世界状态中的公开元数据
+func newCohortFixture(t *testing.T, local []models.MedicalData, fabric []models.MedicalData) *CohortService {
+	t.Helper()
+
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "Adm1n-Passw0rd")
+	t.Setenv("COHORT_MIN_COUNT", "1")
+
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		records := []models.MedicalData{}
+		if r.URL.Query().Get("chain") == "fabric" {
+			records = fabric
+		}
+		json.NewEncoder(w).Encode(records)
+	}))
+	t.Cleanup(gateway.Close)
+	t.Setenv("GATEWAY_URL", gateway.URL)
+
+	dataService := NewDataService()
+	for _, record := range local {
+		if err := dataService.SaveData(record); err != nil {
+			t.Fatalf("保存记录 %s 失败: %v", record.ID, err)
+		}
+	}
+
+	return NewCohortService(dataService, NewGatewayService(), NewTenantService(NewUserService()))
+}
+
+func cohortRecord(id, chain, metadata string) models.MedicalData {
+	return models.MedicalData{
+		ID:        id,
+		DataType:  "影像数据",
+		Metadata:  metadata,
+		Timestamp: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		Chain:     chain,
+	}
+}
+
+func TestCohortCountsFabricRecordsWithoutPatientID(t *testing.T) {
+	tests := []struct {
+		name             string
+		local            []models.MedicalData
+		fabric           []models.MedicalData
+		wantPatients     *int
+		wantUnidentified *int
+	}{
+		{
+			name: "本地记录按患者去重",
+			local: []models.MedicalData{
+				cohortRecord("local-1", "fabric", `{"patientId":"P1"}`),
+				cohortRecord("local-2", "ethereum", `{"patientId":"P1"}`),
+				cohortRecord("local-3", "fabric", `{"patientId":"P2"}`),
+			},
+			wantPatients: intPtr(2),
+		},
+		{
+			name: "本地已有的Fabric记录不重复计数",
+			local: []models.MedicalData{
+				cohortRecord("local-1", "fabric", `{"patientId":"P1"}`),
+			},
+			fabric: []models.MedicalData{
+				cohortRecord("local-1", "fabric", `{"tenantId":"t-1"}`),
+			},
+			wantPatients: intPtr(1),
+		},
+		{
+			name: "仅在Fabric链上的记录单独计数",
+			local: []models.MedicalData{
+				cohortRecord("local-1", "fabric", `{"patientId":"P1"}`),
+			},
+			fabric: []models.MedicalData{
+				cohortRecord("chain-1", "fabric", `{"tenantId":"t-1"}`),
+				cohortRecord("chain-2", "fabric", `{"tenantId":"t-2"}`),
+			},
+			wantPatients:     intPtr(1),
+			wantUnidentified: intPtr(2),
+		},
+		{
+			name: "全部记录缺少患者标识",
+			fabric: []models.MedicalData{
+				cohortRecord("chain-1", "fabric", `{}`),
+			},
+			wantUnidentified: intPtr(1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newCohortFixture(t, tt.local, tt.fabric)
+
+			result, err := service.CountPatients(models.CohortQuery{}, nil, nil)
+			if err != nil {
+				t.Fatalf("CountPatients 返回错误: %v", err)
+			}
+			if !equalCount(result.PatientCount, tt.wantPatients) {
+				t.Errorf("患者数量 = %v, 期望 %v", countString(result.PatientCount), countString(tt.wantPatients))
+			}
+			if !equalCount(result.UnidentifiedRecords, tt.wantUnidentified) {
+				t.Errorf("缺少患者标识的记录数 = %v, 期望 %v", countString(result.UnidentifiedRecords), countString(tt.wantUnidentified))
+			}
+		})
+	}
+}
+
+func TestCohortThresholdHidesUnidentifiedRecords(t *testing.T) {
+	service := newCohortFixture(t, nil, []models.MedicalData{
+		cohortRecord("chain-1", "fabric", `{}`),
+	})
+	service.minCount = 2
+
+	result, err := service.CountPatients(models.CohortQuery{}, nil, nil)
+	if err != nil {
+		t.Fatalf("CountPatients 返回错误: %v", err)
+	}
+	if result.UnidentifiedRecords != nil {
+		t.Errorf("低于阈值的记录数应为nil, 实际 %d", *result.UnidentifiedRecords)
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func equalCount(got, want *int) bool {
+	if got == nil || want == nil {
+		return got == want
+	}
+	return *got == *want
+}
+
+func countString(v *int) interface{} {
+	if v == nil {
+		return "null"
+	}
+	return *v
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+var (
+	// ErrConsentNotFound 知情同意记录不存在
+	ErrConsentNotFound = errors.New("知情同意记录不存在")
+	// ErrConsentForbidden 无权管理该患者的知情同意
+	ErrConsentForbidden = errors.New("无权管理该患者的知情同意")
+)
+
+// 知情同意记录上链的目标区块链
+var consentChains = []string{"ethereum", "fabric"}
+
+// consentStore 知情同意的持久化结构
+type consentStore struct {
+	Consents map[string]*models.Consent `json:"consents"`
+	Proxies  []models.ConsentProxy      `json:"proxies"`
+}
+
+// ConsentService 患者知情同意服务
+// 知情同意记录保存在本地并同步写入以太坊和Fabric
+type ConsentService struct {
+	mu             sync.RWMutex
+	store          consentStore
+	storePath      string
+	gatewayService *GatewayService
+}
+
+// NewConsentService 创建新的知情同意服务
+func NewConsentService(gatewayService *GatewayService) *ConsentService {
+	service := &ConsentService{
+		store: consentStore{
+			Consents: make(map[string]*models.Consent),
+		},
+		storePath:      utils.DataFilePath("consents.json"),
+		gatewayService: gatewayService,
+	}
+
+	// 加载已持久化的知情同意
+	if err := utils.LoadJSONFile(service.storePath, &service.store); err != nil {
+		log.Printf("加载知情同意记录失败: %v", err)
+	}
+	if service.store.Consents == nil {
+		service.store.Consents = make(map[string]*models.Consent)
+	}
+
+	return service
+}
+
+// Grant 授予知情同意
+// actor 必须是患者本人或患者的代理人
+func (s *ConsentService) Grant(actor *models.User, req models.ConsentGrantRequest) (*models.Consent, error) {
+	patientID := req.PatientID
+	if patientID == "" {
+		patientID = actor.PatientID
+	}
+	if patientID == "" {
+		return nil, errors.New("缺少患者标识")
+	}
+
+	if !models.ValidPurposes[req.Purpose] {
+		return nil, fmt.Errorf("不支持的使用目的: %s", req.Purpose)
+	}
+
+	validFrom := req.ValidFrom
+	if validFrom.IsZero() {
+		validFrom = time.Now()
+	}
+	if !req.ValidUntil.After(validFrom) {
+		return nil, errors.New("失效时间必须晚于生效时间")
+	}
+
+	s.mu.Lock()
+	if !s.canManageLocked(actor, patientID) {
+		s.mu.Unlock()
+		return nil, ErrConsentForbidden
+	}
+
+	consent := &models.Consent{
+		ID:           uuid.New().String(),
+		PatientID:    patientID,
+		GrantedBy:    actor.ID,
+		GranteeID:    req.GranteeID,
+		Purpose:      req.Purpose,
+		DataTypes:    req.DataTypes,
+		ValidFrom:    validFrom,
+		ValidUntil:   req.ValidUntil,
+		Status:       models.ConsentStatusActive,
+		CreatedAt:    time.Now(),
+		ChainRecords: make(map[string]string),
+	}
+	s.store.Consents[consent.ID] = consent
+
+	if err := s.saveLocked(); err != nil {
+		delete(s.store.Consents, consent.ID)
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.mu.Unlock()
+
+	log.Printf("知情同意已授予: ID=%s, 被授权人=%s, 目的=%s", consent.ID, consent.GranteeID, consent.Purpose)
+
+	// 写入链上知情同意记录
+	s.anchor(consent, "grant")
+
+	return s.GetConsent(consent.ID)
+}
+
+// Revoke 撤销知情同意
+func (s *ConsentService) Revoke(actor *models.User, consentID string) (*models.Consent, error) {
+	s.mu.Lock()
+	consent, exists := s.store.Consents[consentID]
+	if !exists {
+		s.mu.Unlock()
+		return nil, ErrConsentNotFound
+	}
+	if !s.canManageLocked(actor, consent.PatientID) {
+		s.mu.Unlock()
+		return nil, ErrConsentForbidden
+	}
+	if consent.Status == models.ConsentStatusRevoked {
+		s.mu.Unlock()
+		return nil, errors.New("知情同意已被撤销")
+	}
+
+	now := time.Now()
+	consent.Status = models.ConsentStatusRevoked
+	consent.RevokedAt = &now
+	consent.RevokedBy = actor.ID
+
+	if err := s.saveLocked(); err != nil {
+		consent.Status = models.ConsentStatusActive
+		consent.RevokedAt = nil
+		consent.RevokedBy = ""
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.mu.Unlock()
+
+	log.Printf("知情同意已撤销: ID=%s, 操作人=%s", consentID, actor.ID)
+
+	// 写入链上撤销记录
+	s.anchor(consent, "revoke")
+
+	return s.GetConsent(consentID)
+}
+
+// GetConsent 根据ID获取知情同意
+func (s *ConsentService) GetConsent(consentID string) (*models.Consent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	consent, exists := s.store.Consents[consentID]
+	if !exists {
+		return nil, ErrConsentNotFound
+	}
+
+	copied := *consent
+	return &copied, nil
+}
+
+// ListForUser 获取与用户相关的知情同意
+// 包括用户作为患者、代理人或被授权人的记录
+func (s *ConsentService) ListForUser(user *models.User) []models.Consent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var consents []models.Consent
+	for _, consent := range s.store.Consents {
+		if consent.GranteeID == user.ID || s.canManageLocked(user, consent.PatientID) {
+			consents = append(consents, *consent)
+		}
+	}
+
+	sort.Slice(consents, func(i, j int) bool {
+		return consents[i].CreatedAt.After(consents[j].CreatedAt)
+	})
+
+	return consents
+}
+
+// IsPermitted 检查被授权人是否获得了患者对指定数据类型和目的的有效知情同意
+func (s *ConsentService) IsPermitted(granteeID, patientID, dataType, purpose string, at time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, consent := range s.store.Consents {
+		if consent.PatientID == patientID && consent.Covers(granteeID, purpose, dataType, at) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddProxy 为患者添加代理人
+func (s *ConsentService) AddProxy(patient *models.User, proxyUserID string) (*models.ConsentProxy, error) {
+	if patient.PatientID == "" {
+		return nil, ErrConsentForbidden
+	}
+	if proxyUserID == patient.ID {
+		return nil, errors.New("不能将自己设置为代理人")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isProxyLocked(proxyUserID, patient.PatientID) {
+		return nil, errors.New("代理人已存在")
+	}
+
+	proxy := models.ConsentProxy{
+		PatientID:   patient.PatientID,
+		ProxyUserID: proxyUserID,
+		CreatedAt:   time.Now(),
+	}
+	s.store.Proxies = append(s.store.Proxies, proxy)
+
+	if err := s.saveLocked(); err != nil {
+		s.store.Proxies = s.store.Proxies[:len(s.store.Proxies)-1]
+		return nil, err
+	}
+
+	return &proxy, nil
+}
+
+// RemoveProxy 移除患者的代理人
+func (s *ConsentService) RemoveProxy(patient *models.User, proxyUserID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.store.Proxies
+	var remaining []models.ConsentProxy
+	for _, proxy := range s.store.Proxies {
+		if proxy.PatientID == patient.PatientID && proxy.ProxyUserID == proxyUserID {
+			continue
+		}
+		remaining = append(remaining, proxy)
+	}
+
+	if len(remaining) == len(previous) {
+		return errors.New("代理人不存在")
+	}
+
+	s.store.Proxies = remaining
+	if err := s.saveLocked(); err != nil {
+		s.store.Proxies = previous
+		return err
+	}
+
+	return nil
+}
+
+// ListProxies 获取患者的代理人列表
+func (s *ConsentService) ListProxies(patientID string) []models.ConsentProxy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var proxies []models.ConsentProxy
+	for _, proxy := range s.store.Proxies {
+		if proxy.PatientID == patientID {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	return proxies
+}
+
+// 检查用户是否可以管理患者的知情同意（调用方需持有锁）
+func (s *ConsentService) canManageLocked(user *models.User, patientID string) bool {
+	if patientID == "" {
+		return false
+	}
+	if user.PatientID == patientID {
+		return true
+	}
+	return s.isProxyLocked(user.ID, patientID)
+}
+
+// 检查用户是否为患者的代理人（调用方需持有锁）
+func (s *ConsentService) isProxyLocked(userID, patientID string) bool {
+	for _, proxy := range s.store.Proxies {
+		if proxy.PatientID == patientID && proxy.ProxyUserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// 持久化知情同意（调用方需持有锁）
+func (s *ConsentService) saveLocked() error {
+	if err := utils.SaveJSONFile(s.storePath, s.store); err != nil {
+		log.Printf("保存知情同意记录失败: %v", err)
+		return fmt.Errorf("保存知情同意记录失败: %w", err)
+	}
+	return nil
+}
+
+// 将知情同意写入链上
+// 链上只记录患者标识的哈希，不记录明文；写入失败不影响本地记录，只记录日志
+func (s *ConsentService) anchor(consent *models.Consent, action string) {
+	patientHash := sha256.Sum256([]byte(consent.PatientID))
+	payload := map[string]interface{}{
+		"action":      action,
+		"consentId":   consent.ID,
+		"patientHash": hex.EncodeToString(patientHash[:]),
+		"granteeId":   consent.GranteeID,
+		"purpose":     consent.Purpose,
+		"dataTypes":   consent.DataTypes,
+		"validFrom":   consent.ValidFrom.Unix(),
+		"validUntil":  consent.ValidUntil.Unix(),
+		"status":      consent.Status,
+	}
+
+	for _, chain := range consentChains {
+		txHash, err := s.gatewayService.SubmitBlockchainTransaction(chain, "consent", payload)
+		if err != nil {
+			log.Printf("知情同意上链失败: ID=%s, 链=%s, 错误=%v", consent.ID, chain, err)
+			continue
+		}
+
+		s.mu.Lock()
+		if stored, exists := s.store.Consents[consent.ID]; exists {
+			if stored.ChainRecords == nil {
+				stored.ChainRecords = make(map[string]string)
+			}
+			stored.ChainRecords[chain+":"+action] = txHash
+			if err := s.saveLocked(); err != nil {
+				log.Printf("保存链上交易哈希失败: %v", err)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
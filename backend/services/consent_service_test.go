@@ -0,0 +1,118 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"medcross/models"
+)
+
+func newConsentFixture(t *testing.T) *ConsentService {
+	t.Helper()
+
+	t.Setenv("DATA_DIR", t.TempDir())
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"transactionHash": "0xconsent"})
+	}))
+	t.Cleanup(gateway.Close)
+	t.Setenv("GATEWAY_URL", gateway.URL)
+
+	return NewConsentService(NewGatewayService())
+}
+
+func TestConsentCoversScopeAndExpiry(t *testing.T) {
+	service := newConsentFixture(t)
+	patient := &models.User{ID: "u-patient", PatientID: "P1", Role: models.RolePatient}
+
+	now := time.Now()
+	_, err := service.Grant(patient, models.ConsentGrantRequest{
+		GranteeID:  "u-researcher",
+		Purpose:    models.PurposeResearch,
+		DataTypes:  []string{"影像数据"},
+		ValidFrom:  now.Add(-time.Hour),
+		ValidUntil: now.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("授予知情同意失败: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		grantee   string
+		patientID string
+		dataType  string
+		purpose   string
+		at        time.Time
+		want      bool
+	}{
+		{name: "范围内", grantee: "u-researcher", patientID: "P1", dataType: "影像数据", purpose: models.PurposeResearch, at: now, want: true},
+		{name: "其他被授权人", grantee: "u-other", patientID: "P1", dataType: "影像数据", purpose: models.PurposeResearch, at: now},
+		{name: "其他患者", grantee: "u-researcher", patientID: "P2", dataType: "影像数据", purpose: models.PurposeResearch, at: now},
+		{name: "其他数据类型", grantee: "u-researcher", patientID: "P1", dataType: "检验报告", purpose: models.PurposeResearch, at: now},
+		{name: "其他目的", grantee: "u-researcher", patientID: "P1", dataType: "影像数据", purpose: models.PurposeInsurance, at: now},
+		{name: "尚未生效", grantee: "u-researcher", patientID: "P1", dataType: "影像数据", purpose: models.PurposeResearch, at: now.Add(-2 * time.Hour)},
+		{name: "恰好到期", grantee: "u-researcher", patientID: "P1", dataType: "影像数据", purpose: models.PurposeResearch, at: now.Add(time.Hour)},
+		{name: "已过期", grantee: "u-researcher", patientID: "P1", dataType: "影像数据", purpose: models.PurposeResearch, at: now.Add(2 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := service.IsPermitted(tt.grantee, tt.patientID, tt.dataType, tt.purpose, tt.at); got != tt.want {
+				t.Errorf("IsPermitted = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsentRevocation(t *testing.T) {
+	service := newConsentFixture(t)
+	patient := &models.User{ID: "u-patient", PatientID: "P1", Role: models.RolePatient}
+	other := &models.User{ID: "u-other", PatientID: "P2", Role: models.RolePatient}
+
+	consent, err := service.Grant(patient, models.ConsentGrantRequest{
+		GranteeID:  "u-doctor",
+		Purpose:    models.PurposeTreatment,
+		ValidUntil: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("授予知情同意失败: %v", err)
+	}
+
+	// 未指定生效时间时立即生效
+	now := time.Now()
+	if !service.IsPermitted("u-doctor", "P1", "影像数据", models.PurposeTreatment, now) {
+		t.Fatal("未限定数据类型的知情同意应覆盖所有类型")
+	}
+
+	tests := []struct {
+		name      string
+		actor     *models.User
+		wantErr   bool
+		permitted bool
+	}{
+		{name: "其他患者不能撤销", actor: other, wantErr: true, permitted: true},
+		{name: "患者本人撤销", actor: patient, permitted: false},
+		{name: "不能重复撤销", actor: patient, wantErr: true, permitted: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Revoke(tt.actor, consent.ID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Revoke 错误 = %v, 期望出错 %v", err, tt.wantErr)
+			}
+			if got := service.IsPermitted("u-doctor", "P1", "影像数据", models.PurposeTreatment, now); got != tt.permitted {
+				t.Errorf("IsPermitted = %v, 期望 %v", got, tt.permitted)
+			}
+		})
+	}
+
+	// 撤销状态持久化，重新加载后仍然无效
+	reloaded := NewConsentService(NewGatewayService())
+	if reloaded.IsPermitted("u-doctor", "P1", "影像数据", models.PurposeTreatment, now) {
+		t.Error("重新加载后已撤销的知情同意不应生效")
+	}
+}
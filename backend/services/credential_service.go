@@ -0,0 +1,579 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// 执业凭证错误
+var (
+	ErrCredentialNotFound     = errors.New("凭证不存在")
+	ErrCredentialRequired     = errors.New("需要所属医院签发的有效执业凭证")
+	ErrCredentialInvalid      = errors.New("凭证无效")
+	ErrCredentialUserNotFound = errors.New("用户不存在")
+	ErrCredentialRoleExempt   = errors.New("该角色不需要执业凭证")
+	ErrCredentialNoHospital   = errors.New("用户没有所属医院，无法签发执业凭证")
+	ErrCredentialNoDID        = errors.New("用户还没有DID，需要先登录一次")
+	ErrCredentialUserInactive = errors.New("用户尚未通过审核，不能签发执业凭证")
+	ErrCredentialForbidden    = errors.New("只能管理本医院用户的执业凭证")
+	ErrStatusListFull         = errors.New("医院的凭证状态列表已满")
+)
+
+// 状态列表长度（位），StatusList2021规定至少16KB以保护持有者隐私
+const statusListSize = 131072
+
+// credentialStore 执业凭证的持久化结构
+type credentialStore struct {
+	Credentials map[string]*models.IssuedCredential `json:"credentials"` // 凭证ID -> 凭证
+	NextIndex   map[string]int                      `json:"nextIndex"`   // 医院slug -> 下一个状态列表位置
+}
+
+// CredentialService 可验证凭证服务
+// 医院以其did:web身份签发JWT格式的W3C可验证凭证（VC-JWT，ES256），证明用户的角色、医院和科室。
+// 撤销状态通过每个医院一个的StatusList2021状态列表凭证公开，其他部署可以独立验证凭证和撤销状态。
+// 医生和研究人员必须持有与当前资料一致的有效凭证才能执行临床操作
+type CredentialService struct {
+	mu          sync.RWMutex
+	store       credentialStore
+	storePath   string
+	byUser      map[string][]string // userID -> 凭证ID
+	validity    time.Duration
+	required    bool
+	didService  *DIDService
+	userService *UserService
+	httpClient  *http.Client
+}
+
+// NewCredentialService 创建新的可验证凭证服务
+// CREDENTIAL_VALIDITY 为凭证默认有效期（默认8760h）；REQUIRE_CLINICIAN_CREDENTIAL=false 时不检查执业凭证，仅用于开发
+func NewCredentialService(didService *DIDService, userService *UserService) *CredentialService {
+	validity, err := time.ParseDuration(os.Getenv("CREDENTIAL_VALIDITY"))
+	if err != nil || validity <= 0 {
+		validity = 365 * 24 * time.Hour
+	}
+	required := true
+	if value := os.Getenv("REQUIRE_CLINICIAN_CREDENTIAL"); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			required = parsed
+		}
+	}
+	if !required {
+		log.Printf("警告: 未启用执业凭证检查，医生和研究人员无需凭证即可执行临床操作")
+	}
+
+	service := &CredentialService{
+		store: credentialStore{
+			Credentials: make(map[string]*models.IssuedCredential),
+			NextIndex:   make(map[string]int),
+		},
+		storePath:   utils.DataFilePath("credentials.json"),
+		byUser:      make(map[string][]string),
+		validity:    validity,
+		required:    required,
+		didService:  didService,
+		userService: userService,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+
+	// 加载已签发的凭证
+	if err := utils.LoadJSONFile(service.storePath, &service.store); err != nil {
+		log.Printf("加载执业凭证失败: %v", err)
+	}
+	if service.store.Credentials == nil {
+		service.store.Credentials = make(map[string]*models.IssuedCredential)
+	}
+	if service.store.NextIndex == nil {
+		service.store.NextIndex = make(map[string]int)
+	}
+	for id, credential := range service.store.Credentials {
+		service.byUser[credential.UserID] = append(service.byUser[credential.UserID], id)
+	}
+
+	return service
+}
+
+// Issue 以用户所属医院的身份签发执业凭证
+// 角色、医院和科室取自用户当前资料；用户之前的有效凭证被撤销，同一时间只有一个有效凭证。
+// 医院管理员只能为本医院的用户签发
+func (s *CredentialService) Issue(req models.CredentialIssueRequest, issuedBy string) (*models.IssuedCredential, error) {
+	user, err := s.userService.GetUserByID(req.UserID)
+	if err != nil {
+		return nil, ErrCredentialUserNotFound
+	}
+	if hospital := s.userService.ManagedHospital(issuedBy); hospital != "" && hospital != user.Hospital {
+		return nil, ErrCredentialForbidden
+	}
+	if user.Status != models.UserStatusActive {
+		return nil, ErrCredentialUserInactive
+	}
+	if !models.CredentialRequiredRoles[user.Role] {
+		return nil, ErrCredentialRoleExempt
+	}
+	if user.Hospital == "" {
+		return nil, ErrCredentialNoHospital
+	}
+	if user.DID == "" {
+		return nil, ErrCredentialNoDID
+	}
+
+	hospital, key, err := s.didService.HospitalSigner(user.Hospital)
+	if err != nil {
+		return nil, err
+	}
+
+	validity := s.validity
+	if req.ValidDays > 0 {
+		validity = time.Duration(req.ValidDays) * 24 * time.Hour
+	}
+	now := time.Now().Truncate(time.Second)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := s.store.NextIndex[hospital.Slug]
+	if index >= statusListSize {
+		return nil, ErrStatusListFull
+	}
+
+	credential := &models.IssuedCredential{
+		ID:              "urn:uuid:" + uuid.New().String(),
+		UserID:          user.ID,
+		Issuer:          hospital.DID,
+		HospitalSlug:    hospital.Slug,
+		SubjectDID:      user.DID,
+		Role:            user.Role,
+		Hospital:        user.Hospital,
+		Department:      user.Department,
+		StatusListIndex: index,
+		IssuedBy:        issuedBy,
+		IssuedAt:        now,
+		ExpiresAt:       now.Add(validity),
+	}
+
+	statusListURL := s.statusListURL(hospital.Slug)
+	subject := map[string]interface{}{
+		"id":       credential.SubjectDID,
+		"role":     credential.Role,
+		"hospital": credential.Hospital,
+	}
+	if credential.Department != "" {
+		subject["department"] = credential.Department
+	}
+	vc := models.VerifiableCredential{
+		Context:           models.CredentialContexts,
+		Type:              models.ClinicianCredentialTypes,
+		CredentialSubject: subject,
+		CredentialStatus: &models.CredentialStatusEntry{
+			ID:                   statusListURL + "#" + strconv.Itoa(index),
+			Type:                 "StatusList2021Entry",
+			StatusPurpose:        models.StatusPurposeRevocation,
+			StatusListIndex:      strconv.Itoa(index),
+			StatusListCredential: statusListURL,
+		},
+	}
+	credential.JWT, err = signCredential(hospital.DID, key, jwt.MapClaims{
+		"iss": hospital.DID,
+		"sub": credential.SubjectDID,
+		"jti": credential.ID,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": credential.ExpiresAt.Unix(),
+		"vc":  vc,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("签名执业凭证失败: %w", err)
+	}
+
+	// 撤销用户之前的有效凭证
+	var superseded []*models.IssuedCredential
+	for _, id := range s.byUser[user.ID] {
+		previous := s.store.Credentials[id]
+		if !previous.Revoked {
+			previous.Revoked = true
+			previous.RevokedAt = &now
+			previous.RevokedBy = issuedBy
+			previous.RevocationReason = "已签发新凭证"
+			superseded = append(superseded, previous)
+		}
+	}
+
+	s.store.Credentials[credential.ID] = credential
+	s.store.NextIndex[hospital.Slug] = index + 1
+	if err := s.saveLocked(); err != nil {
+		delete(s.store.Credentials, credential.ID)
+		s.store.NextIndex[hospital.Slug] = index
+		for _, previous := range superseded {
+			previous.Revoked = false
+			previous.RevokedAt = nil
+			previous.RevokedBy = ""
+			previous.RevocationReason = ""
+		}
+		return nil, err
+	}
+	s.byUser[user.ID] = append(s.byUser[user.ID], credential.ID)
+
+	log.Printf("签发执业凭证: 用户=%s, 签发者=%s, 角色=%s, 凭证=%s", user.ID, hospital.DID, user.Role, credential.ID)
+	result := *credential
+	return &result, nil
+}
+
+// Revoke 撤销执业凭证，撤销后立即在状态列表中生效；医院管理员只能撤销本医院的凭证
+func (s *CredentialService) Revoke(id, revokedBy, reason string) (*models.IssuedCredential, error) {
+	managedHospital := s.userService.ManagedHospital(revokedBy)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	credential, exists := s.store.Credentials[id]
+	if !exists {
+		return nil, ErrCredentialNotFound
+	}
+	if managedHospital != "" && managedHospital != credential.Hospital {
+		return nil, ErrCredentialForbidden
+	}
+	if !credential.Revoked {
+		now := time.Now()
+		credential.Revoked = true
+		credential.RevokedAt = &now
+		credential.RevokedBy = revokedBy
+		credential.RevocationReason = reason
+		if err := s.saveLocked(); err != nil {
+			credential.Revoked = false
+			credential.RevokedAt = nil
+			credential.RevokedBy = ""
+			credential.RevocationReason = ""
+			return nil, err
+		}
+		log.Printf("撤销执业凭证: 凭证=%s, 用户=%s, 操作人=%s", id, credential.UserID, revokedBy)
+	}
+
+	result := *credential
+	return &result, nil
+}
+
+// ListCredentials 获取执业凭证列表，userID为空时返回全部，hospital非空时只返回该医院签发的凭证
+func (s *CredentialService) ListCredentials(userID, hospital string) []models.IssuedCredential {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	credentials := make([]models.IssuedCredential, 0)
+	for _, credential := range s.store.Credentials {
+		if (userID == "" || credential.UserID == userID) && (hospital == "" || credential.Hospital == hospital) {
+			credentials = append(credentials, *credential)
+		}
+	}
+	return credentials
+}
+
+// ManagedHospital 获取操作人可以管理的医院，医院管理员只能管理本医院的凭证，其他管理员不限
+func (s *CredentialService) ManagedHospital(userID string) string {
+	return s.userService.ManagedHospital(userID)
+}
+
+// HasValidCredential 检查用户是否可以以当前角色执行临床操作
+// 不需要凭证的角色直接通过；需要凭证的角色必须已通过审核，并持有未撤销、未过期，且角色、医院、科室和DID与当前资料一致的凭证
+func (s *CredentialService) HasValidCredential(userID, role string) bool {
+	if !s.required || !models.CredentialRequiredRoles[role] {
+		return true
+	}
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil || user.Status != models.UserStatusActive {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	for _, id := range s.byUser[userID] {
+		credential := s.store.Credentials[id]
+		if credential.Status(now) == models.CredentialStatusActive &&
+			credential.Role == user.Role &&
+			credential.Hospital == user.Hospital &&
+			credential.Department == user.Department &&
+			credential.SubjectDID == user.DID {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusListCredential 生成医院的StatusList2021状态列表凭证（JWT），撤销的凭证对应位为1
+func (s *CredentialService) StatusListCredential(slug string) (string, error) {
+	hospital, err := s.didService.GetHospital(slug)
+	if err != nil {
+		return "", err
+	}
+	_, key, err := s.didService.HospitalSigner(hospital.Name)
+	if err != nil {
+		return "", err
+	}
+
+	encodedList, err := encodeStatusList(s.revokedIndexes(slug))
+	if err != nil {
+		return "", err
+	}
+
+	statusListURL := s.statusListURL(slug)
+	now := time.Now().Unix()
+	return signCredential(hospital.DID, key, jwt.MapClaims{
+		"iss": hospital.DID,
+		"jti": statusListURL,
+		"iat": now,
+		"nbf": now,
+		"vc": models.VerifiableCredential{
+			Context: models.CredentialContexts,
+			Type:    models.StatusListCredentialTypes,
+			CredentialSubject: map[string]interface{}{
+				"id":            statusListURL + "#list",
+				"type":          "StatusList2021",
+				"statusPurpose": models.StatusPurposeRevocation,
+				"encodedList":   encodedList,
+			},
+		},
+	})
+}
+
+// Verify 验证JWT格式的可验证凭证
+// 通过签发者的DID文档获取验证公钥，检查签名、有效期和状态列表中的撤销状态；支持其他部署签发的凭证
+func (s *CredentialService) Verify(tokenString string) *models.CredentialVerification {
+	result := &models.CredentialVerification{}
+
+	claims, vc, err := s.parseCredential(tokenString)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.ID, _ = claims["jti"].(string)
+	result.Issuer, _ = claims["iss"].(string)
+	result.Subject = vc.CredentialSubject
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		result.IssuedAt = &issuedAt.Time
+	}
+	if expiresAt, err := claims.GetExpirationTime(); err == nil && expiresAt != nil {
+		result.ExpiresAt = &expiresAt.Time
+	}
+
+	s.mu.RLock()
+	_, result.LocalIssue = s.store.Credentials[result.ID]
+	s.mu.RUnlock()
+
+	if vc.CredentialStatus != nil {
+		revoked, err := s.checkStatus(result.Issuer, vc.CredentialStatus)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		if revoked {
+			result.Status = models.CredentialStatusRevoked
+			return result
+		}
+	}
+
+	result.Valid = true
+	result.Status = models.CredentialStatusActive
+	return result
+}
+
+// 验证凭证签名和有效期，返回JWT声明和vc内容
+func (s *CredentialService) parseCredential(tokenString string) (jwt.MapClaims, *models.VerifiableCredential, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		issuer, _ := token.Claims.(jwt.MapClaims)["iss"].(string)
+		if !utils.IsDID(issuer) {
+			return nil, errors.New("签发者不是DID")
+		}
+		keyID, _ := token.Header["kid"].(string)
+		if keyID == "" || (!strings.HasPrefix(keyID, "#") && !strings.HasPrefix(keyID, issuer+"#")) {
+			return nil, errors.New("签名密钥不属于签发者")
+		}
+		return s.didService.VerificationKey(issuer, keyID)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrCredentialInvalid, err)
+	}
+	if _, exists := claims["exp"]; !exists {
+		return nil, nil, fmt.Errorf("%w: 缺少有效期", ErrCredentialInvalid)
+	}
+
+	raw, err := json.Marshal(claims["vc"])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: 缺少vc声明", ErrCredentialInvalid)
+	}
+	var vc models.VerifiableCredential
+	if err := json.Unmarshal(raw, &vc); err != nil || !containsString(vc.Type, "VerifiableCredential") {
+		return nil, nil, fmt.Errorf("%w: vc声明格式无效", ErrCredentialInvalid)
+	}
+	if subjectID, _ := vc.CredentialSubject["id"].(string); subjectID != "" {
+		if sub, _ := claims["sub"].(string); sub != subjectID {
+			return nil, nil, fmt.Errorf("%w: sub与凭证主体不一致", ErrCredentialInvalid)
+		}
+	}
+	return claims, &vc, nil
+}
+
+// 检查凭证在状态列表中是否已撤销
+// 本平台的状态列表直接读取，其他部署的状态列表凭证通过HTTP获取并验证签名
+func (s *CredentialService) checkStatus(issuer string, status *models.CredentialStatusEntry) (bool, error) {
+	if status.Type != "StatusList2021Entry" || status.StatusPurpose != models.StatusPurposeRevocation {
+		return false, fmt.Errorf("%w: 不支持的凭证状态类型", ErrCredentialInvalid)
+	}
+	index, err := strconv.Atoi(status.StatusListIndex)
+	if err != nil || index < 0 {
+		return false, fmt.Errorf("%w: 状态列表位置无效", ErrCredentialInvalid)
+	}
+
+	localPrefix := s.statusListURL("")
+	if strings.HasPrefix(status.StatusListCredential, localPrefix) {
+		slug := strings.TrimPrefix(status.StatusListCredential, localPrefix)
+		hospital, err := s.didService.GetHospital(slug)
+		if err != nil || hospital.DID != issuer {
+			return false, fmt.Errorf("%w: 状态列表不属于签发者", ErrCredentialInvalid)
+		}
+		for _, revoked := range s.revokedIndexes(slug) {
+			if revoked == index {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	resp, err := s.httpClient.Get(status.StatusListCredential)
+	if err != nil {
+		return false, fmt.Errorf("获取状态列表失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("获取状态列表失败: 状态码 %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return false, fmt.Errorf("获取状态列表失败: %v", err)
+	}
+
+	claims, listVC, err := s.parseStatusList(strings.TrimSpace(string(body)))
+	if err != nil {
+		return false, err
+	}
+	if listIssuer, _ := claims["iss"].(string); listIssuer != issuer {
+		return false, fmt.Errorf("%w: 状态列表不属于签发者", ErrCredentialInvalid)
+	}
+	encodedList, _ := listVC.CredentialSubject["encodedList"].(string)
+	return statusListBit(encodedList, index)
+}
+
+// 验证状态列表凭证，状态列表凭证没有有效期要求
+func (s *CredentialService) parseStatusList(tokenString string) (jwt.MapClaims, *models.VerifiableCredential, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		issuer, _ := token.Claims.(jwt.MapClaims)["iss"].(string)
+		keyID, _ := token.Header["kid"].(string)
+		if !utils.IsDID(issuer) || keyID == "" {
+			return nil, errors.New("状态列表签发者无效")
+		}
+		return s.didService.VerificationKey(issuer, keyID)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: 状态列表签名无效: %v", ErrCredentialInvalid, err)
+	}
+
+	raw, _ := json.Marshal(claims["vc"])
+	var vc models.VerifiableCredential
+	if err := json.Unmarshal(raw, &vc); err != nil || !containsString(vc.Type, "StatusList2021Credential") {
+		return nil, nil, fmt.Errorf("%w: 状态列表格式无效", ErrCredentialInvalid)
+	}
+	return claims, &vc, nil
+}
+
+// 医院已撤销凭证的状态列表位置
+func (s *CredentialService) revokedIndexes(slug string) []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var indexes []int
+	for _, credential := range s.store.Credentials {
+		if credential.HospitalSlug == slug && credential.Revoked {
+			indexes = append(indexes, credential.StatusListIndex)
+		}
+	}
+	return indexes
+}
+
+// 医院状态列表凭证的公开地址
+func (s *CredentialService) statusListURL(slug string) string {
+	return s.didService.BaseURL() + "/credentials/status/" + slug
+}
+
+// 持久化凭证（调用方需持有锁）
+func (s *CredentialService) saveLocked() error {
+	if err := utils.SaveJSONFile(s.storePath, s.store); err != nil {
+		log.Printf("保存执业凭证失败: %v", err)
+		return fmt.Errorf("保存执业凭证失败: %w", err)
+	}
+	return nil
+}
+
+// 使用医院私钥以ES256签名凭证，kid为医院DID文档中的验证方法
+func signCredential(issuer string, key *ecdsa.PrivateKey, claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = issuer + "#key-1"
+	return token.SignedString(key)
+}
+
+// 按StatusList2021编码状态列表：位串GZIP压缩后base64url编码，位置0为第一个字节的最高位
+func encodeStatusList(indexes []int) (string, error) {
+	bits := make([]byte, statusListSize/8)
+	for _, index := range indexes {
+		bits[index/8] |= 0x80 >> uint(index%8)
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(bits); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// 读取状态列表中指定位置的值
+func statusListBit(encodedList string, index int) (bool, error) {
+	compressed, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encodedList, "="))
+	if err != nil {
+		return false, fmt.Errorf("%w: 状态列表编码无效", ErrCredentialInvalid)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return false, fmt.Errorf("%w: 状态列表编码无效", ErrCredentialInvalid)
+	}
+	bits, err := io.ReadAll(io.LimitReader(reader, 16<<20))
+	if err != nil {
+		return false, fmt.Errorf("%w: 状态列表编码无效", ErrCredentialInvalid)
+	}
+	if index/8 >= len(bits) {
+		return false, fmt.Errorf("%w: 状态列表位置超出范围", ErrCredentialInvalid)
+	}
+	return bits[index/8]&(0x80>>uint(index%8)) != 0, nil
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"medcross/models"
+)
+
+// 执业凭证测试环境：协和医院已入驻，doctor已通过审核并分配了DID
+type credentialFixture struct {
+	service     *CredentialService
+	userService *UserService
+	doctorID    string
+	patientID   string
+}
+
+func newCredentialFixture(t *testing.T) *credentialFixture {
+	t.Helper()
+
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "Adm1n-Passw0rd")
+	t.Setenv("REQUIRE_CLINICIAN_CREDENTIAL", "")
+
+	userService := NewUserService()
+	didService := NewDIDService(NewIdentityService(nil, nil), userService)
+	if _, err := didService.RegisterHospital("协和医院"); err != nil {
+		t.Fatalf("医院入驻失败: %v", err)
+	}
+
+	fixture := &credentialFixture{userService: userService}
+	for _, registration := range []models.UserRegister{
+		{Username: "doctor", Role: models.RoleDoctor, Hospital: "协和医院"},
+		{Username: "patient", Role: models.RolePatient},
+	} {
+		registration.Password = "password123"
+		registration.Name = registration.Username
+		userID, err := userService.CreateUser(registration)
+		if err != nil {
+			t.Fatalf("创建用户 %s 失败: %v", registration.Username, err)
+		}
+		user, err := userService.ReviewUser(userID, models.UserStatusActive, "", "心内科", "admin", "")
+		if err != nil {
+			t.Fatalf("审核用户 %s 失败: %v", registration.Username, err)
+		}
+		if _, err := didService.AssignUserDID(user); err != nil {
+			t.Fatalf("分配DID失败: %v", err)
+		}
+		if registration.Role == models.RoleDoctor {
+			fixture.doctorID = userID
+		} else {
+			fixture.patientID = userID
+		}
+	}
+
+	fixture.service = NewCredentialService(didService, userService)
+	return fixture
+}
+
+func (f *credentialFixture) issue(t *testing.T) *models.IssuedCredential {
+	t.Helper()
+
+	credential, err := f.service.Issue(models.CredentialIssueRequest{UserID: f.doctorID}, "admin")
+	if err != nil {
+		t.Fatalf("签发执业凭证失败: %v", err)
+	}
+	return credential
+}
+
+func TestHasValidCredential(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, f *credentialFixture)
+		role  string
+		want  bool
+	}{
+		{
+			name:  "没有凭证",
+			setup: func(t *testing.T, f *credentialFixture) {},
+		},
+		{
+			name:  "持有有效凭证",
+			setup: func(t *testing.T, f *credentialFixture) { f.issue(t) },
+			want:  true,
+		},
+		{
+			name: "凭证被撤销",
+			setup: func(t *testing.T, f *credentialFixture) {
+				credential := f.issue(t)
+				if _, err := f.service.Revoke(credential.ID, "admin", "离职"); err != nil {
+					t.Fatalf("撤销凭证失败: %v", err)
+				}
+			},
+		},
+		{
+			name: "撤销后重新签发",
+			setup: func(t *testing.T, f *credentialFixture) {
+				credential := f.issue(t)
+				if _, err := f.service.Revoke(credential.ID, "admin", "离职"); err != nil {
+					t.Fatalf("撤销凭证失败: %v", err)
+				}
+				f.issue(t)
+			},
+			want: true,
+		},
+		{
+			name: "撤销被新凭证取代的旧凭证不影响新凭证",
+			setup: func(t *testing.T, f *credentialFixture) {
+				previous := f.issue(t)
+				f.issue(t)
+				if _, err := f.service.Revoke(previous.ID, "admin", "补充撤销"); err != nil {
+					t.Fatalf("撤销凭证失败: %v", err)
+				}
+			},
+			want: true,
+		},
+		{
+			name: "凭证已过期",
+			setup: func(t *testing.T, f *credentialFixture) {
+				credential := f.issue(t)
+				f.service.store.Credentials[credential.ID].ExpiresAt = time.Now().Add(-time.Minute)
+			},
+		},
+		{
+			name: "科室与凭证不一致",
+			setup: func(t *testing.T, f *credentialFixture) {
+				f.issue(t)
+				if _, err := f.userService.SetDepartment(f.doctorID, "神经内科"); err != nil {
+					t.Fatalf("修改科室失败: %v", err)
+				}
+			},
+		},
+		{
+			name: "用户已停用",
+			setup: func(t *testing.T, f *credentialFixture) {
+				f.issue(t)
+				if _, err := f.userService.SetStatus(f.doctorID, models.UserStatusDisabled, "admin", "停用"); err != nil {
+					t.Fatalf("停用用户失败: %v", err)
+				}
+			},
+		},
+		{
+			name:  "不需要凭证的角色",
+			setup: func(t *testing.T, f *credentialFixture) {},
+			role:  models.RolePatient,
+			want:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newCredentialFixture(t)
+			tt.setup(t, f)
+
+			userID, role := f.doctorID, models.RoleDoctor
+			if tt.role != "" {
+				userID, role = f.patientID, tt.role
+			}
+			if got := f.service.HasValidCredential(userID, role); got != tt.want {
+				t.Errorf("HasValidCredential = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialRevocationPersists(t *testing.T) {
+	f := newCredentialFixture(t)
+	credential := f.issue(t)
+	if _, err := f.service.Revoke(credential.ID, "admin", "离职"); err != nil {
+		t.Fatalf("撤销凭证失败: %v", err)
+	}
+
+	reloaded := NewCredentialService(f.service.didService, f.userService)
+	if reloaded.HasValidCredential(f.doctorID, models.RoleDoctor) {
+		t.Error("重新加载后被撤销的凭证不应有效")
+	}
+	if verification := reloaded.Verify(credential.JWT); verification.Valid || verification.Status != models.CredentialStatusRevoked {
+		t.Errorf("被撤销的凭证验证结果 = %+v, 期望状态为 %s", verification, models.CredentialStatusRevoked)
+	}
+}
@@ -15,13 +15,21 @@ import (
 type DataService struct {
 	// 在实际应用中，这里应该有数据库连接和文件存储服务
 	// 为了演示，我们使用内存存储
-	data map[string]*models.MedicalData
+	data  map[string]*models.MedicalData
+	files map[string]storedFile // dataHash -> 文件内容
+}
+
+// storedFile 已存储的文件
+type storedFile struct {
+	Name    string
+	Content []byte
 }
 
 // NewDataService 创建新的数据服务
 func NewDataService() *DataService {
 	return &DataService{
-		data: make(map[string]*models.MedicalData),
+		data:  make(map[string]*models.MedicalData),
+		files: make(map[string]storedFile),
 	}
 }
 
@@ -48,6 +56,28 @@ func (s *DataService) GetDataByID(dataID string) (*models.MedicalData, error) {
 	return data, nil
 }
 
+// ListData 获取本地保存的全部医疗数据
+func (s *DataService) ListData() []models.MedicalData {
+	records := make([]models.MedicalData, 0, len(s.data))
+	for _, data := range s.data {
+		records = append(records, *data)
+	}
+
+	return records
+}
+
+// ListDataByPatient 获取本地数据库中属于指定患者的医疗数据
+func (s *DataService) ListDataByPatient(patientID string) []models.MedicalData {
+	records := make([]models.MedicalData, 0)
+	for _, data := range s.data {
+		if metadataString(parseMetadata(data.Metadata), "patientId") == patientID {
+			records = append(records, *data)
+		}
+	}
+
+	return records
+}
+
 // StoreFile 存储文件并返回哈希值
 func (s *DataService) StoreFile(fileData []byte, fileName string) (string, error) {
 	// 在实际应用中，这里应该将文件存储到IPFS或其他存储系统
@@ -58,11 +88,23 @@ func (s *DataService) StoreFile(fileData []byte, fileName string) (string, error
 		hash += string("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"[rand.Intn(62)])
 	}
 
+	s.files[hash] = storedFile{Name: fileName, Content: fileData}
+
 	log.Printf("存储文件: %s, 大小: %d 字节, 哈希: %s", fileName, len(fileData), hash)
 
 	return hash, nil
 }
 
+// GetFile 根据哈希值获取文件名和文件内容
+func (s *DataService) GetFile(dataHash string) (string, []byte, error) {
+	file, exists := s.files[dataHash]
+	if !exists {
+		return "", nil, errors.New("文件不存在")
+	}
+
+	return file.Name, file.Content, nil
+}
+
 // GetFileInfo 获取文件信息
 func (s *DataService) GetFileInfo(dataHash string) (map[string]interface{}, error) {
 	// 在实际应用中，这里应该从IPFS或其他存储系统获取文件信息
@@ -78,16 +120,21 @@ func (s *DataService) GetFileInfo(dataHash string) (map[string]interface{}, erro
 }
 
 // GetStatistics 获取统计数据
-func (s *DataService) GetStatistics() (*models.Statistics, error) {
+// inScope 不为nil时只统计满足条件的数据（如本租户的数据）
+func (s *DataService) GetStatistics(inScope func(models.MedicalData) bool) (*models.Statistics, error) {
 	// 在实际应用中，这里应该从数据库获取统计数据
 	// 为了演示，我们返回一些模拟数据
-	totalRecords := len(s.data)
+	totalRecords := 0
 	ethereumRecords := 0
 	fabricRecords := 0
 	dataTypeDistribution := make(map[string]int)
 
 	// 统计各类数据
 	for _, data := range s.data {
+		if inScope != nil && !inScope(*data) {
+			continue
+		}
+		totalRecords++
 		if data.Chain == "ethereum" {
 			ethereumRecords++
 		} else if data.Chain == "fabric" {
@@ -97,8 +144,8 @@ func (s *DataService) GetStatistics() (*models.Statistics, error) {
 		dataTypeDistribution[data.DataType]++
 	}
 
-	// 如果没有数据，使用模拟数据
-	if totalRecords == 0 {
+	// 如果平台没有数据，使用模拟数据
+	if totalRecords == 0 && inScope == nil {
 		totalRecords = 156
 		ethereumRecords = 98
 		fabricRecords = 58
@@ -157,7 +204,8 @@ func (s *DataService) JSONToMap(jsonStr string) map[string]interface{} {
 }
 
 // SearchDataByKeyword 根据关键词搜索医疗数据
-func (s *DataService) SearchDataByKeyword(keyword string, dataType string, chain string, page int, pageSize int) (*models.QueryResult, error) {
+// inScope 不为nil时只搜索满足条件的数据（如本租户的数据）
+func (s *DataService) SearchDataByKeyword(keyword string, dataType string, chain string, page int, pageSize int, inScope func(models.MedicalData) bool) (*models.QueryResult, error) {
 	if page <= 0 {
 		page = 1
 	}
@@ -177,6 +225,9 @@ func (s *DataService) SearchDataByKeyword(keyword string, dataType string, chain
 		if chain != "" && data.Chain != chain {
 			continue
 		}
+		if inScope != nil && !inScope(*data) {
+			continue
+		}
 
 		// 如果没有关键词，则直接添加到结果中
 		if keyword == "" {
@@ -0,0 +1,436 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// DID错误
+var (
+	ErrDIDNotFound           = errors.New("DID不存在")
+	ErrDIDInvalid            = errors.New("无效的DID")
+	ErrDIDResolution         = errors.New("解析DID失败")
+	ErrDIDUnavailable        = errors.New("区块链身份还没有DID，请先解锁密钥库")
+	ErrHospitalNotRegistered = errors.New("医院尚未入驻平台")
+)
+
+// didStore 医院DID的持久化结构
+type didStore struct {
+	Hospitals map[string]*models.HospitalDID `json:"hospitals"` // slug -> 医院DID
+}
+
+// DIDService 去中心化标识符服务
+// 有区块链身份的用户使用以太坊公钥对应的did:key，可在任何部署中独立验证；
+// 没有区块链身份的用户（单点登录用户、服务账户）和医院使用本平台的did:web，DID文档由本服务发布。
+// 医院的签名私钥保存在 DID_DIR（默认数据目录下的did）中
+type DIDService struct {
+	mu              sync.RWMutex
+	domain          string
+	dir             string
+	store           didStore
+	identityService *IdentityService
+	userService     *UserService
+	httpClient      *http.Client
+}
+
+// NewDIDService 创建新的DID服务
+// DID_WEB_DOMAIN 为did:web使用的域名（可带端口），默认为localhost:8000
+func NewDIDService(identityService *IdentityService, userService *UserService) *DIDService {
+	domain := os.Getenv("DID_WEB_DOMAIN")
+	if domain == "" {
+		domain = "localhost:8000"
+	}
+	dir := os.Getenv("DID_DIR")
+	if dir == "" {
+		dir = utils.DataFilePath("did")
+	}
+
+	service := &DIDService{
+		domain: domain,
+		dir:    dir,
+		store: didStore{
+			Hospitals: make(map[string]*models.HospitalDID),
+		},
+		identityService: identityService,
+		userService:     userService,
+		httpClient:      &http.Client{Timeout: 10 * time.Second},
+	}
+
+	// 加载已创建的医院DID
+	if err := utils.LoadJSONFile(service.storePath(), &service.store); err != nil {
+		log.Printf("加载医院DID失败: %v", err)
+	}
+	if service.store.Hospitals == nil {
+		service.store.Hospitals = make(map[string]*models.HospitalDID)
+	}
+
+	return service
+}
+
+// RegisterHospital 医院入驻：生成签名密钥并创建医院的did:web，已入驻的医院直接返回
+func (s *DIDService) RegisterHospital(name string) (*models.HospitalDID, error) {
+	if name == "" {
+		return nil, errors.New("医院名称不能为空")
+	}
+	slug := hospitalSlug(name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if hospital, exists := s.store.Hospitals[slug]; exists {
+		result := *hospital
+		return &result, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成医院签名密钥失败: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(s.dir, "hospitals"), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(s.hospitalKeyPath(slug), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, fmt.Errorf("保存医院签名密钥失败: %w", err)
+	}
+
+	hospital := &models.HospitalDID{
+		Slug:      slug,
+		Name:      name,
+		DID:       utils.DIDWebID(s.domain, "hospitals", slug),
+		PublicKey: utils.P256Multibase(&key.PublicKey),
+		CreatedAt: time.Now(),
+	}
+	s.store.Hospitals[slug] = hospital
+	if err := utils.SaveJSONFile(s.storePath(), s.store); err != nil {
+		delete(s.store.Hospitals, slug)
+		return nil, fmt.Errorf("保存医院DID失败: %w", err)
+	}
+
+	log.Printf("创建医院DID: 医院=%s, DID=%s", name, hospital.DID)
+	result := *hospital
+	return &result, nil
+}
+
+// HospitalSigner 获取已入驻医院的DID和签名私钥
+// 医院私钥用于签发可验证凭证等需要以医院身份签名的场景
+func (s *DIDService) HospitalSigner(name string) (*models.HospitalDID, *ecdsa.PrivateKey, error) {
+	hospital, err := s.GetHospital(hospitalSlug(name))
+	if err != nil {
+		return nil, nil, ErrHospitalNotRegistered
+	}
+
+	data, err := os.ReadFile(s.hospitalKeyPath(hospital.Slug))
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取医院签名密钥失败: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, errors.New("医院签名密钥格式无效")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析医院签名密钥失败: %w", err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok || key.Curve != elliptic.P256() {
+		return nil, nil, errors.New("医院签名密钥不是P-256密钥")
+	}
+	if utils.P256Multibase(&key.PublicKey) != hospital.PublicKey {
+		return nil, nil, errors.New("医院签名密钥与DID文档中的公钥不一致")
+	}
+	return hospital, key, nil
+}
+
+// GetHospital 根据slug获取医院DID
+func (s *DIDService) GetHospital(slug string) (*models.HospitalDID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hospital, exists := s.store.Hospitals[slug]
+	if !exists {
+		return nil, ErrDIDNotFound
+	}
+	result := *hospital
+	return &result, nil
+}
+
+// IsHospitalRegistered 检查医院是否已入驻
+func (s *DIDService) IsHospitalRegistered(name string) bool {
+	_, err := s.GetHospital(hospitalSlug(name))
+	return err == nil
+}
+
+// ListHospitals 获取所有医院DID
+func (s *DIDService) ListHospitals() []models.HospitalDID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hospitals := make([]models.HospitalDID, 0, len(s.store.Hospitals))
+	for _, hospital := range s.store.Hospitals {
+		hospitals = append(hospitals, *hospital)
+	}
+	return hospitals
+}
+
+// AssignUserDID 为用户分配DID，已分配的直接返回
+// 有区块链身份的用户使用密钥库中的did:key；没有区块链身份的用户使用 did:web:<域名>:users:<用户ID>
+func (s *DIDService) AssignUserDID(user *models.User) (string, error) {
+	if user.DID != "" {
+		return user.DID, nil
+	}
+
+	did := utils.DIDWebID(s.domain, "users", user.ID)
+	identity, err := s.identityService.GetIdentity(user.ID)
+	if err == nil {
+		if identity.DID == "" {
+			return "", ErrDIDUnavailable
+		}
+		did = identity.DID
+	} else if !errors.Is(err, ErrIdentityNotFound) {
+		return "", err
+	}
+
+	if err := s.userService.SetDID(user.ID, did); err != nil {
+		return "", err
+	}
+	log.Printf("分配用户DID: 用户=%s, DID=%s", user.ID, did)
+	return did, nil
+}
+
+// OwnerDID 获取用户作为数据所有者的DID，未分配时分配
+func (s *DIDService) OwnerDID(userID string) (string, error) {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return "", err
+	}
+	return s.AssignUserDID(user)
+}
+
+// HospitalDocument 生成医院的DID文档
+func (s *DIDService) HospitalDocument(slug string) (*models.DIDDocument, error) {
+	s.mu.RLock()
+	hospital, exists := s.store.Hospitals[slug]
+	s.mu.RUnlock()
+	if !exists {
+		return nil, ErrDIDNotFound
+	}
+
+	keyID := hospital.DID + "#key-1"
+	return &models.DIDDocument{
+		Context: models.DIDContexts,
+		ID:      hospital.DID,
+		VerificationMethod: []models.DIDVerificationMethod{{
+			ID:                 keyID,
+			Type:               "Multikey",
+			Controller:         hospital.DID,
+			PublicKeyMultibase: hospital.PublicKey,
+		}},
+		Authentication:  []string{keyID},
+		AssertionMethod: []string{keyID},
+		Service: []models.DIDServiceEndpoint{{
+			ID:              hospital.DID + "#medcross",
+			Type:            "MedCrossAPI",
+			ServiceEndpoint: s.BaseURL() + "/api",
+		}},
+	}, nil
+}
+
+// UserDocument 生成没有区块链身份的用户的did:web文档，由已入驻的所属医院控制
+func (s *DIDService) UserDocument(userID string) (*models.DIDDocument, error) {
+	did := utils.DIDWebID(s.domain, "users", userID)
+	ownerID, exists := s.userService.ResolveOwner(did)
+	if !exists || ownerID != userID {
+		return nil, ErrDIDNotFound
+	}
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return nil, ErrDIDNotFound
+	}
+
+	document := &models.DIDDocument{
+		Context: models.DIDContexts,
+		ID:      did,
+	}
+	if user.Hospital != "" && s.IsHospitalRegistered(user.Hospital) {
+		document.Controller = utils.DIDWebID(s.domain, "hospitals", hospitalSlug(user.Hospital))
+	}
+	return document, nil
+}
+
+// Resolve 解析DID，支持did:key和did:web
+// 本平台的did:web直接生成文档，其他域名的did:web通过HTTPS获取
+func (s *DIDService) Resolve(did string) (*models.DIDResolutionResult, error) {
+	if !utils.IsDID(did) {
+		return nil, ErrDIDInvalid
+	}
+
+	switch {
+	case strings.HasPrefix(did, "did:key:"):
+		document, err := didKeyDocument(did)
+		if err != nil {
+			return nil, err
+		}
+		_, local := s.userService.ResolveOwner(did)
+		return &models.DIDResolutionResult{DIDDocument: document, Method: "key", Local: local}, nil
+
+	case strings.HasPrefix(did, "did:web:"):
+		prefix := utils.DIDWebID(s.domain) + ":"
+		if strings.HasPrefix(did, prefix) {
+			document, err := s.localWebDocument(strings.Split(strings.TrimPrefix(did, prefix), ":"))
+			if err != nil {
+				return nil, err
+			}
+			return &models.DIDResolutionResult{DIDDocument: document, Method: "web", Local: true}, nil
+		}
+
+		document, err := s.fetchWebDocument(did)
+		if err != nil {
+			return nil, err
+		}
+		return &models.DIDResolutionResult{DIDDocument: document, Method: "web"}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: 不支持的DID方法", ErrDIDInvalid)
+	}
+}
+
+// VerificationKey 解析DID并返回指定验证方法的P-256公钥
+// keyID可以是完整的验证方法ID，也可以是以#开头的片段
+func (s *DIDService) VerificationKey(did, keyID string) (*ecdsa.PublicKey, error) {
+	result, err := s.Resolve(did)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(keyID, "#") {
+		keyID = did + keyID
+	}
+
+	for _, method := range result.DIDDocument.VerificationMethod {
+		if method.ID != keyID {
+			continue
+		}
+		key, err := utils.ParseMultibaseKey(method.PublicKeyMultibase)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDIDInvalid, err)
+		}
+		if key.Type != utils.DIDKeyTypeP256 {
+			return nil, fmt.Errorf("%w: 只支持P-256验证方法", ErrDIDInvalid)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: key.X, Y: key.Y}, nil
+	}
+	return nil, fmt.Errorf("%w: DID文档中没有验证方法 %s", ErrDIDNotFound, keyID)
+}
+
+// 本平台发布的did:web文档：hospitals:<slug> 或 users:<用户ID>
+func (s *DIDService) localWebDocument(path []string) (*models.DIDDocument, error) {
+	if len(path) != 2 {
+		return nil, ErrDIDNotFound
+	}
+	switch path[0] {
+	case "hospitals":
+		return s.HospitalDocument(path[1])
+	case "users":
+		return s.UserDocument(path[1])
+	default:
+		return nil, ErrDIDNotFound
+	}
+}
+
+// 通过HTTPS获取其他域名发布的did:web文档
+func (s *DIDService) fetchWebDocument(did string) (*models.DIDDocument, error) {
+	documentURL, err := utils.DIDWebURL(did)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDIDInvalid, err)
+	}
+
+	resp, err := s.httpClient.Get(documentURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDIDResolution, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrDIDNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: DID文档地址返回状态码 %d", ErrDIDResolution, resp.StatusCode)
+	}
+
+	var document models.DIDDocument
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, fmt.Errorf("%w: DID文档格式无效", ErrDIDResolution)
+	}
+	if document.ID != did {
+		return nil, fmt.Errorf("%w: DID文档的id与DID不一致", ErrDIDResolution)
+	}
+	return &document, nil
+}
+
+// did:key文档完全由公钥推导
+func didKeyDocument(did string) (*models.DIDDocument, error) {
+	_, multibase, err := utils.ParseDIDKey(did)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDIDInvalid, err)
+	}
+
+	keyID := did + "#" + multibase
+	return &models.DIDDocument{
+		Context: models.DIDContexts,
+		ID:      did,
+		VerificationMethod: []models.DIDVerificationMethod{{
+			ID:                 keyID,
+			Type:               "Multikey",
+			Controller:         did,
+			PublicKeyMultibase: multibase,
+		}},
+		Authentication:  []string{keyID},
+		AssertionMethod: []string{keyID},
+	}, nil
+}
+
+// 医院在did:web路径中的标识：医院名称的SHA-256前8字节，避免非ASCII字符
+func hospitalSlug(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return "h-" + hex.EncodeToString(sum[:8])
+}
+
+// BaseURL did:web域名对应的服务地址
+func (s *DIDService) BaseURL() string {
+	documentURL, err := utils.DIDWebURL(utils.DIDWebID(s.domain))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(documentURL, "/.well-known/did.json")
+}
+
+// 医院DID登记文件路径
+func (s *DIDService) storePath() string {
+	return filepath.Join(s.dir, "hospitals.json")
+}
+
+// 医院签名私钥文件路径
+func (s *DIDService) hospitalKeyPath(slug string) string {
+	return filepath.Join(s.dir, "hospitals", slug+".pem")
+}
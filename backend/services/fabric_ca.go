@@ -0,0 +1,246 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fabricAttrOID Fabric CA写入证书属性的扩展OID，链码通过cid.GetAttributeValue读取
+var fabricAttrOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// FabricEnrollmentRequest 向Fabric CA登记身份的请求
+type FabricEnrollmentRequest struct {
+	EnrollmentID string            // 登记ID，写入证书CN
+	CSR          []byte            // DER格式的证书签名请求
+	Affiliation  string            // 所属机构，写入证书OU
+	Attributes   map[string]string // 写入证书的属性，供链码做访问控制
+}
+
+// FabricCA Fabric证书颁发机构
+// 生产环境对接fabric-ca-server的enroll接口，开发环境使用LocalFabricCA
+type FabricCA interface {
+	// Enroll 校验证书签名请求并签发PEM格式的客户端证书
+	Enroll(req FabricEnrollmentRequest) ([]byte, error)
+	// MSPID 返回签发证书所属组织的MSP ID
+	MSPID() string
+}
+
+// LocalFabricCA 本地Fabric CA替身，使用自签名的P-256根证书签发客户端证书
+// 根证书和私钥保存在FABRIC_CA_DIR（默认数据目录下的fabric-ca），需要加入通道MSP的cacerts才能被链码信任
+type LocalFabricCA struct {
+	mu      sync.Mutex
+	mspID   string
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certTTL time.Duration
+}
+
+// 本地CA文件名
+const (
+	localCACertFile = "ca-cert.pem"
+	localCAKeyFile  = "ca-key.pem"
+)
+
+// NewLocalFabricCA 加载或创建本地CA
+func NewLocalFabricCA(dir, mspID string) (*LocalFabricCA, error) {
+	ca := &LocalFabricCA{
+		mspID:   mspID,
+		certTTL: 365 * 24 * time.Hour,
+	}
+
+	certPath := filepath.Join(dir, localCACertFile)
+	keyPath := filepath.Join(dir, localCAKeyFile)
+
+	certPEM, certErr := os.ReadFile(certPath)
+	keyPEM, keyErr := os.ReadFile(keyPath)
+	if certErr == nil && keyErr == nil {
+		if err := ca.load(certPEM, keyPEM); err != nil {
+			return nil, fmt.Errorf("加载本地Fabric CA失败: %w", err)
+		}
+		return ca, nil
+	}
+	if !errors.Is(certErr, os.ErrNotExist) && certErr != nil {
+		return nil, certErr
+	}
+	if !errors.Is(keyErr, os.ErrNotExist) && keyErr != nil {
+		return nil, keyErr
+	}
+
+	if err := ca.generate(certPath, keyPath); err != nil {
+		return nil, fmt.Errorf("创建本地Fabric CA失败: %w", err)
+	}
+	return ca, nil
+}
+
+// MSPID 返回签发证书所属组织的MSP ID
+func (ca *LocalFabricCA) MSPID() string {
+	return ca.mspID
+}
+
+// CACertificatePEM 返回根证书，部署时加入组织MSP的cacerts目录
+func (ca *LocalFabricCA) CACertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+// Enroll 校验证书签名请求并签发客户端证书
+func (ca *LocalFabricCA) Enroll(req FabricEnrollmentRequest) ([]byte, error) {
+	csr, err := x509.ParseCertificateRequest(req.CSR)
+	if err != nil {
+		return nil, fmt.Errorf("解析证书签名请求失败: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("证书签名请求的签名无效: %w", err)
+	}
+	if _, ok := csr.PublicKey.(*ecdsa.PublicKey); !ok {
+		return nil, errors.New("Fabric身份只支持ECDSA公钥")
+	}
+	if req.EnrollmentID == "" {
+		return nil, errors.New("登记ID不能为空")
+	}
+
+	// 与fabric-ca-server一致：登记ID和角色类型写入属性扩展
+	attrs := map[string]string{
+		"hf.EnrollmentID": req.EnrollmentID,
+		"hf.Type":         "client",
+	}
+	for name, value := range req.Attributes {
+		attrs[name] = value
+	}
+	attrValue, err := json.Marshal(map[string]interface{}{"attrs": attrs})
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	subject := pkix.Name{
+		CommonName:         req.EnrollmentID,
+		OrganizationalUnit: []string{"client"},
+	}
+	if req.Affiliation != "" {
+		subject.OrganizationalUnit = append(subject.OrganizationalUnit, req.Affiliation)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               subject,
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(ca.certTTL),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+		ExtraExtensions: []pkix.Extension{
+			{Id: fabricAttrOID, Value: attrValue},
+		},
+	}
+
+	ca.mu.Lock()
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, csr.PublicKey, ca.key)
+	ca.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// 从PEM加载根证书和私钥
+func (ca *LocalFabricCA) load(certPEM, keyPEM []byte) error {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil || certBlock.Type != "CERTIFICATE" {
+		return errors.New("根证书格式无效")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return err
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil || keyBlock.Type != "PRIVATE KEY" {
+		return errors.New("根证书私钥格式无效")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return errors.New("根证书私钥不是ECDSA私钥")
+	}
+
+	ca.cert = cert
+	ca.key = key
+	return nil
+}
+
+// 生成自签名根证书并写入目录
+func (ca *LocalFabricCA) generate(certPath, keyPath string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   "ca." + ca.mspID,
+			Organization: []string{ca.mspID},
+		},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+
+	ca.cert = cert
+	ca.key = key
+	return nil
+}
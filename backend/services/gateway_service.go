@@ -17,9 +17,10 @@ import (
 
 // GatewayService 跨链网关服务
 type GatewayService struct {
-	gatewayURL string
-	timeout    time.Duration // HTTP请求超时时间
-	maxRetries int           // 最大重试次数
+	gatewayURL    string
+	timeout       time.Duration       // HTTP请求超时时间
+	maxRetries    int                 // 最大重试次数
+	ownerResolver utils.OwnerResolver // 跨链转移时转换数据所有者
 }
 
 // NewGatewayService 创建新的网关服务
@@ -54,7 +55,14 @@ func NewGatewayService() *GatewayService {
 	}
 }
 
-// QueryData 查询医疗数据
+// SetOwnerResolver 设置跨链转移时转换数据所有者使用的身份注册表
+// 身份注册表上链时依赖网关服务，因此在创建后设置
+func (s *GatewayService) SetOwnerResolver(resolver utils.OwnerResolver) {
+	s.ownerResolver = resolver
+}
+
+// QueryData 查询医疗数据，返回全部匹配的记录
+// 不按页向网关查询：调用方需要先按租户和访问权限过滤，再对可读的记录分页
 func (s *GatewayService) QueryData(query models.MedicalDataQuery) (*models.QueryResult, error) {
 	// 构建查询URL
 	url := fmt.Sprintf("%s/query?keyword=%s&dataType=%s&chain=%s",
@@ -71,11 +79,8 @@ func (s *GatewayService) QueryData(query models.MedicalDataQuery) (*models.Query
 		url += "&endDate=" + query.EndDate
 	}
 
-	// 添加排序和分页
-	url += fmt.Sprintf("&sortBy=%s&page=%d&pageSize=%d",
-		query.SortBy,
-		query.Page,
-		query.PageSize)
+	// 添加排序
+	url += "&sortBy=" + query.SortBy
 
 	// 创建带超时的HTTP客户端
 	client := &http.Client{
@@ -231,7 +236,7 @@ func (s *GatewayService) GetDataByID(dataID string) (*models.MedicalData, error)
 // CrossChainTransfer 跨链数据转移
 func (s *GatewayService) CrossChainTransfer(data models.MedicalData, targetChain string) (*models.MedicalData, error) {
 	// 创建链转换器
-	converter := utils.NewChainConverter()
+	converter := utils.NewChainConverter(s.ownerResolver)
 
 	// 检查源链和目标链
 	if data.Chain == targetChain {
@@ -633,20 +638,15 @@ func (s *GatewayService) VerifyDataIntegrity(sourceData models.MedicalData, targ
 		}
 	}
 
-	// 检查特殊字段映射
-	if sourceData.Chain == "ethereum" && targetData.Chain == "fabric" {
-		// 检查以太坊地址是否正确映射到Fabric ID
-		expectedFabricID := fmt.Sprintf("fabric-user-%s", strings.TrimPrefix(sourceData.Owner, "0x")[:8])
-		if targetData.Owner != expectedFabricID {
-			log.Printf("所有者映射不正确: 预期=%s, 实际=%s", expectedFabricID, targetData.Owner)
-			return false, fmt.Errorf("所有者映射不正确")
-		}
-	} else if sourceData.Chain == "fabric" && targetData.Chain == "ethereum" {
-		// Fabric到以太坊的映射验证较为复杂，这里简化处理
-		if !strings.HasPrefix(targetData.Owner, "0x") {
-			log.Printf("以太坊地址格式不正确: %s", targetData.Owner)
-			return false, fmt.Errorf("以太坊地址格式不正确")
-		}
+	// 检查所有者是否按身份注册表映射为同一用户在目标链上的身份
+	expectedOwner, err := utils.NewChainConverter(s.ownerResolver).ConvertOwner(sourceData.Owner, targetData.Chain)
+	if err != nil {
+		log.Printf("所有者映射失败: %v", err)
+		return false, err
+	}
+	if targetData.Owner != expectedOwner {
+		log.Printf("所有者映射不正确: 预期=%s, 实际=%s", expectedOwner, targetData.Owner)
+		return false, fmt.Errorf("所有者映射不正确")
 	}
 
 	log.Printf("跨链数据完整性验证通过")
@@ -882,7 +882,7 @@ func (s *GatewayService) SubmitBlockchainTransaction(chain string, txType string
 	}
 
 	// 验证交易类型
-	validTxTypes := map[string]bool{"upload": true, "transfer": true, "update": true, "delete": true}
+	validTxTypes := map[string]bool{"upload": true, "transfer": true, "update": true, "delete": true, "consent": true, "anchor": true, "identity": true, "auditor": true}
 	if !validTxTypes[txType] {
 		log.Printf("不支持的交易类型: %s", txType)
 		return "", fmt.Errorf("不支持的交易类型: %s", txType)
@@ -966,6 +966,98 @@ func (s *GatewayService) SubmitBlockchainTransaction(chain string, txType string
 	return txResponse.TransactionHash, nil
 }
 
+// GetEthereumTxParams 获取构造以太坊交易所需的链ID、nonce、gas价格和合约地址
+func (s *GatewayService) GetEthereumTxParams(address string) (*models.EthereumTxParams, error) {
+	var params models.EthereumTxParams
+	if err := s.gatewayJSON(http.MethodGet, fmt.Sprintf("%s/tx-params/ethereum/%s", s.gatewayURL, address), nil, &params); err != nil {
+		return nil, fmt.Errorf("获取以太坊交易参数失败: %w", err)
+	}
+	return &params, nil
+}
+
+// GetFabricTxParams 获取Fabric链码调用所需的通道和链码名称
+func (s *GatewayService) GetFabricTxParams() (*models.FabricTxParams, error) {
+	var params models.FabricTxParams
+	if err := s.gatewayJSON(http.MethodGet, fmt.Sprintf("%s/tx-params/fabric", s.gatewayURL), nil, &params); err != nil {
+		return nil, fmt.Errorf("获取Fabric交易参数失败: %w", err)
+	}
+	return &params, nil
+}
+
+// RelayTransaction 将用户签名的交易交给网关转发上链
+// 以太坊交易由节点校验签名，Fabric提案由网关校验签名和证书后提交
+func (s *GatewayService) RelayTransaction(tx models.SignedTransaction) (*models.RelayResponse, error) {
+	log.Printf("转发用户签名交易: 链=%s, 签名者=%s", tx.Chain, tx.Signer)
+
+	var result models.RelayResponse
+	if err := s.gatewayJSON(http.MethodPost, fmt.Sprintf("%s/relay", s.gatewayURL), tx, &result); err != nil {
+		return nil, fmt.Errorf("转发交易失败: %w", err)
+	}
+
+	log.Printf("用户签名交易已上链: 链=%s, 交易哈希=%s", tx.Chain, result.TransactionHash)
+	return &result, nil
+}
+
+// 向网关发送JSON请求并解析响应，网络错误时按退避策略重试
+// 转发的已签名交易哈希固定，重试不会重复上链
+func (s *GatewayService) gatewayJSON(method, url string, body interface{}, out interface{}) error {
+	var reqData []byte
+	if body != nil {
+		var err error
+		reqData, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("序列化请求失败: %w", err)
+		}
+	}
+
+	// 创建带超时的HTTP客户端
+	client := &http.Client{
+		Timeout: s.timeout,
+	}
+
+	var resp *http.Response
+	var err error
+	for i := 0; i < s.maxRetries; i++ {
+		req, reqErr := http.NewRequest(method, url, strings.NewReader(string(reqData)))
+		if reqErr != nil {
+			return fmt.Errorf("创建请求失败: %w", reqErr)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err = client.Do(req)
+		if err == nil {
+			break
+		}
+		log.Printf("请求网关失败 (尝试 %d/%d): %v", i+1, s.maxRetries, err)
+		if i < s.maxRetries-1 {
+			// 指数退避策略
+			backoff := time.Duration(100*(i+1)) * time.Millisecond
+			time.Sleep(backoff)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("请求网关失败，已达到最大重试次数: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		var errorResponse struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err == nil && errorResponse.Error != "" {
+			return fmt.Errorf("网关返回错误: %s", errorResponse.Error)
+		}
+		return fmt.Errorf("网关返回错误状态码: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析网关响应失败: %w", err)
+	}
+	return nil
+}
+
 // GetBlockchainTransactionStatus 获取区块链交易状态
 func (s *GatewayService) GetBlockchainTransactionStatus(chain string, txHash string) (string, error) {
 	log.Printf("获取区块链交易状态: 链=%s, 交易哈希=%s", chain, txHash)
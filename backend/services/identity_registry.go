@@ -0,0 +1,338 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// 身份关联错误
+var (
+	ErrIdentityLinkNotFound = errors.New("未找到该链上身份的关联记录")
+	ErrIdentityLinkConflict = errors.New("链上身份已关联到其他用户")
+	ErrIdentityLinkInvalid  = errors.New("身份关联记录的签名无效")
+)
+
+// 身份关联记录上链的目标区块链
+var identityLinkChains = []string{"ethereum", "fabric"}
+
+// identityRegistryStore 身份关联的持久化结构
+type identityRegistryStore struct {
+	Links map[string]*models.IdentityLink `json:"links"` // userID -> 关联记录
+}
+
+// IdentityRegistry 跨链身份注册表
+// 记录每个用户的以太坊地址与Fabric身份的双向关联，保存在本地并写入两条链。
+// 跨链转移时通过注册表转换数据所有者，未登记的身份不做转换
+type IdentityRegistry struct {
+	mu             sync.RWMutex
+	store          identityRegistryStore
+	storePath      string
+	byEthereum     map[string]string // 小写以太坊地址 -> userID
+	byFabric       map[string]string // Fabric身份标识 -> userID
+	gatewayService *GatewayService
+}
+
+// NewIdentityRegistry 创建新的跨链身份注册表
+func NewIdentityRegistry(gatewayService *GatewayService) *IdentityRegistry {
+	registry := &IdentityRegistry{
+		store: identityRegistryStore{
+			Links: make(map[string]*models.IdentityLink),
+		},
+		storePath:      utils.DataFilePath("identity_registry.json"),
+		byEthereum:     make(map[string]string),
+		byFabric:       make(map[string]string),
+		gatewayService: gatewayService,
+	}
+
+	// 加载已持久化的关联记录，签名无效的记录不加入索引
+	var store identityRegistryStore
+	if err := utils.LoadJSONFile(registry.storePath, &store); err != nil {
+		log.Printf("加载身份注册表失败: %v", err)
+	}
+	for userID, link := range store.Links {
+		if err := VerifyIdentityLink(link); err != nil {
+			log.Printf("忽略无效的身份关联记录: 用户=%s, 错误=%v", userID, err)
+			continue
+		}
+		registry.indexLocked(link)
+	}
+
+	return registry
+}
+
+// Link 使用用户已解锁的两个私钥签名关联声明并登记，已登记的身份直接返回原记录
+func (r *IdentityRegistry) Link(identity *UnlockedIdentity) (*models.IdentityLink, error) {
+	userID := identity.Identity.UserID
+	ethAddress := identity.Identity.EthereumAddress
+	fabricID := identity.Identity.FabricID
+
+	r.mu.RLock()
+	existing, exists := r.store.Links[userID]
+	if exists && existing.EthereumAddress == ethAddress && existing.FabricID == fabricID {
+		defer r.mu.RUnlock()
+		return copyIdentityLink(existing), nil
+	}
+	r.mu.RUnlock()
+
+	// 声明中的时间精确到秒，与CreatedAt一致以便验证
+	now := time.Now().UTC().Truncate(time.Second)
+	statement := identityLinkStatement(ethAddress, fabricID, now)
+
+	ethSignature, err := identity.EthereumKey.SignEthereumHash(utils.EthereumMessageHash([]byte(statement)))
+	if err != nil {
+		return nil, fmt.Errorf("以太坊私钥签名失败: %w", err)
+	}
+	digest := sha256.Sum256([]byte(statement))
+	fabricSignature, err := signFabricDigest(identity.FabricKey, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("Fabric私钥签名失败: %w", err)
+	}
+
+	link := &models.IdentityLink{
+		UserID:            userID,
+		EthereumAddress:   ethAddress,
+		FabricID:          fabricID,
+		FabricCertificate: identity.Identity.FabricCertificate,
+		Statement:         statement,
+		EthereumSignature: "0x" + hex.EncodeToString(ethSignature),
+		FabricSignature:   base64.StdEncoding.EncodeToString(fabricSignature),
+		CreatedAt:         now,
+		ChainRecords:      make(map[string]string),
+	}
+
+	r.mu.Lock()
+	if owner, taken := r.byEthereum[strings.ToLower(ethAddress)]; taken && owner != userID {
+		r.mu.Unlock()
+		return nil, ErrIdentityLinkConflict
+	}
+	if owner, taken := r.byFabric[fabricID]; taken && owner != userID {
+		r.mu.Unlock()
+		return nil, ErrIdentityLinkConflict
+	}
+
+	previous := r.store.Links[userID]
+	if previous != nil {
+		delete(r.byEthereum, strings.ToLower(previous.EthereumAddress))
+		delete(r.byFabric, previous.FabricID)
+	}
+	r.indexLocked(link)
+
+	if err := r.saveLocked(); err != nil {
+		delete(r.store.Links, userID)
+		delete(r.byEthereum, strings.ToLower(ethAddress))
+		delete(r.byFabric, fabricID)
+		if previous != nil {
+			r.indexLocked(previous)
+		}
+		r.mu.Unlock()
+		return nil, err
+	}
+	r.mu.Unlock()
+
+	log.Printf("登记跨链身份关联: 用户=%s, 以太坊地址=%s, Fabric身份=%s", userID, ethAddress, fabricID)
+
+	// 写入链上关联记录
+	go r.anchor(userID)
+
+	return copyIdentityLink(link), nil
+}
+
+// IsLinked 检查用户是否已登记身份关联
+func (r *IdentityRegistry) IsLinked(userID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, exists := r.store.Links[userID]
+	return exists
+}
+
+// Resolve 根据以太坊地址或Fabric身份标识查找关联记录
+func (r *IdentityRegistry) Resolve(chainIdentity string) (*models.IdentityLink, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	userID, exists := r.byEthereum[strings.ToLower(chainIdentity)]
+	if !exists {
+		userID, exists = r.byFabric[chainIdentity]
+	}
+	if !exists {
+		return nil, ErrIdentityLinkNotFound
+	}
+
+	return copyIdentityLink(r.store.Links[userID]), nil
+}
+
+// FabricIDForEthereum 查找以太坊地址关联的Fabric身份
+func (r *IdentityRegistry) FabricIDForEthereum(address string) (string, bool) {
+	link, err := r.Resolve(address)
+	if err != nil || !strings.EqualFold(link.EthereumAddress, address) {
+		return "", false
+	}
+	return link.FabricID, true
+}
+
+// EthereumAddressForFabric 查找Fabric身份关联的以太坊地址
+func (r *IdentityRegistry) EthereumAddressForFabric(fabricID string) (string, bool) {
+	link, err := r.Resolve(fabricID)
+	if err != nil || link.FabricID != fabricID {
+		return "", false
+	}
+	return link.EthereumAddress, true
+}
+
+// SetAuditor 在链码的审计员登记表中登记或移除Fabric身份
+// 链码不信任证书中登记时固定的角色属性，只有登记的审计员可以查询任意数据的历史版本
+func (r *IdentityRegistry) SetAuditor(fabricID string, auditor bool) error {
+	action := "remove"
+	if auditor {
+		action = "add"
+	}
+	payload := map[string]interface{}{
+		"action":   action,
+		"fabricId": fabricID,
+	}
+
+	txHash, err := r.gatewayService.SubmitBlockchainTransaction("fabric", "auditor", payload)
+	if err != nil {
+		return fmt.Errorf("同步审计员登记表失败: %w", err)
+	}
+	log.Printf("同步审计员登记表: Fabric身份=%s, 操作=%s, 交易=%s", fabricID, action, txHash)
+	return nil
+}
+
+// AnchorPending 将尚未写入全部目标链的关联记录补写上链，启动时调用
+func (r *IdentityRegistry) AnchorPending() {
+	r.mu.RLock()
+	var pending []string
+	for userID, link := range r.store.Links {
+		for _, chain := range identityLinkChains {
+			if link.ChainRecords[chain] == "" {
+				pending = append(pending, userID)
+				break
+			}
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, userID := range pending {
+		r.anchor(userID)
+	}
+}
+
+// VerifyIdentityLink 验证关联声明的两个签名分别来自记录中的以太坊地址和Fabric证书
+func VerifyIdentityLink(link *models.IdentityLink) error {
+	if link.Statement != identityLinkStatement(link.EthereumAddress, link.FabricID, link.CreatedAt) {
+		return ErrIdentityLinkInvalid
+	}
+
+	ethSignature, err := hex.DecodeString(strings.TrimPrefix(link.EthereumSignature, "0x"))
+	if err != nil {
+		return ErrIdentityLinkInvalid
+	}
+	signer, err := utils.RecoverEthereumAddress(utils.EthereumMessageHash([]byte(link.Statement)), ethSignature)
+	if err != nil || !strings.EqualFold(signer, link.EthereumAddress) {
+		return ErrIdentityLinkInvalid
+	}
+
+	fabricID, err := fabricIDFromCertificate([]byte(link.FabricCertificate))
+	if err != nil || fabricID != link.FabricID {
+		return ErrIdentityLinkInvalid
+	}
+	fabricSignature, err := base64.StdEncoding.DecodeString(link.FabricSignature)
+	if err != nil {
+		return ErrIdentityLinkInvalid
+	}
+	digest := sha256.Sum256([]byte(link.Statement))
+	if !verifyFabricSignature(link.FabricCertificate, digest[:], fabricSignature) {
+		return ErrIdentityLinkInvalid
+	}
+
+	return nil
+}
+
+// 关联声明原文，使用固定的ASCII格式便于在链上和钱包中验证
+func identityLinkStatement(ethAddress, fabricID string, issuedAt time.Time) string {
+	return fmt.Sprintf("MedCross identity link\nethereum: %s\nfabric: %s\nissued: %s", ethAddress, fabricID, issuedAt.UTC().Format(time.RFC3339))
+}
+
+// 复制关联记录，避免调用方与上链回写并发访问ChainRecords
+func copyIdentityLink(link *models.IdentityLink) *models.IdentityLink {
+	result := *link
+	result.ChainRecords = make(map[string]string, len(link.ChainRecords))
+	for chain, txHash := range link.ChainRecords {
+		result.ChainRecords[chain] = txHash
+	}
+	return &result
+}
+
+// 加入索引（调用方需持有锁）
+func (r *IdentityRegistry) indexLocked(link *models.IdentityLink) {
+	r.store.Links[link.UserID] = link
+	r.byEthereum[strings.ToLower(link.EthereumAddress)] = link.UserID
+	r.byFabric[link.FabricID] = link.UserID
+}
+
+// 持久化注册表（调用方需持有锁）
+func (r *IdentityRegistry) saveLocked() error {
+	if err := utils.SaveJSONFile(r.storePath, r.store); err != nil {
+		log.Printf("保存身份注册表失败: %v", err)
+		return fmt.Errorf("保存身份注册表失败: %w", err)
+	}
+	return nil
+}
+
+// 将关联记录写入尚未记录的目标链
+// 链上记录关联声明、两个签名和Fabric证书，不记录用户ID；写入失败不影响本地记录，只记录日志
+func (r *IdentityRegistry) anchor(userID string) {
+	r.mu.RLock()
+	link, exists := r.store.Links[userID]
+	if !exists {
+		r.mu.RUnlock()
+		return
+	}
+	payload := map[string]interface{}{
+		"ethereumAddress":   link.EthereumAddress,
+		"fabricId":          link.FabricID,
+		"fabricCertificate": link.FabricCertificate,
+		"statement":         link.Statement,
+		"ethereumSignature": link.EthereumSignature,
+		"fabricSignature":   link.FabricSignature,
+	}
+	var chains []string
+	for _, chain := range identityLinkChains {
+		if link.ChainRecords[chain] == "" {
+			chains = append(chains, chain)
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, chain := range chains {
+		txHash, err := r.gatewayService.SubmitBlockchainTransaction(chain, "identity", payload)
+		if err != nil {
+			log.Printf("身份关联上链失败: 用户=%s, 链=%s, 错误=%v", userID, chain, err)
+			continue
+		}
+
+		r.mu.Lock()
+		if stored, exists := r.store.Links[userID]; exists && stored.Statement == link.Statement {
+			if stored.ChainRecords == nil {
+				stored.ChainRecords = make(map[string]string)
+			}
+			stored.ChainRecords[chain] = txHash
+			if err := r.saveLocked(); err != nil {
+				log.Printf("保存链上交易哈希失败: %v", err)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
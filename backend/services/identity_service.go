@@ -0,0 +1,547 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// 区块链身份错误
+var (
+	ErrIdentityExists   = errors.New("用户已有区块链身份")
+	ErrIdentityNotFound = errors.New("用户没有区块链身份")
+	ErrWalletLocked     = errors.New("密码错误，无法解锁密钥库")
+)
+
+// 密钥库加密参数
+const (
+	walletVersion = 1
+	walletScryptN = 1 << 15
+	walletScryptR = 8
+	walletScryptP = 1
+	walletKeyLen  = 32
+)
+
+// UnlockedIdentity 解锁后的区块链身份，包含两条链的明文私钥，只保存在内存中
+type UnlockedIdentity struct {
+	Identity    models.BlockchainIdentity
+	EthereumKey *utils.EthereumKey
+	FabricKey   *ecdsa.PrivateKey
+}
+
+// 加密保存的私钥
+type walletSecrets struct {
+	EthereumPrivateKey string `json:"ethereumPrivateKey"` // 十六进制
+	FabricPrivateKey   string `json:"fabricPrivateKey"`   // PKCS#8 PEM
+}
+
+// 已解锁的密钥库
+type openWallet struct {
+	identity  *UnlockedIdentity
+	expiresAt time.Time
+}
+
+// IdentityService 区块链身份服务
+// 每个用户一个密钥库文件，私钥使用用户密码通过scrypt派生的密钥以AES-256-GCM加密，服务端不保存明文私钥。
+// 用户登录或主动解锁后，私钥在内存中保留 WALLET_UNLOCK_TTL（默认12小时）用于签名交易
+type IdentityService struct {
+	mu         sync.RWMutex
+	ca         FabricCA
+	registry   *IdentityRegistry
+	dir        string
+	unlockTTL  time.Duration
+	identities map[string]*models.BlockchainIdentity // userID -> 身份公开部分
+	wallets    map[string]*openWallet                // userID -> 已解锁的密钥库
+}
+
+// NewIdentityService 创建新的区块链身份服务
+// 密钥库目录由WALLET_DIR指定，默认为数据目录下的wallets
+func NewIdentityService(ca FabricCA, registry *IdentityRegistry) *IdentityService {
+	dir := os.Getenv("WALLET_DIR")
+	if dir == "" {
+		dir = utils.DataFilePath("wallets")
+	}
+
+	unlockTTL := 12 * time.Hour
+	if d, err := time.ParseDuration(os.Getenv("WALLET_UNLOCK_TTL")); err == nil && d > 0 {
+		unlockTTL = d
+	}
+
+	service := &IdentityService{
+		ca:         ca,
+		registry:   registry,
+		dir:        dir,
+		unlockTTL:  unlockTTL,
+		identities: make(map[string]*models.BlockchainIdentity),
+		wallets:    make(map[string]*openWallet),
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		log.Printf("读取密钥库目录失败: %v", err)
+		return service
+	}
+	for _, path := range paths {
+		var wallet models.WalletFile
+		if err := utils.LoadJSONFile(path, &wallet); err != nil {
+			log.Printf("读取密钥库失败 %s: %v", path, err)
+			continue
+		}
+		identity := wallet.Identity
+		service.identities[identity.UserID] = &identity
+	}
+
+	return service
+}
+
+// NewFabricCAFromEnv 根据环境变量创建Fabric CA
+// FABRIC_MSP_ID 默认为Org1MSP；FABRIC_CA_DIR 默认为数据目录下的fabric-ca
+func NewFabricCAFromEnv() (FabricCA, error) {
+	mspID := os.Getenv("FABRIC_MSP_ID")
+	if mspID == "" {
+		mspID = "Org1MSP"
+	}
+
+	dir := os.Getenv("FABRIC_CA_DIR")
+	if dir == "" {
+		dir = utils.DataFilePath("fabric-ca")
+	}
+
+	return NewLocalFabricCA(dir, mspID)
+}
+
+// CreateIdentity 为用户生成以太坊账户和Fabric身份，使用用户密码加密后保存
+func (s *IdentityService) CreateIdentity(user *models.User, password string) (*models.BlockchainIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.identities[user.ID]; exists {
+		return nil, ErrIdentityExists
+	}
+
+	// 以太坊账户
+	ethKey, err := utils.GenerateEthereumKey()
+	if err != nil {
+		return nil, fmt.Errorf("生成以太坊私钥失败: %w", err)
+	}
+
+	// Fabric身份：本地生成P-256私钥，只把证书签名请求发给CA
+	fabricKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成Fabric私钥失败: %w", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: user.Username},
+	}, fabricKey)
+	if err != nil {
+		return nil, fmt.Errorf("生成证书签名请求失败: %w", err)
+	}
+
+	certPEM, err := s.ca.Enroll(FabricEnrollmentRequest{
+		EnrollmentID: user.Username,
+		CSR:          csr,
+		Affiliation:  user.Hospital,
+		Attributes: map[string]string{
+			"medcross.userId": user.ID,
+			"medcross.role":   user.Role,
+			// 链码按该属性确定数据所有者，与上传时的owner参数比对
+			"medcross.did": ethKey.DIDKey(),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Fabric CA登记失败: %w", err)
+	}
+
+	fabricID, err := fabricIDFromCertificate(certPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	fabricKeyDER, err := x509.MarshalPKCS8PrivateKey(fabricKey)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := models.BlockchainIdentity{
+		UserID:             user.ID,
+		DID:                ethKey.DIDKey(),
+		EthereumAddress:    ethKey.Address(),
+		FabricMSPID:        s.ca.MSPID(),
+		FabricEnrollmentID: user.Username,
+		FabricID:           fabricID,
+		FabricCertificate:  string(certPEM),
+		CreatedAt:          time.Now(),
+	}
+
+	secrets := walletSecrets{
+		EthereumPrivateKey: ethKey.Hex(),
+		FabricPrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: fabricKeyDER})),
+	}
+
+	walletCrypto, err := encryptWallet(identity, secrets, password)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet := models.WalletFile{
+		Version:  walletVersion,
+		Identity: identity,
+		Crypto:   walletCrypto,
+	}
+	if err := utils.SaveJSONFile(s.walletPath(user.ID), wallet); err != nil {
+		return nil, fmt.Errorf("保存密钥库失败: %w", err)
+	}
+
+	s.identities[user.ID] = &identity
+	log.Printf("创建区块链身份: 用户=%s, 以太坊地址=%s, Fabric身份=%s", user.ID, identity.EthereumAddress, identity.FabricID)
+
+	// 登记两个身份的关联，失败时在下次解锁密钥库时补登
+	if _, err := s.registry.Link(&UnlockedIdentity{Identity: identity, EthereumKey: ethKey, FabricKey: fabricKey}); err != nil {
+		log.Printf("登记跨链身份关联失败: 用户=%s, 错误=%v", user.ID, err)
+	}
+
+	result := identity
+	return &result, nil
+}
+
+// SyncAuditor 按用户当前的角色和账户状态同步链码的审计员登记表
+// 拥有审计日志查看权限且已启用的用户登记为审计员，其他用户移除；没有Fabric身份的用户不处理
+func (s *IdentityService) SyncAuditor(user *models.User) error {
+	if user.FabricID == "" {
+		return nil
+	}
+	auditor := user.Status == models.UserStatusActive && models.HasPermission(user.Role, models.PermAuditRead)
+	return s.registry.SetAuditor(user.FabricID, auditor)
+}
+
+// GetIdentity 获取用户区块链身份的公开部分
+func (s *IdentityService) GetIdentity(userID string) (*models.BlockchainIdentity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	identity, exists := s.identities[userID]
+	if !exists {
+		return nil, ErrIdentityNotFound
+	}
+
+	result := *identity
+	return &result, nil
+}
+
+// Unlock 使用用户密码解锁密钥库
+func (s *IdentityService) Unlock(userID, password string) (*UnlockedIdentity, error) {
+	s.mu.RLock()
+	_, exists := s.identities[userID]
+	s.mu.RUnlock()
+	if !exists {
+		return nil, ErrIdentityNotFound
+	}
+
+	var wallet models.WalletFile
+	if err := utils.LoadJSONFile(s.walletPath(userID), &wallet); err != nil {
+		return nil, fmt.Errorf("读取密钥库失败: %w", err)
+	}
+	if wallet.Version != walletVersion {
+		return nil, fmt.Errorf("不支持的密钥库版本: %d", wallet.Version)
+	}
+
+	secrets, err := decryptWallet(wallet, password)
+	if err != nil {
+		return nil, err
+	}
+
+	ethKey, err := utils.EthereumKeyFromHex(secrets.EthereumPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if ethKey.Address() != wallet.Identity.EthereumAddress {
+		return nil, errors.New("密钥库中的以太坊私钥与地址不匹配")
+	}
+
+	block, _ := pem.Decode([]byte(secrets.FabricPrivateKey))
+	if block == nil {
+		return nil, errors.New("密钥库中的Fabric私钥格式无效")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	fabricKey, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("密钥库中的Fabric私钥不是ECDSA私钥")
+	}
+
+	return &UnlockedIdentity{
+		Identity:    wallet.Identity,
+		EthereumKey: ethKey,
+		FabricKey:   fabricKey,
+	}, nil
+}
+
+// OpenWallet 使用用户密码解锁密钥库并在内存中保留，返回过期时间
+func (s *IdentityService) OpenWallet(userID, password string) (time.Time, error) {
+	identity, err := s.Unlock(userID, password)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// 补充在DID功能上线前创建的身份的did:key
+	if identity.Identity.DID == "" {
+		if err := s.backfillDID(identity); err != nil {
+			log.Printf("补充用户DID失败: 用户=%s, 错误=%v", userID, err)
+		}
+	}
+
+	// 补登在关联功能上线前创建或登记失败的身份
+	if !s.registry.IsLinked(userID) {
+		if _, err := s.registry.Link(identity); err != nil {
+			log.Printf("登记跨链身份关联失败: 用户=%s, 错误=%v", userID, err)
+		}
+	}
+
+	expiresAt := time.Now().Add(s.unlockTTL)
+
+	s.mu.Lock()
+	s.wallets[userID] = &openWallet{identity: identity, expiresAt: expiresAt}
+	s.mu.Unlock()
+
+	return expiresAt, nil
+}
+
+// OpenedWallet 获取已解锁且未过期的密钥库
+func (s *IdentityService) OpenedWallet(userID string) (*UnlockedIdentity, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wallet, exists := s.wallets[userID]
+	if !exists {
+		return nil, time.Time{}, false
+	}
+	if time.Now().After(wallet.expiresAt) {
+		delete(s.wallets, userID)
+		return nil, time.Time{}, false
+	}
+	return wallet.identity, wallet.expiresAt, true
+}
+
+// CloseWallet 锁定密钥库，清除内存中的私钥
+func (s *IdentityService) CloseWallet(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.wallets, userID)
+}
+
+// ChangeWalletPassword 使用旧密码解锁密钥库，改用新密码重新加密
+// 用户修改密码时调用；重置密码后可以用旧密码调用以恢复密钥库
+func (s *IdentityService) ChangeWalletPassword(userID, oldPassword, newPassword string) error {
+	identity, err := s.Unlock(userID, oldPassword)
+	if err != nil {
+		return err
+	}
+	return s.rewrap(identity, newPassword)
+}
+
+// RewrapOpenWallet 使用新密码重新加密已解锁的密钥库，密钥库未解锁时返回ErrWalletNotOpen
+// 用于重置密码：用户不知道旧密码，只有内存中仍保留私钥时才能改用新密码
+func (s *IdentityService) RewrapOpenWallet(userID, newPassword string) error {
+	if _, err := s.GetIdentity(userID); err != nil {
+		return err
+	}
+	identity, _, open := s.OpenedWallet(userID)
+	if !open {
+		return ErrWalletNotOpen
+	}
+	return s.rewrap(identity, newPassword)
+}
+
+// 使用新密码重新加密私钥并写回密钥库文件
+func (s *IdentityService) rewrap(identity *UnlockedIdentity, password string) error {
+	fabricKeyDER, err := x509.MarshalPKCS8PrivateKey(identity.FabricKey)
+	if err != nil {
+		return err
+	}
+	secrets := walletSecrets{
+		EthereumPrivateKey: identity.EthereumKey.Hex(),
+		FabricPrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: fabricKeyDER})),
+	}
+
+	walletCrypto, err := encryptWallet(identity.Identity, secrets, password)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wallet := models.WalletFile{
+		Version:  walletVersion,
+		Identity: identity.Identity,
+		Crypto:   walletCrypto,
+	}
+	if err := utils.SaveJSONFile(s.walletPath(identity.Identity.UserID), wallet); err != nil {
+		return fmt.Errorf("保存密钥库失败: %w", err)
+	}
+
+	log.Printf("密钥库已改用新密码加密: 用户=%s", identity.Identity.UserID)
+	return nil
+}
+
+// 根据已解锁的以太坊私钥补充did:key，写回密钥库文件的公开部分（不参与加密的附加认证数据）
+func (s *IdentityService) backfillDID(identity *UnlockedIdentity) error {
+	userID := identity.Identity.UserID
+	did := identity.EthereumKey.DIDKey()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var wallet models.WalletFile
+	if err := utils.LoadJSONFile(s.walletPath(userID), &wallet); err != nil {
+		return err
+	}
+	wallet.Identity.DID = did
+	if err := utils.SaveJSONFile(s.walletPath(userID), wallet); err != nil {
+		return err
+	}
+
+	identity.Identity.DID = did
+	if stored, exists := s.identities[userID]; exists {
+		stored.DID = did
+	}
+	return nil
+}
+
+// 密钥库文件路径，用户ID来自服务端生成的UUID
+func (s *IdentityService) walletPath(userID string) string {
+	return filepath.Join(s.dir, userID+".json")
+}
+
+// 使用密码加密私钥，身份公开部分作为附加认证数据，防止密钥库被替换为其他用户的身份
+func encryptWallet(identity models.BlockchainIdentity, secrets walletSecrets, password string) (models.WalletCrypto, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return models.WalletCrypto{}, err
+	}
+
+	params := models.WalletKDFParams{
+		N:    walletScryptN,
+		R:    walletScryptR,
+		P:    walletScryptP,
+		Salt: hex.EncodeToString(salt),
+	}
+
+	aead, err := walletAEAD(password, params)
+	if err != nil {
+		return models.WalletCrypto{}, err
+	}
+
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return models.WalletCrypto{}, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return models.WalletCrypto{}, err
+	}
+
+	ciphertext := aead.Seal(nil, nonce, plaintext, walletAAD(identity))
+
+	return models.WalletCrypto{
+		KDF:        "scrypt",
+		KDFParams:  params,
+		Cipher:     "aes-256-gcm",
+		Nonce:      hex.EncodeToString(nonce),
+		Ciphertext: hex.EncodeToString(ciphertext),
+	}, nil
+}
+
+// 使用密码解密私钥，密码错误时返回ErrWalletLocked
+func decryptWallet(wallet models.WalletFile, password string) (*walletSecrets, error) {
+	if wallet.Crypto.KDF != "scrypt" || wallet.Crypto.Cipher != "aes-256-gcm" {
+		return nil, fmt.Errorf("不支持的密钥库加密方式: %s/%s", wallet.Crypto.KDF, wallet.Crypto.Cipher)
+	}
+
+	aead, err := walletAEAD(password, wallet.Crypto.KDFParams)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := hex.DecodeString(wallet.Crypto.Nonce)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return nil, errors.New("密钥库格式无效")
+	}
+	ciphertext, err := hex.DecodeString(wallet.Crypto.Ciphertext)
+	if err != nil {
+		return nil, errors.New("密钥库格式无效")
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, walletAAD(wallet.Identity))
+	if err != nil {
+		return nil, ErrWalletLocked
+	}
+
+	var secrets walletSecrets
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, err
+	}
+	return &secrets, nil
+}
+
+// 由密码派生AES-256-GCM密钥
+func walletAEAD(password string, params models.WalletKDFParams) (cipher.AEAD, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, errors.New("密钥库格式无效")
+	}
+
+	key, err := scrypt.Key([]byte(password), salt, params.N, params.R, params.P, walletKeyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 附加认证数据：用户ID和两条链上的地址
+func walletAAD(identity models.BlockchainIdentity) []byte {
+	return []byte(strings.Join([]string{identity.UserID, identity.EthereumAddress, identity.FabricID}, "|"))
+}
+
+// 按Fabric链码cid.GetID的格式生成身份标识：x509::<subject>::<issuer>
+func fabricIDFromCertificate(certPEM []byte) (string, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return "", errors.New("Fabric证书格式无效")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("解析Fabric证书失败: %w", err)
+	}
+
+	return "x509::" + cert.Subject.String() + "::" + cert.Issuer.String(), nil
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 登录限制相关错误
+var (
+	ErrLoginThrottled = errors.New("登录失败次数过多，请稍后再试")
+	ErrLoginLocked    = errors.New("登录失败次数过多，账户已临时锁定")
+)
+
+const (
+	// 连续失败达到该次数后开始递增等待时间
+	loginFreeFailures = 3
+	// 递增等待的初始值和上限
+	loginBaseDelay = time.Second
+	loginMaxDelay  = 30 * time.Second
+)
+
+// loginFailures 某个账户或IP的连续登录失败记录
+type loginFailures struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// LoginThrottleService 登录暴力破解防护服务
+// 按账户和来源IP分别统计连续失败次数：超过免费次数后每次失败的等待时间翻倍，
+// 达到上限后临时锁定。记录只保存在内存中，服务重启后清空
+type LoginThrottleService struct {
+	mu                 sync.Mutex
+	failures           map[string]*loginFailures
+	maxAccountFailures int
+	maxIPFailures      int
+	lockoutDuration    time.Duration
+	lastPrune          time.Time
+}
+
+// NewLoginThrottleService 创建新的登录限制服务
+func NewLoginThrottleService() *LoginThrottleService {
+	maxAccountFailures := 10
+	if v, err := strconv.Atoi(os.Getenv("LOGIN_MAX_FAILURES")); err == nil && v > 0 {
+		maxAccountFailures = v
+	}
+
+	maxIPFailures := 50
+	if v, err := strconv.Atoi(os.Getenv("LOGIN_IP_MAX_FAILURES")); err == nil && v > 0 {
+		maxIPFailures = v
+	}
+
+	lockoutDuration := 15 * time.Minute
+	if d, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_DURATION")); err == nil && d > 0 {
+		lockoutDuration = d
+	}
+
+	return &LoginThrottleService{
+		failures:           make(map[string]*loginFailures),
+		maxAccountFailures: maxAccountFailures,
+		maxIPFailures:      maxIPFailures,
+		lockoutDuration:    lockoutDuration,
+	}
+}
+
+// Check 检查是否允许本次登录尝试，不允许时返回需要等待的时间
+func (s *LoginThrottleService) Check(username, clientIP string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	var result error
+
+	for _, key := range []string{loginAccountKey(username), loginIPKey(clientIP)} {
+		record, exists := s.failures[key]
+		if !exists {
+			continue
+		}
+
+		if now.Before(record.lockedUntil) {
+			if d := record.lockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+			result = ErrLoginLocked
+			continue
+		}
+
+		if next := record.lastFailure.Add(failureDelay(record.count)); now.Before(next) {
+			if d := next.Sub(now); d > wait {
+				wait = d
+			}
+			if result == nil {
+				result = ErrLoginThrottled
+			}
+		}
+	}
+
+	return wait, result
+}
+
+// RecordFailure 记录一次登录失败
+func (s *LoginThrottleService) RecordFailure(username, clientIP string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.pruneLocked(now)
+
+	s.recordLocked(loginAccountKey(username), s.maxAccountFailures, now)
+	s.recordLocked(loginIPKey(clientIP), s.maxIPFailures, now)
+}
+
+// RecordSuccess 登录成功后清除账户的失败记录
+// 来源IP的记录保留，避免攻击者用自己的账户重置IP计数
+func (s *LoginThrottleService) RecordSuccess(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, loginAccountKey(username))
+}
+
+// 累加失败次数，达到上限时锁定，调用方需持有锁
+func (s *LoginThrottleService) recordLocked(key string, limit int, now time.Time) {
+	record, exists := s.failures[key]
+	if !exists || now.Sub(record.lastFailure) > s.lockoutDuration {
+		record = &loginFailures{}
+		s.failures[key] = record
+	}
+
+	record.count++
+	record.lastFailure = now
+
+	if record.count >= limit {
+		record.lockedUntil = now.Add(s.lockoutDuration)
+		record.count = 0
+		log.Printf("警告: 登录失败次数过多，已临时锁定: %s, 时长=%s", key, s.lockoutDuration)
+	}
+}
+
+// 清理已过期的失败记录，每分钟最多一次，调用方需持有锁
+func (s *LoginThrottleService) pruneLocked(now time.Time) {
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	s.lastPrune = now
+
+	for key, record := range s.failures {
+		if now.After(record.lockedUntil) && now.Sub(record.lastFailure) > s.lockoutDuration {
+			delete(s.failures, key)
+		}
+	}
+}
+
+// 连续失败count次后下一次尝试前需要等待的时间
+func failureDelay(count int) time.Duration {
+	if count < loginFreeFailures {
+		return 0
+	}
+	delay := loginBaseDelay
+	for i := loginFreeFailures; i < count && delay < loginMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > loginMaxDelay {
+		delay = loginMaxDelay
+	}
+	return delay
+}
+
+// 账户维度的计数键，用户名不区分大小写
+func loginAccountKey(username string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// 来源IP维度的计数键
+func loginIPKey(clientIP string) string {
+	return "ip:" + clientIP
+}
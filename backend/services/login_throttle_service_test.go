@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFailureDelay(t *testing.T) {
+	tests := []struct {
+		count int
+		want  time.Duration
+	}{
+		{count: 0, want: 0},
+		{count: loginFreeFailures - 1, want: 0},
+		{count: loginFreeFailures, want: loginBaseDelay},
+		{count: loginFreeFailures + 1, want: 2 * loginBaseDelay},
+		{count: loginFreeFailures + 3, want: 8 * loginBaseDelay},
+		{count: 100, want: loginMaxDelay},
+	}
+
+	for _, tt := range tests {
+		if got := failureDelay(tt.count); got != tt.want {
+			t.Errorf("failureDelay(%d) = %s, 期望 %s", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestLoginThrottleLockoutAndReset(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILURES", "5")
+	t.Setenv("LOGIN_IP_MAX_FAILURES", "8")
+	t.Setenv("LOGIN_LOCKOUT_DURATION", "200ms")
+
+	fail := func(s *LoginThrottleService, username, clientIP string, times int) {
+		for i := 0; i < times; i++ {
+			s.RecordFailure(username, clientIP)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		setup    func(s *LoginThrottleService)
+		username string
+		clientIP string
+		wantErr  error
+	}{
+		{
+			name:     "免费失败次数内",
+			setup:    func(s *LoginThrottleService) { fail(s, "alice", "10.0.0.1", loginFreeFailures-1) },
+			username: "alice",
+			clientIP: "10.0.0.1",
+		},
+		{
+			name:     "超过免费次数后需要等待",
+			setup:    func(s *LoginThrottleService) { fail(s, "alice", "10.0.0.1", loginFreeFailures) },
+			username: "alice",
+			clientIP: "10.0.0.1",
+			wantErr:  ErrLoginThrottled,
+		},
+		{
+			name:     "账户达到上限后锁定，其他IP同样无法登录",
+			setup:    func(s *LoginThrottleService) { fail(s, "alice", "10.0.0.1", 5) },
+			username: "ALICE ",
+			clientIP: "10.0.0.2",
+			wantErr:  ErrLoginLocked,
+		},
+		{
+			name: "IP达到上限后锁定，其他账户同样无法登录",
+			setup: func(s *LoginThrottleService) {
+				fail(s, "alice", "10.0.0.1", 4)
+				fail(s, "bob", "10.0.0.1", 4)
+			},
+			username: "carol",
+			clientIP: "10.0.0.1",
+			wantErr:  ErrLoginLocked,
+		},
+		{
+			name: "登录成功后清除账户的失败记录",
+			setup: func(s *LoginThrottleService) {
+				fail(s, "alice", "10.0.0.1", 5)
+				s.RecordSuccess("alice")
+			},
+			username: "alice",
+			clientIP: "10.0.0.2",
+		},
+		{
+			name: "登录成功不清除IP的失败记录",
+			setup: func(s *LoginThrottleService) {
+				fail(s, "alice", "10.0.0.1", 4)
+				fail(s, "bob", "10.0.0.1", 4)
+				s.RecordSuccess("bob")
+			},
+			username: "bob",
+			clientIP: "10.0.0.1",
+			wantErr:  ErrLoginLocked,
+		},
+		{
+			name: "账户锁定到期后可以重新登录",
+			setup: func(s *LoginThrottleService) {
+				fail(s, "alice", "10.0.0.1", 5)
+				time.Sleep(250 * time.Millisecond)
+			},
+			username: "alice",
+			clientIP: "10.0.0.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewLoginThrottleService()
+			tt.setup(service)
+
+			wait, err := service.Check(tt.username, tt.clientIP)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Check 错误 = %v, 期望 %v", err, tt.wantErr)
+			}
+			if (err != nil) != (wait > 0) {
+				t.Errorf("等待时间 = %s, 错误 = %v", wait, err)
+			}
+		})
+	}
+}
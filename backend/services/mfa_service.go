@@ -0,0 +1,473 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// 双因素认证相关错误
+var (
+	ErrMFAAlreadyEnabled    = errors.New("双因素认证已启用")
+	ErrMFANotEnabled        = errors.New("尚未启用双因素认证")
+	ErrMFANotSetup          = errors.New("请先获取双因素认证密钥")
+	ErrMFAInvalidCode       = errors.New("验证码无效")
+	ErrMFAChallengeInvalid  = errors.New("二次验证已过期，请重新登录")
+	ErrMFARequiredForRole   = errors.New("当前角色要求启用双因素认证，不能关闭")
+	ErrMFAPolicyInvalidRole = errors.New("未知的角色")
+)
+
+const (
+	// 登录第二步的有效期
+	mfaChallengeTTL = 5 * time.Minute
+	// 每个登录挑战允许的验证码尝试次数
+	mfaChallengeMaxAttempts = 5
+	// 生成的恢复码数量
+	mfaRecoveryCodeCount = 10
+	// 允许的时钟偏差（时间步）
+	mfaTOTPSkew = 1
+)
+
+// mfaStore 双因素认证的持久化结构
+type mfaStore struct {
+	Enrollments map[string]*models.MFAEnrollment `json:"enrollments"`
+	Policy      models.MFAPolicy                 `json:"policy"`
+}
+
+// mfaChallenge 密码验证通过、等待验证码的登录挑战
+type mfaChallenge struct {
+	userID    string
+	expiresAt time.Time
+	attempts  int
+}
+
+// MFAService TOTP双因素认证服务
+// TOTP密钥使用 MFA_ENCRYPTION_KEY 以AES-256-GCM加密后保存
+type MFAService struct {
+	mu         sync.Mutex
+	store      mfaStore
+	challenges map[string]*mfaChallenge
+	issuer     string
+	storePath  string
+	cipher     *utils.SecretCipher
+}
+
+// NewMFAService 创建新的双因素认证服务
+// 首次启动时按环境变量 MFA_REQUIRED_ROLES 初始化要求双因素认证的角色，之后由管理员接口维护。
+// 没有配置 MFA_ENCRYPTION_KEY 时，allowDevKey为true则使用开发密钥，否则返回错误
+func NewMFAService(allowDevKey bool) (*MFAService, error) {
+	issuer := os.Getenv("MFA_ISSUER")
+	if issuer == "" {
+		issuer = "MedCross"
+	}
+
+	secretCipher, err := utils.NewSecretCipherFromEnv("MFA_ENCRYPTION_KEY", allowDevKey)
+	if err != nil {
+		return nil, err
+	}
+
+	service := &MFAService{
+		challenges: make(map[string]*mfaChallenge),
+		issuer:     issuer,
+		storePath:  utils.DataFilePath("mfa.json"),
+		cipher:     secretCipher,
+	}
+
+	if _, err := os.Stat(service.storePath); errors.Is(err, os.ErrNotExist) {
+		for _, role := range strings.Split(os.Getenv("MFA_REQUIRED_ROLES"), ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				service.store.Policy.RequiredRoles = append(service.store.Policy.RequiredRoles, role)
+			}
+		}
+	}
+
+	// 加载已持久化的双因素认证设置
+	if err := utils.LoadJSONFile(service.storePath, &service.store); err != nil {
+		log.Printf("加载双因素认证设置失败: %v", err)
+	}
+	if service.store.Enrollments == nil {
+		service.store.Enrollments = make(map[string]*models.MFAEnrollment)
+	}
+
+	// 加密旧版本明文保存的TOTP密钥
+	migrated := 0
+	for _, enrollment := range service.store.Enrollments {
+		if utils.IsSealedSecret(enrollment.Secret) {
+			continue
+		}
+		sealed, err := secretCipher.Seal(enrollment.Secret, enrollment.UserID)
+		if err != nil {
+			return nil, err
+		}
+		enrollment.Secret = sealed
+		migrated++
+	}
+	if migrated > 0 {
+		if err := service.saveLocked(); err != nil {
+			return nil, err
+		}
+		log.Printf("已加密 %d 个明文保存的TOTP密钥", migrated)
+	}
+
+	return service, nil
+}
+
+// IsEnabled 检查用户是否已启用双因素认证
+func (s *MFAService) IsEnabled(userID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enrollment, exists := s.store.Enrollments[userID]
+	return exists && enrollment.Confirmed
+}
+
+// IsRequired 检查角色是否要求双因素认证
+func (s *MFAService) IsRequired(role string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return containsString(s.store.Policy.RequiredRoles, role)
+}
+
+// Status 获取用户的双因素认证状态
+func (s *MFAService) Status(user *models.User) models.MFAStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := models.MFAStatus{
+		Required: containsString(s.store.Policy.RequiredRoles, user.Role),
+	}
+	if enrollment, exists := s.store.Enrollments[user.ID]; exists && enrollment.Confirmed {
+		status.Enabled = true
+		status.RecoveryCodesRemaining = len(enrollment.RecoveryCodeHashes)
+	}
+	return status
+}
+
+// BeginSetup 为用户生成新的TOTP密钥，确认前不生效
+func (s *MFAService) BeginSetup(user *models.User) (*models.MFASetupResponse, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, exists := s.store.Enrollments[user.ID]
+	if exists && previous.Confirmed {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	sealed, err := s.cipher.Seal(secret, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.store.Enrollments[user.ID] = &models.MFAEnrollment{
+		UserID:    user.ID,
+		Secret:    sealed,
+		CreatedAt: time.Now(),
+	}
+	if err := s.saveLocked(); err != nil {
+		if exists {
+			s.store.Enrollments[user.ID] = previous
+		} else {
+			delete(s.store.Enrollments, user.ID)
+		}
+		return nil, err
+	}
+
+	return &models.MFASetupResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.issuer, user.Username, secret),
+	}, nil
+}
+
+// Confirm 使用验证码确认启用双因素认证，返回恢复码
+func (s *MFAService) Confirm(userID, code string) ([]string, error) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enrollment, exists := s.store.Enrollments[userID]
+	if !exists {
+		return nil, ErrMFANotSetup
+	}
+	if enrollment.Confirmed {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := s.totpSecretLocked(enrollment)
+	if err != nil {
+		return nil, err
+	}
+	counter, ok := utils.ValidateTOTP(secret, code, time.Now(), mfaTOTPSkew)
+	if !ok {
+		return nil, ErrMFAInvalidCode
+	}
+
+	previous := *enrollment
+	now := time.Now()
+	enrollment.Confirmed = true
+	enrollment.ConfirmedAt = &now
+	enrollment.LastCounter = counter
+	enrollment.RecoveryCodeHashes = hashes
+
+	if err := s.saveLocked(); err != nil {
+		*enrollment = previous
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Verify 校验TOTP验证码或恢复码，恢复码使用后失效
+func (s *MFAService) Verify(userID, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.verifyLocked(userID, code)
+}
+
+// Disable 关闭双因素认证，需要提供有效的验证码
+// 角色要求双因素认证时不能关闭
+func (s *MFAService) Disable(user *models.User, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if containsString(s.store.Policy.RequiredRoles, user.Role) {
+		return ErrMFARequiredForRole
+	}
+	if err := s.verifyLocked(user.ID, code); err != nil {
+		return err
+	}
+
+	previous := s.store.Enrollments[user.ID]
+	delete(s.store.Enrollments, user.ID)
+	if err := s.saveLocked(); err != nil {
+		s.store.Enrollments[user.ID] = previous
+		return err
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，原有恢复码全部失效
+func (s *MFAService) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.verifyLocked(userID, code); err != nil {
+		return nil, err
+	}
+
+	enrollment := s.store.Enrollments[userID]
+	previous := enrollment.RecoveryCodeHashes
+	enrollment.RecoveryCodeHashes = hashes
+	if err := s.saveLocked(); err != nil {
+		enrollment.RecoveryCodeHashes = previous
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// CreateChallenge 为密码验证通过的用户创建登录挑战，返回挑战令牌
+func (s *MFAService) CreateChallenge(userID string) (string, time.Duration, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 顺便清理过期的挑战
+	now := time.Now()
+	for key, challenge := range s.challenges {
+		if now.After(challenge.expiresAt) {
+			delete(s.challenges, key)
+		}
+	}
+
+	s.challenges[token] = &mfaChallenge{
+		userID:    userID,
+		expiresAt: now.Add(mfaChallengeTTL),
+	}
+
+	return token, mfaChallengeTTL, nil
+}
+
+// CompleteChallenge 使用验证码完成登录挑战，返回用户ID
+// 挑战成功后立即失效，连续失败达到上限后也会失效
+func (s *MFAService) CompleteChallenge(token, code string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, exists := s.challenges[token]
+	if !exists || time.Now().After(challenge.expiresAt) {
+		delete(s.challenges, token)
+		return "", ErrMFAChallengeInvalid
+	}
+
+	if err := s.verifyLocked(challenge.userID, code); err != nil {
+		challenge.attempts++
+		if challenge.attempts >= mfaChallengeMaxAttempts {
+			delete(s.challenges, token)
+			log.Printf("警告: 双因素认证失败次数过多: 用户=%s", challenge.userID)
+		}
+		return "", err
+	}
+
+	delete(s.challenges, token)
+	return challenge.userID, nil
+}
+
+// GetPolicy 获取双因素认证策略
+func (s *MFAService) GetPolicy() models.MFAPolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	roles := make([]string, len(s.store.Policy.RequiredRoles))
+	copy(roles, s.store.Policy.RequiredRoles)
+	return models.MFAPolicy{RequiredRoles: roles}
+}
+
+// SetPolicy 设置要求双因素认证的角色
+func (s *MFAService) SetPolicy(policy models.MFAPolicy) error {
+	roles := make([]string, 0, len(policy.RequiredRoles))
+	for _, role := range policy.RequiredRoles {
+		if _, exists := models.RolePermissions[role]; !exists {
+			return fmt.Errorf("%w: %s", ErrMFAPolicyInvalidRole, role)
+		}
+		if !containsString(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.store.Policy
+	s.store.Policy = models.MFAPolicy{RequiredRoles: roles}
+	if err := s.saveLocked(); err != nil {
+		s.store.Policy = previous
+		return err
+	}
+
+	log.Printf("双因素认证策略已更新: 角色=%s", strings.Join(roles, ","))
+	return nil
+}
+
+// 校验TOTP验证码或恢复码，调用方需持有锁
+func (s *MFAService) verifyLocked(userID, code string) error {
+	enrollment, exists := s.store.Enrollments[userID]
+	if !exists || !enrollment.Confirmed {
+		return ErrMFANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+
+	// 6位数字按TOTP验证码处理，拒绝已使用过的时间步；密钥无法解密时仍可以使用恢复码
+	secret, err := s.totpSecretLocked(enrollment)
+	if err != nil {
+		log.Printf("解密TOTP密钥失败: 用户=%s, 错误=%v", userID, err)
+	}
+	if counter, ok := utils.ValidateTOTP(secret, code, time.Now(), mfaTOTPSkew); err == nil && ok {
+		if counter <= enrollment.LastCounter {
+			return ErrMFAInvalidCode
+		}
+		previous := enrollment.LastCounter
+		enrollment.LastCounter = counter
+		if err := s.saveLocked(); err != nil {
+			enrollment.LastCounter = previous
+			return err
+		}
+		return nil
+	}
+
+	// 其他输入按恢复码处理
+	normalized := normalizeRecoveryCode(code)
+	for i, hash := range enrollment.RecoveryCodeHashes {
+		if !utils.CheckPasswordHash(normalized, hash) {
+			continue
+		}
+
+		previous := enrollment.RecoveryCodeHashes
+		remaining := make([]string, 0, len(previous)-1)
+		remaining = append(remaining, previous[:i]...)
+		remaining = append(remaining, previous[i+1:]...)
+		enrollment.RecoveryCodeHashes = remaining
+		if err := s.saveLocked(); err != nil {
+			enrollment.RecoveryCodeHashes = previous
+			return err
+		}
+
+		log.Printf("用户使用恢复码完成双因素认证: 用户=%s, 剩余=%d", userID, len(remaining))
+		return nil
+	}
+
+	return ErrMFAInvalidCode
+}
+
+// 解密用户的TOTP密钥，调用方需持有锁
+func (s *MFAService) totpSecretLocked(enrollment *models.MFAEnrollment) (string, error) {
+	return s.cipher.Open(enrollment.Secret, enrollment.UserID)
+}
+
+// 持久化双因素认证设置，调用方需持有锁
+func (s *MFAService) saveLocked() error {
+	if err := utils.SaveJSONFile(s.storePath, s.store); err != nil {
+		log.Printf("保存双因素认证设置失败: %v", err)
+		return err
+	}
+	return nil
+}
+
+// 生成恢复码及其bcrypt哈希，恢复码格式为 xxxxx-xxxxx
+func generateRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, mfaRecoveryCodeCount)
+	hashes := make([]string, 0, mfaRecoveryCodeCount)
+
+	for i := 0; i < mfaRecoveryCodeCount; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(buf))[:10]
+
+		hash, err := utils.HashPassword(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hash)
+	}
+
+	return codes, hashes, nil
+}
+
+// 规范化用户输入的恢复码
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// Notifier 通知发送接口
+type Notifier interface {
+	Notify(userID, subject, message string) error
+}
+
+// NotificationService 站内通知服务
+type NotificationService struct {
+	mu            sync.RWMutex
+	notifications map[string]*models.Notification
+	storePath     string
+}
+
+// NewNotificationService 创建新的站内通知服务
+func NewNotificationService() *NotificationService {
+	service := &NotificationService{
+		notifications: make(map[string]*models.Notification),
+		storePath:     utils.DataFilePath("notifications.json"),
+	}
+
+	// 加载已持久化的通知
+	if err := utils.LoadJSONFile(service.storePath, &service.notifications); err != nil {
+		log.Printf("加载站内通知失败: %v", err)
+	}
+	if service.notifications == nil {
+		service.notifications = make(map[string]*models.Notification)
+	}
+
+	return service
+}
+
+// Notify 向用户发送站内通知
+func (s *NotificationService) Notify(userID, subject, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notification := &models.Notification{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Subject:   subject,
+		Message:   message,
+		CreatedAt: time.Now(),
+	}
+	s.notifications[notification.ID] = notification
+
+	if err := utils.SaveJSONFile(s.storePath, s.notifications); err != nil {
+		log.Printf("保存站内通知失败: %v", err)
+		return err
+	}
+
+	log.Printf("已发送站内通知: 用户=%s, 主题=%s", userID, subject)
+	return nil
+}
+
+// ListForUser 获取用户的站内通知，按时间倒序
+func (s *NotificationService) ListForUser(userID string, unreadOnly bool) []models.Notification {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	notifications := []models.Notification{}
+	for _, notification := range s.notifications {
+		if notification.UserID != userID || (unreadOnly && notification.Read) {
+			continue
+		}
+		notifications = append(notifications, *notification)
+	}
+
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].CreatedAt.After(notifications[j].CreatedAt)
+	})
+
+	return notifications
+}
+
+// MarkRead 将通知标记为已读
+func (s *NotificationService) MarkRead(userID, notificationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notification, exists := s.notifications[notificationID]
+	if !exists || notification.UserID != userID {
+		return errors.New("通知不存在")
+	}
+
+	notification.Read = true
+	return utils.SaveJSONFile(s.storePath, s.notifications)
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// LogNotifier 将通知写入服务日志，仅用于开发环境
+// 密码重置令牌等敏感内容会出现在日志中，生产环境应配置webhook
+type LogNotifier struct{}
+
+// Notify 将通知写入服务日志
+func (n *LogNotifier) Notify(userID, subject, message string) error {
+	log.Printf("[通知] 用户=%s, 主题=%s, 内容=%s", userID, subject, message)
+	return nil
+}
+
+// WebhookNotifier 将通知POST到外部webhook，由邮件或短信网关投递给用户
+type WebhookNotifier struct {
+	url         string
+	userService *UserService
+	client      *http.Client
+}
+
+// 发送到webhook的通知内容
+type webhookNotification struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	Email    string `json:"email,omitempty"`
+	Subject  string `json:"subject"`
+	Message  string `json:"message"`
+}
+
+// NewWebhookNotifier 创建新的webhook通知发送器
+func NewWebhookNotifier(url string, userService *UserService) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:         url,
+		userService: userService,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify 将通知连同用户的联系方式发送到webhook
+func (n *WebhookNotifier) Notify(userID, subject, message string) error {
+	user, err := n.userService.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(webhookNotification{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Subject:  subject,
+		Message:  message,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("发送通知失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("通知webhook返回错误状态码: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NewNotifierFromEnv 根据环境变量创建站外通知发送器
+// PASSWORD_RESET_NOTIFIER为webhook时发送到NOTIFIER_WEBHOOK_URL，默认写入服务日志
+func NewNotifierFromEnv(userService *UserService) (Notifier, error) {
+	switch os.Getenv("PASSWORD_RESET_NOTIFIER") {
+	case "webhook":
+		url := os.Getenv("NOTIFIER_WEBHOOK_URL")
+		if url == "" {
+			return nil, errors.New("PASSWORD_RESET_NOTIFIER=webhook 时必须设置 NOTIFIER_WEBHOOK_URL")
+		}
+		return NewWebhookNotifier(url, userService), nil
+	case "", "log":
+		log.Printf("警告: 密码重置令牌将写入服务日志，生产环境请设置 PASSWORD_RESET_NOTIFIER=webhook")
+		return &LogNotifier{}, nil
+	default:
+		return nil, fmt.Errorf("不支持的通知方式: %s", os.Getenv("PASSWORD_RESET_NOTIFIER"))
+	}
+}
@@ -0,0 +1,578 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// 单点登录相关错误
+var (
+	ErrOIDCProviderNotFound     = errors.New("身份提供方不存在")
+	ErrOIDCStateInvalid         = errors.New("单点登录请求已过期，请重新登录")
+	ErrOIDCTokenInvalid         = errors.New("身份提供方返回的ID令牌无效")
+	ErrOIDCUnavailable          = errors.New("无法连接身份提供方")
+	ErrOIDCRoleNotAllowed       = errors.New("身份提供方返回的角色不允许登录")
+	ErrOIDCProvisioningDisabled = errors.New("该身份提供方不允许自动创建用户")
+	ErrOIDCHospitalNotAllowed   = errors.New("身份提供方返回的医院不允许登录")
+	ErrOIDCHospitalMismatch     = errors.New("身份提供方返回的医院与账户所属医院不一致")
+)
+
+const (
+	// 单点登录请求（state）的有效期
+	oidcStateTTL = 10 * time.Minute
+	// 发现文档的缓存时间
+	oidcDiscoveryTTL = time.Hour
+	// 遇到未知kid时刷新JWKS的最小间隔
+	oidcJWKSRefreshInterval = time.Minute
+	// 允许的时钟偏差
+	oidcClockSkew = time.Minute
+)
+
+// oidcDiscovery OpenID Connect发现文档中使用的字段
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcProvider 身份提供方配置及缓存的元数据和公钥
+type oidcProvider struct {
+	config      models.OIDCProviderConfig
+	discovery   *oidcDiscovery
+	discoveryAt time.Time
+	keys        map[string]*utils.JWK
+	keysAt      time.Time
+}
+
+// oidcLoginState 尚未完成的单点登录请求
+type oidcLoginState struct {
+	providerID string
+	verifier   string
+	nonce      string
+	expiresAt  time.Time
+}
+
+// OIDCService OpenID Connect单点登录服务
+// 使用授权码模式和PKCE，ID令牌通过身份提供方的JWKS验证签名
+type OIDCService struct {
+	mu         sync.Mutex
+	providers  map[string]*oidcProvider
+	states     map[string]*oidcLoginState
+	httpClient *http.Client
+}
+
+// NewOIDCService 创建新的单点登录服务
+// 身份提供方配置从 OIDC_PROVIDERS_FILE（默认 ./oidc_providers.json）读取，文件不存在时不启用单点登录
+func NewOIDCService() (*OIDCService, error) {
+	service := &OIDCService{
+		providers:  make(map[string]*oidcProvider),
+		states:     make(map[string]*oidcLoginState),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	path := os.Getenv("OIDC_PROVIDERS_FILE")
+	if path == "" {
+		path = "./oidc_providers.json"
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return service, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []models.OIDCProviderConfig
+	if err := json.Unmarshal(content, &configs); err != nil {
+		return nil, fmt.Errorf("解析身份提供方配置失败: %w", err)
+	}
+
+	for _, config := range configs {
+		if err := validateOIDCProviderConfig(config); err != nil {
+			return nil, err
+		}
+		if _, exists := service.providers[config.ID]; exists {
+			return nil, fmt.Errorf("身份提供方ID重复: %s", config.ID)
+		}
+		service.providers[config.ID] = &oidcProvider{config: config}
+		log.Printf("已加载身份提供方: ID=%s, Issuer=%s", config.ID, config.Issuer)
+	}
+
+	return service, nil
+}
+
+// ListProviders 获取已配置的身份提供方
+func (s *OIDCService) ListProviders() []models.OIDCProviderInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	providers := make([]models.OIDCProviderInfo, 0, len(s.providers))
+	for _, provider := range s.providers {
+		providers = append(providers, models.OIDCProviderInfo{ID: provider.config.ID, Name: provider.config.Name})
+	}
+
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].ID < providers[j].ID
+	})
+
+	return providers
+}
+
+// AuthorizationURL 生成跳转到身份提供方的授权地址
+func (s *OIDCService) AuthorizationURL(ctx context.Context, providerID string) (*models.OIDCAuthorizeResponse, error) {
+	provider, err := s.getProvider(providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	discovery, err := s.discover(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	for key, pending := range s.states {
+		if now.After(pending.expiresAt) {
+			delete(s.states, key)
+		}
+	}
+	s.states[state] = &oidcLoginState{
+		providerID: providerID,
+		verifier:   verifier,
+		nonce:      nonce,
+		expiresAt:  now.Add(oidcStateTTL),
+	}
+	s.mu.Unlock()
+
+	scopes := provider.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", provider.config.ClientID)
+	params.Set("redirect_uri", provider.config.RedirectURL)
+	params.Set("scope", strings.Join(scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", utils.PKCEChallengeS256(verifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return &models.OIDCAuthorizeResponse{
+		AuthorizationURL: discovery.AuthorizationEndpoint + separator + params.Encode(),
+		State:            state,
+	}, nil
+}
+
+// Exchange 使用授权码换取并验证ID令牌，返回映射后的外部身份
+// state只能使用一次
+func (s *OIDCService) Exchange(ctx context.Context, code, state string) (*models.OIDCIdentity, error) {
+	s.mu.Lock()
+	pending, exists := s.states[state]
+	delete(s.states, state)
+	s.mu.Unlock()
+
+	if !exists || time.Now().After(pending.expiresAt) {
+		return nil, ErrOIDCStateInvalid
+	}
+
+	provider, err := s.getProvider(pending.providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	discovery, err := s.discover(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, err := s.redeemCode(ctx, provider, discovery, code, pending.verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.verifyIDToken(ctx, provider, rawIDToken, pending.nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapOIDCClaims(provider.config, claims)
+}
+
+// 获取身份提供方
+func (s *OIDCService) getProvider(providerID string) (*oidcProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	provider, exists := s.providers[providerID]
+	if !exists {
+		return nil, ErrOIDCProviderNotFound
+	}
+	return provider, nil
+}
+
+// 获取并缓存发现文档，发现文档中的issuer必须与配置一致
+func (s *OIDCService) discover(ctx context.Context, provider *oidcProvider) (*oidcDiscovery, error) {
+	s.mu.Lock()
+	if provider.discovery != nil && time.Since(provider.discoveryAt) < oidcDiscoveryTTL {
+		discovery := provider.discovery
+		s.mu.Unlock()
+		return discovery, nil
+	}
+	s.mu.Unlock()
+
+	var discovery oidcDiscovery
+	endpoint := strings.TrimSuffix(provider.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := s.getJSON(ctx, endpoint, &discovery); err != nil {
+		log.Printf("获取身份提供方发现文档失败: ID=%s, 错误=%v", provider.config.ID, err)
+		return nil, ErrOIDCUnavailable
+	}
+	if discovery.Issuer != provider.config.Issuer {
+		log.Printf("身份提供方发现文档的issuer不一致: 配置=%s, 实际=%s", provider.config.Issuer, discovery.Issuer)
+		return nil, ErrOIDCUnavailable
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		log.Printf("身份提供方发现文档不完整: ID=%s", provider.config.ID)
+		return nil, ErrOIDCUnavailable
+	}
+
+	s.mu.Lock()
+	provider.discovery = &discovery
+	provider.discoveryAt = time.Now()
+	s.mu.Unlock()
+
+	return &discovery, nil
+}
+
+// 使用授权码和PKCE验证码换取ID令牌
+func (s *OIDCService) redeemCode(ctx context.Context, provider *oidcProvider, discovery *oidcDiscovery, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.config.RedirectURL)
+	form.Set("client_id", provider.config.ClientID)
+	form.Set("code_verifier", verifier)
+	if provider.config.ClientSecret != "" {
+		form.Set("client_secret", provider.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		log.Printf("请求身份提供方令牌端点失败: %v", err)
+		return "", ErrOIDCUnavailable
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", ErrOIDCUnavailable
+	}
+
+	var tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		log.Printf("解析身份提供方令牌响应失败: 状态=%d", resp.StatusCode)
+		return "", ErrOIDCUnavailable
+	}
+	if resp.StatusCode != http.StatusOK || tokenResponse.IDToken == "" {
+		log.Printf("身份提供方拒绝授权码: 状态=%d, 错误=%s %s", resp.StatusCode, tokenResponse.Error, tokenResponse.ErrorDescription)
+		return "", fmt.Errorf("%w: 授权码无效或已过期", ErrOIDCTokenInvalid)
+	}
+
+	return tokenResponse.IDToken, nil
+}
+
+// 验证ID令牌的签名、签发方、受众、有效期和nonce
+func (s *OIDCService) verifyIDToken(ctx context.Context, provider *oidcProvider, rawIDToken, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := s.signingKey(ctx, provider, kid)
+		if err != nil {
+			return nil, err
+		}
+		if key.Alg != "" && key.Alg != token.Method.Alg() {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return key.PublicKey()
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(provider.config.Issuer),
+		jwt.WithAudience(provider.config.ClientID),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(oidcClockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCTokenInvalid, err)
+	}
+
+	// ID令牌必须带有效期
+	if exp, _ := claims.GetExpirationTime(); exp == nil {
+		return nil, fmt.Errorf("%w: 缺少exp声明", ErrOIDCTokenInvalid)
+	}
+
+	// 多个受众时azp必须是本系统
+	if aud, _ := claims.GetAudience(); len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != provider.config.ClientID {
+			return nil, fmt.Errorf("%w: azp不匹配", ErrOIDCTokenInvalid)
+		}
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce不匹配", ErrOIDCTokenInvalid)
+	}
+
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("%w: 缺少sub声明", ErrOIDCTokenInvalid)
+	}
+
+	return claims, nil
+}
+
+// 查找ID令牌的签名公钥，遇到未知kid时刷新JWKS（身份提供方轮换密钥）
+func (s *OIDCService) signingKey(ctx context.Context, provider *oidcProvider, kid string) (*utils.JWK, error) {
+	s.mu.Lock()
+	key, found := lookupJWK(provider.keys, kid)
+	refreshable := time.Since(provider.keysAt) >= oidcJWKSRefreshInterval
+	jwksURI := ""
+	if provider.discovery != nil {
+		jwksURI = provider.discovery.JWKSURI
+	}
+	s.mu.Unlock()
+
+	if found {
+		return key, nil
+	}
+	if !refreshable || jwksURI == "" {
+		return nil, fmt.Errorf("未知的签名密钥: %s", kid)
+	}
+
+	var set utils.JWKSet
+	if err := s.getJSON(ctx, jwksURI, &set); err != nil {
+		log.Printf("获取身份提供方JWKS失败: ID=%s, 错误=%v", provider.config.ID, err)
+		return nil, ErrOIDCUnavailable
+	}
+
+	keys := make(map[string]*utils.JWK, len(set.Keys))
+	for i := range set.Keys {
+		if set.Keys[i].Use == "" || set.Keys[i].Use == "sig" {
+			keys[set.Keys[i].Kid] = &set.Keys[i]
+		}
+	}
+
+	s.mu.Lock()
+	provider.keys = keys
+	provider.keysAt = time.Now()
+	s.mu.Unlock()
+
+	if key, found := lookupJWK(keys, kid); found {
+		return key, nil
+	}
+	return nil, fmt.Errorf("未知的签名密钥: %s", kid)
+}
+
+// 发送GET请求并解析JSON响应
+func (s *OIDCService) getJSON(ctx context.Context, endpoint string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP状态码 %d", resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(target)
+}
+
+// 按kid查找公钥，ID令牌没有kid且只有一个密钥时使用该密钥
+func lookupJWK(keys map[string]*utils.JWK, kid string) (*utils.JWK, bool) {
+	if key, exists := keys[kid]; exists {
+		return key, true
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// 将ID令牌声明映射为外部身份
+func mapOIDCClaims(config models.OIDCProviderConfig, claims jwt.MapClaims) (*models.OIDCIdentity, error) {
+	mapping := config.Claims
+	if mapping.Username == "" {
+		mapping.Username = "preferred_username"
+	}
+	if mapping.Name == "" {
+		mapping.Name = "name"
+	}
+
+	identity := &models.OIDCIdentity{
+		ProviderID: config.ID,
+		Issuer:     config.Issuer,
+		CanCreate:  !config.DisableProvisioning,
+	}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Username = firstClaimValue(claims, mapping.Username)
+	identity.Name = firstClaimValue(claims, mapping.Name)
+	identity.Hospital = firstClaimValue(claims, mapping.Hospital)
+	identity.Department = firstClaimValue(claims, mapping.Department)
+	identity.PatientID = firstClaimValue(claims, mapping.PatientID)
+	identity.AMR = claimValues(claims, "amr")
+
+	if identity.Name == "" {
+		identity.Name = identity.Username
+	}
+	if identity.Hospital == "" {
+		identity.Hospital = config.DefaultHospital
+	}
+
+	// 依次尝试身份提供方返回的角色值，使用第一个允许的系统角色
+	for _, value := range claimValues(claims, mapping.Role) {
+		role := value
+		if mapped, exists := config.RoleMapping[value]; exists {
+			role = mapped
+		}
+		if models.SelfRegistrableRoles[role] {
+			identity.Role = role
+			break
+		}
+	}
+	if identity.Role == "" {
+		identity.Role = config.DefaultRole
+	}
+	if !models.SelfRegistrableRoles[identity.Role] {
+		return nil, ErrOIDCRoleNotAllowed
+	}
+
+	// 医院只能是该身份提供方绑定的医院，医生和研究人员必须属于医院
+	if identity.Hospital == "" {
+		if identity.Role != models.RolePatient {
+			return nil, ErrOIDCHospitalNotAllowed
+		}
+	} else if !containsString(config.Hospitals, identity.Hospital) {
+		log.Printf("身份提供方返回的医院不在绑定范围内: 身份提供方=%s, 医院=%s", config.ID, identity.Hospital)
+		return nil, ErrOIDCHospitalNotAllowed
+	}
+
+	return identity, nil
+}
+
+// 读取声明的第一个字符串值
+func firstClaimValue(claims jwt.MapClaims, path string) string {
+	values := claimValues(claims, path)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// 按点号路径读取声明，字符串和字符串数组都返回为切片
+func claimValues(claims jwt.MapClaims, path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	var current interface{} = map[string]interface{}(claims)
+	for _, part := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = object[part]
+	}
+
+	switch value := current.(type) {
+	case string:
+		if value == "" {
+			return nil
+		}
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if str, ok := item.(string); ok && str != "" {
+				values = append(values, str)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// 校验身份提供方配置
+func validateOIDCProviderConfig(config models.OIDCProviderConfig) error {
+	if config.ID == "" || config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return fmt.Errorf("身份提供方配置缺少id、issuer、clientId或redirectUrl: %s", config.ID)
+	}
+	if config.DefaultRole != "" && !models.SelfRegistrableRoles[config.DefaultRole] {
+		return fmt.Errorf("身份提供方 %s 的默认角色无效: %s", config.ID, config.DefaultRole)
+	}
+	if len(config.Hospitals) == 0 {
+		return fmt.Errorf("身份提供方 %s 没有配置可以声明的医院(hospitals)", config.ID)
+	}
+	if config.DefaultHospital != "" && !containsString(config.Hospitals, config.DefaultHospital) {
+		return fmt.Errorf("身份提供方 %s 的默认医院不在hospitals中: %s", config.ID, config.DefaultHospital)
+	}
+	for from, to := range config.RoleMapping {
+		if !models.SelfRegistrableRoles[to] {
+			return fmt.Errorf("身份提供方 %s 的角色映射无效: %s -> %s", config.ID, from, to)
+		}
+	}
+	return nil
+}
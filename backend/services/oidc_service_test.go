@@ -0,0 +1,105 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"medcross/models"
+)
+
+func TestMapOIDCClaimsHospitalBinding(t *testing.T) {
+	config := models.OIDCProviderConfig{
+		ID:              "mock",
+		Issuer:          "http://localhost:9000",
+		Claims:          models.OIDCClaimMapping{Hospital: "hospital", Role: "roles"},
+		RoleMapping:     map[string]string{"physician": models.RoleDoctor},
+		DefaultHospital: "协和医院",
+		Hospitals:       []string{"协和医院", "协和医院西院"},
+	}
+
+	tests := []struct {
+		name         string
+		config       models.OIDCProviderConfig
+		claims       jwt.MapClaims
+		wantHospital string
+		wantErr      error
+	}{
+		{
+			name:         "绑定的医院",
+			config:       config,
+			claims:       jwt.MapClaims{"sub": "1", "hospital": "协和医院西院", "roles": []interface{}{"physician"}},
+			wantHospital: "协和医院西院",
+		},
+		{
+			name:         "未声明医院时使用默认医院",
+			config:       config,
+			claims:       jwt.MapClaims{"sub": "1", "roles": []interface{}{"physician"}},
+			wantHospital: "协和医院",
+		},
+		{
+			name:    "声明其他医院",
+			config:  config,
+			claims:  jwt.MapClaims{"sub": "1", "hospital": "华山医院", "roles": []interface{}{"physician"}},
+			wantErr: ErrOIDCHospitalNotAllowed,
+		},
+		{
+			name: "医生没有医院",
+			config: models.OIDCProviderConfig{
+				ID:        "mock",
+				Claims:    config.Claims,
+				Hospitals: config.Hospitals,
+			},
+			claims:  jwt.MapClaims{"sub": "1", "roles": []interface{}{"doctor"}},
+			wantErr: ErrOIDCHospitalNotAllowed,
+		},
+		{
+			name: "患者可以没有医院",
+			config: models.OIDCProviderConfig{
+				ID:        "mock",
+				Claims:    config.Claims,
+				Hospitals: config.Hospitals,
+			},
+			claims: jwt.MapClaims{"sub": "1", "roles": []interface{}{"patient"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := mapOIDCClaims(tt.config, tt.claims)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("mapOIDCClaims 错误 = %v, 期望 %v", err, tt.wantErr)
+			}
+			if err == nil && identity.Hospital != tt.wantHospital {
+				t.Errorf("医院 = %q, 期望 %q", identity.Hospital, tt.wantHospital)
+			}
+		})
+	}
+}
+
+func TestValidateOIDCProviderConfigRequiresHospitals(t *testing.T) {
+	base := models.OIDCProviderConfig{ID: "mock", Issuer: "http://localhost:9000", ClientID: "medcross", RedirectURL: "http://localhost:3000/oidc/callback"}
+
+	tests := []struct {
+		name      string
+		hospitals []string
+		fallback  string
+		wantErr   bool
+	}{
+		{name: "没有绑定医院", wantErr: true},
+		{name: "默认医院不在绑定范围内", hospitals: []string{"协和医院"}, fallback: "华山医院", wantErr: true},
+		{name: "有效配置", hospitals: []string{"协和医院"}, fallback: "协和医院"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := base
+			config.Hospitals = tt.hospitals
+			config.DefaultHospital = tt.fallback
+			if err := validateOIDCProviderConfig(config); (err != nil) != tt.wantErr {
+				t.Errorf("validateOIDCProviderConfig 错误 = %v, 期望出错 %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"medcross/models"
+)
+
+// 医院入驻和注册审核错误
+var (
+	ErrRegistrationNotFound  = errors.New("注册用户不存在")
+	ErrRegistrationReviewed  = errors.New("该用户已审核")
+	ErrRegistrationForbidden = errors.New("只能审核本医院的注册用户")
+	ErrApprovalRoleInvalid   = errors.New("审核时只能在医生和研究人员之间调整角色")
+	ErrUsernameTaken         = errors.New("用户名已存在")
+	ErrPatientIDBound        = errors.New("患者标识已被绑定")
+)
+
+// OnboardingService 医院入驻和注册审核服务
+// 平台管理员登记入驻医院并创建医院管理员；自助注册的用户进入待审核状态，
+// 由所属医院的管理员（没有所属医院的用户由平台管理员）核实身份后启用或拒绝
+type OnboardingService struct {
+	userService         *UserService
+	didService          *DIDService
+	sessionService      *SessionService
+	credentialService   *CredentialService
+	notificationService *NotificationService
+	tenantService       *TenantService
+}
+
+// NewOnboardingService 创建新的入驻审核服务
+func NewOnboardingService(userService *UserService, didService *DIDService, sessionService *SessionService, credentialService *CredentialService, notificationService *NotificationService, tenantService *TenantService) *OnboardingService {
+	return &OnboardingService{
+		userService:         userService,
+		didService:          didService,
+		sessionService:      sessionService,
+		credentialService:   credentialService,
+		notificationService: notificationService,
+		tenantService:       tenantService,
+	}
+}
+
+// RegisterInstitution 登记入驻医院，创建医院的did:web和签名密钥以及对应的租户
+func (s *OnboardingService) RegisterInstitution(name string) (*models.HospitalDID, error) {
+	hospital, err := s.didService.RegisterHospital(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.tenantService.CreateTenant(hospital); err != nil {
+		return nil, err
+	}
+	return hospital, nil
+}
+
+// ListInstitutions 获取已入驻的医院
+func (s *OnboardingService) ListInstitutions() []models.HospitalDID {
+	return s.didService.ListHospitals()
+}
+
+// IsInstitutionRegistered 检查医院是否已入驻
+func (s *OnboardingService) IsInstitutionRegistered(name string) bool {
+	return s.didService.IsHospitalRegistered(name)
+}
+
+// CreateHospitalAdmin 为已入驻的医院创建医院管理员
+func (s *OnboardingService) CreateHospitalAdmin(slug string, req models.HospitalAdminRequest) (*models.User, error) {
+	hospital, err := s.didService.GetHospital(slug)
+	if err != nil {
+		return nil, ErrHospitalNotRegistered
+	}
+	if s.userService.UsernameExists(req.Username) {
+		return nil, ErrUsernameTaken
+	}
+
+	userID, err := s.userService.CreateHospitalAdmin(req, hospital.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.tenantService.CreateTenant(hospital); err != nil {
+		return nil, err
+	}
+	if err := s.userService.SetTenant(userID, hospital.Slug); err != nil {
+		return nil, err
+	}
+
+	log.Printf("创建医院管理员: 医院=%s, 用户=%s", hospital.Name, userID)
+	return s.userService.GetUserByID(userID)
+}
+
+// SubmitRegistration 通知审核人有新的注册用户待审核
+// 审核人为所属医院的管理员，医院没有管理员或用户没有所属医院时通知平台管理员
+func (s *OnboardingService) SubmitRegistration(user *models.User) {
+	// 用户归入所属医院的租户
+	s.tenantService.UserTenant(user.ID)
+
+	var reviewers []*models.User
+	if user.Hospital != "" {
+		reviewers = s.userService.ListUsersByRole(models.RoleHospitalAdmin, user.Hospital)
+	}
+	if len(reviewers) == 0 {
+		reviewers = s.userService.ListUsersByRole(models.RoleAdmin, "")
+	}
+
+	message := fmt.Sprintf("用户 %s（%s）注册为%s，请核实身份后审核", user.Name, user.Username, user.Role)
+	if user.Hospital != "" {
+		message = fmt.Sprintf("%s的用户 %s（%s）注册为%s，请核实身份后审核", user.Hospital, user.Name, user.Username, user.Role)
+	}
+	for _, reviewer := range reviewers {
+		if err := s.notificationService.Notify(reviewer.ID, "新的注册申请", message); err != nil {
+			log.Printf("发送注册审核通知失败: 审核人=%s, 错误=%v", reviewer.ID, err)
+		}
+	}
+}
+
+// ListRegistrations 获取指定审核状态的用户，医院管理员只能看到本医院的用户
+func (s *OnboardingService) ListRegistrations(reviewerID, status string) []*models.User {
+	return s.userService.ListUsersByStatus(status, s.userService.ManagedHospital(reviewerID))
+}
+
+// Approve 审核通过注册用户，可以调整角色和科室
+// 患者申请绑定的患者标识需审核人明确核实后才生效，未核实的改用用户ID作为患者标识；
+// 通过后撤销用户待审核状态下的会话，重新登录后获得完整权限；医生和研究人员已有DID时同时签发执业凭证
+func (s *OnboardingService) Approve(reviewerID, userID string, req models.RegistrationApproval) (*models.User, *models.IssuedCredential, error) {
+	user, err := s.pendingUser(reviewerID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.Role != "" && req.Role != user.Role && (!models.CredentialRequiredRoles[req.Role] || !models.CredentialRequiredRoles[user.Role]) {
+		return nil, nil, ErrApprovalRoleInvalid
+	}
+	if err := s.tenantService.ValidateDepartment(s.tenantService.UserTenant(userID), req.Department); err != nil {
+		return nil, nil, err
+	}
+	if req.VerifyPatientID && user.PendingPatientID != "" && s.userService.PatientIDExists(user.PendingPatientID) {
+		return nil, nil, ErrPatientIDBound
+	}
+
+	user, err = s.userService.ReviewUser(userID, models.UserStatusActive, req.Role, req.Department, reviewerID, req.Note)
+	if err != nil {
+		return nil, nil, ErrRegistrationNotFound
+	}
+	if _, err := s.sessionService.RevokeAll(userID, "registration_approved"); err != nil {
+		log.Printf("撤销待审核会话失败: 用户=%s, 错误=%v", userID, err)
+	}
+	log.Printf("注册审核通过: 用户=%s, 审核人=%s, 角色=%s", userID, reviewerID, user.Role)
+
+	if user.PendingPatientID != "" {
+		user, err = s.userService.ResolvePatientClaim(userID, req.VerifyPatientID)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("处理患者标识绑定申请: 用户=%s, 已核实=%t, 患者标识=%s", userID, req.VerifyPatientID, user.PatientID)
+	}
+
+	var credential *models.IssuedCredential
+	if models.CredentialRequiredRoles[user.Role] && user.DID != "" {
+		credential, err = s.credentialService.Issue(models.CredentialIssueRequest{UserID: userID}, reviewerID)
+		if err != nil {
+			log.Printf("审核通过后签发执业凭证失败: 用户=%s, 错误=%v", userID, err)
+		}
+	}
+
+	if err := s.notificationService.Notify(userID, "账户审核通过", "您的账户已通过审核，请重新登录"); err != nil {
+		log.Printf("发送审核结果通知失败: 用户=%s, 错误=%v", userID, err)
+	}
+	return user, credential, nil
+}
+
+// Reject 拒绝注册用户，被拒绝的用户不能登录
+func (s *OnboardingService) Reject(reviewerID, userID, reason string) (*models.User, error) {
+	if _, err := s.pendingUser(reviewerID, userID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.ReviewUser(userID, models.UserStatusRejected, "", "", reviewerID, reason)
+	if err != nil {
+		return nil, ErrRegistrationNotFound
+	}
+	if _, err := s.sessionService.RevokeAll(userID, "registration_rejected"); err != nil {
+		log.Printf("撤销待审核会话失败: 用户=%s, 错误=%v", userID, err)
+	}
+	log.Printf("注册审核未通过: 用户=%s, 审核人=%s", userID, reviewerID)
+
+	message := "您的账户未通过审核"
+	if reason != "" {
+		message += "，原因：" + reason
+	}
+	if err := s.notificationService.Notify(userID, "账户审核未通过", message); err != nil {
+		log.Printf("发送审核结果通知失败: 用户=%s, 错误=%v", userID, err)
+	}
+	return user, nil
+}
+
+// 获取待审核的用户，并检查审核人是否可以审核该用户
+func (s *OnboardingService) pendingUser(reviewerID, userID string) (*models.User, error) {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return nil, ErrRegistrationNotFound
+	}
+	if hospital := s.userService.ManagedHospital(reviewerID); hospital != "" && hospital != user.Hospital {
+		return nil, ErrRegistrationForbidden
+	}
+	if user.Status != models.UserStatusPending {
+		return nil, ErrRegistrationReviewed
+	}
+	return user, nil
+}
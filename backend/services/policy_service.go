@@ -0,0 +1,391 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"medcross/models"
+)
+
+// 支持的策略条件运算符
+var policyOperators = map[string]bool{
+	"eq":       true,
+	"ne":       true,
+	"in":       true,
+	"not_in":   true,
+	"contains": true,
+	"before":   true,
+	"after":    true,
+	"exists":   true,
+}
+
+// 支持的策略操作
+var policyActions = map[string]bool{
+	models.PolicyActionRead:     true,
+	models.PolicyActionUpload:   true,
+	models.PolicyActionTransfer: true,
+}
+
+// PolicyService 基于属性的访问控制策略引擎
+// 策略从 POLICY_DIR 目录下的JSON文件加载，每个文件可以包含一条策略或策略数组
+type PolicyService struct {
+	mu        sync.RWMutex
+	policies  []models.Policy
+	policyDir string
+}
+
+// NewPolicyService 创建新的策略引擎并加载策略
+// 任何策略文件无效时返回错误，避免在缺少deny策略的情况下运行
+func NewPolicyService() (*PolicyService, error) {
+	policyDir := os.Getenv("POLICY_DIR")
+	if policyDir == "" {
+		policyDir = "./policies"
+	}
+
+	service := &PolicyService{
+		policyDir: policyDir,
+	}
+
+	if err := service.Reload(); err != nil {
+		return nil, err
+	}
+
+	return service, nil
+}
+
+// Reload 重新加载策略文件
+// 加载失败时保留原有策略
+func (s *PolicyService) Reload() error {
+	policies, err := loadPolicies(s.policyDir)
+	if err != nil {
+		log.Printf("加载访问策略失败: %v", err)
+		return err
+	}
+
+	s.mu.Lock()
+	s.policies = policies
+	s.mu.Unlock()
+
+	log.Printf("已加载 %d 条访问策略: 目录=%s", len(policies), s.policyDir)
+	return nil
+}
+
+// ListPolicies 获取当前生效的策略
+func (s *PolicyService) ListPolicies() []models.Policy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	policies := make([]models.Policy, len(s.policies))
+	copy(policies, s.policies)
+	return policies
+}
+
+// Evaluate 评估访问请求
+// 任一deny策略适用则拒绝；否则任一allow策略适用则允许；都不适用时返回not_applicable
+func (s *PolicyService) Evaluate(req models.PolicyRequest) models.PolicyDecision {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	decision := models.PolicyDecision{
+		Effect:          models.PolicyEffectNotApplicable,
+		MatchedPolicies: []string{},
+	}
+
+	var allowed, denied []string
+	for _, policy := range s.policies {
+		trace := evaluatePolicy(policy, req)
+		decision.Trace = append(decision.Trace, trace)
+		if !trace.Applicable {
+			continue
+		}
+
+		if policy.Effect == models.PolicyEffectDeny {
+			denied = append(denied, policy.ID)
+		} else {
+			allowed = append(allowed, policy.ID)
+		}
+	}
+
+	if len(denied) > 0 {
+		decision.Effect = models.PolicyEffectDeny
+		decision.MatchedPolicies = denied
+	} else if len(allowed) > 0 {
+		decision.Effect = models.PolicyEffectAllow
+		decision.MatchedPolicies = allowed
+	}
+
+	return decision
+}
+
+// SubjectAttributes 从用户构建主体属性
+func SubjectAttributes(user *models.User) map[string]string {
+	return map[string]string{
+		"id":         user.ID,
+		"username":   user.Username,
+		"role":       user.Role,
+		"hospital":   user.Hospital,
+		"department": user.Department,
+		"tenant":     user.TenantID,
+		"patientId":  user.PatientID,
+	}
+}
+
+// ResourceAttributes 从医疗数据构建资源属性
+// 元数据中的字符串和数字字段以 metadata.<key> 的形式提供
+func ResourceAttributes(data models.MedicalData) map[string]string {
+	attributes := map[string]string{
+		"id":        data.ID,
+		"owner":     data.Owner,
+		"dataType":  data.DataType,
+		"chain":     data.Chain,
+		"keywords":  data.Keywords,
+		"timestamp": data.Timestamp.Format(time.RFC3339),
+		"tenant":    data.TenantID,
+	}
+
+	for key, value := range parseMetadata(data.Metadata) {
+		switch v := value.(type) {
+		case string:
+			attributes["metadata."+key] = v
+		case float64:
+			attributes["metadata."+key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			attributes["metadata."+key] = strconv.FormatBool(v)
+		}
+	}
+	if attributes["tenant"] == "" {
+		attributes["tenant"] = attributes["metadata.tenantId"]
+	}
+
+	return attributes
+}
+
+// EnvironmentAttributes 构建环境属性
+func EnvironmentAttributes(at time.Time, purpose string) map[string]string {
+	return map[string]string{
+		"time":    at.Format(time.RFC3339),
+		"date":    at.Format("2006-01-02"),
+		"hour":    strconv.Itoa(at.Hour()),
+		"weekday": at.Weekday().String(),
+		"purpose": purpose,
+	}
+}
+
+// 评估单条策略
+func evaluatePolicy(policy models.Policy, req models.PolicyRequest) models.PolicyTrace {
+	trace := models.PolicyTrace{
+		PolicyID: policy.ID,
+		Effect:   policy.Effect,
+	}
+
+	if !containsString(policy.Actions, req.Action) {
+		trace.Reason = "操作不匹配"
+		return trace
+	}
+
+	trace.Applicable = true
+	for _, condition := range policy.Conditions {
+		conditionTrace := evaluateCondition(condition, req)
+		trace.Conditions = append(trace.Conditions, conditionTrace)
+		if !conditionTrace.Matched {
+			trace.Applicable = false
+		}
+	}
+
+	if !trace.Applicable {
+		trace.Reason = "条件不满足"
+	}
+
+	return trace
+}
+
+// 评估单个条件
+func evaluateCondition(condition models.PolicyCondition, req models.PolicyRequest) models.ConditionTrace {
+	actual := lookupAttribute(req, condition.Attribute)
+
+	expected := condition.Value
+	if condition.ValueRef != "" {
+		expected = lookupAttribute(req, condition.ValueRef)
+	}
+
+	return models.ConditionTrace{
+		Attribute: condition.Attribute,
+		Operator:  condition.Operator,
+		Expected:  expected,
+		Actual:    actual,
+		Matched:   compareAttribute(condition.Operator, actual, expected),
+	}
+}
+
+// 按 subject./resource./environment. 前缀查找属性值
+func lookupAttribute(req models.PolicyRequest, attribute string) string {
+	parts := strings.SplitN(attribute, ".", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+
+	switch parts[0] {
+	case "subject":
+		return req.Subject[parts[1]]
+	case "resource":
+		return req.Resource[parts[1]]
+	case "environment":
+		return req.Environment[parts[1]]
+	}
+	return ""
+}
+
+// 按运算符比较属性值
+func compareAttribute(operator, actual string, expected interface{}) bool {
+	switch operator {
+	case "eq":
+		return actual == fmt.Sprint(expected)
+	case "ne":
+		return actual != fmt.Sprint(expected)
+	case "in":
+		return containsString(stringList(expected), actual)
+	case "not_in":
+		return !containsString(stringList(expected), actual)
+	case "contains":
+		value := fmt.Sprint(expected)
+		return value != "" && strings.Contains(actual, value)
+	case "before", "after":
+		actualTime, err := parsePolicyTime(actual)
+		if err != nil {
+			return false
+		}
+		expectedTime, err := parsePolicyTime(fmt.Sprint(expected))
+		if err != nil {
+			return false
+		}
+		if operator == "before" {
+			return actualTime.Before(expectedTime)
+		}
+		return actualTime.After(expectedTime)
+	case "exists":
+		want := true
+		if b, ok := expected.(bool); ok {
+			want = b
+		}
+		return (actual != "") == want
+	}
+	return false
+}
+
+// 将条件值转换为字符串列表
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			list = append(list, fmt.Sprint(item))
+		}
+		return list
+	case string:
+		return []string{v}
+	}
+	return nil
+}
+
+// 解析RFC3339时间或YYYY-MM-DD日期
+func parsePolicyTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// 从目录加载全部策略文件
+func loadPolicies(dir string) ([]models.Policy, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var policies []models.Policy
+	seen := make(map[string]string)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取策略文件失败 %s: %w", file, err)
+		}
+
+		filePolicies, err := parsePolicyFile(content)
+		if err != nil {
+			return nil, fmt.Errorf("解析策略文件失败 %s: %w", file, err)
+		}
+
+		for _, policy := range filePolicies {
+			if err := validatePolicy(policy); err != nil {
+				return nil, fmt.Errorf("策略文件 %s 无效: %w", file, err)
+			}
+			if previous, exists := seen[policy.ID]; exists {
+				return nil, fmt.Errorf("策略ID重复: %s（%s 和 %s）", policy.ID, previous, file)
+			}
+			seen[policy.ID] = file
+			policy.Source = filepath.Base(file)
+			policies = append(policies, policy)
+		}
+	}
+
+	return policies, nil
+}
+
+// 解析策略文件，支持单条策略或策略数组
+func parsePolicyFile(content []byte) ([]models.Policy, error) {
+	trimmed := strings.TrimSpace(string(content))
+	if strings.HasPrefix(trimmed, "[") {
+		var policies []models.Policy
+		if err := json.Unmarshal(content, &policies); err != nil {
+			return nil, err
+		}
+		return policies, nil
+	}
+
+	var policy models.Policy
+	if err := json.Unmarshal(content, &policy); err != nil {
+		return nil, err
+	}
+	return []models.Policy{policy}, nil
+}
+
+// 校验策略定义
+func validatePolicy(policy models.Policy) error {
+	if policy.ID == "" {
+		return errors.New("缺少策略ID")
+	}
+	if policy.Effect != models.PolicyEffectAllow && policy.Effect != models.PolicyEffectDeny {
+		return fmt.Errorf("策略 %s 的效果无效: %s", policy.ID, policy.Effect)
+	}
+	if len(policy.Actions) == 0 {
+		return fmt.Errorf("策略 %s 缺少适用操作", policy.ID)
+	}
+	for _, action := range policy.Actions {
+		if !policyActions[action] {
+			return fmt.Errorf("策略 %s 的操作无效: %s", policy.ID, action)
+		}
+	}
+	for _, condition := range policy.Conditions {
+		if !policyOperators[condition.Operator] {
+			return fmt.Errorf("策略 %s 的运算符无效: %s", policy.ID, condition.Operator)
+		}
+		if !strings.HasPrefix(condition.Attribute, "subject.") &&
+			!strings.HasPrefix(condition.Attribute, "resource.") &&
+			!strings.HasPrefix(condition.Attribute, "environment.") {
+			return fmt.Errorf("策略 %s 的属性无效: %s", policy.ID, condition.Attribute)
+		}
+	}
+	return nil
+}
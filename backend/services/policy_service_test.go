@@ -0,0 +1,187 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"medcross/models"
+)
+
+// 测试用策略：医生可以读取和转移数据，同院数据可以读取，基因组数据禁止转移，研究项目过期后禁止读取
+const testPolicies = `[
+  {
+    "id": "doctor-read",
+    "effect": "allow",
+    "actions": ["read", "transfer"],
+    "conditions": [
+      { "attribute": "subject.role", "operator": "eq", "value": "doctor" }
+    ]
+  },
+  {
+    "id": "same-hospital-read",
+    "effect": "allow",
+    "actions": ["read"],
+    "conditions": [
+      { "attribute": "resource.metadata.hospital", "operator": "eq", "valueRef": "subject.hospital" }
+    ]
+  },
+  {
+    "id": "genomics-no-transfer",
+    "effect": "deny",
+    "actions": ["transfer"],
+    "conditions": [
+      { "attribute": "resource.dataType", "operator": "eq", "value": "基因组数据" }
+    ]
+  },
+  {
+    "id": "expired-project",
+    "effect": "deny",
+    "actions": ["read"],
+    "conditions": [
+      { "attribute": "resource.metadata.project", "operator": "exists" },
+      { "attribute": "environment.time", "operator": "after", "value": "2025-12-31T23:59:59+08:00" }
+    ]
+  }
+]`
+
+// 在临时目录中写入策略文件并创建策略引擎
+func newTestPolicyService(t *testing.T, files map[string]string) (*PolicyService, error) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("写入策略文件失败: %v", err)
+		}
+	}
+	t.Setenv("POLICY_DIR", dir)
+	return NewPolicyService()
+}
+
+func TestPolicyEvaluateDenyOverrides(t *testing.T) {
+	service, err := newTestPolicyService(t, map[string]string{"test.json": testPolicies})
+	if err != nil {
+		t.Fatalf("加载策略失败: %v", err)
+	}
+
+	doctor := map[string]string{"role": "doctor", "hospital": "协和医院"}
+	researcher := map[string]string{"role": "researcher", "hospital": "协和医院"}
+	imaging := map[string]string{"dataType": "影像数据", "metadata.hospital": "协和医院"}
+	genomics := map[string]string{"dataType": "基因组数据", "metadata.hospital": "协和医院"}
+	project := map[string]string{"dataType": "影像数据", "metadata.hospital": "协和医院", "metadata.project": "ONC-2024-001"}
+	before := map[string]string{"time": "2025-06-01T00:00:00+08:00"}
+	after := map[string]string{"time": "2026-06-01T00:00:00+08:00"}
+
+	cases := []struct {
+		name    string
+		req     models.PolicyRequest
+		effect  string
+		matched []string
+	}{
+		{"多条allow同时适用", models.PolicyRequest{Action: "read", Subject: doctor, Resource: imaging, Environment: before},
+			models.PolicyEffectAllow, []string{"doctor-read", "same-hospital-read"}},
+		{"属性引用匹配", models.PolicyRequest{Action: "read", Subject: researcher, Resource: imaging, Environment: before},
+			models.PolicyEffectAllow, []string{"same-hospital-read"}},
+		{"deny优先于allow", models.PolicyRequest{Action: "transfer", Subject: doctor, Resource: genomics, Environment: before},
+			models.PolicyEffectDeny, []string{"genomics-no-transfer"}},
+		{"deny条件未全部满足", models.PolicyRequest{Action: "read", Subject: doctor, Resource: project, Environment: before},
+			models.PolicyEffectAllow, []string{"doctor-read", "same-hospital-read"}},
+		{"全部条件满足时deny生效", models.PolicyRequest{Action: "read", Subject: doctor, Resource: project, Environment: after},
+			models.PolicyEffectDeny, []string{"expired-project"}},
+		{"操作不匹配", models.PolicyRequest{Action: "upload", Subject: doctor, Resource: imaging, Environment: before},
+			models.PolicyEffectNotApplicable, []string{}},
+		{"没有适用的策略", models.PolicyRequest{Action: "transfer", Subject: researcher, Resource: imaging, Environment: before},
+			models.PolicyEffectNotApplicable, []string{}},
+	}
+
+	for _, tc := range cases {
+		decision := service.Evaluate(tc.req)
+		if decision.Effect != tc.effect {
+			t.Errorf("%s: 效果 = %s, 期望 %s", tc.name, decision.Effect, tc.effect)
+		}
+		if !reflect.DeepEqual(decision.MatchedPolicies, tc.matched) {
+			t.Errorf("%s: 生效策略 = %v, 期望 %v", tc.name, decision.MatchedPolicies, tc.matched)
+		}
+		if len(decision.Trace) != 4 {
+			t.Errorf("%s: 评估过程应包含全部 %d 条策略, 实际 %d", tc.name, 4, len(decision.Trace))
+		}
+	}
+}
+
+func TestCompareAttribute(t *testing.T) {
+	cases := []struct {
+		operator string
+		actual   string
+		expected interface{}
+		want     bool
+	}{
+		{"eq", "doctor", "doctor", true},
+		{"eq", "doctor", "researcher", false},
+		{"eq", "3", float64(3), true},
+		{"ne", "doctor", "researcher", true},
+		{"ne", "doctor", "doctor", false},
+		{"in", "b", []interface{}{"a", "b"}, true},
+		{"in", "c", []interface{}{"a", "b"}, false},
+		{"in", "a", "a", true},
+		{"not_in", "c", []interface{}{"a", "b"}, true},
+		{"not_in", "a", []interface{}{"a", "b"}, false},
+		{"contains", "肺癌,化疗", "化疗", true},
+		{"contains", "肺癌", "化疗", false},
+		{"contains", "肺癌", "", false},
+		{"before", "2025-01-01T00:00:00Z", "2025-06-01", true},
+		{"before", "2025-07-01", "2025-06-01", false},
+		{"after", "2025-07-01", "2025-06-01T00:00:00+08:00", true},
+		{"after", "invalid", "2025-06-01", false},
+		{"exists", "x", nil, true},
+		{"exists", "", nil, false},
+		{"exists", "", false, true},
+		{"exists", "x", false, false},
+		{"unknown", "x", "x", false},
+	}
+
+	for _, tc := range cases {
+		if got := compareAttribute(tc.operator, tc.actual, tc.expected); got != tc.want {
+			t.Errorf("%s(%q, %v) = %v, 期望 %v", tc.operator, tc.actual, tc.expected, got, tc.want)
+		}
+	}
+}
+
+func TestLoadPoliciesRejectsInvalid(t *testing.T) {
+	cases := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"无效的效果", map[string]string{"a.json": `{"id": "p", "effect": "permit", "actions": ["read"]}`}},
+		{"缺少策略ID", map[string]string{"a.json": `{"effect": "allow", "actions": ["read"]}`}},
+		{"缺少操作", map[string]string{"a.json": `{"id": "p", "effect": "allow"}`}},
+		{"无效的操作", map[string]string{"a.json": `{"id": "p", "effect": "allow", "actions": ["delete"]}`}},
+		{"无效的运算符", map[string]string{"a.json": `{"id": "p", "effect": "deny", "actions": ["read"],
+			"conditions": [{"attribute": "subject.role", "operator": "like", "value": "doc"}]}`}},
+		{"无效的属性", map[string]string{"a.json": `{"id": "p", "effect": "deny", "actions": ["read"],
+			"conditions": [{"attribute": "role", "operator": "eq", "value": "doctor"}]}`}},
+		{"跨文件重复ID", map[string]string{
+			"a.json": `{"id": "p", "effect": "allow", "actions": ["read"]}`,
+			"b.json": `[{"id": "p", "effect": "deny", "actions": ["read"]}]`,
+		}},
+		{"JSON格式错误", map[string]string{"a.json": `{"id": `}},
+	}
+
+	for _, tc := range cases {
+		if _, err := newTestPolicyService(t, tc.files); err == nil {
+			t.Errorf("%s: 应拒绝加载", tc.name)
+		}
+	}
+}
+
+func TestLoadExamplePolicies(t *testing.T) {
+	t.Setenv("POLICY_DIR", filepath.Join("..", "policies", "examples"))
+	service, err := NewPolicyService()
+	if err != nil {
+		t.Fatalf("示例策略无效: %v", err)
+	}
+	if len(service.ListPolicies()) == 0 {
+		t.Error("示例策略目录应至少包含一条策略")
+	}
+}
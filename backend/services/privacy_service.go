@@ -0,0 +1,431 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// ErrPrivacyBudgetExhausted 隐私预算不足
+var ErrPrivacyBudgetExhausted = errors.New("隐私预算不足")
+
+// ErrNoiseUnavailable 无法生成随机噪声，拒绝返回未加噪声的结果
+var ErrNoiseUnavailable = errors.New("无法生成差分隐私噪声，请稍后重试")
+
+// histogram查询的最大单元格数量（各维度取值数量的乘积）
+const maxAggregateCells = 1000
+
+// 比较剩余预算时的浮点误差容忍度，避免累加误差导致恰好用完预算的查询被拒绝
+const budgetTolerance = 1e-9
+
+// 支持的聚合分组维度
+var aggregateDimensions = map[string]bool{
+	"dataType":   true,
+	"chain":      true,
+	"hospital":   true,
+	"department": true,
+	"month":      true,
+}
+
+// PrivacyService 差分隐私聚合统计服务
+// 对计数查询添加拉普拉斯噪声，隐藏小单元格，并按用户记录隐私预算消耗
+type PrivacyService struct {
+	mu             sync.Mutex
+	budgets        map[string]*models.PrivacyBudget
+	tenantService  *TenantService
+	storePath      string
+	userBudget     float64 // 每个用户的总预算
+	defaultEpsilon float64 // 单次查询默认消耗
+	maxEpsilon     float64 // 单次查询最大消耗
+	minCellSize    int     // 小于该值的单元格将被隐藏
+}
+
+// NewPrivacyService 创建新的差分隐私服务
+func NewPrivacyService(tenantService *TenantService) *PrivacyService {
+	service := &PrivacyService{
+		budgets:        make(map[string]*models.PrivacyBudget),
+		tenantService:  tenantService,
+		storePath:      utils.DataFilePath("privacy_budgets.json"),
+		userBudget:     getEnvFloat("DP_USER_BUDGET", 5.0),
+		defaultEpsilon: getEnvFloat("DP_DEFAULT_EPSILON", 0.5),
+		maxEpsilon:     getEnvFloat("DP_MAX_EPSILON", 1.0),
+		minCellSize:    10,
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("DP_MIN_CELL_SIZE")); err == nil && v > 0 {
+		service.minCellSize = v
+	}
+
+	// 加载已持久化的预算
+	if err := utils.LoadJSONFile(service.storePath, &service.budgets); err != nil {
+		log.Printf("加载隐私预算失败: %v", err)
+	}
+
+	return service
+}
+
+// GetBudget 获取用户的隐私预算
+func (s *PrivacyService) GetBudget(userID string) models.PrivacyBudget {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return *s.budgetFor(userID)
+}
+
+// Aggregate 执行差分隐私聚合查询
+// 单元格由查询声明的分组取值确定，与数据无关，每个单元格都添加噪声，避免通过单元格是否出现泄露数据是否存在。
+// 噪声生成后、返回结果前扣除预算，即使结果全部被隐藏也不退还；无法生成噪声时拒绝查询且不扣除预算
+func (s *PrivacyService) Aggregate(userID string, query models.AggregateQuery, records []models.MedicalData) (*models.AggregateResult, error) {
+	epsilon, domain, err := s.validateQuery(&query)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按声明的取值范围生成全部单元格，count查询只有一个单元格
+	keys, groups := aggregateCells(query.GroupBy, domain)
+	counts := make(map[string]int, len(keys))
+	for _, key := range keys {
+		counts[key] = 0
+	}
+
+	// 统计真实计数，不在声明范围内的记录不计入
+	for _, record := range records {
+		metadata := parseMetadata(record.Metadata)
+		hospital := s.tenantService.RecordHospital(record)
+		if !matchesAggregateFilter(record, hospital, query) {
+			continue
+		}
+
+		keyParts := make([]string, 0, len(query.GroupBy))
+		for _, dimension := range query.GroupBy {
+			keyParts = append(keyParts, dimensionValue(record, metadata, hospital, dimension))
+		}
+
+		key := strings.Join(keyParts, "\x00")
+		if _, declared := counts[key]; declared {
+			counts[key]++
+		}
+	}
+
+	// 先为所有单元格生成噪声，任何一个失败都不返回结果
+	noisyCounts := make(map[string]int, len(keys))
+	for _, key := range keys {
+		noise, err := laplaceNoise(1 / epsilon)
+		if err != nil {
+			log.Printf("生成差分隐私噪声失败: 用户=%s, 错误=%v", userID, err)
+			return nil, ErrNoiseUnavailable
+		}
+		noisyCounts[key] = int(math.Round(float64(counts[key]) + noise))
+	}
+
+	budget, err := s.spend(userID, epsilon)
+	if err != nil {
+		return nil, err
+	}
+
+	// 每条记录只落入一个单元格，敏感度为1，整个直方图只消耗一次预算
+	result := &models.AggregateResult{
+		Metric:          query.Metric,
+		GroupBy:         query.GroupBy,
+		Cells:           []models.AggregateCell{},
+		MinCellSize:     s.minCellSize,
+		Epsilon:         epsilon,
+		RemainingBudget: budget.Remaining(),
+	}
+
+	// 隐藏和计数都只依据加噪后的结果，属于后处理，不额外消耗预算
+	for _, key := range keys {
+		noisy := noisyCounts[key]
+		if noisy < s.minCellSize {
+			result.SuppressedCells++
+			continue
+		}
+
+		cell := models.AggregateCell{Count: noisy}
+		if len(query.GroupBy) > 0 {
+			cell.Group = groups[key]
+		}
+		result.Cells = append(result.Cells, cell)
+	}
+
+	log.Printf("差分隐私查询: 用户=%s, 类型=%s, epsilon=%.2f, 剩余预算=%.2f", userID, query.Metric, epsilon, budget.Remaining())
+	return result, nil
+}
+
+// 校验查询参数，返回本次消耗的epsilon和各分组维度的取值范围
+func (s *PrivacyService) validateQuery(query *models.AggregateQuery) (float64, [][]string, error) {
+	switch query.Metric {
+	case "count":
+		if len(query.GroupBy) > 0 {
+			return 0, nil, errors.New("count查询不支持分组，请使用histogram")
+		}
+	case "histogram":
+		if len(query.GroupBy) == 0 {
+			return 0, nil, errors.New("histogram查询至少需要一个分组维度")
+		}
+	default:
+		return 0, nil, fmt.Errorf("不支持的查询类型: %s", query.Metric)
+	}
+
+	seen := make(map[string]bool)
+	for _, dimension := range query.GroupBy {
+		if !aggregateDimensions[dimension] {
+			return 0, nil, fmt.Errorf("不支持的分组维度: %s", dimension)
+		}
+		if seen[dimension] {
+			return 0, nil, fmt.Errorf("重复的分组维度: %s", dimension)
+		}
+		seen[dimension] = true
+	}
+	for dimension := range query.GroupValues {
+		if !seen[dimension] {
+			return 0, nil, fmt.Errorf("groupValues中的维度不在groupBy中: %s", dimension)
+		}
+	}
+
+	for _, date := range []string{query.StartDate, query.EndDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return 0, nil, fmt.Errorf("无效的日期格式: %s", date)
+		}
+	}
+
+	domain := make([][]string, 0, len(query.GroupBy))
+	cells := 1
+	for _, dimension := range query.GroupBy {
+		values := uniqueStrings(query.GroupValues[dimension])
+		if len(values) == 0 && dimension == "month" && query.StartDate != "" && query.EndDate != "" {
+			values = monthRange(query.StartDate, query.EndDate)
+		}
+		if len(values) == 0 {
+			return 0, nil, fmt.Errorf("histogram查询必须在groupValues中声明分组维度的取值: %s", dimension)
+		}
+
+		cells *= len(values)
+		if cells > maxAggregateCells {
+			return 0, nil, fmt.Errorf("单元格数量不能超过%d", maxAggregateCells)
+		}
+		domain = append(domain, values)
+	}
+
+	epsilon := query.Epsilon
+	if epsilon == 0 {
+		epsilon = s.defaultEpsilon
+	}
+	if epsilon < 0 || epsilon > s.maxEpsilon {
+		return 0, nil, fmt.Errorf("epsilon必须在0到%.2f之间", s.maxEpsilon)
+	}
+
+	return epsilon, domain, nil
+}
+
+// 按各维度的取值范围生成全部单元格的键和分组取值，顺序与声明顺序一致
+func aggregateCells(groupBy []string, domain [][]string) ([]string, map[string]map[string]string) {
+	keys := []string{""}
+	groups := map[string]map[string]string{"": {}}
+	for i, dimension := range groupBy {
+		nextKeys := make([]string, 0, len(keys)*len(domain[i]))
+		nextGroups := make(map[string]map[string]string, len(keys)*len(domain[i]))
+		for _, key := range keys {
+			for _, value := range domain[i] {
+				nextKey := value
+				if i > 0 {
+					nextKey = key + "\x00" + value
+				}
+				group := make(map[string]string, i+1)
+				for k, v := range groups[key] {
+					group[k] = v
+				}
+				group[dimension] = value
+				nextKeys = append(nextKeys, nextKey)
+				nextGroups[nextKey] = group
+			}
+		}
+		keys, groups = nextKeys, nextGroups
+	}
+	return keys, groups
+}
+
+// 生成开始日期到结束日期之间的月份（YYYY-MM），调用方需先校验日期格式
+func monthRange(startDate, endDate string) []string {
+	start, _ := time.Parse("2006-01-02", startDate)
+	end, _ := time.Parse("2006-01-02", endDate)
+
+	var months []string
+	for month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(end); month = month.AddDate(0, 1, 0) {
+		months = append(months, month.Format("2006-01"))
+		if len(months) > maxAggregateCells {
+			break
+		}
+	}
+	return months
+}
+
+// 去除重复和空白的取值，保持原有顺序
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" && !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// 扣除隐私预算并持久化
+func (s *PrivacyService) spend(userID string, epsilon float64) (models.PrivacyBudget, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	budget := s.budgetFor(userID)
+	if budget.Remaining()+budgetTolerance < epsilon {
+		return *budget, ErrPrivacyBudgetExhausted
+	}
+
+	budget.Spent += epsilon
+	budget.QueryCount++
+	budget.UpdatedAt = time.Now()
+	s.budgets[userID] = budget
+
+	// 持久化失败时回滚，确保预算不会被绕过
+	if err := utils.SaveJSONFile(s.storePath, s.budgets); err != nil {
+		budget.Spent -= epsilon
+		budget.QueryCount--
+		log.Printf("保存隐私预算失败: %v", err)
+		return *budget, fmt.Errorf("保存隐私预算失败: %w", err)
+	}
+
+	return *budget, nil
+}
+
+// 获取用户预算，不存在时按默认值初始化（调用方需持有锁）
+func (s *PrivacyService) budgetFor(userID string) *models.PrivacyBudget {
+	budget, exists := s.budgets[userID]
+	if !exists {
+		budget = &models.PrivacyBudget{
+			UserID:    userID,
+			Total:     s.userBudget,
+			UpdatedAt: time.Now(),
+		}
+		s.budgets[userID] = budget
+	}
+	return budget
+}
+
+// 检查记录是否满足聚合查询的筛选条件，hospital为记录所属的医院
+func matchesAggregateFilter(record models.MedicalData, hospital string, query models.AggregateQuery) bool {
+	if query.DataType != "" && record.DataType != query.DataType {
+		return false
+	}
+	if query.Chain != "" && record.Chain != query.Chain {
+		return false
+	}
+	if query.Hospital != "" && hospital != query.Hospital {
+		return false
+	}
+
+	day := record.Timestamp.Format("2006-01-02")
+	if query.StartDate != "" && day < query.StartDate {
+		return false
+	}
+	if query.EndDate != "" && day > query.EndDate {
+		return false
+	}
+
+	return true
+}
+
+// 获取记录在指定分组维度上的取值，医院按记录所属的租户确定，科室取上传者的科室
+func dimensionValue(record models.MedicalData, metadata map[string]interface{}, hospital, dimension string) string {
+	var value string
+	switch dimension {
+	case "dataType":
+		value = record.DataType
+	case "chain":
+		value = record.Chain
+	case "hospital":
+		value = hospital
+	case "department":
+		value = metadataString(metadata, "department")
+	case "month":
+		value = record.Timestamp.Format("2006-01")
+	}
+
+	if value == "" {
+		return "未知"
+	}
+	return value
+}
+
+// 解析元数据JSON，失败时返回空map
+func parseMetadata(metadataJSON string) map[string]interface{} {
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil || metadata == nil {
+		return map[string]interface{}{}
+	}
+	return metadata
+}
+
+// 读取元数据中的字符串字段
+func metadataString(metadata map[string]interface{}, key string) string {
+	if value, ok := metadata[key].(string); ok {
+		return value
+	}
+	return ""
+}
+
+// 生成服从拉普拉斯分布 Lap(0, scale) 的噪声
+func laplaceNoise(scale float64) (float64, error) {
+	uniform, err := secureUniform()
+	if err != nil {
+		return 0, err
+	}
+
+	// u 服从 (-0.5, 0.5) 上的均匀分布
+	u := uniform - 0.5
+	if u == -0.5 {
+		u = 0
+	}
+
+	sign := 1.0
+	if u < 0 {
+		sign = -1.0
+	}
+
+	return -scale * sign * math.Log(1-2*math.Abs(u)), nil
+}
+
+// 使用加密安全的随机数生成 [0, 1) 上的均匀分布
+// 随机源不可用时返回错误，不能退化为固定值，否则噪声为零会泄露真实计数
+func secureUniform() (float64, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, fmt.Errorf("生成随机数失败: %w", err)
+	}
+	return float64(binary.BigEndian.Uint64(buf[:])>>11) / float64(1<<53), nil
+}
+
+// 获取浮点型环境变量，如果不存在或无效则返回默认值
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil && v > 0 {
+		return v
+	}
+	return defaultValue
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"medcross/models"
+)
+
+func TestPrivacyServiceBudget(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "Adm1n-Passw0rd")
+	t.Setenv("DP_USER_BUDGET", "1.0")
+	t.Setenv("DP_DEFAULT_EPSILON", "0.4")
+	t.Setenv("DP_MAX_EPSILON", "0.5")
+
+	tenantService := NewTenantService(NewUserService())
+	service := NewPrivacyService(tenantService)
+
+	// 按顺序执行，后面的查询依赖前面的预算消耗
+	steps := []struct {
+		name          string
+		userID        string
+		epsilon       float64
+		wantErr       bool
+		wantExhausted bool
+		wantSpent     float64
+	}{
+		{name: "默认epsilon", userID: "u-1", wantSpent: 0.4},
+		{name: "超过单次上限被拒绝且不扣预算", userID: "u-1", epsilon: 0.8, wantErr: true, wantSpent: 0.4},
+		{name: "负数epsilon被拒绝", userID: "u-1", epsilon: -0.1, wantErr: true, wantSpent: 0.4},
+		{name: "指定epsilon", userID: "u-1", epsilon: 0.5, wantSpent: 0.9},
+		{name: "剩余预算不足", userID: "u-1", epsilon: 0.2, wantErr: true, wantExhausted: true, wantSpent: 0.9},
+		{name: "恰好用完剩余预算", userID: "u-1", epsilon: 0.1, wantSpent: 1.0},
+		{name: "预算耗尽后继续拒绝", userID: "u-1", epsilon: 0.1, wantErr: true, wantExhausted: true, wantSpent: 1.0},
+		{name: "其他用户的预算独立", userID: "u-2", epsilon: 0.5, wantSpent: 0.5},
+	}
+
+	for _, step := range steps {
+		query := models.AggregateQuery{Metric: "count", Epsilon: step.epsilon}
+		result, err := service.Aggregate(step.userID, query, nil)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: Aggregate 错误 = %v, 期望出错 %v", step.name, err, step.wantErr)
+		}
+		if errors.Is(err, ErrPrivacyBudgetExhausted) != step.wantExhausted {
+			t.Fatalf("%s: 错误 = %v, 期望预算不足 %v", step.name, err, step.wantExhausted)
+		}
+		if err == nil && result.Cells == nil {
+			t.Fatalf("%s: 查询成功时应返回结果", step.name)
+		}
+
+		budget := service.GetBudget(step.userID)
+		if !approxEqual(budget.Spent, step.wantSpent) {
+			t.Errorf("%s: 已消耗预算 = %.2f, 期望 %.2f", step.name, budget.Spent, step.wantSpent)
+		}
+	}
+
+	// 预算持久化，重启后不能重置
+	reloaded := NewPrivacyService(tenantService)
+	if budget := reloaded.GetBudget("u-1"); budget.Remaining() > 1e-9 || budget.QueryCount != 3 {
+		t.Errorf("重新加载后预算 = %+v, 期望已耗尽且查询3次", budget)
+	}
+	if _, err := reloaded.Aggregate("u-1", models.AggregateQuery{Metric: "count", Epsilon: 0.1}, nil); !errors.Is(err, ErrPrivacyBudgetExhausted) {
+		t.Errorf("重新加载后查询错误 = %v, 期望预算不足", err)
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	diff := a - b
+	return diff < 1e-9 && diff > -1e-9
+}
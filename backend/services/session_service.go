@@ -0,0 +1,304 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// 会话相关错误
+var (
+	ErrSessionInvalid     = errors.New("刷新令牌无效或已过期")
+	ErrRefreshTokenReused = errors.New("刷新令牌已被使用，会话已撤销")
+	ErrSessionNotFound    = errors.New("会话不存在")
+)
+
+// 每个会话保留的已轮换刷新令牌哈希数量上限
+const maxUsedTokenHashes = 50
+
+// SessionService 登录会话服务
+// 刷新令牌格式为 "<会话ID>.<随机串>"，服务端只保存随机串的SHA-256哈希。
+// 已轮换的旧令牌再次出现时视为令牌被盗用，撤销整个会话
+type SessionService struct {
+	mu         sync.RWMutex
+	sessions   map[string]*models.Session
+	refreshTTL time.Duration
+	storePath  string
+}
+
+// NewSessionService 创建新的会话服务
+func NewSessionService() *SessionService {
+	refreshTTL := 30 * 24 * time.Hour
+	if v := os.Getenv("REFRESH_TOKEN_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			refreshTTL = d
+		}
+	}
+
+	service := &SessionService{
+		sessions:   make(map[string]*models.Session),
+		refreshTTL: refreshTTL,
+		storePath:  utils.DataFilePath("sessions.json"),
+	}
+
+	// 加载已持久化的会话
+	if err := utils.LoadJSONFile(service.storePath, &service.sessions); err != nil {
+		log.Printf("加载登录会话失败: %v", err)
+	}
+	if service.sessions == nil {
+		service.sessions = make(map[string]*models.Session)
+	}
+
+	return service
+}
+
+// CreateSession 创建登录会话，返回会话和刷新令牌
+// template提供用户ID、权限范围、认证方式和客户端信息，其余字段由服务生成
+func (s *SessionService) CreateSession(template models.Session) (*models.Session, string, error) {
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:               uuid.New().String(),
+		UserID:           template.UserID,
+		RefreshTokenHash: hashTokenSecret(secret),
+		Scope:            template.Scope,
+		AuthMethod:       template.AuthMethod,
+		MFA:              template.MFA,
+		UserAgent:        template.UserAgent,
+		ClientIP:         template.ClientIP,
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(s.refreshTTL),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.ID] = session
+	if err := s.saveLocked(); err != nil {
+		delete(s.sessions, session.ID)
+		return nil, "", err
+	}
+
+	return session, session.ID + "." + secret, nil
+}
+
+// Refresh 使用刷新令牌换取新的刷新令牌
+// 旧令牌立即失效；旧令牌被再次使用时撤销整个会话
+func (s *SessionService) Refresh(refreshToken, clientIP string) (*models.Session, string, error) {
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" || secret == "" {
+		return nil, "", ErrSessionInvalid
+	}
+
+	newSecret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		return nil, "", ErrSessionInvalid
+	}
+
+	now := time.Now()
+	presented := hashTokenSecret(secret)
+
+	// 已轮换的令牌再次出现，说明令牌可能被盗用
+	for _, used := range session.UsedTokenHashes {
+		if subtle.ConstantTimeCompare([]byte(used), []byte(presented)) == 1 {
+			if session.RevokedAt == nil {
+				s.revokeLocked(session, now, "refresh_token_reuse")
+				if err := s.saveLocked(); err != nil {
+					log.Printf("保存登录会话失败: %v", err)
+				}
+				log.Printf("警告: 检测到刷新令牌重放，已撤销会话: 会话=%s, 用户=%s, IP=%s", session.ID, session.UserID, clientIP)
+			}
+			return nil, "", ErrRefreshTokenReused
+		}
+	}
+
+	if !session.Active(now) || subtle.ConstantTimeCompare([]byte(session.RefreshTokenHash), []byte(presented)) != 1 {
+		return nil, "", ErrSessionInvalid
+	}
+
+	previous := *session
+	session.UsedTokenHashes = append(session.UsedTokenHashes, session.RefreshTokenHash)
+	if len(session.UsedTokenHashes) > maxUsedTokenHashes {
+		session.UsedTokenHashes = session.UsedTokenHashes[len(session.UsedTokenHashes)-maxUsedTokenHashes:]
+	}
+	session.RefreshTokenHash = hashTokenSecret(newSecret)
+	session.LastUsedAt = now
+	session.ClientIP = clientIP
+	session.ExpiresAt = now.Add(s.refreshTTL)
+
+	if err := s.saveLocked(); err != nil {
+		*session = previous
+		return nil, "", err
+	}
+
+	return session, session.ID + "." + newSecret, nil
+}
+
+// IsActive 检查会话是否有效
+func (s *SessionService) IsActive(sessionID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, exists := s.sessions[sessionID]
+	return exists && session.Active(time.Now())
+}
+
+// MarkMFAVerified 启用双因素认证后将受限会话升级为完整权限
+func (s *SessionService) MarkMFAVerified(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[sessionID]
+	if !exists || !session.Active(time.Now()) {
+		return ErrSessionNotFound
+	}
+
+	previous := *session
+	session.Scope = ""
+	session.MFA = true
+	if err := s.saveLocked(); err != nil {
+		*session = previous
+		return err
+	}
+	return nil
+}
+
+// Revoke 撤销用户的指定会话
+func (s *SessionService) Revoke(userID, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[sessionID]
+	if !exists || session.UserID != userID || session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+
+	s.revokeLocked(session, time.Now(), "logout")
+	return s.saveLocked()
+}
+
+// RevokeAll 撤销用户的全部会话，返回撤销的数量
+func (s *SessionService) RevokeAll(userID, reason string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	count := 0
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			s.revokeLocked(session, now, reason)
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+	return count, s.saveLocked()
+}
+
+// RevokeOthers 撤销用户除当前会话外的全部会话，返回撤销的数量
+func (s *SessionService) RevokeOthers(userID, currentSessionID, reason string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	count := 0
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.ID != currentSessionID && session.RevokedAt == nil {
+			s.revokeLocked(session, now, reason)
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+	return count, s.saveLocked()
+}
+
+// ListActive 获取用户的有效会话，按最近使用时间倒序
+func (s *SessionService) ListActive(userID, currentSessionID string) []models.SessionResponse {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	sessions := make([]models.SessionResponse, 0)
+	for _, session := range s.sessions {
+		if session.UserID != userID || !session.Active(now) {
+			continue
+		}
+		sessions = append(sessions, models.SessionResponse{
+			ID:         session.ID,
+			AuthMethod: session.AuthMethod,
+			MFA:        session.MFA,
+			UserAgent:  session.UserAgent,
+			ClientIP:   session.ClientIP,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == currentSessionID,
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions
+}
+
+// 撤销会话，调用方需持有锁
+func (s *SessionService) revokeLocked(session *models.Session, at time.Time, reason string) {
+	revokedAt := at
+	session.RevokedAt = &revokedAt
+	session.RevokeReason = reason
+}
+
+// 持久化会话，调用方需持有锁
+// 已过期超过一个刷新周期的会话不再保存
+func (s *SessionService) saveLocked() error {
+	cutoff := time.Now().Add(-s.refreshTTL)
+	for id, session := range s.sessions {
+		if session.ExpiresAt.Before(cutoff) {
+			delete(s.sessions, id)
+		}
+	}
+
+	if err := utils.SaveJSONFile(s.storePath, s.sessions); err != nil {
+		log.Printf("保存登录会话失败: %v", err)
+		return err
+	}
+	return nil
+}
+
+// 计算刷新令牌、API密钥等随机串的哈希
+func hashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"medcross/models"
+)
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	tests := []struct {
+		name   string
+		replay int // 重放第几个已轮换的令牌
+	}{
+		{name: "重放最早的令牌", replay: 0},
+		{name: "重放上一个令牌", replay: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DATA_DIR", t.TempDir())
+			service := NewSessionService()
+
+			session, token, err := service.CreateSession(models.Session{UserID: "u-1"})
+			if err != nil {
+				t.Fatalf("创建会话失败: %v", err)
+			}
+			other, otherToken, err := service.CreateSession(models.Session{UserID: "u-1"})
+			if err != nil {
+				t.Fatalf("创建会话失败: %v", err)
+			}
+
+			// 连续轮换两次，tokens中保存每一代令牌
+			tokens := []string{token}
+			for i := 0; i < 2; i++ {
+				_, next, err := service.Refresh(tokens[len(tokens)-1], "127.0.0.1")
+				if err != nil {
+					t.Fatalf("第%d次刷新失败: %v", i+1, err)
+				}
+				tokens = append(tokens, next)
+			}
+			latest := tokens[len(tokens)-1]
+
+			if _, _, err := service.Refresh(tokens[tt.replay], "10.0.0.1"); !errors.Is(err, ErrRefreshTokenReused) {
+				t.Fatalf("重放令牌错误 = %v, 期望 %v", err, ErrRefreshTokenReused)
+			}
+			if service.IsActive(session.ID) {
+				t.Error("重放令牌后会话应被撤销")
+			}
+			if _, _, err := service.Refresh(latest, "127.0.0.1"); !errors.Is(err, ErrSessionInvalid) {
+				t.Errorf("会话撤销后最新令牌刷新错误 = %v, 期望 %v", err, ErrSessionInvalid)
+			}
+			if _, _, err := service.Refresh(tokens[tt.replay], "10.0.0.1"); !errors.Is(err, ErrRefreshTokenReused) {
+				t.Errorf("再次重放令牌错误 = %v, 期望 %v", err, ErrRefreshTokenReused)
+			}
+
+			// 撤销状态持久化，且不影响同一用户的其他会话
+			reloaded := NewSessionService()
+			if reloaded.IsActive(session.ID) {
+				t.Error("重新加载后被撤销的会话不应有效")
+			}
+			if !reloaded.IsActive(other.ID) {
+				t.Error("同一用户的其他会话不应被撤销")
+			}
+			if _, _, err := reloaded.Refresh(otherToken, "127.0.0.1"); err != nil {
+				t.Errorf("其他会话刷新失败: %v", err)
+			}
+		})
+	}
+}
+
+func TestRefreshRejectsInvalidTokens(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+	service := NewSessionService()
+
+	session, token, err := service.CreateSession(models.Session{UserID: "u-1"})
+	if err != nil {
+		t.Fatalf("创建会话失败: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "空令牌", token: ""},
+		{name: "缺少分隔符", token: session.ID},
+		{name: "未知会话", token: "unknown." + token[len(session.ID)+1:]},
+		{name: "错误的随机串", token: session.ID + ".wrong"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := service.Refresh(tt.token, "127.0.0.1"); !errors.Is(err, ErrSessionInvalid) {
+				t.Errorf("Refresh 错误 = %v, 期望 %v", err, ErrSessionInvalid)
+			}
+		})
+	}
+
+	if !service.IsActive(session.ID) {
+		t.Error("无效令牌不应撤销会话")
+	}
+}
@@ -0,0 +1,502 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// 交易签名错误
+var (
+	ErrWalletNotOpen        = errors.New("密钥库未解锁，请先调用 /api/wallet/unlock 解锁")
+	ErrPendingTxNotFound    = errors.New("待签名交易不存在或已过期")
+	ErrTxSignatureInvalid   = errors.New("交易签名无效")
+	ErrUnsupportedTxChain   = errors.New("不支持的目标区块链，必须是 'ethereum' 或 'fabric'")
+	ErrSigningModeInvalid   = errors.New("不支持的签名方式")
+	ErrNoBlockchainAccount  = errors.New("用户没有区块链身份，只能由网关代为上链")
+	ErrGatewaySigningDenied = errors.New("已有区块链身份的用户必须使用自己的私钥签名交易")
+)
+
+// 合约中的上传函数
+const (
+	ethereumUploadFunction = "uploadDataWithOwnerDid(string,string,string,string,string)"
+	fabricUploadFunction   = "UploadData"
+)
+
+// Fabric上传时通过瞬态数据传入敏感元数据和随机盐的键，与链码一致
+// 链码在世界状态中保存加盐后的哈希，避免患者标识等取值空间小的字段被穷举
+const (
+	fabricPrivateMetadataKey     = "privateMetadata"
+	fabricPrivateMetadataSaltKey = "privateMetadataSalt"
+	fabricPrivateSaltSize        = 32
+)
+
+// fabricPrivateMetadataFields 可识别患者身份的元数据字段，上传到Fabric时写入私有数据集合而不是世界状态
+var fabricPrivateMetadataFields = []string{"patientId", "patientAddress", "patientName", "hospital", "department"}
+
+// 待签名交易的有效期
+const pendingTxTTL = 10 * time.Minute
+
+// 构造好的上传交易
+type uploadTx struct {
+	chain       string
+	signer      string
+	ethereum    *utils.EthereumTransaction
+	payload     []byte // Fabric提案的JSON编码
+	signingHash []byte
+}
+
+// 等待客户端签名的上传
+type pendingUpload struct {
+	userID    string
+	data      models.MedicalData
+	tx        *uploadTx
+	expiresAt time.Time
+}
+
+// SigningService 用户级交易签名服务
+// 由后端构造上传交易，使用用户托管密钥库中的私钥签名，或返回待签名交易由客户端签名，再交给网关转发上链，
+// 使链上记录的所有者（以太坊msg.sender、Fabric提案创建者）就是上传数据的用户
+type SigningService struct {
+	mu              sync.Mutex
+	identityService *IdentityService
+	gatewayService  *GatewayService
+	pending         map[string]*pendingUpload
+	userLocks       map[string]*sync.Mutex // 同一用户的交易串行构造，避免以太坊nonce冲突
+}
+
+// NewSigningService 创建新的交易签名服务
+func NewSigningService(identityService *IdentityService, gatewayService *GatewayService) *SigningService {
+	return &SigningService{
+		identityService: identityService,
+		gatewayService:  gatewayService,
+		pending:         make(map[string]*pendingUpload),
+		userLocks:       make(map[string]*sync.Mutex),
+	}
+}
+
+// ResolveSigningMode 确定上传交易的签名方式
+// 有区块链身份的用户默认使用托管密钥签名，不能改由网关代签；没有区块链身份的用户只能由网关代签
+func (s *SigningService) ResolveSigningMode(userID, requested string) (string, error) {
+	_, err := s.identityService.GetIdentity(userID)
+	hasIdentity := err == nil
+
+	switch requested {
+	case "":
+		if hasIdentity {
+			return models.SigningModeCustodial, nil
+		}
+		return models.SigningModeGateway, nil
+	case models.SigningModeCustodial, models.SigningModeClient:
+		if !hasIdentity {
+			return "", ErrNoBlockchainAccount
+		}
+		return requested, nil
+	case models.SigningModeGateway:
+		if hasIdentity {
+			return "", ErrGatewaySigningDenied
+		}
+		return requested, nil
+	default:
+		return "", ErrSigningModeInvalid
+	}
+}
+
+// SubmitUpload 使用已解锁的托管密钥签名上传交易并转发上链
+// data.Owner 设置为签名者的DID
+func (s *SigningService) SubmitUpload(userID string, data *models.MedicalData) (*models.RelayResponse, error) {
+	unlocked, _, ok := s.identityService.OpenedWallet(userID)
+	if !ok {
+		if _, err := s.identityService.GetIdentity(userID); err != nil {
+			return nil, ErrNoBlockchainAccount
+		}
+		return nil, ErrWalletNotOpen
+	}
+
+	lock := s.userLock(userID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	tx, err := s.buildUpload(unlocked.Identity, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var signature []byte
+	switch tx.chain {
+	case "ethereum":
+		signature, err = unlocked.EthereumKey.SignEthereumHash(tx.signingHash)
+	case "fabric":
+		signature, err = signFabricDigest(unlocked.FabricKey, tx.signingHash)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("签名交易失败: %w", err)
+	}
+
+	return s.relay(tx, signature)
+}
+
+// PrepareUpload 构造待签名的上传交易，客户端签名后调用CompleteUpload提交
+func (s *SigningService) PrepareUpload(userID string, data models.MedicalData) (*models.UnsignedTransaction, error) {
+	identity, err := s.identityService.GetIdentity(userID)
+	if err != nil {
+		return nil, ErrNoBlockchainAccount
+	}
+
+	tx, err := s.buildUpload(*identity, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := &pendingUpload{
+		userID:    userID,
+		data:      data,
+		tx:        tx,
+		expiresAt: time.Now().Add(pendingTxTTL),
+	}
+	id := uuid.New().String()
+
+	s.mu.Lock()
+	s.cleanupLocked(time.Now())
+	s.pending[id] = pending
+	s.mu.Unlock()
+
+	response := &models.UnsignedTransaction{
+		ID:          id,
+		Chain:       tx.chain,
+		Signer:      tx.signer,
+		SigningHash: "0x" + hex.EncodeToString(tx.signingHash),
+		ExpiresAt:   pending.expiresAt,
+	}
+	if tx.ethereum != nil {
+		response.Ethereum = &models.EthereumUnsignedTx{
+			ChainID:  tx.ethereum.ChainID.Int64(),
+			Nonce:    tx.ethereum.Nonce,
+			GasPrice: tx.ethereum.GasPrice.String(),
+			GasLimit: tx.ethereum.GasLimit,
+			To:       utils.ChecksumAddress(tx.ethereum.To),
+			Value:    "0",
+			Data:     "0x" + hex.EncodeToString(tx.ethereum.Data),
+		}
+	} else {
+		response.Payload = base64.StdEncoding.EncodeToString(tx.payload)
+	}
+
+	return response, nil
+}
+
+// CompleteUpload 校验客户端签名并转发上链，返回上传的数据和转发结果
+func (s *SigningService) CompleteUpload(userID, pendingID, signature string) (*models.MedicalData, *models.RelayResponse, error) {
+	s.mu.Lock()
+	pending, exists := s.pending[pendingID]
+	if !exists || pending.userID != userID || time.Now().After(pending.expiresAt) {
+		s.mu.Unlock()
+		return nil, nil, ErrPendingTxNotFound
+	}
+	s.mu.Unlock()
+
+	var sig []byte
+	var err error
+	switch pending.tx.chain {
+	case "ethereum":
+		sig, err = hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+		if err != nil {
+			return nil, nil, ErrTxSignatureInvalid
+		}
+		signer, err := utils.RecoverEthereumAddress(pending.tx.signingHash, sig)
+		if err != nil || signer != pending.tx.signer {
+			return nil, nil, ErrTxSignatureInvalid
+		}
+	case "fabric":
+		sig, err = base64.StdEncoding.DecodeString(signature)
+		if err != nil {
+			return nil, nil, ErrTxSignatureInvalid
+		}
+		identity, err := s.identityService.GetIdentity(userID)
+		if err != nil {
+			return nil, nil, ErrNoBlockchainAccount
+		}
+		if !verifyFabricSignature(identity.FabricCertificate, pending.tx.signingHash, sig) {
+			return nil, nil, ErrTxSignatureInvalid
+		}
+	}
+
+	// 签名通过后才移除，签名错误时客户端可以重试
+	s.mu.Lock()
+	if _, exists := s.pending[pendingID]; !exists {
+		s.mu.Unlock()
+		return nil, nil, ErrPendingTxNotFound
+	}
+	delete(s.pending, pendingID)
+	s.mu.Unlock()
+
+	result, err := s.relay(pending.tx, sig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data := pending.data
+	return &data, result, nil
+}
+
+// 构造上传交易，并将数据所有者设置为签名者的DID，两条链上记录相同的所有者
+func (s *SigningService) buildUpload(identity models.BlockchainIdentity, data *models.MedicalData) (*uploadTx, error) {
+	if identity.DID == "" {
+		return nil, ErrDIDUnavailable
+	}
+	data.Owner = identity.DID
+
+	switch data.Chain {
+	case "ethereum":
+		return s.buildEthereumUpload(identity, *data)
+	case "fabric":
+		return s.buildFabricUpload(identity, *data)
+	default:
+		return nil, ErrUnsupportedTxChain
+	}
+}
+
+// 构造调用MedicalData合约uploadDataWithOwnerDid的以太坊交易
+// 合约记录msg.sender和所有者DID，并要求同一账户始终使用同一个DID
+func (s *SigningService) buildEthereumUpload(identity models.BlockchainIdentity, data models.MedicalData) (*uploadTx, error) {
+	params, err := s.gatewayService.GetEthereumTxParams(identity.EthereumAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if !utils.IsEthereumAddress(params.Contract) {
+		return nil, fmt.Errorf("网关返回的合约地址无效: %s", params.Contract)
+	}
+	to, _ := hex.DecodeString(params.Contract[2:])
+
+	gasPrice, ok := new(big.Int).SetString(params.GasPrice, 10)
+	if !ok {
+		return nil, fmt.Errorf("网关返回的gas价格无效: %s", params.GasPrice)
+	}
+
+	callData, err := utils.EncodeABICall(ethereumUploadFunction, data.Owner, data.DataHash, data.DataType, data.Metadata, data.Keywords)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &utils.EthereumTransaction{
+		Nonce:    params.Nonce,
+		GasPrice: gasPrice,
+		GasLimit: params.GasLimit,
+		To:       to,
+		Value:    big.NewInt(0),
+		Data:     callData,
+		ChainID:  big.NewInt(params.ChainID),
+	}
+
+	return &uploadTx{
+		chain:       "ethereum",
+		signer:      identity.EthereumAddress,
+		ethereum:    tx,
+		signingHash: tx.SigningHash(),
+	}, nil
+}
+
+// 构造调用链码UploadData的Fabric提案，创建者为用户的Fabric证书
+func (s *SigningService) buildFabricUpload(identity models.BlockchainIdentity, data models.MedicalData) (*uploadTx, error) {
+	params, err := s.gatewayService.GetFabricTxParams()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		return nil, err
+	}
+
+	// 患者身份信息不写入世界状态，通过瞬态数据交给链码写入组织的私有数据集合
+	publicMetadata, privateMetadata, err := splitPrivateMetadata(data.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	// owner参数留空，由链码按创建者证书确定所有者：证书有medcross.did属性时为DID，
+	// 早期登记的证书没有该属性，链上所有者为客户端身份，平台仍按本地记录的DID判断所有权
+
+	proposal := models.FabricProposal{
+		Channel:   params.Channel,
+		Chaincode: params.Chaincode,
+		Function:  fabricUploadFunction,
+		Args:      []string{data.ID, "", data.DataHash, data.DataType, publicMetadata, data.Keywords},
+		MSPID:     identity.FabricMSPID,
+		Creator:   identity.FabricCertificate,
+		Nonce:     nonce,
+		Timestamp: time.Now().Unix(),
+	}
+	if privateMetadata != "" {
+		salt := make([]byte, fabricPrivateSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("生成私有元数据的盐失败: %w", err)
+		}
+		proposal.Transient = map[string]string{
+			fabricPrivateMetadataKey:     base64.StdEncoding.EncodeToString([]byte(privateMetadata)),
+			fabricPrivateMetadataSaltKey: base64.StdEncoding.EncodeToString(salt),
+		}
+	}
+
+	payload, err := json.Marshal(proposal)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(payload)
+
+	return &uploadTx{
+		chain:       "fabric",
+		signer:      identity.FabricID,
+		payload:     payload,
+		signingHash: digest[:],
+	}, nil
+}
+
+// 将元数据拆分为写入世界状态的公开部分和写入私有数据集合的敏感部分，没有敏感字段时后者为空
+func splitPrivateMetadata(metadata string) (string, string, error) {
+	if metadata == "" {
+		return "", "", nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(metadata), &fields); err != nil {
+		return "", "", fmt.Errorf("元数据不是有效的JSON对象: %w", err)
+	}
+
+	private := make(map[string]interface{})
+	for _, name := range fabricPrivateMetadataFields {
+		if value, exists := fields[name]; exists {
+			private[name] = value
+			delete(fields, name)
+		}
+	}
+	if len(private) == 0 {
+		return metadata, "", nil
+	}
+
+	publicJSON, err := json.Marshal(fields)
+	if err != nil {
+		return "", "", err
+	}
+	privateJSON, err := json.Marshal(private)
+	if err != nil {
+		return "", "", err
+	}
+	return string(publicJSON), string(privateJSON), nil
+}
+
+// 组装签名交易并交给网关转发
+func (s *SigningService) relay(tx *uploadTx, signature []byte) (*models.RelayResponse, error) {
+	signed := models.SignedTransaction{
+		Chain:  tx.chain,
+		Signer: tx.signer,
+	}
+
+	switch tx.chain {
+	case "ethereum":
+		raw, err := tx.ethereum.EncodeSigned(signature)
+		if err != nil {
+			return nil, err
+		}
+		signed.RawTransaction = "0x" + hex.EncodeToString(raw)
+	case "fabric":
+		signed.Payload = base64.StdEncoding.EncodeToString(tx.payload)
+		signed.Signature = base64.StdEncoding.EncodeToString(signature)
+	}
+
+	result, err := s.gatewayService.RelayTransaction(signed)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(result.From, tx.signer) {
+		log.Printf("警告: 网关返回的交易发送者与签名者不一致: 签名者=%s, 发送者=%s", tx.signer, result.From)
+	}
+	return result, nil
+}
+
+// 获取用户的交易构造锁
+func (s *SigningService) userLock(userID string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, exists := s.userLocks[userID]
+	if !exists {
+		lock = &sync.Mutex{}
+		s.userLocks[userID] = lock
+	}
+	return lock
+}
+
+// 清理过期的待签名交易
+func (s *SigningService) cleanupLocked(now time.Time) {
+	for id, pending := range s.pending {
+		if now.After(pending.expiresAt) {
+			delete(s.pending, id)
+		}
+	}
+}
+
+// ECDSA签名值
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// 使用Fabric私钥对摘要签名，返回DER格式签名
+// Fabric节点只接受低S签名，S大于n/2时取n-S
+func signFabricDigest(key *ecdsa.PrivateKey, digest []byte) ([]byte, error) {
+	r, sValue, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	n := key.Curve.Params().N
+	if sValue.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		sValue.Sub(n, sValue)
+	}
+	return asn1.Marshal(ecdsaSignature{R: r, S: sValue})
+}
+
+// 使用证书公钥校验DER格式的低S签名
+func verifyFabricSignature(certPEM string, digest, signature []byte) bool {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || pub.Curve != elliptic.P256() {
+		return false
+	}
+
+	var sig ecdsaSignature
+	if rest, err := asn1.Unmarshal(signature, &sig); err != nil || len(rest) != 0 {
+		return false
+	}
+	if sig.S.Cmp(new(big.Int).Rsh(pub.Curve.Params().N, 1)) > 0 {
+		return false
+	}
+	return ecdsa.Verify(pub, digest, sig.R, sig.S)
+}
@@ -0,0 +1,506 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"medcross/models"
+	"medcross/utils"
+)
+
+// 租户管理错误
+var (
+	ErrTenantNotFound      = errors.New("租户不存在")
+	ErrTenantForbidden     = errors.New("只能管理本租户的科室、用户和共享授权")
+	ErrTenantRequired      = errors.New("请指定租户")
+	ErrDepartmentNotFound  = errors.New("科室不存在")
+	ErrDepartmentExists    = errors.New("科室已存在")
+	ErrDepartmentInUse     = errors.New("科室中还有用户，不能删除")
+	ErrTenantGrantInvalid  = errors.New("无效的共享授权")
+	ErrTenantGrantNotFound = errors.New("共享授权不存在")
+)
+
+// tenantStore 租户的持久化结构
+type tenantStore struct {
+	Tenants map[string]*models.Tenant      `json:"tenants"`
+	Grants  map[string]*models.TenantGrant `json:"grants"`
+}
+
+// TenantService 多租户服务
+// 每个入驻的医院是一个租户，用户和医疗数据归属于租户。医生和研究人员默认只能查询和统计本租户的数据，
+// 读取其他租户的数据需要该租户授予的共享授权。平台管理员、患者和服务账户不属于任何租户，不受租户隔离限制
+type TenantService struct {
+	mu          sync.RWMutex
+	store       tenantStore
+	storePath   string
+	userService *UserService
+}
+
+// NewTenantService 创建新的租户服务
+func NewTenantService(userService *UserService) *TenantService {
+	service := &TenantService{
+		store: tenantStore{
+			Tenants: make(map[string]*models.Tenant),
+			Grants:  make(map[string]*models.TenantGrant),
+		},
+		storePath:   utils.DataFilePath("tenants.json"),
+		userService: userService,
+	}
+
+	// 加载租户和共享授权
+	if err := utils.LoadJSONFile(service.storePath, &service.store); err != nil {
+		log.Printf("加载租户失败: %v", err)
+	}
+	if service.store.Tenants == nil {
+		service.store.Tenants = make(map[string]*models.Tenant)
+	}
+	if service.store.Grants == nil {
+		service.store.Grants = make(map[string]*models.TenantGrant)
+	}
+
+	return service
+}
+
+// CreateTenant 为入驻的医院创建租户，已存在时直接返回
+// 填写了该医院的已有用户（如内置测试用户、单点登录用户）同时归入该租户
+func (s *TenantService) CreateTenant(hospital *models.HospitalDID) (*models.Tenant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if tenant, exists := s.store.Tenants[hospital.Slug]; exists {
+		result := *tenant
+		return &result, nil
+	}
+
+	now := time.Now()
+	tenant := &models.Tenant{
+		ID:          hospital.Slug,
+		Name:        hospital.Name,
+		DID:         hospital.DID,
+		Departments: []string{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	s.store.Tenants[tenant.ID] = tenant
+	if err := s.saveLocked(); err != nil {
+		delete(s.store.Tenants, tenant.ID)
+		return nil, err
+	}
+
+	for _, user := range s.userService.ListUsersByHospital(hospital.Name) {
+		if user.TenantID == "" {
+			if err := s.userService.SetTenant(user.ID, tenant.ID); err != nil {
+				log.Printf("用户归入租户失败: 用户=%s, 租户=%s, 错误=%v", user.ID, tenant.ID, err)
+			}
+		}
+	}
+
+	log.Printf("创建租户: ID=%s, 名称=%s", tenant.ID, tenant.Name)
+	result := *tenant
+	return &result, nil
+}
+
+// GetTenant 获取租户
+func (s *TenantService) GetTenant(tenantID string) (*models.Tenant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tenant, exists := s.store.Tenants[tenantID]
+	if !exists {
+		return nil, ErrTenantNotFound
+	}
+	result := *tenant
+	result.Departments = append([]string{}, tenant.Departments...)
+	return &result, nil
+}
+
+// ListTenants 获取所有租户
+func (s *TenantService) ListTenants() []models.Tenant {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tenants := make([]models.Tenant, 0, len(s.store.Tenants))
+	for _, tenant := range s.store.Tenants {
+		tenants = append(tenants, *tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].CreatedAt.Before(tenants[j].CreatedAt)
+	})
+	return tenants
+}
+
+// UserTenant 获取用户所属的租户ID，不属于任何租户时返回空字符串
+// 还没有记录租户的用户按填写的医院归入已入驻医院的租户
+// 患者和服务账户不属于任何租户：患者的数据可能分布在多家医院，需要跨租户读取本人数据
+func (s *TenantService) UserTenant(userID string) string {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return ""
+	}
+	if user.Role == models.RolePatient || user.Role == models.RoleService {
+		return ""
+	}
+	if user.TenantID != "" || user.Hospital == "" {
+		return user.TenantID
+	}
+
+	tenantID := hospitalSlug(user.Hospital)
+	s.mu.RLock()
+	_, exists := s.store.Tenants[tenantID]
+	s.mu.RUnlock()
+	if !exists {
+		return ""
+	}
+
+	if err := s.userService.SetTenant(userID, tenantID); err != nil {
+		return ""
+	}
+	return tenantID
+}
+
+// ManagedTenant 确定用户可以管理的租户
+// 医院管理员只能管理本租户；平台管理员需要通过requested指定租户
+func (s *TenantService) ManagedTenant(userID, requested string) (string, error) {
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return "", ErrTenantForbidden
+	}
+
+	tenantID := requested
+	switch {
+	case user.Role == models.RoleHospitalAdmin:
+		own := s.UserTenant(userID)
+		if own == "" || (requested != "" && requested != own) {
+			return "", ErrTenantForbidden
+		}
+		tenantID = own
+	case models.HasPermission(user.Role, models.PermUserAdmin):
+		if requested == "" {
+			return "", ErrTenantRequired
+		}
+	default:
+		return "", ErrTenantForbidden
+	}
+
+	if _, err := s.GetTenant(tenantID); err != nil {
+		return "", err
+	}
+	return tenantID, nil
+}
+
+// AddDepartment 为租户新增科室
+func (s *TenantService) AddDepartment(tenantID, name string) (*models.Tenant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant, exists := s.store.Tenants[tenantID]
+	if !exists {
+		return nil, ErrTenantNotFound
+	}
+	for _, department := range tenant.Departments {
+		if department == name {
+			return nil, ErrDepartmentExists
+		}
+	}
+
+	tenant.Departments = append(tenant.Departments, name)
+	tenant.UpdatedAt = time.Now()
+	if err := s.saveLocked(); err != nil {
+		tenant.Departments = tenant.Departments[:len(tenant.Departments)-1]
+		return nil, err
+	}
+
+	result := *tenant
+	return &result, nil
+}
+
+// RemoveDepartment 删除租户的科室，科室中还有用户时不能删除
+func (s *TenantService) RemoveDepartment(tenantID, name string) (*models.Tenant, error) {
+	for _, user := range s.ListMembers(tenantID) {
+		if user.Department == name {
+			return nil, ErrDepartmentInUse
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant, exists := s.store.Tenants[tenantID]
+	if !exists {
+		return nil, ErrTenantNotFound
+	}
+
+	departments := make([]string, 0, len(tenant.Departments))
+	for _, department := range tenant.Departments {
+		if department != name {
+			departments = append(departments, department)
+		}
+	}
+	if len(departments) == len(tenant.Departments) {
+		return nil, ErrDepartmentNotFound
+	}
+
+	previous := tenant.Departments
+	tenant.Departments = departments
+	tenant.UpdatedAt = time.Now()
+	if err := s.saveLocked(); err != nil {
+		tenant.Departments = previous
+		return nil, err
+	}
+
+	result := *tenant
+	return &result, nil
+}
+
+// ValidateDepartment 检查科室是否属于租户，租户未设置科室时不限制
+func (s *TenantService) ValidateDepartment(tenantID, department string) error {
+	if tenantID == "" || department == "" {
+		return nil
+	}
+	tenant, err := s.GetTenant(tenantID)
+	if err != nil {
+		return err
+	}
+	if !tenant.HasDepartment(department) {
+		return ErrDepartmentNotFound
+	}
+	return nil
+}
+
+// ValidateAffiliation 检查医院已入驻，且科室属于该医院的租户
+func (s *TenantService) ValidateAffiliation(hospital, department string) error {
+	if hospital == "" {
+		return nil
+	}
+	tenantID := hospitalSlug(hospital)
+	if _, err := s.GetTenant(tenantID); err != nil {
+		return err
+	}
+	return s.ValidateDepartment(tenantID, department)
+}
+
+// ListMembers 获取租户的用户
+func (s *TenantService) ListMembers(tenantID string) []*models.User {
+	tenant, err := s.GetTenant(tenantID)
+	if err != nil {
+		return []*models.User{}
+	}
+
+	members := make([]*models.User, 0)
+	for _, user := range s.userService.ListUsersByHospital(tenant.Name) {
+		if s.UserTenant(user.ID) == tenantID {
+			members = append(members, user)
+		}
+	}
+	return members
+}
+
+// AssignDepartment 调整租户用户的科室
+func (s *TenantService) AssignDepartment(tenantID, userID, department string) (*models.User, error) {
+	if s.UserTenant(userID) != tenantID {
+		return nil, ErrTenantForbidden
+	}
+	if err := s.ValidateDepartment(tenantID, department); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.SetDepartment(userID, department)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("调整用户科室: 租户=%s, 用户=%s, 科室=%s", tenantID, userID, department)
+	return user, nil
+}
+
+// RecordTenant 获取医疗数据所属的租户
+// 依次取数据记录的租户、元数据中的tenantId（随数据上链）和数据所有者所属的租户
+func (s *TenantService) RecordTenant(data models.MedicalData) string {
+	if data.TenantID != "" {
+		return data.TenantID
+	}
+	if tenantID := metadataString(parseMetadata(data.Metadata), "tenantId"); tenantID != "" {
+		return tenantID
+	}
+	if ownerID, exists := s.userService.ResolveOwner(data.Owner); exists {
+		return s.UserTenant(ownerID)
+	}
+	return ""
+}
+
+// RecordHospital 获取医疗数据所属的医院名称
+// 优先使用数据所属租户的名称，无法确定租户时使用元数据中的hospital
+func (s *TenantService) RecordHospital(data models.MedicalData) string {
+	if tenantID := s.RecordTenant(data); tenantID != "" {
+		if tenant, err := s.GetTenant(tenantID); err == nil {
+			return tenant.Name
+		}
+	}
+	return metadataString(parseMetadata(data.Metadata), "hospital")
+}
+
+// TenantScoped 检查用户是否受租户隔离限制
+// 属于租户的用户，以及医生、研究人员和医院管理员（即使所属医院尚未入驻）都受限制
+func (s *TenantService) TenantScoped(userID string) bool {
+	if s.UserTenant(userID) != "" {
+		return true
+	}
+	user, err := s.userService.GetUserByID(userID)
+	return err != nil || models.TenantScopedRoles[user.Role]
+}
+
+// InTenant 检查数据是否属于用户所在的租户
+// 平台管理员、患者和服务账户不受限制；受租户隔离限制的用户没有租户时不能访问任何数据，
+// 也不能访问无法确定租户的数据
+func (s *TenantService) InTenant(userID string, data models.MedicalData) bool {
+	if !s.TenantScoped(userID) {
+		return true
+	}
+	userTenant := s.UserTenant(userID)
+	return userTenant != "" && s.RecordTenant(data) == userTenant
+}
+
+// CanAccess 检查用户能否以指定目的访问数据所在租户的数据
+// 同一租户的数据直接放行，其他租户的数据需要该租户授予用户所在租户的有效共享授权
+func (s *TenantService) CanAccess(userID, purpose string, data models.MedicalData) bool {
+	if s.InTenant(userID, data) {
+		return true
+	}
+
+	grantor := s.RecordTenant(data)
+	grantee := s.UserTenant(userID)
+	now := time.Now()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, grant := range s.store.Grants {
+		if grant.GrantorTenant == grantor && grant.GranteeTenant == grantee && grant.Covers(data.DataType, purpose, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterTenant 过滤出用户可以查询的数据
+// 默认只保留本租户的数据，shared为true时还包括其他租户共享给本租户的数据
+func (s *TenantService) FilterTenant(userID, purpose string, records []models.MedicalData, shared bool) []models.MedicalData {
+	filtered := make([]models.MedicalData, 0, len(records))
+	for _, record := range records {
+		if s.InTenant(userID, record) || (shared && s.CanAccess(userID, purpose, record)) {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+// CreateGrant 授予其他租户读取本租户数据的共享授权
+func (s *TenantService) CreateGrant(grantorTenant string, req models.TenantGrantRequest, createdBy string) (*models.TenantGrant, error) {
+	if req.GranteeTenant == grantorTenant || req.ValidDays < 0 {
+		return nil, ErrTenantGrantInvalid
+	}
+	for _, purpose := range req.Purposes {
+		if !models.ValidPurposes[purpose] {
+			return nil, ErrTenantGrantInvalid
+		}
+	}
+	if _, err := s.GetTenant(req.GranteeTenant); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	grant := &models.TenantGrant{
+		ID:            uuid.New().String(),
+		GrantorTenant: grantorTenant,
+		GranteeTenant: req.GranteeTenant,
+		DataTypes:     req.DataTypes,
+		Purposes:      req.Purposes,
+		CreatedBy:     createdBy,
+		CreatedAt:     now,
+	}
+	if req.ValidDays > 0 {
+		expiresAt := now.AddDate(0, 0, req.ValidDays)
+		grant.ExpiresAt = &expiresAt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.store.Grants[grant.ID] = grant
+	if err := s.saveLocked(); err != nil {
+		delete(s.store.Grants, grant.ID)
+		return nil, err
+	}
+
+	log.Printf("创建租户共享授权: ID=%s, 授权租户=%s, 被授权租户=%s", grant.ID, grantorTenant, req.GranteeTenant)
+	result := *grant
+	return &result, nil
+}
+
+// RevokeGrant 撤销本租户授予的共享授权
+func (s *TenantService) RevokeGrant(grantorTenant, grantID, revokedBy, note string) (*models.TenantGrant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grant, exists := s.store.Grants[grantID]
+	if !exists {
+		return nil, ErrTenantGrantNotFound
+	}
+	if grant.GrantorTenant != grantorTenant {
+		return nil, ErrTenantForbidden
+	}
+	if grant.Revoked {
+		result := *grant
+		return &result, nil
+	}
+
+	now := time.Now()
+	grant.Revoked = true
+	grant.RevokedAt = &now
+	grant.RevokedBy = revokedBy
+	grant.RevocationNote = note
+	if err := s.saveLocked(); err != nil {
+		grant.Revoked = false
+		grant.RevokedAt = nil
+		return nil, err
+	}
+
+	log.Printf("撤销租户共享授权: ID=%s, 撤销人=%s", grantID, revokedBy)
+	result := *grant
+	return &result, nil
+}
+
+// ListGrants 获取租户授予的和收到的共享授权
+func (s *TenantService) ListGrants(tenantID string) (granted, received []models.TenantGrant) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	granted = make([]models.TenantGrant, 0)
+	received = make([]models.TenantGrant, 0)
+	for _, grant := range s.store.Grants {
+		if grant.GrantorTenant == tenantID {
+			granted = append(granted, *grant)
+		}
+		if grant.GranteeTenant == tenantID {
+			received = append(received, *grant)
+		}
+	}
+	sort.Slice(granted, func(i, j int) bool { return granted[i].CreatedAt.After(granted[j].CreatedAt) })
+	sort.Slice(received, func(i, j int) bool { return received[i].CreatedAt.After(received[j].CreatedAt) })
+	return granted, received
+}
+
+// 保存租户和共享授权，调用方需持有写锁
+func (s *TenantService) saveLocked() error {
+	if err := utils.SaveJSONFile(s.storePath, s.store); err != nil {
+		log.Printf("保存租户失败: %v", err)
+		return fmt.Errorf("保存租户失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"medcross/models"
+)
+
+// 租户隔离测试环境：协和医院和华山医院已入驻，仁济医院尚未入驻
+type tenantFixture struct {
+	service *TenantService
+	users   map[string]string // 名称 -> 用户ID
+	tenantA string
+	tenantB string
+}
+
+func newTenantFixture(t *testing.T) *tenantFixture {
+	t.Helper()
+
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "Adm1n-Passw0rd")
+
+	userService := NewUserService()
+	fixture := &tenantFixture{
+		service: NewTenantService(userService),
+		users:   make(map[string]string),
+	}
+
+	registrations := []models.UserRegister{
+		{Username: "doctor-a", Role: models.RoleDoctor, Hospital: "协和医院"},
+		{Username: "researcher-a", Role: models.RoleResearcher, Hospital: "协和医院"},
+		{Username: "doctor-b", Role: models.RoleDoctor, Hospital: "华山医院"},
+		{Username: "doctor-none", Role: models.RoleDoctor, Hospital: "仁济医院"},
+		{Username: "patient", Role: models.RolePatient},
+	}
+	for _, registration := range registrations {
+		registration.Password = "password123"
+		registration.Name = registration.Username
+		userID, err := userService.CreateUser(registration)
+		if err != nil {
+			t.Fatalf("创建用户 %s 失败: %v", registration.Username, err)
+		}
+		fixture.users[registration.Username] = userID
+	}
+
+	admin, err := userService.GetUserByUsername("admin")
+	if err != nil {
+		t.Fatalf("初始管理员不存在: %v", err)
+	}
+	fixture.users["admin"] = admin.ID
+
+	for _, name := range []string{"协和医院", "华山医院"} {
+		tenant, err := fixture.service.CreateTenant(&models.HospitalDID{Slug: hospitalSlug(name), Name: name})
+		if err != nil {
+			t.Fatalf("创建租户 %s 失败: %v", name, err)
+		}
+		if name == "协和医院" {
+			fixture.tenantA = tenant.ID
+		} else {
+			fixture.tenantB = tenant.ID
+		}
+	}
+
+	return fixture
+}
+
+func TestTenantScoped(t *testing.T) {
+	f := newTenantFixture(t)
+
+	cases := []struct {
+		user   string
+		tenant string
+		scoped bool
+	}{
+		{"doctor-a", f.tenantA, true},
+		{"researcher-a", f.tenantA, true},
+		{"doctor-b", f.tenantB, true},
+		{"doctor-none", "", true},
+		{"patient", "", false},
+		{"admin", "", false},
+		{"unknown", "", true},
+	}
+
+	for _, tc := range cases {
+		// 不存在的用户ID为空字符串
+		userID := f.users[tc.user]
+		if got := f.service.UserTenant(userID); got != tc.tenant {
+			t.Errorf("%s: 租户 = %q, 期望 %q", tc.user, got, tc.tenant)
+		}
+		if got := f.service.TenantScoped(userID); got != tc.scoped {
+			t.Errorf("%s: 受租户限制 = %v, 期望 %v", tc.user, got, tc.scoped)
+		}
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	f := newTenantFixture(t)
+
+	recordA := models.MedicalData{ID: "a", DataType: "影像数据", TenantID: f.tenantA}
+	recordB := models.MedicalData{ID: "b", DataType: "影像数据", TenantID: f.tenantB}
+	genomicsB := models.MedicalData{ID: "b-genomics", DataType: "基因组数据", TenantID: f.tenantB}
+	metadataA := models.MedicalData{ID: "a-metadata", DataType: "影像数据", Metadata: `{"tenantId":"` + f.tenantA + `"}`}
+	ownerB := models.MedicalData{ID: "b-owner", DataType: "影像数据", Owner: f.users["doctor-b"]}
+	unknown := models.MedicalData{ID: "unknown", DataType: "影像数据", Owner: "did:key:unknown"}
+
+	// 华山医院向协和医院共享影像数据，仅限科研目的
+	if _, err := f.service.CreateGrant(f.tenantB, models.TenantGrantRequest{
+		GranteeTenant: f.tenantA,
+		DataTypes:     []string{"影像数据"},
+		Purposes:      []string{models.PurposeResearch},
+	}, f.users["admin"]); err != nil {
+		t.Fatalf("创建共享授权失败: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		user     string
+		record   models.MedicalData
+		purpose  string
+		inTenant bool
+		access   bool
+	}{
+		{"本租户数据", "doctor-a", recordA, models.PurposeTreatment, true, true},
+		{"元数据中的租户", "researcher-a", metadataA, models.PurposeResearch, true, true},
+		{"所有者所属租户", "doctor-b", ownerB, models.PurposeTreatment, true, true},
+		{"其他租户数据", "doctor-b", recordA, models.PurposeResearch, false, false},
+		{"共享授权覆盖", "doctor-a", recordB, models.PurposeResearch, false, true},
+		{"共享授权不覆盖目的", "doctor-a", recordB, models.PurposeTreatment, false, false},
+		{"共享授权不覆盖类型", "doctor-a", genomicsB, models.PurposeResearch, false, false},
+		{"共享授权是单向的", "doctor-b", recordA, models.PurposeResearch, false, false},
+		{"无法确定租户的数据", "doctor-a", unknown, models.PurposeResearch, false, false},
+		{"没有租户的医生", "doctor-none", recordA, models.PurposeTreatment, false, false},
+		{"没有租户的医生读取未知数据", "doctor-none", unknown, models.PurposeTreatment, false, false},
+		{"患者不受限制", "patient", recordB, models.PurposeTreatment, true, true},
+		{"平台管理员不受限制", "admin", unknown, models.PurposeResearch, true, true},
+	}
+
+	for _, tc := range cases {
+		userID := f.users[tc.user]
+		if got := f.service.InTenant(userID, tc.record); got != tc.inTenant {
+			t.Errorf("%s: InTenant = %v, 期望 %v", tc.name, got, tc.inTenant)
+		}
+		if got := f.service.CanAccess(userID, tc.purpose, tc.record); got != tc.access {
+			t.Errorf("%s: CanAccess = %v, 期望 %v", tc.name, got, tc.access)
+		}
+	}
+}
+
+func TestFilterTenant(t *testing.T) {
+	f := newTenantFixture(t)
+
+	records := []models.MedicalData{
+		{ID: "a", DataType: "影像数据", TenantID: f.tenantA},
+		{ID: "b", DataType: "影像数据", TenantID: f.tenantB},
+		{ID: "unknown", DataType: "影像数据"},
+	}
+
+	grant, err := f.service.CreateGrant(f.tenantB, models.TenantGrantRequest{GranteeTenant: f.tenantA}, f.users["admin"])
+	if err != nil {
+		t.Fatalf("创建共享授权失败: %v", err)
+	}
+
+	ids := func(records []models.MedicalData) []string {
+		result := make([]string, 0, len(records))
+		for _, record := range records {
+			result = append(result, record.ID)
+		}
+		return result
+	}
+
+	cases := []struct {
+		name   string
+		user   string
+		shared bool
+		revoke bool
+		want   []string
+	}{
+		{"默认只包括本租户", "doctor-a", false, false, []string{"a"}},
+		{"包括共享数据", "doctor-a", true, false, []string{"a", "b"}},
+		{"没有租户时为空", "doctor-none", true, false, []string{}},
+		{"患者不过滤", "patient", false, false, []string{"a", "b", "unknown"}},
+		{"撤销后不再包括共享数据", "doctor-a", true, true, []string{"a"}},
+	}
+
+	for _, tc := range cases {
+		if tc.revoke {
+			if _, err := f.service.RevokeGrant(f.tenantB, grant.ID, f.users["admin"], ""); err != nil {
+				t.Fatalf("撤销共享授权失败: %v", err)
+			}
+		}
+		got := ids(f.service.FilterTenant(f.users[tc.user], models.PurposeResearch, records, tc.shared))
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: 结果 = %v, 期望 %v", tc.name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: 结果 = %v, 期望 %v", tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCreateGrantRejects(t *testing.T) {
+	f := newTenantFixture(t)
+
+	cases := []struct {
+		name string
+		req  models.TenantGrantRequest
+		err  error
+	}{
+		{"授权给自己", models.TenantGrantRequest{GranteeTenant: f.tenantA}, ErrTenantGrantInvalid},
+		{"有效期为负数", models.TenantGrantRequest{GranteeTenant: f.tenantB, ValidDays: -1}, ErrTenantGrantInvalid},
+		{"无效的目的", models.TenantGrantRequest{GranteeTenant: f.tenantB, Purposes: []string{"marketing"}}, ErrTenantGrantInvalid},
+		{"租户不存在", models.TenantGrantRequest{GranteeTenant: "h-missing"}, ErrTenantNotFound},
+	}
+
+	for _, tc := range cases {
+		if _, err := f.service.CreateGrant(f.tenantA, tc.req, f.users["admin"]); !errors.Is(err, tc.err) {
+			t.Errorf("%s: 错误 = %v, 期望 %v", tc.name, err, tc.err)
+		}
+	}
+}
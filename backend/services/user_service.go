@@ -3,6 +3,10 @@ package services
 import (
 	"errors"
 	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,11 +16,17 @@ import (
 )
 
 // UserService 用户服务
+// 用户和各索引由读写锁保护，对外返回用户的副本，修改只能通过本服务的方法进行
 type UserService struct {
 	// 在实际应用中，这里应该有数据库连接
 	// 为了演示，我们使用内存存储
+	mu            sync.RWMutex
 	users         map[string]*models.User
 	usernameIndex map[string]string // username -> id 映射
+	patientIndex  map[string]string // patientId -> id 映射
+	externalIndex map[string]string // 外部身份(issuer|sub) -> id 映射
+	chainIndex    map[string]string // 以太坊地址、Fabric身份标识（小写） -> id 映射
+	didIndex      map[string]string // DID -> id 映射（DID区分大小写）
 }
 
 // NewUserService 创建新的用户服务
@@ -24,6 +34,10 @@ func NewUserService() *UserService {
 	service := &UserService{
 		users:         make(map[string]*models.User),
 		usernameIndex: make(map[string]string),
+		patientIndex:  make(map[string]string),
+		externalIndex: make(map[string]string),
+		chainIndex:    make(map[string]string),
+		didIndex:      make(map[string]string),
 	}
 
 	// 添加一个测试用户
@@ -39,27 +53,75 @@ func NewUserService() *UserService {
 		Department: "内科",
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
+		Status:     models.UserStatusActive,
 	}
 	service.usernameIndex["testuser"] = testUserID
 
+	// 管理员不能自助注册，通过环境变量创建初始管理员
+	adminUsername := os.Getenv("ADMIN_USERNAME")
+	adminPassword := os.Getenv("ADMIN_PASSWORD")
+	if adminUsername != "" && adminPassword != "" {
+		adminID := uuid.New().String()
+		hashedPassword, err := utils.HashPassword(adminPassword)
+		if err != nil {
+			log.Printf("创建初始管理员失败: %v", err)
+			return service
+		}
+		service.users[adminID] = &models.User{
+			ID:        adminID,
+			Username:  adminUsername,
+			Password:  hashedPassword,
+			Name:      "系统管理员",
+			Role:      models.RoleAdmin,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			Status:    models.UserStatusActive,
+		}
+		service.usernameIndex[adminUsername] = adminID
+	}
+
 	return service
 }
 
-// CreateUser 创建新用户
+// CreateUser 创建自助注册的用户，需要医院管理员审核通过后才能使用
 func (s *UserService) CreateUser(userData models.UserRegister) (string, error) {
+	// 对密码进行哈希处理（在加锁前完成，避免阻塞其他请求）
+	hashedPassword, err := utils.HashPassword(userData.Password)
+	if err != nil {
+		log.Printf("密码哈希失败: %v", err)
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// 检查用户名是否已存在
-	if s.UsernameExists(userData.Username) {
+	if _, exists := s.usernameIndex[userData.Username]; exists {
 		return "", errors.New("用户名已存在")
 	}
 
+	// 只允许注册普通角色
+	if !models.SelfRegistrableRoles[userData.Role] {
+		return "", errors.New("不允许注册该角色")
+	}
+
+	// 患者标识只对患者用户有效，且不能重复绑定
+	// 申请绑定的患者标识由审核人核实身份后才生效，在此之前不能用于读取数据或管理知情同意
+	pendingPatientID := ""
+	if userData.Role == models.RolePatient {
+		pendingPatientID = userData.PatientID
+		if _, bound := s.patientIndex[pendingPatientID]; pendingPatientID != "" && bound {
+			return "", errors.New("患者标识已被绑定")
+		}
+	}
+
 	// 生成唯一ID
 	userID := uuid.New().String()
 
-	// 对密码进行哈希处理
-	hashedPassword, err := utils.HashPassword(userData.Password)
-	if err != nil {
-		log.Printf("密码哈希失败: %v", err)
-		return "", err
+	// 未申请绑定患者标识时使用用户ID
+	patientID := ""
+	if userData.Role == models.RolePatient && pendingPatientID == "" {
+		patientID = userID
 	}
 
 	// 创建用户
@@ -71,26 +133,160 @@ func (s *UserService) CreateUser(userData models.UserRegister) (string, error) {
 		Role:       userData.Role,
 		Hospital:   userData.Hospital,
 		Department: userData.Department,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		PatientID:  patientID,
+		Email:      userData.Email,
+
+		PendingPatientID: pendingPatientID,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+		Status:           models.UserStatusPending,
 	}
 
 	// 保存用户
 	s.users[userID] = user
 	s.usernameIndex[userData.Username] = userID
+	if patientID != "" {
+		s.patientIndex[patientID] = userID
+	}
 
 	return userID, nil
 }
 
-// VerifyUser 验证用户凭据
-func (s *UserService) VerifyUser(username, password string) (*models.User, error) {
-	// 查找用户
-	userID, exists := s.usernameIndex[username]
+// CreateHospitalAdmin 创建医院管理员，由平台管理员在医院入驻后创建，无需审核
+func (s *UserService) CreateHospitalAdmin(req models.HospitalAdminRequest, hospital string) (string, error) {
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		log.Printf("密码哈希失败: %v", err)
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.usernameIndex[req.Username]; exists {
+		return "", errors.New("用户名已存在")
+	}
+
+	userID := uuid.New().String()
+	s.users[userID] = &models.User{
+		ID:        userID,
+		Username:  req.Username,
+		Password:  hashedPassword,
+		Name:      req.Name,
+		Role:      models.RoleHospitalAdmin,
+		Hospital:  hospital,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Status:    models.UserStatusActive,
+	}
+	s.usernameIndex[req.Username] = userID
+
+	return userID, nil
+}
+
+// ManagedHospital 获取医院管理员所管理的医院，其他用户返回空字符串，表示不限医院
+func (s *UserService) ManagedHospital(userID string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.users[userID]
+	if !exists || user.Role != models.RoleHospitalAdmin {
+		return ""
+	}
+	return user.Hospital
+}
+
+// ListUsersByStatus 按审核状态获取用户，hospital非空时只返回该医院的用户
+func (s *UserService) ListUsersByStatus(status, hospital string) []*models.User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*models.User, 0)
+	for _, user := range s.users {
+		if user.Status == status && (hospital == "" || user.Hospital == hospital) {
+			users = append(users, cloneUser(user))
+		}
+	}
+	return users
+}
+
+// ListUsersByRole 按角色获取已启用的用户，hospital非空时只返回该医院的用户
+func (s *UserService) ListUsersByRole(role, hospital string) []*models.User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*models.User, 0)
+	for _, user := range s.users {
+		if user.Role == role && user.Status == models.UserStatusActive && (hospital == "" || user.Hospital == hospital) {
+			users = append(users, cloneUser(user))
+		}
+	}
+	return users
+}
+
+// ReviewUser 记录用户的审核结果，审核通过时可以调整角色和科室
+func (s *UserService) ReviewUser(userID, status, role, department, reviewerID, note string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return nil, errors.New("用户不存在")
+	}
+
+	now := time.Now()
+	user.Status = status
+	if role != "" {
+		user.Role = role
+	}
+	if department != "" {
+		user.Department = department
+	}
+	user.ReviewedBy = reviewerID
+	user.ReviewedAt = &now
+	user.ReviewNote = note
+	user.UpdatedAt = now
+
+	return cloneUser(user), nil
+}
+
+// ResolvePatientClaim 处理患者注册时申请绑定的患者标识
+// verified为true时启用申请的标识，标识已被其他用户绑定时返回ErrPatientIDBound；否则改用用户ID作为患者标识
+func (s *UserService) ResolvePatientClaim(userID string, verified bool) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
 	if !exists {
 		return nil, errors.New("用户不存在")
 	}
+	if user.PendingPatientID == "" {
+		return cloneUser(user), nil
+	}
+
+	patientID := user.ID
+	if verified {
+		if _, bound := s.patientIndex[user.PendingPatientID]; bound {
+			return nil, ErrPatientIDBound
+		}
+		patientID = user.PendingPatientID
+	}
+
+	user.PatientID = patientID
+	user.PendingPatientID = ""
+	user.UpdatedAt = time.Now()
+	s.patientIndex[patientID] = userID
+
+	return cloneUser(user), nil
+}
 
-	user := s.users[userID]
+// VerifyUser 验证用户凭据
+func (s *UserService) VerifyUser(username, password string) (*models.User, error) {
+	// 查找用户，校验密码前释放锁
+	user, err := s.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
 
 	// 验证密码
 	if !utils.CheckPasswordHash(password, user.Password) {
@@ -102,16 +298,421 @@ func (s *UserService) VerifyUser(username, password string) (*models.User, error
 
 // GetUserByID 根据ID获取用户
 func (s *UserService) GetUserByID(userID string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	user, exists := s.users[userID]
 	if !exists {
 		return nil, errors.New("用户不存在")
 	}
 
-	return user, nil
+	return cloneUser(user), nil
+}
+
+// UpsertExternalUser 根据单点登录的外部身份查找或创建用户，返回用户和是否新建
+// 已关联的用户每次登录时按身份提供方的声明同步姓名、医院、科室和角色；
+// 已归入租户的用户声明的医院变化时拒绝登录，需要管理员处理
+func (s *UserService) UpsertExternalUser(identity models.OIDCIdentity) (*models.User, bool, error) {
+	externalKey := identity.Issuer + "|" + identity.Subject
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if userID, exists := s.externalIndex[externalKey]; exists {
+		user := s.users[userID]
+		if user.TenantID != "" && identity.Hospital != "" && identity.Hospital != user.Hospital {
+			return nil, false, ErrOIDCHospitalMismatch
+		}
+		if identity.Name != "" {
+			user.Name = identity.Name
+		}
+		if identity.Hospital != "" {
+			user.Hospital = identity.Hospital
+		}
+		if identity.Department != "" {
+			user.Department = identity.Department
+		}
+		if identity.Role != "" && identity.Role != user.Role {
+			log.Printf("单点登录同步用户角色: 用户=%s, %s -> %s", user.ID, user.Role, identity.Role)
+			user.Role = identity.Role
+		}
+		user.UpdatedAt = time.Now()
+		return cloneUser(user), false, nil
+	}
+
+	if !identity.CanCreate {
+		return nil, false, ErrOIDCProvisioningDisabled
+	}
+
+	// 用户名与本地用户冲突时加上身份提供方前缀
+	username := identity.Username
+	if username == "" {
+		username = identity.Subject
+	}
+	if _, exists := s.usernameIndex[username]; exists {
+		username = identity.ProviderID + "." + username
+	}
+	if _, exists := s.usernameIndex[username]; exists {
+		username = username + "." + uuid.New().String()[:8]
+	}
+
+	userID := uuid.New().String()
+
+	// 患者标识只对患者用户有效，且不能重复绑定
+	patientID := ""
+	if identity.Role == models.RolePatient {
+		patientID = identity.PatientID
+		if _, bound := s.patientIndex[patientID]; patientID != "" && bound {
+			return nil, false, errors.New("患者标识已被绑定")
+		}
+		if patientID == "" {
+			patientID = userID
+		}
+	}
+
+	user := &models.User{
+		ID:              userID,
+		Username:        username,
+		Name:            identity.Name,
+		Role:            identity.Role,
+		Hospital:        identity.Hospital,
+		Department:      identity.Department,
+		PatientID:       patientID,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+		Status:          models.UserStatusActive,
+		ExternalIssuer:  identity.Issuer,
+		ExternalSubject: identity.Subject,
+	}
+
+	s.users[userID] = user
+	s.usernameIndex[username] = userID
+	s.externalIndex[externalKey] = userID
+	if patientID != "" {
+		s.patientIndex[patientID] = userID
+	}
+
+	log.Printf("单点登录自动创建用户: 用户=%s, 用户名=%s, 身份提供方=%s", userID, username, identity.ProviderID)
+	return cloneUser(user), true, nil
+}
+
+// RegisterServiceAccount 将服务账户登记为用户，使访问控制和数据归属可以按用户ID查找
+// 服务账户不加入用户名索引，不能使用密码登录
+func (s *UserService) RegisterServiceAccount(account *models.ServiceAccount) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[account.ID] = account.User()
+}
+
+// SetBlockchainIdentity 记录用户的区块链地址
+func (s *UserService) SetBlockchainIdentity(userID, ethereumAddress, fabricID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return errors.New("用户不存在")
+	}
+
+	user.EthereumAddress = ethereumAddress
+	user.FabricID = fabricID
+	user.UpdatedAt = time.Now()
+	s.chainIndex[strings.ToLower(ethereumAddress)] = userID
+	s.chainIndex[strings.ToLower(fabricID)] = userID
+	return nil
+}
+
+// SetDID 记录用户的去中心化标识符
+func (s *UserService) SetDID(userID, did string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return errors.New("用户不存在")
+	}
+
+	if user.DID != "" && s.didIndex[user.DID] == userID {
+		delete(s.didIndex, user.DID)
+	}
+	user.DID = did
+	user.UpdatedAt = time.Now()
+	s.didIndex[did] = userID
+	return nil
+}
+
+// SetTenant 记录用户所属的租户
+func (s *UserService) SetTenant(userID, tenantID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return errors.New("用户不存在")
+	}
+
+	user.TenantID = tenantID
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetDepartment 调整用户的科室
+func (s *UserService) SetDepartment(userID, department string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return nil, errors.New("用户不存在")
+	}
+
+	user.Department = department
+	user.UpdatedAt = time.Now()
+	return cloneUser(user), nil
+}
+
+// UpdateProfile 更新用户的个人资料，为空的字段保持不变
+// 医院变更后清除原来的租户，由租户服务按新医院重新确定
+func (s *UserService) UpdateProfile(userID string, req models.ProfileUpdate) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return nil, errors.New("用户不存在")
+	}
+
+	if req.Name != "" {
+		user.Name = req.Name
+	}
+	if req.Email != "" {
+		user.Email = req.Email
+	}
+	if req.Hospital != "" && req.Hospital != user.Hospital {
+		user.Hospital = req.Hospital
+		user.TenantID = ""
+	}
+	if req.Department != "" {
+		user.Department = req.Department
+	}
+	user.UpdatedAt = time.Now()
+
+	return cloneUser(user), nil
+}
+
+// SetPassword 设置用户的新密码
+func (s *UserService) SetPassword(userID, password string) error {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		log.Printf("密码哈希失败: %v", err)
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return errors.New("用户不存在")
+	}
+
+	now := time.Now()
+	user.Password = hashedPassword
+	user.PasswordChangedAt = &now
+	user.UpdatedAt = now
+	return nil
+}
+
+// SetRole 调整用户角色
+func (s *UserService) SetRole(userID, role string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return nil, errors.New("用户不存在")
+	}
+
+	user.Role = role
+	user.UpdatedAt = time.Now()
+	return cloneUser(user), nil
+}
+
+// SetStatus 设置用户的账户状态，停用时记录操作人和原因，重新启用时清除停用记录
+func (s *UserService) SetStatus(userID, status, actorID, reason string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return nil, errors.New("用户不存在")
+	}
+
+	now := time.Now()
+	user.Status = status
+	if status == models.UserStatusDisabled {
+		user.DisabledAt = &now
+		user.DisabledBy = actorID
+		user.DisableReason = reason
+	} else {
+		user.DisabledAt = nil
+		user.DisabledBy = ""
+		user.DisableReason = ""
+	}
+	user.UpdatedAt = now
+	return cloneUser(user), nil
+}
+
+// GetUserByUsername 根据用户名获取用户
+func (s *UserService) GetUserByUsername(username string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userID, exists := s.usernameIndex[username]
+	if !exists {
+		return nil, errors.New("用户不存在")
+	}
+
+	return cloneUser(s.users[userID]), nil
+}
+
+// ListUsers 按条件查询用户，按创建时间倒序分页，返回当前页和总数
+// 服务账户通过服务账户接口管理，不在结果中
+func (s *UserService) ListUsers(query models.UserListQuery) ([]*models.User, int) {
+	keyword := strings.ToLower(query.Query)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := make([]*models.User, 0)
+	for _, user := range s.users {
+		if user.Role == models.RoleService {
+			continue
+		}
+		if query.Role != "" && user.Role != query.Role {
+			continue
+		}
+		if query.Status != "" && user.Status != query.Status {
+			continue
+		}
+		if query.Tenant != "" && user.TenantID != query.Tenant {
+			continue
+		}
+		if keyword != "" && !strings.Contains(strings.ToLower(user.Username), keyword) &&
+			!strings.Contains(strings.ToLower(user.Name), keyword) &&
+			!strings.Contains(strings.ToLower(user.Email), keyword) {
+			continue
+		}
+		matched = append(matched, cloneUser(user))
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].CreatedAt.After(matched[j].CreatedAt)
+	})
+
+	total := len(matched)
+	start := (query.Page - 1) * query.PageSize
+	if start >= total {
+		return []*models.User{}, total
+	}
+	end := start + query.PageSize
+	if end > total {
+		end = total
+	}
+	return matched[start:end], total
+}
+
+// ListUsersByHospital 获取填写了指定医院的用户
+func (s *UserService) ListUsersByHospital(hospital string) []*models.User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*models.User, 0)
+	for _, user := range s.users {
+		if user.Hospital == hospital {
+			users = append(users, cloneUser(user))
+		}
+	}
+	return users
+}
+
+// ResolveOwner 将数据所有者解析为用户ID
+// 数据所有者是用户的DID；早期用户签名上链的数据所有者是以太坊地址或Fabric身份标识，网关代为上链的是用户ID
+func (s *UserService) ResolveOwner(owner string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, exists := s.users[owner]; exists {
+		return owner, true
+	}
+	if userID, exists := s.didIndex[owner]; exists {
+		return userID, true
+	}
+
+	userID, exists := s.chainIndex[strings.ToLower(owner)]
+	return userID, exists
+}
+
+// DeleteUser 删除用户，用于注册过程中后续步骤失败时回滚
+func (s *UserService) DeleteUser(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return
+	}
+
+	delete(s.users, userID)
+	delete(s.usernameIndex, user.Username)
+	if user.PatientID != "" && s.patientIndex[user.PatientID] == userID {
+		delete(s.patientIndex, user.PatientID)
+	}
+	if user.ExternalIssuer != "" {
+		delete(s.externalIndex, user.ExternalIssuer+"|"+user.ExternalSubject)
+	}
+	if user.EthereumAddress != "" {
+		delete(s.chainIndex, strings.ToLower(user.EthereumAddress))
+		delete(s.chainIndex, strings.ToLower(user.FabricID))
+	}
+	if user.DID != "" {
+		delete(s.didIndex, user.DID)
+	}
 }
 
 // UsernameExists 检查用户名是否已存在
 func (s *UserService) UsernameExists(username string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	_, exists := s.usernameIndex[username]
 	return exists
 }
+
+// PatientIDExists 检查患者标识是否已被绑定
+func (s *UserService) PatientIDExists(patientID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, exists := s.patientIndex[patientID]
+	return exists
+}
+
+// GetUserByPatientID 根据患者标识获取患者用户
+func (s *UserService) GetUserByPatientID(patientID string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userID, exists := s.patientIndex[patientID]
+	if !exists {
+		return nil, errors.New("用户不存在")
+	}
+
+	return cloneUser(s.users[userID]), nil
+}
+
+// 复制用户，避免调用方在锁外读取被并发修改的字段
+func cloneUser(user *models.User) *models.User {
+	result := *user
+	return &result
+}
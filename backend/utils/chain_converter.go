@@ -2,21 +2,35 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
 	"medcross/models"
 )
 
+// ErrUnknownChainIdentity 数据所有者不是已登记的链上身份，无法在两条链之间转换
+var ErrUnknownChainIdentity = errors.New("数据所有者不是已登记的链上身份，无法跨链转换")
+
+// OwnerResolver 查询同一用户在两条链上的身份，由跨链身份注册表实现
+type OwnerResolver interface {
+	FabricIDForEthereum(address string) (string, bool)
+	EthereumAddressForFabric(fabricID string) (string, bool)
+}
+
 // ChainConverter 区块链数据转换工具
 // 用于处理以太坊和Fabric之间的数据格式转换
-type ChainConverter struct{}
+type ChainConverter struct {
+	resolver OwnerResolver
+}
 
-// NewChainConverter 创建新的链转换器
-func NewChainConverter() *ChainConverter {
-	return &ChainConverter{}
+// NewChainConverter 创建新的链转换器，数据所有者通过resolver在两条链的身份之间转换
+func NewChainConverter(resolver OwnerResolver) *ChainConverter {
+	return &ChainConverter{resolver: resolver}
 }
 
 // EthereumToFabric 将以太坊格式的医疗数据转换为Fabric格式
@@ -28,7 +42,11 @@ func (c *ChainConverter) EthereumToFabric(ethData models.MedicalData) (models.Me
 	fabricData.Chain = "fabric"
 
 	// 转换所有者格式 (从以太坊地址转为Fabric身份)
-	fabricData.Owner = convertEthAddressToFabricID(ethData.Owner)
+	owner, err := c.ConvertOwner(ethData.Owner, "fabric")
+	if err != nil {
+		return models.MedicalData{}, err
+	}
+	fabricData.Owner = owner
 
 	// 转换元数据格式
 	metadata, err := c.convertMetadata(ethData.Metadata, "ethereum", "fabric")
@@ -52,7 +70,11 @@ func (c *ChainConverter) FabricToEthereum(fabricData models.MedicalData) (models
 	ethData.Chain = "ethereum"
 
 	// 转换所有者格式 (从Fabric身份转为以太坊地址)
-	ethData.Owner = convertFabricIDToEthAddress(fabricData.Owner)
+	owner, err := c.ConvertOwner(fabricData.Owner, "ethereum")
+	if err != nil {
+		return models.MedicalData{}, err
+	}
+	ethData.Owner = owner
 
 	// 转换元数据格式
 	metadata, err := c.convertMetadata(fabricData.Metadata, "fabric", "ethereum")
@@ -107,27 +129,36 @@ func (c *ChainConverter) convertMetadata(metadataJSON, sourceChain, targetChain
 	return string(updatedJSON), nil
 }
 
-// 将以太坊地址转换为Fabric ID
-func convertEthAddressToFabricID(ethAddress string) string {
-	// 移除0x前缀
-	if strings.HasPrefix(ethAddress, "0x") {
-		ethAddress = strings.TrimPrefix(ethAddress, "0x")
-	}
-
-	// 为简单起见，我们使用一个固定的前缀
-	return fmt.Sprintf("fabric-user-%s", ethAddress[:8])
-}
-
-// 将Fabric ID转换为以太坊地址
-func convertFabricIDToEthAddress(fabricID string) string {
-	// 为简单起见，我们生成一个模拟的以太坊地址
-	// 在实际应用中，应该使用更复杂的映射或转换逻辑
-	if strings.HasPrefix(fabricID, "fabric-user-") {
-		// 提取用户ID部分
-		userPart := strings.TrimPrefix(fabricID, "fabric-user-")
-		return fmt.Sprintf("0x%s000000000000000000000000", userPart)
+// ConvertOwner 将数据所有者转换为目标链上同一用户的身份
+// DID和网关代为上链的平台用户ID与链无关，原样保留；早期数据的以太坊地址和Fabric身份标识必须在注册表中登记
+func (c *ChainConverter) ConvertOwner(owner, targetChain string) (string, error) {
+	switch {
+	case IsDID(owner):
+		// DID与链无关，两条链上的所有者相同
+		return owner, nil
+	case IsEthereumAddress(owner):
+		if targetChain == "ethereum" {
+			return owner, nil
+		}
+		if c.resolver != nil {
+			if fabricID, ok := c.resolver.FabricIDForEthereum(owner); ok {
+				return fabricID, nil
+			}
+		}
+	case strings.HasPrefix(owner, "x509::"):
+		if targetChain == "fabric" {
+			return owner, nil
+		}
+		if c.resolver != nil {
+			if address, ok := c.resolver.EthereumAddressForFabric(owner); ok {
+				return address, nil
+			}
+		}
+	default:
+		if _, err := uuid.Parse(owner); err == nil {
+			return owner, nil
+		}
 	}
 
-	// 生成一个默认地址
-	return "0x" + fmt.Sprintf("%040x", time.Now().UnixNano())
+	return "", fmt.Errorf("%w: %s", ErrUnknownChainIdentity, owner)
 }
@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DID公钥类型
+const (
+	DIDKeyTypeSecp256k1 = "secp256k1"
+	DIDKeyTypeP256      = "P-256"
+)
+
+// multicodec公钥前缀（无符号varint编码）
+var (
+	multicodecSecp256k1Pub = []byte{0xe7, 0x01}
+	multicodecP256Pub      = []byte{0x80, 0x24}
+)
+
+// DID语法：did:<method>:<method-specific-id>
+var didPattern = regexp.MustCompile(`^did:[a-z0-9]+:[A-Za-z0-9._%:-]+$`)
+
+// base58btc字母表
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// DIDPublicKey 从multibase编码中解析出的公钥
+type DIDPublicKey struct {
+	Type string // DIDKeyTypeSecp256k1 或 DIDKeyTypeP256
+	X    *big.Int
+	Y    *big.Int
+}
+
+// IsDID 检查字符串是否符合DID语法
+func IsDID(s string) bool {
+	return didPattern.MatchString(s)
+}
+
+// Secp256k1Multibase 将secp256k1公钥编码为multibase（base58btc）格式的压缩公钥
+func Secp256k1Multibase(x, y *big.Int) string {
+	return "z" + base58Encode(append(append([]byte{}, multicodecSecp256k1Pub...), compressPoint(x, y)...))
+}
+
+// P256Multibase 将P-256公钥编码为multibase（base58btc）格式的压缩公钥
+func P256Multibase(pub *ecdsa.PublicKey) string {
+	return "z" + base58Encode(append(append([]byte{}, multicodecP256Pub...), elliptic.MarshalCompressed(elliptic.P256(), pub.X, pub.Y)...))
+}
+
+// DIDKey 返回以太坊账户公钥对应的did:key
+func (k *EthereumKey) DIDKey() string {
+	return "did:key:" + Secp256k1Multibase(k.X, k.Y)
+}
+
+// ParseMultibaseKey 解析multibase编码的公钥，支持secp256k1和P-256
+func ParseMultibaseKey(multibase string) (*DIDPublicKey, error) {
+	if !strings.HasPrefix(multibase, "z") {
+		return nil, errors.New("只支持base58btc编码的公钥")
+	}
+	raw, err := base58Decode(multibase[1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != 35 {
+		return nil, errors.New("公钥长度无效")
+	}
+
+	switch {
+	case raw[0] == multicodecSecp256k1Pub[0] && raw[1] == multicodecSecp256k1Pub[1]:
+		x, y, err := decompressSecp256k1(raw[2:])
+		if err != nil {
+			return nil, err
+		}
+		return &DIDPublicKey{Type: DIDKeyTypeSecp256k1, X: x, Y: y}, nil
+	case raw[0] == multicodecP256Pub[0] && raw[1] == multicodecP256Pub[1]:
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), raw[2:])
+		if x == nil {
+			return nil, errors.New("P-256公钥无效")
+		}
+		return &DIDPublicKey{Type: DIDKeyTypeP256, X: x, Y: y}, nil
+	default:
+		return nil, errors.New("不支持的公钥类型")
+	}
+}
+
+// ParseDIDKey 解析did:key中的公钥，返回公钥和multibase编码
+func ParseDIDKey(did string) (*DIDPublicKey, string, error) {
+	if !strings.HasPrefix(did, "did:key:") {
+		return nil, "", errors.New("不是did:key")
+	}
+	multibase := strings.TrimPrefix(did, "did:key:")
+	key, err := ParseMultibaseKey(multibase)
+	if err != nil {
+		return nil, "", err
+	}
+	return key, multibase, nil
+}
+
+// DIDWebURL 按did:web规范将DID转换为DID文档地址
+// did:web:example.com → https://example.com/.well-known/did.json
+// did:web:example.com:hospitals:h1 → https://example.com/hospitals/h1/did.json
+// 本机地址使用http，便于开发环境解析
+func DIDWebURL(did string) (string, error) {
+	if !strings.HasPrefix(did, "did:web:") || !IsDID(did) {
+		return "", errors.New("不是有效的did:web")
+	}
+
+	segments := strings.Split(strings.TrimPrefix(did, "did:web:"), ":")
+	host, err := url.PathUnescape(segments[0])
+	if err != nil || host == "" {
+		return "", errors.New("did:web域名无效")
+	}
+	for i := 1; i < len(segments); i++ {
+		segment, err := url.PathUnescape(segments[i])
+		if err != nil || segment == "" || segment == "." || segment == ".." || strings.Contains(segment, "/") {
+			return "", errors.New("did:web路径无效")
+		}
+		segments[i] = url.PathEscape(segment)
+	}
+
+	scheme := "https"
+	hostname := strings.Split(host, ":")[0]
+	if hostname == "localhost" || hostname == "127.0.0.1" {
+		scheme = "http"
+	}
+
+	path := "/.well-known/did.json"
+	if len(segments) > 1 {
+		path = "/" + strings.Join(segments[1:], "/") + "/did.json"
+	}
+	return scheme + "://" + host + path, nil
+}
+
+// DIDWebID 根据域名（可带端口）和路径生成did:web
+func DIDWebID(domain string, path ...string) string {
+	parts := []string{didWebEscape(domain)}
+	for _, segment := range path {
+		parts = append(parts, didWebEscape(segment))
+	}
+	return "did:web:" + strings.Join(parts, ":")
+}
+
+// did:web中冒号是路径分隔符，域名端口和路径中的冒号需要转义
+func didWebEscape(segment string) string {
+	return strings.ReplaceAll(url.PathEscape(segment), ":", "%3A")
+}
+
+// 压缩格式的secp256k1公钥：前缀0x02/0x03表示y的奇偶
+func compressPoint(x, y *big.Int) []byte {
+	prefix := byte(0x02)
+	if y.Bit(0) == 1 {
+		prefix = 0x03
+	}
+	return append([]byte{prefix}, leftPad(x.Bytes(), 32)...)
+}
+
+// 解压缩secp256k1公钥：y² = x³ + 7，p ≡ 3 (mod 4) 时 y = (y²)^((p+1)/4)
+func decompressSecp256k1(compressed []byte) (*big.Int, *big.Int, error) {
+	if len(compressed) != 33 || (compressed[0] != 0x02 && compressed[0] != 0x03) {
+		return nil, nil, errors.New("secp256k1公钥格式无效")
+	}
+
+	x := new(big.Int).SetBytes(compressed[1:])
+	if x.Cmp(secp256k1P) >= 0 {
+		return nil, nil, errors.New("secp256k1公钥无效")
+	}
+	ySquared := new(big.Int).Exp(x, big.NewInt(3), secp256k1P)
+	ySquared.Add(ySquared, big.NewInt(7))
+	ySquared.Mod(ySquared, secp256k1P)
+	exp := new(big.Int).Add(secp256k1P, big.NewInt(1))
+	exp.Rsh(exp, 2)
+	y := new(big.Int).Exp(ySquared, exp, secp256k1P)
+	if new(big.Int).Exp(y, big.NewInt(2), secp256k1P).Cmp(ySquared) != 0 {
+		return nil, nil, errors.New("secp256k1公钥不在曲线上")
+	}
+	if y.Bit(0) != uint(compressed[0]&1) {
+		y.Sub(secp256k1P, y)
+	}
+	return x, y, nil
+}
+
+// base58btc编码，前导零字节编码为'1'
+func base58Encode(data []byte) string {
+	zeros := 0
+	for zeros < len(data) && data[zeros] == 0 {
+		zeros++
+	}
+
+	n := new(big.Int).SetBytes(data)
+	base := big.NewInt(58)
+	mod := new(big.Int)
+	var encoded []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	for i := 0; i < zeros; i++ {
+		encoded = append(encoded, '1')
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+// base58btc解码
+func base58Decode(s string) ([]byte, error) {
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+
+	n := new(big.Int)
+	base := big.NewInt(58)
+	for _, c := range []byte(s[zeros:]) {
+		index := strings.IndexByte(base58Alphabet, c)
+		if index < 0 {
+			return nil, errors.New("base58编码无效")
+		}
+		n.Mul(n, base)
+		n.Add(n, big.NewInt(int64(index)))
+	}
+
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// secp256k1曲线参数（y² = x³ + 7），标准库的elliptic包只支持a=-3的曲线，这里用math/big实现
+var (
+	secp256k1P, _  = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F", 16)
+	secp256k1N, _  = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+	secp256k1Gx, _ = new(big.Int).SetString("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798", 16)
+	secp256k1Gy, _ = new(big.Int).SetString("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8", 16)
+)
+
+// EthereumKey 以太坊账户私钥（secp256k1）
+type EthereumKey struct {
+	D *big.Int
+	X *big.Int
+	Y *big.Int
+}
+
+// GenerateEthereumKey 生成新的以太坊账户私钥
+func GenerateEthereumKey() (*EthereumKey, error) {
+	max := new(big.Int).Sub(secp256k1N, big.NewInt(1))
+	for {
+		d, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return nil, err
+		}
+		if d.Sign() > 0 {
+			return newEthereumKey(d), nil
+		}
+	}
+}
+
+// EthereumKeyFromHex 从十六进制私钥恢复以太坊账户私钥
+func EthereumKeyFromHex(privateKeyHex string) (*EthereumKey, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(privateKeyHex, "0x"))
+	if err != nil || len(raw) != 32 {
+		return nil, errors.New("以太坊私钥格式无效")
+	}
+
+	d := new(big.Int).SetBytes(raw)
+	if d.Sign() == 0 || d.Cmp(secp256k1N) >= 0 {
+		return nil, errors.New("以太坊私钥超出范围")
+	}
+	return newEthereumKey(d), nil
+}
+
+// Hex 返回32字节私钥的十六进制表示（不带0x前缀）
+func (k *EthereumKey) Hex() string {
+	return hex.EncodeToString(leftPad(k.D.Bytes(), 32))
+}
+
+// Address 返回带EIP-55校验大小写的以太坊地址
+func (k *EthereumKey) Address() string {
+	return EthereumAddressFromPublicKey(k.X, k.Y)
+}
+
+// EthereumAddressFromPublicKey 根据secp256k1公钥计算以太坊地址：Keccak-256(X || Y) 的后20字节
+func EthereumAddressFromPublicKey(x, y *big.Int) string {
+	pub := append(leftPad(x.Bytes(), 32), leftPad(y.Bytes(), 32)...)
+	return ChecksumAddress(Keccak256(pub)[12:])
+}
+
+// ChecksumAddress 按EIP-55生成带校验大小写的地址
+func ChecksumAddress(address []byte) string {
+	lower := hex.EncodeToString(address)
+	hash := hex.EncodeToString(Keccak256([]byte(lower)))
+
+	result := make([]byte, len(lower))
+	for i := 0; i < len(lower); i++ {
+		c := lower[i]
+		if c >= 'a' && c <= 'f' && hash[i] >= '8' {
+			c -= 'a' - 'A'
+		}
+		result[i] = c
+	}
+	return "0x" + string(result)
+}
+
+// IsEthereumAddress 检查字符串是否为0x开头的20字节十六进制地址
+func IsEthereumAddress(address string) bool {
+	if !strings.HasPrefix(address, "0x") || len(address) != 42 {
+		return false
+	}
+	_, err := hex.DecodeString(address[2:])
+	return err == nil
+}
+
+// Keccak256 计算以太坊使用的Keccak-256哈希（与标准SHA3-256填充不同）
+func Keccak256(data ...[]byte) []byte {
+	hasher := sha3.NewLegacyKeccak256()
+	for _, item := range data {
+		hasher.Write(item)
+	}
+	return hasher.Sum(nil)
+}
+
+// 根据私钥计算公钥
+func newEthereumKey(d *big.Int) *EthereumKey {
+	x, y := secp256k1ScalarBaseMult(d)
+	return &EthereumKey{D: d, X: x, Y: y}
+}
+
+// secp256k1ScalarBaseMult 计算 k·G
+func secp256k1ScalarBaseMult(k *big.Int) (*big.Int, *big.Int) {
+	return secp256k1ScalarMult(secp256k1Gx, secp256k1Gy, k)
+}
+
+// secp256k1ScalarMult 使用倍加法计算 k·(x, y)，无穷远点用nil表示
+func secp256k1ScalarMult(x, y, k *big.Int) (*big.Int, *big.Int) {
+	var rx, ry *big.Int
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		rx, ry = secp256k1Add(rx, ry, rx, ry)
+		if k.Bit(i) == 1 {
+			rx, ry = secp256k1Add(rx, ry, x, y)
+		}
+	}
+	return rx, ry
+}
+
+// secp256k1Add 仿射坐标下的点加法（包含倍点），无穷远点用nil表示
+func secp256k1Add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	if x1 == nil {
+		return x2, y2
+	}
+	if x2 == nil {
+		return x1, y1
+	}
+
+	p := secp256k1P
+	var lambda *big.Int
+	if x1.Cmp(x2) == 0 {
+		if y1.Cmp(y2) != 0 || y1.Sign() == 0 {
+			return nil, nil
+		}
+		// λ = 3x² / 2y
+		num := new(big.Int).Mul(x1, x1)
+		num.Mul(num, big.NewInt(3))
+		den := new(big.Int).Lsh(y1, 1)
+		lambda = num.Mul(num, den.ModInverse(den.Mod(den, p), p))
+	} else {
+		// λ = (y2 - y1) / (x2 - x1)
+		num := new(big.Int).Sub(y2, y1)
+		den := new(big.Int).Sub(x2, x1)
+		lambda = num.Mul(num, den.ModInverse(den.Mod(den, p), p))
+	}
+	lambda.Mod(lambda, p)
+
+	x3 := new(big.Int).Mul(lambda, lambda)
+	x3.Sub(x3, x1)
+	x3.Sub(x3, x2)
+	x3.Mod(x3, p)
+
+	y3 := new(big.Int).Sub(x1, x3)
+	y3.Mul(y3, lambda)
+	y3.Sub(y3, y1)
+	y3.Mod(y3, p)
+
+	return x3, y3
+}
+
+// 左侧补零到指定长度
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
+
+// SignEthereumHash 使用私钥对32字节哈希签名，返回65字节的 r || s || v（v为恢复标识0或1）
+// s取低半区间（EIP-2），以太坊节点拒绝高s签名
+func (k *EthereumKey) SignEthereumHash(hash []byte) ([]byte, error) {
+	if len(hash) != 32 {
+		return nil, errors.New("签名哈希必须为32字节")
+	}
+
+	z := new(big.Int).SetBytes(hash)
+	halfN := new(big.Int).Rsh(secp256k1N, 1)
+	max := new(big.Int).Sub(secp256k1N, big.NewInt(1))
+
+	for {
+		nonce, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return nil, err
+		}
+		nonce.Add(nonce, big.NewInt(1))
+
+		rx, ry := secp256k1ScalarBaseMult(nonce)
+		if rx.Cmp(secp256k1N) >= 0 {
+			continue
+		}
+		r := new(big.Int).Set(rx)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		// s = k⁻¹(z + r·d) mod n
+		s := new(big.Int).Mul(r, k.D)
+		s.Add(s, z)
+		s.Mul(s, new(big.Int).ModInverse(nonce, secp256k1N))
+		s.Mod(s, secp256k1N)
+		if s.Sign() == 0 {
+			continue
+		}
+
+		recovery := byte(ry.Bit(0))
+		if s.Cmp(halfN) > 0 {
+			s.Sub(secp256k1N, s)
+			recovery ^= 1
+		}
+
+		signature := make([]byte, 65)
+		copy(signature[0:32], leftPad(r.Bytes(), 32))
+		copy(signature[32:64], leftPad(s.Bytes(), 32))
+		signature[64] = recovery
+		return signature, nil
+	}
+}
+
+// RecoverEthereumAddress 从哈希和65字节签名恢复签名者地址，v可以是0/1或27/28
+func RecoverEthereumAddress(hash, signature []byte) (string, error) {
+	if len(hash) != 32 || len(signature) != 65 {
+		return "", errors.New("签名格式无效")
+	}
+
+	v := signature[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return "", errors.New("签名恢复标识无效")
+	}
+
+	r := new(big.Int).SetBytes(signature[0:32])
+	s := new(big.Int).SetBytes(signature[32:64])
+	if r.Sign() == 0 || r.Cmp(secp256k1N) >= 0 || s.Sign() == 0 || s.Cmp(new(big.Int).Rsh(secp256k1N, 1)) > 0 {
+		return "", errors.New("签名值超出范围")
+	}
+
+	// 由r还原签名时使用的点R：y² = x³ + 7，p ≡ 3 (mod 4) 时 y = (y²)^((p+1)/4)
+	ySquared := new(big.Int).Exp(r, big.NewInt(3), secp256k1P)
+	ySquared.Add(ySquared, big.NewInt(7))
+	ySquared.Mod(ySquared, secp256k1P)
+	exp := new(big.Int).Add(secp256k1P, big.NewInt(1))
+	exp.Rsh(exp, 2)
+	y := new(big.Int).Exp(ySquared, exp, secp256k1P)
+	if new(big.Int).Exp(y, big.NewInt(2), secp256k1P).Cmp(ySquared) != 0 {
+		return "", errors.New("签名无效")
+	}
+	if y.Bit(0) != uint(v) {
+		y.Sub(secp256k1P, y)
+	}
+
+	// Q = r⁻¹(s·R - z·G)
+	rInv := new(big.Int).ModInverse(r, secp256k1N)
+	u1 := new(big.Int).Mul(new(big.Int).SetBytes(hash), rInv)
+	u1.Neg(u1).Mod(u1, secp256k1N)
+	u2 := new(big.Int).Mul(s, rInv)
+	u2.Mod(u2, secp256k1N)
+
+	x1, y1 := secp256k1ScalarBaseMult(u1)
+	x2, y2 := secp256k1ScalarMult(r, y, u2)
+	qx, qy := secp256k1Add(x1, y1, x2, y2)
+	if qx == nil {
+		return "", errors.New("签名无效")
+	}
+
+	return EthereumAddressFromPublicKey(qx, qy), nil
+}
+
+// EthereumMessageHash 按EIP-191（personal_sign）计算消息哈希，钱包可以用同样的方式验证签名
+func EthereumMessageHash(message []byte) []byte {
+	prefix := fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(message))
+	return Keccak256([]byte(prefix), message)
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// EthereumTransaction 以太坊交易（EIP-155 legacy格式）
+type EthereumTransaction struct {
+	Nonce    uint64
+	GasPrice *big.Int
+	GasLimit uint64
+	To       []byte // 20字节合约地址
+	Value    *big.Int
+	Data     []byte
+	ChainID  *big.Int
+}
+
+// SigningHash 返回EIP-155签名哈希：Keccak-256(RLP([nonce, gasPrice, gasLimit, to, value, data, chainId, 0, 0]))
+func (tx *EthereumTransaction) SigningHash() []byte {
+	return Keccak256(RLPEncodeList(
+		RLPEncodeUint(tx.Nonce),
+		RLPEncodeBigInt(tx.GasPrice),
+		RLPEncodeUint(tx.GasLimit),
+		RLPEncodeBytes(tx.To),
+		RLPEncodeBigInt(tx.Value),
+		RLPEncodeBytes(tx.Data),
+		RLPEncodeBigInt(tx.ChainID),
+		RLPEncodeUint(0),
+		RLPEncodeUint(0),
+	))
+}
+
+// EncodeSigned 使用65字节签名（r || s || v，v为0/1或27/28）生成可直接广播的RLP编码交易
+func (tx *EthereumTransaction) EncodeSigned(signature []byte) ([]byte, error) {
+	if len(signature) != 65 {
+		return nil, errors.New("签名必须为65字节")
+	}
+
+	recovery := signature[64]
+	if recovery >= 27 {
+		recovery -= 27
+	}
+	if recovery > 1 {
+		return nil, errors.New("签名恢复标识无效")
+	}
+
+	// EIP-155: v = recovery + chainId*2 + 35
+	v := new(big.Int).Mul(tx.ChainID, big.NewInt(2))
+	v.Add(v, big.NewInt(int64(recovery)+35))
+
+	return RLPEncodeList(
+		RLPEncodeUint(tx.Nonce),
+		RLPEncodeBigInt(tx.GasPrice),
+		RLPEncodeUint(tx.GasLimit),
+		RLPEncodeBytes(tx.To),
+		RLPEncodeBigInt(tx.Value),
+		RLPEncodeBytes(tx.Data),
+		RLPEncodeBigInt(v),
+		RLPEncodeBytes(new(big.Int).SetBytes(signature[0:32]).Bytes()),
+		RLPEncodeBytes(new(big.Int).SetBytes(signature[32:64]).Bytes()),
+	), nil
+}
+
+// RLPEncodeBytes 按RLP编码字节串
+func RLPEncodeBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return []byte{b[0]}
+	}
+	return append(rlpHeader(0x80, len(b)), b...)
+}
+
+// RLPEncodeUint 按RLP编码无符号整数（大端序、去掉前导零）
+func RLPEncodeUint(i uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, i)
+	return RLPEncodeBytes(trimLeadingZeros(buf))
+}
+
+// RLPEncodeBigInt 按RLP编码大整数，nil视为0
+func RLPEncodeBigInt(i *big.Int) []byte {
+	if i == nil {
+		return RLPEncodeBytes(nil)
+	}
+	return RLPEncodeBytes(i.Bytes())
+}
+
+// RLPEncodeList 将已编码的元素拼接为RLP列表
+func RLPEncodeList(items ...[]byte) []byte {
+	var payload []byte
+	for _, item := range items {
+		payload = append(payload, item...)
+	}
+	return append(rlpHeader(0xc0, len(payload)), payload...)
+}
+
+// RLP长度前缀：短于56字节时为 offset+长度，否则为 offset+55+长度的字节数，后跟长度
+func rlpHeader(offset byte, length int) []byte {
+	if length < 56 {
+		return []byte{offset + byte(length)}
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(length))
+	lengthBytes := trimLeadingZeros(buf)
+	return append([]byte{offset + 55 + byte(len(lengthBytes))}, lengthBytes...)
+}
+
+// 去掉前导零
+func trimLeadingZeros(b []byte) []byte {
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	return b
+}
+
+// EncodeABICall 按Solidity ABI编码合约调用，signature形如 uploadData(string,string)
+// 参数支持string、[]byte（bytes）、[32]byte（bytes32）、uint64和*big.Int（uint256）
+func EncodeABICall(signature string, args ...interface{}) ([]byte, error) {
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return nil, errors.New("函数签名格式无效")
+	}
+
+	selector := Keccak256([]byte(signature))[:4]
+
+	// 静态参数直接写入头部，动态参数在头部写入偏移量，内容追加在尾部
+	head := make([]byte, 0, 32*len(args))
+	var tail []byte
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			head = append(head, abiWord(big.NewInt(int64(32*len(args)+len(tail))))...)
+			tail = append(tail, abiDynamicBytes([]byte(v))...)
+		case []byte:
+			head = append(head, abiWord(big.NewInt(int64(32*len(args)+len(tail))))...)
+			tail = append(tail, abiDynamicBytes(v)...)
+		case [32]byte:
+			head = append(head, v[:]...)
+		case uint64:
+			head = append(head, abiWord(new(big.Int).SetUint64(v))...)
+		case *big.Int:
+			if v.Sign() < 0 {
+				return nil, errors.New("不支持负数参数")
+			}
+			head = append(head, abiWord(v)...)
+		default:
+			return nil, errors.New("不支持的ABI参数类型")
+		}
+	}
+
+	return append(append(selector, head...), tail...), nil
+}
+
+// 32字节左补零的整数
+func abiWord(i *big.Int) []byte {
+	return leftPad(i.Bytes(), 32)
+}
+
+// 动态字节串：长度 + 右补零到32字节倍数的内容
+func abiDynamicBytes(b []byte) []byte {
+	encoded := abiWord(big.NewInt(int64(len(b))))
+	padded := make([]byte, (len(b)+31)/32*32)
+	copy(padded, b)
+	return append(encoded, padded...)
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// DataFilePath 返回数据目录下指定文件的路径
+// 数据目录由环境变量DATA_DIR指定，默认为./data
+func DataFilePath(name string) string {
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "./data"
+	}
+	return filepath.Join(dataDir, name)
+}
+
+// SaveJSONFile 将数据以JSON格式写入文件
+// 先写入临时文件再重命名，避免写入中断导致文件损坏
+func SaveJSONFile(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// LoadJSONFile 从JSON文件读取数据
+// 文件不存在时不返回错误，v保持原值
+func LoadJSONFile(path string, v interface{}) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	return json.Unmarshal(content, v)
+}
+
+// AppendJSONLine 以JSON Lines格式向文件末尾追加一条记录
+// 写入后立即同步到磁盘，适用于只追加的日志文件
+func AppendJSONLine(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(content, '\n')); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// LoadJSONLines 逐行读取JSON Lines文件，每行调用一次handle
+// 文件不存在时不返回错误
+func LoadJSONLines(path string, handle func(line []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if err := handle(line); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
@@ -1,58 +1,118 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 
 	"medcross/models"
 )
 
-// GenerateJWT 生成JWT令牌
-func GenerateJWT(user *models.User) (string, error) {
-	// 获取JWT密钥
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "your-secret-key-change-in-production"
+// AccessTokenTTL 访问令牌有效期
+// 由环境变量ACCESS_TOKEN_TTL指定，默认15分钟
+func AccessTokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return 15 * time.Minute
+}
+
+// TokenIssuer 访问令牌的签发者（iss）
+// 由环境变量JWT_ISSUER指定，默认medcross
+func TokenIssuer() string {
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "medcross"
+}
+
+// TokenAudience 访问令牌的受众（aud）
+// 由环境变量JWT_AUDIENCE指定，默认medcross-api
+func TokenAudience() string {
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		return audience
+	}
+	return "medcross-api"
+}
+
+// GenerateJWT 生成短期访问令牌
+// 使用密钥库中的当前密钥签名，头部kid标识签名密钥；sid声明关联登录会话，会话被撤销后令牌立即失效。
+// scope非空时写入scope声明，表示受限令牌
+func GenerateJWT(user *models.User, sessionID, scope string) (string, error) {
+	ks := CurrentKeyStore()
+	if ks == nil {
+		return "", ErrNoSigningKey
+	}
+	key, err := ks.SigningKey()
+	if err != nil {
+		return "", err
 	}
 
 	// 创建JWT声明
+	now := time.Now()
 	claims := jwt.MapClaims{
+		"iss":  TokenIssuer(),
+		"aud":  TokenAudience(),
 		"sub":  user.ID,
 		"name": user.Name,
 		"role": user.Role,
-		"exp":  time.Now().Add(time.Hour * 24).Unix(), // 24小时过期
+		"sid":  sessionID,
+		"jti":  uuid.New().String(),
+		"iat":  now.Unix(),
+		"exp":  now.Add(AccessTokenTTL()).Unix(),
+	}
+	if scope != "" {
+		claims["scope"] = scope
 	}
 
 	// 创建令牌
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Alg), claims)
+	token.Header["kid"] = key.Kid
 
 	// 签名令牌
-	tokenString, err := token.SignedString([]byte(jwtSecret))
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
+	return token.SignedString(key.Private)
 }
 
 // ParseJWT 解析JWT令牌
+// 根据头部kid查找验证密钥，签名算法必须与密钥一致；iss和aud必须与本服务的配置一致
 func ParseJWT(tokenString string) (*jwt.Token, error) {
-	// 获取JWT密钥
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "your-secret-key-change-in-production"
+	ks := CurrentKeyStore()
+	if ks == nil {
+		return nil, ErrNoSigningKey
 	}
 
 	// 解析令牌
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.VerificationKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("未知的签名密钥: %s", kid)
+		}
+
 		// 验证签名算法
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if token.Method.Alg() != key.Alg {
 			return nil, jwt.ErrSignatureInvalid
 		}
-		return []byte(jwtSecret), nil
-	})
+		return key.Public(), nil
+	},
+		jwt.WithValidMethods([]string{SigningAlgEdDSA, SigningAlgRS256}),
+		jwt.WithIssuer(TokenIssuer()),
+		jwt.WithAudience(TokenAudience()),
+	)
 
 	return token, err
 }
+
+// GenerateRandomToken 生成指定字节数的随机令牌（URL安全的Base64编码）
+func GenerateRandomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
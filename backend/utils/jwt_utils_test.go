@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"testing"
+
+	"medcross/models"
+)
+
+func TestParseJWTValidatesIssuerAndAudience(t *testing.T) {
+	t.Setenv("JWT_KEYS_DIR", t.TempDir())
+	if _, err := InitKeyStore(true); err != nil {
+		t.Fatalf("初始化密钥库失败: %v", err)
+	}
+
+	user := &models.User{ID: "u-1", Name: "测试用户", Role: models.RoleDoctor}
+
+	tests := []struct {
+		name     string
+		issuer   string // 验证时的JWT_ISSUER
+		audience string // 验证时的JWT_AUDIENCE
+		wantErr  bool
+	}{
+		{name: "签发者和受众一致"},
+		{name: "签发者不一致", issuer: "other-issuer", wantErr: true},
+		{name: "受众不一致", audience: "other-api", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_ISSUER", "")
+			t.Setenv("JWT_AUDIENCE", "")
+			token, err := GenerateJWT(user, "s-1", "")
+			if err != nil {
+				t.Fatalf("生成令牌失败: %v", err)
+			}
+
+			t.Setenv("JWT_ISSUER", tt.issuer)
+			t.Setenv("JWT_AUDIENCE", tt.audience)
+			_, err = ParseJWT(token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseJWT 错误 = %v, 期望出错 %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,517 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 支持的签名算法
+const (
+	SigningAlgEdDSA = "EdDSA"
+	SigningAlgRS256 = "RS256"
+)
+
+// 密钥库清单文件名
+const keyManifestFile = "keys.json"
+
+// ErrNoSigningKey 未配置签名密钥
+var ErrNoSigningKey = errors.New("未配置JWT签名密钥")
+
+// SigningKey JWT签名密钥
+type SigningKey struct {
+	Kid        string
+	Alg        string
+	Private    crypto.Signer
+	CreatedAt  time.Time
+	RetiredAt  *time.Time // 轮换后停止签发的时间，重叠期内仍可用于验证
+	Persistent bool       // 是否已写入密钥库目录
+}
+
+// Public 返回公钥
+func (k *SigningKey) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+// JWK JSON Web Key（仅公钥部分）
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// PublicKey 将JWK解析为公钥，支持RSA、P-256椭圆曲线和Ed25519
+// 用于验证外部身份提供方签发的令牌
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch j.Kty {
+	case "RSA":
+		n, err := decode(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("RSA模数格式无效: %w", err)
+		}
+		e, err := decode(j.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("RSA指数格式无效")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("不支持的椭圆曲线: %s", j.Crv)
+		}
+		x, err := decode(j.X)
+		if err != nil {
+			return nil, fmt.Errorf("椭圆曲线公钥格式无效: %w", err)
+		}
+		y, err := decode(j.Y)
+		if err != nil {
+			return nil, fmt.Errorf("椭圆曲线公钥格式无效: %w", err)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("椭圆曲线公钥不在曲线上")
+		}
+		return pub, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("不支持的曲线: %s", j.Crv)
+		}
+		x, err := decode(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("Ed25519公钥格式无效")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("不支持的密钥类型: %s", j.Kty)
+}
+
+// JWKSet JSON Web Key Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyInfo 密钥元数据（不包含私钥）
+type KeyInfo struct {
+	Kid       string     `json:"kid"`
+	Alg       string     `json:"alg"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RetiredAt *time.Time `json:"retiredAt,omitempty"`
+	Current   bool       `json:"current"`
+}
+
+// keyManifest 密钥库清单
+type keyManifest struct {
+	Current string             `json:"current"`
+	Keys    []keyManifestEntry `json:"keys"`
+}
+
+// keyManifestEntry 清单中的单个密钥
+type keyManifestEntry struct {
+	Kid       string     `json:"kid"`
+	Alg       string     `json:"alg"`
+	File      string     `json:"file"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RetiredAt *time.Time `json:"retiredAt,omitempty"`
+}
+
+// KeyStore JWT签名密钥库
+// 密钥以PKCS#8 PEM文件保存在密钥目录中，keys.json 记录当前签名密钥和已轮换的旧密钥。
+// 旧密钥在轮换后的重叠期内仍用于验证并出现在JWKS中，过期后移除
+type KeyStore struct {
+	mu      sync.RWMutex
+	dir     string
+	overlap time.Duration
+	keys    map[string]*SigningKey
+	current string
+}
+
+// 全局密钥库，由InitKeyStore初始化
+var (
+	keyStoreMu     sync.RWMutex
+	activeKeyStore *KeyStore
+)
+
+// InitKeyStore 从JWT_KEYS_DIR（默认./keys）加载密钥库并设为全局密钥库
+// 目录中没有密钥时，allowEphemeral为true则生成仅存在于内存的临时密钥，否则返回错误
+func InitKeyStore(allowEphemeral bool) (*KeyStore, error) {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		dir = "./keys"
+	}
+
+	overlap := time.Hour
+	if d, err := time.ParseDuration(os.Getenv("JWT_KEY_OVERLAP")); err == nil && d > 0 {
+		overlap = d
+	}
+	if overlap < AccessTokenTTL() {
+		overlap = AccessTokenTTL()
+	}
+
+	ks, err := LoadKeyStore(dir, overlap)
+	if err != nil {
+		return nil, err
+	}
+
+	if ks.current == "" {
+		if !allowEphemeral {
+			return nil, fmt.Errorf("%w: 请使用 cmd/keygen 在 %s 中生成密钥", ErrNoSigningKey, dir)
+		}
+		key, err := GenerateSigningKey(SigningAlgEdDSA)
+		if err != nil {
+			return nil, err
+		}
+		ks.keys[key.Kid] = key
+		ks.current = key.Kid
+		log.Printf("警告: %s 中没有JWT签名密钥，已生成临时密钥（重启后已签发的令牌全部失效）", dir)
+	}
+
+	keyStoreMu.Lock()
+	activeKeyStore = ks
+	keyStoreMu.Unlock()
+
+	return ks, nil
+}
+
+// CurrentKeyStore 返回全局密钥库
+func CurrentKeyStore() *KeyStore {
+	keyStoreMu.RLock()
+	defer keyStoreMu.RUnlock()
+
+	return activeKeyStore
+}
+
+// LoadKeyStore 从目录加载密钥库，目录或清单不存在时返回空密钥库
+func LoadKeyStore(dir string, overlap time.Duration) (*KeyStore, error) {
+	ks := &KeyStore{
+		dir:     dir,
+		overlap: overlap,
+		keys:    make(map[string]*SigningKey),
+	}
+
+	var manifest keyManifest
+	if err := LoadJSONFile(filepath.Join(dir, keyManifestFile), &manifest); err != nil {
+		return nil, fmt.Errorf("读取密钥清单失败: %w", err)
+	}
+
+	for _, entry := range manifest.Keys {
+		content, err := os.ReadFile(filepath.Join(dir, entry.File))
+		if err != nil {
+			return nil, fmt.Errorf("读取密钥文件失败 %s: %w", entry.File, err)
+		}
+
+		signer, err := parsePrivateKeyPEM(content)
+		if err != nil {
+			return nil, fmt.Errorf("解析密钥文件失败 %s: %w", entry.File, err)
+		}
+		if alg := signingAlgFor(signer); alg != entry.Alg {
+			return nil, fmt.Errorf("密钥 %s 的算法与清单不一致: %s", entry.Kid, entry.Alg)
+		}
+
+		ks.keys[entry.Kid] = &SigningKey{
+			Kid:        entry.Kid,
+			Alg:        entry.Alg,
+			Private:    signer,
+			CreatedAt:  entry.CreatedAt,
+			RetiredAt:  entry.RetiredAt,
+			Persistent: true,
+		}
+	}
+
+	if manifest.Current != "" {
+		key, exists := ks.keys[manifest.Current]
+		if !exists || key.RetiredAt != nil {
+			return nil, fmt.Errorf("密钥清单中的当前密钥无效: %s", manifest.Current)
+		}
+		ks.current = manifest.Current
+	}
+
+	return ks, nil
+}
+
+// SigningKey 返回当前签名密钥
+func (ks *KeyStore) SigningKey() (*SigningKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, exists := ks.keys[ks.current]
+	if !exists {
+		return nil, ErrNoSigningKey
+	}
+	return key, nil
+}
+
+// VerificationKey 根据kid返回可用于验证的密钥
+// 已轮换且超过重叠期的密钥不再接受
+func (ks *KeyStore) VerificationKey(kid string) (*SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, exists := ks.keys[kid]
+	if !exists || !ks.verifiableLocked(key, time.Now()) {
+		return nil, false
+	}
+	return key, true
+}
+
+// JWKS 返回仍可用于验证的公钥集合
+func (ks *KeyStore) JWKS() JWKSet {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range ks.sortedKeysLocked() {
+		if ks.verifiableLocked(key, now) {
+			set.Keys = append(set.Keys, publicJWK(key))
+		}
+	}
+	return set
+}
+
+// ListKeys 返回仍可用于验证的密钥元数据
+func (ks *KeyStore) ListKeys() []KeyInfo {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	now := time.Now()
+	infos := make([]KeyInfo, 0, len(ks.keys))
+	for _, key := range ks.sortedKeysLocked() {
+		if !ks.verifiableLocked(key, now) {
+			continue
+		}
+		infos = append(infos, KeyInfo{
+			Kid:       key.Kid,
+			Alg:       key.Alg,
+			CreatedAt: key.CreatedAt,
+			RetiredAt: key.RetiredAt,
+			Current:   key.Kid == ks.current,
+		})
+	}
+	return infos
+}
+
+// Rotate 生成新的签名密钥并设为当前密钥
+// 原密钥停止签发，但在重叠期内仍可验证已签发的令牌
+func (ks *KeyStore) Rotate(alg string) (*SigningKey, error) {
+	key, err := GenerateSigningKey(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if err := writePrivateKeyPEM(filepath.Join(ks.dir, key.Kid+".pem"), key.Private); err != nil {
+		return nil, err
+	}
+	key.Persistent = true
+
+	now := time.Now()
+	previous := ks.keys[ks.current]
+	if previous != nil {
+		previous.RetiredAt = &now
+	}
+	ks.keys[key.Kid] = key
+	previousCurrent := ks.current
+	ks.current = key.Kid
+
+	if err := ks.saveManifestLocked(now); err != nil {
+		// 回滚内存状态
+		delete(ks.keys, key.Kid)
+		ks.current = previousCurrent
+		if previous != nil {
+			previous.RetiredAt = nil
+		}
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// GenerateSigningKey 生成新的签名密钥，kid为公钥的RFC 7638指纹
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	var signer crypto.Signer
+	switch alg {
+	case SigningAlgEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		signer = private
+	case SigningAlgRS256:
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		signer = private
+	default:
+		return nil, fmt.Errorf("不支持的签名算法: %s", alg)
+	}
+
+	key := &SigningKey{
+		Alg:       alg,
+		Private:   signer,
+		CreatedAt: time.Now(),
+	}
+	key.Kid = jwkThumbprint(publicJWK(key))
+
+	return key, nil
+}
+
+// 检查密钥在指定时间是否仍可用于验证，调用方需持有锁
+func (ks *KeyStore) verifiableLocked(key *SigningKey, at time.Time) bool {
+	return key.RetiredAt == nil || at.Before(key.RetiredAt.Add(ks.overlap))
+}
+
+// 按创建时间倒序返回密钥，调用方需持有锁
+func (ks *KeyStore) sortedKeysLocked() []*SigningKey {
+	keys := make([]*SigningKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// 写入密钥清单，已超过重叠期的旧密钥从清单和目录中移除，调用方需持有锁
+func (ks *KeyStore) saveManifestLocked(now time.Time) error {
+	manifest := keyManifest{Current: ks.current}
+	for _, key := range ks.sortedKeysLocked() {
+		if !key.Persistent {
+			continue
+		}
+		if !ks.verifiableLocked(key, now) {
+			delete(ks.keys, key.Kid)
+			os.Remove(filepath.Join(ks.dir, key.Kid+".pem"))
+			continue
+		}
+		manifest.Keys = append(manifest.Keys, keyManifestEntry{
+			Kid:       key.Kid,
+			Alg:       key.Alg,
+			File:      key.Kid + ".pem",
+			CreatedAt: key.CreatedAt,
+			RetiredAt: key.RetiredAt,
+		})
+	}
+
+	return SaveJSONFile(filepath.Join(ks.dir, keyManifestFile), manifest)
+}
+
+// SaveNewKeyStore 在目录中写入只包含一个密钥的新密钥库，供密钥生成工具使用
+func SaveNewKeyStore(dir string, key *SigningKey) error {
+	ks := &KeyStore{
+		dir:     dir,
+		keys:    map[string]*SigningKey{key.Kid: key},
+		current: key.Kid,
+	}
+	if err := writePrivateKeyPEM(filepath.Join(dir, key.Kid+".pem"), key.Private); err != nil {
+		return err
+	}
+	key.Persistent = true
+	return ks.saveManifestLocked(time.Now())
+}
+
+// 生成公钥的JWK表示
+func publicJWK(key *SigningKey) JWK {
+	jwk := JWK{Kid: key.Kid, Alg: key.Alg, Use: "sig"}
+	switch pub := key.Public().(type) {
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	}
+	return jwk
+}
+
+// 计算RFC 7638 JWK指纹
+func jwkThumbprint(jwk JWK) string {
+	var canonical string
+	switch jwk.Kty {
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, jwk.Crv, jwk.X)
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// 根据私钥类型返回签名算法
+func signingAlgFor(signer crypto.Signer) string {
+	switch signer.(type) {
+	case ed25519.PrivateKey:
+		return SigningAlgEdDSA
+	case *rsa.PrivateKey:
+		return SigningAlgRS256
+	}
+	return ""
+}
+
+// 解析PKCS#8 PEM私钥
+func parsePrivateKeyPEM(content []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("不是PKCS#8 PEM私钥")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok || signingAlgFor(signer) == "" {
+		return nil, errors.New("不支持的私钥类型，仅支持Ed25519和RSA")
+	}
+	return signer, nil
+}
+
+// 以PKCS#8 PEM格式写入私钥文件
+func writePrivateKeyPEM(path string, signer crypto.Signer) error {
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	content := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return os.WriteFile(path, content, 0600)
+}
+
+// MarshalJSON 避免私钥被意外序列化
+func (k *SigningKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(KeyInfo{Kid: k.Kid, Alg: k.Alg, CreatedAt: k.CreatedAt, RetiredAt: k.RetiredAt})
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJWKThumbprint(t *testing.T) {
+	cases := []struct {
+		name string
+		jwk  JWK
+		want string
+	}{
+		{
+			// RFC 7638 第3.1节示例
+			name: "RSA",
+			jwk: JWK{
+				Kty: "RSA",
+				N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+				E:   "AQAB",
+			},
+			want: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			// RFC 8037 附录A.3示例
+			name: "Ed25519",
+			jwk: JWK{
+				Kty: "OKP",
+				Crv: "Ed25519",
+				X:   "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+			},
+			want: "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+
+	for _, tc := range cases {
+		// kid、alg、use不参与指纹计算
+		tc.jwk.Kid, tc.jwk.Alg, tc.jwk.Use = "ignored", "ignored", "sig"
+		if got := jwkThumbprint(tc.jwk); got != tc.want {
+			t.Errorf("%s: 指纹 = %s, 期望 %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestGenerateSigningKey(t *testing.T) {
+	for _, alg := range []string{SigningAlgEdDSA, SigningAlgRS256} {
+		key, err := GenerateSigningKey(alg)
+		if err != nil {
+			t.Fatalf("%s: 生成密钥失败: %v", alg, err)
+		}
+		if key.Alg != alg {
+			t.Errorf("%s: 算法 = %s", alg, key.Alg)
+		}
+
+		jwk := publicJWK(key)
+		if key.Kid != jwkThumbprint(jwk) {
+			t.Errorf("%s: kid不是公钥指纹", alg)
+		}
+
+		// JWK应能还原出相同的公钥
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			t.Fatalf("%s: 解析JWK失败: %v", alg, err)
+		}
+		if !key.Private.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(pub) {
+			t.Errorf("%s: JWK还原的公钥不一致", alg)
+		}
+	}
+
+	if _, err := GenerateSigningKey("HS256"); err == nil {
+		t.Error("不支持的算法应返回错误")
+	}
+}
+
+func TestKeyStoreRotate(t *testing.T) {
+	dir := t.TempDir()
+
+	initial, err := GenerateSigningKey(SigningAlgEdDSA)
+	if err != nil {
+		t.Fatalf("生成密钥失败: %v", err)
+	}
+	if err := SaveNewKeyStore(dir, initial); err != nil {
+		t.Fatalf("保存密钥库失败: %v", err)
+	}
+
+	ks, err := LoadKeyStore(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("加载密钥库失败: %v", err)
+	}
+	if current, _ := ks.SigningKey(); current == nil || current.Kid != initial.Kid {
+		t.Fatalf("当前密钥应为初始密钥")
+	}
+
+	rotated, err := ks.Rotate(SigningAlgRS256)
+	if err != nil {
+		t.Fatalf("轮换密钥失败: %v", err)
+	}
+
+	// 重新加载应得到相同的状态
+	reloaded, err := LoadKeyStore(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("重新加载密钥库失败: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		ks   *KeyStore
+	}{
+		{"轮换后", ks},
+		{"重新加载后", reloaded},
+	}
+	verifiers := []string{rotated.Kid, initial.Kid}
+
+	for _, tc := range cases {
+		current, err := tc.ks.SigningKey()
+		if err != nil || current.Kid != rotated.Kid {
+			t.Errorf("%s: 当前密钥 = %v, 期望 %s", tc.name, current, rotated.Kid)
+		}
+		for _, kid := range verifiers {
+			if _, ok := tc.ks.VerificationKey(kid); !ok {
+				t.Errorf("%s: 重叠期内密钥 %s 应可用于验证", tc.name, kid)
+			}
+		}
+		if got := len(tc.ks.JWKS().Keys); got != len(verifiers) {
+			t.Errorf("%s: JWKS包含 %d 个密钥, 期望 %d", tc.name, got, len(verifiers))
+		}
+		if _, ok := tc.ks.VerificationKey("unknown"); ok {
+			t.Errorf("%s: 未知kid不应可用于验证", tc.name)
+		}
+	}
+}
+
+func TestKeyStoreRotateAfterOverlap(t *testing.T) {
+	dir := t.TempDir()
+
+	initial, err := GenerateSigningKey(SigningAlgEdDSA)
+	if err != nil {
+		t.Fatalf("生成密钥失败: %v", err)
+	}
+	if err := SaveNewKeyStore(dir, initial); err != nil {
+		t.Fatalf("保存密钥库失败: %v", err)
+	}
+
+	// 重叠期为0，旧密钥轮换后立即失效
+	ks, err := LoadKeyStore(dir, 0)
+	if err != nil {
+		t.Fatalf("加载密钥库失败: %v", err)
+	}
+	second, err := ks.Rotate(SigningAlgEdDSA)
+	if err != nil {
+		t.Fatalf("轮换密钥失败: %v", err)
+	}
+	if _, ok := ks.VerificationKey(initial.Kid); ok {
+		t.Error("超过重叠期的旧密钥不应可用于验证")
+	}
+	if keys := ks.ListKeys(); len(keys) != 1 || keys[0].Kid != second.Kid || !keys[0].Current {
+		t.Errorf("密钥列表 = %+v, 期望只有当前密钥 %s", keys, second.Kid)
+	}
+
+	// 下一次轮换时过期密钥从目录中删除
+	if _, err := ks.Rotate(SigningAlgEdDSA); err != nil {
+		t.Fatalf("轮换密钥失败: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, initial.Kid+".pem")); !os.IsNotExist(err) {
+		t.Errorf("过期密钥文件应被删除: %v", err)
+	}
+}
+
+func TestLoadKeyStoreRejectsInvalidCurrent(t *testing.T) {
+	dir := t.TempDir()
+	if err := SaveJSONFile(filepath.Join(dir, keyManifestFile), keyManifest{Current: "missing"}); err != nil {
+		t.Fatalf("写入清单失败: %v", err)
+	}
+	if _, err := LoadKeyStore(dir, time.Hour); err == nil {
+		t.Error("当前密钥不存在时应返回错误")
+	}
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// Merkle树实现遵循RFC 6962（Certificate Transparency）的约定：
+// 叶子哈希为 SHA-256(0x00 || 数据)，内部节点哈希为 SHA-256(0x01 || 左 || 右)，
+// n个叶子的树在小于n的最大2的幂处划分左右子树。前缀区分叶子和内部节点，防止第二原像攻击。
+
+// MerkleLeafHash 计算叶子哈希
+func MerkleLeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// MerkleNodeHash 计算内部节点哈希
+func MerkleNodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// MerkleRoot 根据叶子哈希计算Merkle根
+// 空树的根为空串的SHA-256
+func MerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	}
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+
+	k := largestPowerOfTwoBelow(len(leaves))
+	return MerkleNodeHash(MerkleRoot(leaves[:k]), MerkleRoot(leaves[k:]))
+}
+
+// MerkleProof 生成第index个叶子的包含证明，证明从叶子侧到根侧排列
+func MerkleProof(leaves [][]byte, index int) [][]byte {
+	if index < 0 || index >= len(leaves) || len(leaves) == 1 {
+		return [][]byte{}
+	}
+
+	k := largestPowerOfTwoBelow(len(leaves))
+	if index < k {
+		return append(MerkleProof(leaves[:k], index), MerkleRoot(leaves[k:]))
+	}
+	return append(MerkleProof(leaves[k:], index-k), MerkleRoot(leaves[:k]))
+}
+
+// VerifyMerkleProof 校验包含证明
+// leafHash 为叶子哈希，index 为叶子序号（从0开始），size 为树的叶子数量
+func VerifyMerkleProof(leafHash []byte, index, size int, proof [][]byte, root []byte) bool {
+	if index < 0 || index >= size {
+		return false
+	}
+
+	// 按RFC 9162第2.1.3.2节的算法自底向上计算根
+	fn, sn := index, size-1
+	hash := leafHash
+	for _, sibling := range proof {
+		if sn == 0 {
+			return false
+		}
+		if fn%2 == 1 || fn == sn {
+			hash = MerkleNodeHash(sibling, hash)
+			for fn%2 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			hash = MerkleNodeHash(hash, sibling)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	return sn == 0 && bytes.Equal(hash, root)
+}
+
+// 返回小于n的最大2的幂（n > 1）
+func largestPowerOfTwoBelow(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// RFC 6962测试向量，来自Certificate Transparency参考实现的merkle_tree_test
+var rfc6962Leaves = [][]byte{
+	{},
+	{0x00},
+	{0x10},
+	{0x20, 0x21},
+	{0x30, 0x31},
+	{0x40, 0x41, 0x42, 0x43},
+	{0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57},
+	{0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6a, 0x6b, 0x6c, 0x6d, 0x6e, 0x6f},
+}
+
+// 前n个叶子组成的树的根，下标为n-1
+var rfc6962Roots = []string{
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+// 包含证明测试向量（叶子序号从0开始）
+var rfc6962Proofs = []struct {
+	index int
+	size  int
+	proof []string
+}{
+	{0, 1, nil},
+	{0, 8, []string{
+		"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+	}},
+	{5, 8, []string{
+		"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	}},
+	{2, 3, []string{
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	}},
+	{1, 5, []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+		"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+	}},
+}
+
+func rfc6962LeafHashes(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		leaves[i] = MerkleLeafHash(rfc6962Leaves[i])
+	}
+	return leaves
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("解析测试向量失败: %v", err)
+	}
+	return b
+}
+
+func TestMerkleRootEmpty(t *testing.T) {
+	want := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got := hex.EncodeToString(MerkleRoot(nil)); got != want {
+		t.Errorf("空树的根 = %s, 期望 %s", got, want)
+	}
+}
+
+func TestMerkleRoot(t *testing.T) {
+	for i, want := range rfc6962Roots {
+		size := i + 1
+		if got := hex.EncodeToString(MerkleRoot(rfc6962LeafHashes(size))); got != want {
+			t.Errorf("size=%d: 根 = %s, 期望 %s", size, got, want)
+		}
+	}
+}
+
+func TestMerkleProof(t *testing.T) {
+	for _, tc := range rfc6962Proofs {
+		leaves := rfc6962LeafHashes(tc.size)
+		root := mustDecodeHex(t, rfc6962Roots[tc.size-1])
+
+		proof := MerkleProof(leaves, tc.index)
+		if len(proof) != len(tc.proof) {
+			t.Fatalf("index=%d size=%d: 证明长度 = %d, 期望 %d", tc.index, tc.size, len(proof), len(tc.proof))
+		}
+		for i, want := range tc.proof {
+			if !bytes.Equal(proof[i], mustDecodeHex(t, want)) {
+				t.Errorf("index=%d size=%d: 证明第%d项 = %x, 期望 %s", tc.index, tc.size, i, proof[i], want)
+			}
+		}
+
+		if !VerifyMerkleProof(leaves[tc.index], tc.index, tc.size, proof, root) {
+			t.Errorf("index=%d size=%d: 有效证明校验失败", tc.index, tc.size)
+		}
+	}
+}
+
+func TestVerifyMerkleProofRejects(t *testing.T) {
+	for _, tc := range rfc6962Proofs {
+		if len(tc.proof) == 0 {
+			continue
+		}
+		leaves := rfc6962LeafHashes(tc.size)
+		root := mustDecodeHex(t, rfc6962Roots[tc.size-1])
+		proof := make([][]byte, len(tc.proof))
+		for i, p := range tc.proof {
+			proof[i] = mustDecodeHex(t, p)
+		}
+
+		tampered := make([][]byte, len(proof))
+		copy(tampered, proof)
+		tampered[0] = MerkleLeafHash([]byte("tampered"))
+
+		cases := []struct {
+			name  string
+			leaf  []byte
+			index int
+			size  int
+			proof [][]byte
+		}{
+			{"错误的叶子", MerkleLeafHash([]byte("other")), tc.index, tc.size, proof},
+			{"错误的序号", leaves[tc.index], (tc.index + 1) % tc.size, tc.size, proof},
+			{"错误的大小", leaves[tc.index], tc.index, tc.size * 2, proof},
+			{"篡改的证明", leaves[tc.index], tc.index, tc.size, tampered},
+			{"截断的证明", leaves[tc.index], tc.index, tc.size, proof[:len(proof)-1]},
+			{"越界的序号", leaves[tc.index], tc.size, tc.size, proof},
+		}
+		for _, c := range cases {
+			if VerifyMerkleProof(c.leaf, c.index, c.size, c.proof, root) {
+				t.Errorf("index=%d size=%d: %s 不应通过校验", tc.index, tc.size, c.name)
+			}
+		}
+	}
+}
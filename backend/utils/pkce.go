@@ -0,0 +1,12 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// PKCEChallengeS256 根据code_verifier计算S256方式的code_challenge（RFC 7636）
+func PKCEChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// sealedSecretPrefix 加密后的密文前缀，没有前缀的值视为尚未加密的旧数据
+const sealedSecretPrefix = "v1:"
+
+// ErrSecretKeyMissing 没有配置加密密钥
+var ErrSecretKeyMissing = errors.New("没有配置加密密钥")
+
+// SecretCipher 使用AES-256-GCM加密保存在磁盘上的短密钥（如TOTP密钥）
+type SecretCipher struct {
+	aead cipher.AEAD
+}
+
+// NewSecretCipherFromEnv 从环境变量读取Base64编码的32字节密钥
+// 没有配置时，allowDevKey为true则使用固定的开发密钥，否则返回错误
+func NewSecretCipherFromEnv(name string, allowDevKey bool) (*SecretCipher, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		if !allowDevKey {
+			return nil, fmt.Errorf("%w: 请在 %s 中配置Base64编码的32字节密钥（如 openssl rand -base64 32）", ErrSecretKeyMissing, name)
+		}
+		log.Printf("警告: 没有配置 %s，使用开发密钥加密（不能用于生产环境）", name)
+		key := sha256.Sum256([]byte("medcross-dev-" + name))
+		return NewSecretCipher(key[:])
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s 必须是Base64编码的32字节密钥", name)
+	}
+	return NewSecretCipher(key)
+}
+
+// NewSecretCipher 使用32字节密钥创建加密器
+func NewSecretCipher(key []byte) (*SecretCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretCipher{aead: aead}, nil
+}
+
+// Seal 加密明文，aad为附加认证数据（如用户ID），解密时必须一致
+func (c *SecretCipher) Seal(plaintext, aad string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(aad))
+	return sealedSecretPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open 解密Seal的结果
+func (c *SecretCipher) Open(sealed, aad string) (string, error) {
+	if !IsSealedSecret(sealed) {
+		return "", errors.New("密文格式无效")
+	}
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedSecretPrefix))
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return "", errors.New("密文格式无效")
+	}
+
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(aad))
+	if err != nil {
+		return "", errors.New("解密失败，密钥不匹配或密文已被篡改")
+	}
+	return string(plaintext), nil
+}
+
+// IsSealedSecret 检查值是否为Seal生成的密文
+func IsSealedSecret(value string) bool {
+	return strings.HasPrefix(value, sealedSecretPrefix)
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSecretCipherSealOpen(t *testing.T) {
+	t.Setenv("TEST_SECRET_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	c, err := NewSecretCipherFromEnv("TEST_SECRET_KEY", false)
+	if err != nil {
+		t.Fatalf("创建加密器失败: %v", err)
+	}
+
+	sealed, err := c.Seal("JBSWY3DPEHPK3PXP", "u-1")
+	if err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	if !IsSealedSecret(sealed) || strings.Contains(sealed, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("密文格式不正确: %s", sealed)
+	}
+
+	other, _ := NewSecretCipher([]byte(strings.Repeat("k", 32)))
+	tampered := sealed[:len(sealed)-2] + "AA"
+
+	tests := []struct {
+		name    string
+		cipher  *SecretCipher
+		sealed  string
+		aad     string
+		wantErr bool
+	}{
+		{name: "正确的密钥和用户", cipher: c, sealed: sealed, aad: "u-1"},
+		{name: "其他用户", cipher: c, sealed: sealed, aad: "u-2", wantErr: true},
+		{name: "其他密钥", cipher: other, sealed: sealed, aad: "u-1", wantErr: true},
+		{name: "密文被篡改", cipher: c, sealed: tampered, aad: "u-1", wantErr: true},
+		{name: "明文", cipher: c, sealed: "JBSWY3DPEHPK3PXP", aad: "u-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := tt.cipher.Open(tt.sealed, tt.aad)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open 错误 = %v, 期望出错 %v", err, tt.wantErr)
+			}
+			if err == nil && plaintext != "JBSWY3DPEHPK3PXP" {
+				t.Errorf("解密结果 = %q", plaintext)
+			}
+		})
+	}
+}
+
+func TestNewSecretCipherFromEnvRequiresKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		allowDevKey bool
+		wantErr     bool
+	}{
+		{name: "release模式缺少密钥", wantErr: true},
+		{name: "开发模式使用开发密钥", allowDevKey: true},
+		{name: "密钥长度不正确", value: base64.StdEncoding.EncodeToString(make([]byte, 16)), allowDevKey: true, wantErr: true},
+		{name: "密钥不是Base64", value: "not base64!", allowDevKey: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_SECRET_KEY", tt.value)
+			_, err := NewSecretCipherFromEnv("TEST_SECRET_KEY", tt.allowDevKey)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSecretCipherFromEnv 错误 = %v, 期望出错 %v", err, tt.wantErr)
+			}
+			if tt.value == "" && tt.wantErr && !errors.Is(err, ErrSecretKeyMissing) {
+				t.Errorf("缺少密钥时应返回 ErrSecretKeyMissing, 实际 %v", err)
+			}
+		})
+	}
+}
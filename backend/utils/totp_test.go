@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// RFC 6238附录B的SHA-1测试向量，密钥为ASCII字符串"12345678901234567890"
+// 附录给出的是8位验证码，本实现使用6位，取其后6位比较（截断值对10^6取模）
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "94287082"},
+	{1111111109, "07081804"},
+	{1111111111, "14050471"},
+	{1234567890, "89005924"},
+	{2000000000, "69279037"},
+	{20000000000, "65353130"},
+}
+
+func rfc6238Secret() string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+}
+
+func TestTOTPCode(t *testing.T) {
+	secret := rfc6238Secret()
+	for _, tc := range rfc6238Vectors {
+		want := tc.code[len(tc.code)-totpDigits:]
+		got, err := TOTPCode(secret, TOTPCounter(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatalf("t=%d: 计算验证码失败: %v", tc.unix, err)
+		}
+		if got != want {
+			t.Errorf("t=%d: 验证码 = %s, 期望 %s", tc.unix, got, want)
+		}
+	}
+}
+
+func TestTOTPCodeInvalidSecret(t *testing.T) {
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("无效的密钥应返回错误")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := rfc6238Secret()
+	for _, tc := range rfc6238Vectors {
+		at := time.Unix(tc.unix, 0)
+		code := tc.code[len(tc.code)-totpDigits:]
+		counter := TOTPCounter(at)
+
+		cases := []struct {
+			name   string
+			code   string
+			at     time.Time
+			skew   int64
+			want   bool
+			wantAt int64
+		}{
+			{"当前时间步", code, at, 0, true, counter},
+			{"前后空白", " " + code + " ", at, 0, true, counter},
+			{"上一时间步在偏差内", code, at.Add(totpPeriod * time.Second), 1, true, counter},
+			{"下一时间步在偏差内", code, at.Add(-totpPeriod * time.Second), 1, true, counter},
+			{"超出偏差", code, at.Add(2 * totpPeriod * time.Second), 1, false, 0},
+			{"8位验证码", tc.code, at, 1, false, 0},
+			{"长度错误", code[1:], at, 1, false, 0},
+		}
+		for _, c := range cases {
+			gotAt, ok := ValidateTOTP(secret, c.code, c.at, c.skew)
+			if ok != c.want || gotAt != c.wantAt {
+				t.Errorf("t=%d %s: ValidateTOTP = (%d, %v), 期望 (%d, %v)", tc.unix, c.name, gotAt, ok, c.wantAt, c.want)
+			}
+		}
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	got := TOTPProvisioningURI("MedCross", "alice", "JBSWY3DPEHPK3PXP")
+	want := "otpauth://totp/MedCross:alice?algorithm=SHA1&digits=6&issuer=MedCross&period=30&secret=JBSWY3DPEHPK3PXP"
+	if got != want {
+		t.Errorf("URI = %s, 期望 %s", got, want)
+	}
+}
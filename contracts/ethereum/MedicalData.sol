@@ -26,8 +26,43 @@ contract MedicalData {
     // 数据类型到数据ID的映射
     mapping(string => uint256[]) private typeToDataIds;
     
+    // 数据ID到所有者DID的映射（与Fabric链码记录的所有者相同）
+    mapping(uint256 => string) private dataOwnerDids;
+    
+    // 账户绑定的DID，首次使用DID上传时绑定，之后不可更改
+    mapping(address => string) private accountDids;
+    
+    // 所有者DID到数据ID的映射
+    mapping(string => uint256[]) private didDataIds;
+    
+    // 链上知情同意记录（只保存患者标识的哈希）
+    struct ConsentRecord {
+        bytes32 patientHash;  // 患者标识的SHA-256哈希
+        string payload;       // JSON格式的知情同意内容
+        address recordedBy;   // 写入记录的账户
+        uint256 updatedAt;    // 最近更新时间
+    }
+    
+    // 知情同意ID到记录的映射
+    mapping(string => ConsentRecord) private consents;
+    
+    // 审计日志检查点（Merkle根）
+    struct AuditCheckpoint {
+        bytes32 merkleRoot;    // 审计记录的Merkle根
+        uint256 fromSequence;  // 起始审计记录序号
+        uint256 toSequence;    // 结束审计记录序号
+        address recordedBy;    // 写入记录的账户
+        uint256 anchoredAt;    // 上链时间
+    }
+    
+    // 检查点ID到记录的映射，检查点写入后不可修改
+    mapping(string => AuditCheckpoint) private auditCheckpoints;
+    
     // 事件定义
     event DataUploaded(uint256 indexed id, address indexed owner, string dataType, uint256 timestamp);
+    event OwnerDidRecorded(uint256 indexed id, address indexed owner, string ownerDid);
+    event ConsentRecorded(string consentId, bytes32 indexed patientHash, string payload, uint256 timestamp);
+    event AuditCheckpointAnchored(string checkpointId, bytes32 indexed merkleRoot, uint256 fromSequence, uint256 toSequence, uint256 timestamp);
     
     /**
      * @dev 上传新的医疗数据
@@ -64,6 +99,41 @@ contract MedicalData {
         return newId;
     }
     
+    /**
+     * @dev 以DID作为所有者上传新的医疗数据
+     * 账户首次上传时绑定DID，之后必须使用相同的DID，避免冒用他人的DID
+     * @param ownerDid 数据所有者的DID
+     * @param dataHash 数据的哈希值
+     * @param dataType 数据类型
+     * @param metadata 元数据（JSON格式）
+     * @param keywords 关键词，用于搜索
+     * @return 新数据的ID
+     */
+    function uploadDataWithOwnerDid(
+        string memory ownerDid,
+        string memory dataHash,
+        string memory dataType,
+        string memory metadata,
+        string memory keywords
+    ) public returns (uint256) {
+        require(bytes(ownerDid).length > 0, "Owner DID is required");
+        
+        string memory boundDid = accountDids[msg.sender];
+        if (bytes(boundDid).length == 0) {
+            accountDids[msg.sender] = ownerDid;
+        } else {
+            require(keccak256(bytes(boundDid)) == keccak256(bytes(ownerDid)), "Owner DID does not match account");
+        }
+        
+        uint256 newId = uploadData(dataHash, dataType, metadata, keywords);
+        dataOwnerDids[newId] = ownerDid;
+        didDataIds[ownerDid].push(newId);
+        
+        emit OwnerDidRecorded(newId, msg.sender, ownerDid);
+        
+        return newId;
+    }
+    
     /**
      * @dev 获取特定ID的医疗数据
      * @param id 数据ID
@@ -101,6 +171,34 @@ contract MedicalData {
         return userDataIds[user];
     }
     
+    /**
+     * @dev 获取数据所有者的DID
+     * @param id 数据ID
+     * @return 所有者DID，使用uploadData上传的数据返回空字符串
+     */
+    function getOwnerDid(uint256 id) public view returns (string memory) {
+        require(id < allData.length, "Data does not exist");
+        return dataOwnerDids[id];
+    }
+    
+    /**
+     * @dev 获取账户绑定的DID
+     * @param account 账户地址
+     * @return 绑定的DID，未绑定时返回空字符串
+     */
+    function getAccountDid(address account) public view returns (string memory) {
+        return accountDids[account];
+    }
+    
+    /**
+     * @dev 获取DID拥有的所有数据ID
+     * @param ownerDid 所有者DID
+     * @return 数据ID数组
+     */
+    function getDataIdsByOwnerDid(string memory ownerDid) public view returns (uint256[] memory) {
+        return didDataIds[ownerDid];
+    }
+    
     /**
      * @dev 获取特定类型的所有数据ID
      * @param dataType 数据类型
@@ -110,6 +208,84 @@ contract MedicalData {
         return typeToDataIds[dataType];
     }
     
+    /**
+     * @dev 记录或更新知情同意
+     * @param consentId 知情同意ID
+     * @param patientHash 患者标识的哈希
+     * @param payload 知情同意内容（JSON格式）
+     */
+    function recordConsent(
+        string memory consentId,
+        bytes32 patientHash,
+        string memory payload
+    ) public {
+        ConsentRecord storage existing = consents[consentId];
+        require(
+            existing.recordedBy == address(0) || existing.recordedBy == msg.sender,
+            "Only the original recorder can update consent"
+        );
+        
+        consents[consentId] = ConsentRecord({
+            patientHash: patientHash,
+            payload: payload,
+            recordedBy: msg.sender,
+            updatedAt: block.timestamp
+        });
+        
+        emit ConsentRecorded(consentId, patientHash, payload, block.timestamp);
+    }
+    
+    /**
+     * @dev 获取知情同意记录
+     * @param consentId 知情同意ID
+     * @return 患者标识哈希、知情同意内容和最近更新时间
+     */
+    function getConsent(string memory consentId) public view returns (bytes32, string memory, uint256) {
+        ConsentRecord memory record = consents[consentId];
+        require(record.recordedBy != address(0), "Consent does not exist");
+        
+        return (record.patientHash, record.payload, record.updatedAt);
+    }
+    
+    /**
+     * @dev 锚定审计日志检查点
+     * @param checkpointId 检查点ID
+     * @param merkleRoot 审计记录的Merkle根
+     * @param fromSequence 起始审计记录序号
+     * @param toSequence 结束审计记录序号
+     */
+    function anchorAuditCheckpoint(
+        string memory checkpointId,
+        bytes32 merkleRoot,
+        uint256 fromSequence,
+        uint256 toSequence
+    ) public {
+        require(auditCheckpoints[checkpointId].recordedBy == address(0), "Checkpoint already anchored");
+        require(fromSequence <= toSequence, "Invalid sequence range");
+        
+        auditCheckpoints[checkpointId] = AuditCheckpoint({
+            merkleRoot: merkleRoot,
+            fromSequence: fromSequence,
+            toSequence: toSequence,
+            recordedBy: msg.sender,
+            anchoredAt: block.timestamp
+        });
+        
+        emit AuditCheckpointAnchored(checkpointId, merkleRoot, fromSequence, toSequence, block.timestamp);
+    }
+    
+    /**
+     * @dev 获取审计日志检查点
+     * @param checkpointId 检查点ID
+     * @return Merkle根、起始序号、结束序号和上链时间
+     */
+    function getAuditCheckpoint(string memory checkpointId) public view returns (bytes32, uint256, uint256, uint256) {
+        AuditCheckpoint memory checkpoint = auditCheckpoints[checkpointId];
+        require(checkpoint.recordedBy != address(0), "Checkpoint does not exist");
+        
+        return (checkpoint.merkleRoot, checkpoint.fromSequence, checkpoint.toSequence, checkpoint.anchoredAt);
+    }
+    
     /**
      * @dev 获取数据总数
      * @return 数据总数
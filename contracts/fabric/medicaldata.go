@@ -1,8 +1,11 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
@@ -14,18 +17,154 @@ type MedicalData struct {
 }
 
 // MedicalRecord 结构定义医疗数据记录
+// 所有者由交易创建者的客户端身份确定，不信任调用方传入的参数
 type MedicalRecord struct {
-	ID        string    `json:"id"`
-	Owner     string    `json:"owner"`
-	DataHash  string    `json:"dataHash"`  // IPFS或其他存储系统的哈希值
-	DataType  string    `json:"dataType"`  // 数据类型（如：影像数据、电子病历等）
-	Metadata  string    `json:"metadata"`  // JSON格式的元数据
-	Timestamp time.Time `json:"timestamp"` // 上传时间戳
-	Keywords  string    `json:"keywords"`  // 关键词，用于搜索，以逗号分隔
+	ID             string    `json:"id"`
+	Owner          string    `json:"owner"`                    // 所有者DID，证书没有DID属性时为客户端身份
+	OwnerMSP       string    `json:"ownerMsp"`                 // 上传者所属组织的MSP ID
+	OwnerID        string    `json:"ownerId"`                  // 上传者的客户端身份（x509::subject::issuer）
+	DataHash       string    `json:"dataHash"`                 // IPFS或其他存储系统的哈希值
+	DataType       string    `json:"dataType"`                 // 数据类型（如：影像数据、电子病历等）
+	Metadata       string    `json:"metadata"`                 // JSON格式的公开元数据，不含患者身份信息
+	Timestamp      time.Time `json:"timestamp"`                // 首次上传时间戳
+	Keywords       string    `json:"keywords"`                 // 关键词，用于搜索，以逗号分隔
+	Grantees       []string  `json:"grantees,omitempty"`       // 被所有者授权读取的身份（DID或客户端身份）
+	AuthorizedMSPs []string  `json:"authorizedMsps,omitempty"` // 被所有者授权读取的组织
+
+	// 敏感元数据保存在上传者所属组织的私有数据集合中，世界状态只保存集合名称和哈希
+	PrivateCollection   string `json:"privateCollection,omitempty"`
+	PrivateMetadataHash string `json:"privateMetadataHash,omitempty"` // 加盐后私有元数据的SHA-256哈希，其他组织可据此校验线下获得的元数据和盐
+
+	// 版本信息，每次更新、删除或访问授权变更生成新版本，全部版本可通过GetDataHistory查询
+	Version      int        `json:"version"`
+	TxID         string     `json:"txId"`                   // 生成当前版本的交易ID
+	PreviousTxID string     `json:"previousTxId,omitempty"` // 上一版本的交易ID
+	UpdatedAt    *time.Time `json:"updatedAt,omitempty"`
+	ChangeReason string     `json:"changeReason,omitempty"` // 更正或删除的原因
+
+	// 删除后保留墓碑记录，所有者和类型索引仍指向该记录，查询时跳过
+	Deleted   bool       `json:"deleted,omitempty"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// DataVersion 医疗数据的一个历史版本
+type DataVersion struct {
+	TxID      string         `json:"txId"`
+	Timestamp time.Time      `json:"timestamp"`
+	IsDelete  bool           `json:"isDelete"` // 键被从世界状态中删除（墓碑记录不会出现该情况）
+	Record    *MedicalRecord `json:"record,omitempty"`
+}
+
+// PrivateMedicalData 私有数据集合中的敏感元数据
+type PrivateMedicalData struct {
+	ID       string `json:"id"`
+	Metadata string `json:"metadata"` // JSON格式的敏感元数据
+	Salt     string `json:"salt"`     // 十六进制的随机盐，公开哈希为SHA-256(盐 || 元数据)
+}
+
+// 上传时通过瞬态数据传入敏感元数据和随机盐的键，瞬态数据不写入交易和区块
+// 患者标识等字段取值空间很小，不加盐的哈希可以被穷举还原
+const (
+	privateMetadataTransientKey = "privateMetadata"
+	privateSaltTransientKey     = "privateMetadataSalt"
+	minPrivateSaltSize          = 16
+)
+
+// privateMetadataFields 可识别患者身份的元数据字段，只能通过瞬态数据传入私有数据集合
+var privateMetadataFields = []string{"patientId", "patientAddress", "patientName", "hospital", "department"}
+
+// 组织的私有数据集合名称，与 collections_config.json 中的定义一致
+func privateCollectionName(mspID string) string {
+	return mspID + "PrivateCollection"
+}
+
+// 平台Fabric CA登记时写入证书的DID属性
+const didAttribute = "medcross.did"
+
+// 审计员登记表在世界状态中的键
+// 证书属性在登记时固定，角色变更后不会更新，因此审计员由平台后端在链上登记和移除
+const (
+	auditorAdminKey   = "auditorAdmin"
+	auditorObjectType = "auditor"
+)
+
+// clientIdentity 交易创建者的身份
+type clientIdentity struct {
+	ID    string // 客户端身份（x509::subject::issuer）
+	MSPID string // 所属组织的MSP ID
+	DID   string // 证书中的DID属性，没有时为空
+}
+
+// 从交易上下文获取创建者的身份
+func getClientIdentity(ctx contractapi.TransactionContextInterface) (*clientIdentity, error) {
+	cid := ctx.GetClientIdentity()
+
+	id, err := cid.GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client identity: %v", err)
+	}
+	mspID, err := cid.GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	did, _, err := cid.GetAttributeValue(didAttribute)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client attribute %s: %v", didAttribute, err)
+	}
+
+	return &clientIdentity{ID: id, MSPID: mspID, DID: did}, nil
+}
+
+// 创建者作为数据所有者时的名称：证书有DID属性时为DID，否则为客户端身份
+func (c *clientIdentity) ownerName() string {
+	if c.DID != "" {
+		return c.DID
+	}
+	return c.ID
+}
+
+// 检查创建者是否为记录的所有者
+func (c *clientIdentity) owns(record *MedicalRecord) bool {
+	return c.ID == record.OwnerID || (c.DID != "" && c.DID == record.Owner)
+}
+
+// 检查记录能否出现在创建者的列表查询结果中，已删除的墓碑记录不返回
+func (c *clientIdentity) canList(record *MedicalRecord) bool {
+	return !record.Deleted && c.canRead(record)
+}
+
+// 检查创建者能否读取记录：所有者、被授权的身份和被授权组织的成员
+func (c *clientIdentity) canRead(record *MedicalRecord) bool {
+	if c.owns(record) {
+		return true
+	}
+	for _, grantee := range record.Grantees {
+		if grantee == c.ID || (c.DID != "" && grantee == c.DID) {
+			return true
+		}
+	}
+	for _, mspID := range record.AuthorizedMSPs {
+		if mspID == c.MSPID {
+			return true
+		}
+	}
+	return false
 }
 
 // UploadData 上传新的医疗数据
+// 所有者为交易创建者；owner参数为空时使用创建者身份，不为空时必须与创建者身份一致
 func (s *MedicalData) UploadData(ctx contractapi.TransactionContextInterface, id string, owner string, dataHash string, dataType string, metadata string, keywords string) error {
+	client, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	if owner == "" {
+		owner = client.ownerName()
+	}
+	if owner != client.ownerName() {
+		return fmt.Errorf("permission denied: owner %s does not match client identity %s", owner, client.ownerName())
+	}
+
 	// 检查数据是否已存在
 	exists, err := s.DataExists(ctx, id)
 	if err != nil {
@@ -35,15 +174,34 @@ func (s *MedicalData) UploadData(ctx contractapi.TransactionContextInterface, id
 		return fmt.Errorf("data already exists: %s", id)
 	}
 
+	// 公开元数据不能包含患者身份信息
+	if err := checkPublicMetadata(metadata); err != nil {
+		return err
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
 	// 创建新的医疗数据记录
 	record := MedicalRecord{
 		ID:        id,
 		Owner:     owner,
+		OwnerMSP:  client.MSPID,
+		OwnerID:   client.ID,
 		DataHash:  dataHash,
 		DataType:  dataType,
 		Metadata:  metadata,
-		Timestamp: time.Now(),
+		Timestamp: now,
 		Keywords:  keywords,
+		Version:   1,
+		TxID:      ctx.GetStub().GetTxID(),
+	}
+
+	// 敏感元数据写入上传者所属组织的私有数据集合
+	if err := s.putPrivateMetadata(ctx, &record); err != nil {
+		return err
 	}
 
 	// 将数据转换为JSON并存储
@@ -85,8 +243,129 @@ func (s *MedicalData) UploadData(ctx contractapi.TransactionContextInterface, id
 	return nil
 }
 
-// GetData 根据ID获取医疗数据
+// GetData 根据ID获取医疗数据，只有所有者、被授权的身份和被授权组织的成员可以读取
 func (s *MedicalData) GetData(ctx contractapi.TransactionContextInterface, id string) (*MedicalRecord, error) {
+	client, err := getClientIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := s.readRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !client.canRead(record) {
+		return nil, fmt.Errorf("permission denied: client %s is not authorized to read data %s", client.ownerName(), id)
+	}
+	if record.Deleted {
+		return nil, fmt.Errorf("data has been deleted: %s", id)
+	}
+
+	// 只有私有数据集合所属组织的成员能读取敏感元数据，其他组织只返回公开字段和哈希
+	if record.PrivateCollection != "" && client.MSPID == record.OwnerMSP {
+		if err := s.mergePrivateMetadata(ctx, record); err != nil {
+			return nil, err
+		}
+	}
+
+	return record, nil
+}
+
+// 检查公开元数据中是否包含患者身份信息
+func checkPublicMetadata(metadata string) error {
+	if metadata == "" {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(metadata), &fields); err != nil {
+		return fmt.Errorf("metadata must be a JSON object: %v", err)
+	}
+	for _, name := range privateMetadataFields {
+		if _, exists := fields[name]; exists {
+			return fmt.Errorf("metadata field %s must be passed as transient data %s", name, privateMetadataTransientKey)
+		}
+	}
+	return nil
+}
+
+// 将瞬态数据中的敏感元数据写入创建者所属组织的私有数据集合，并在公开记录中保存加盐哈希
+// 链码不能自行生成随机数（各背书节点的结果必须一致），盐由客户端通过瞬态数据传入
+func (s *MedicalData) putPrivateMetadata(ctx contractapi.TransactionContextInterface, record *MedicalRecord) error {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to get transient data: %v", err)
+	}
+	privateJSON, exists := transient[privateMetadataTransientKey]
+	if !exists || len(privateJSON) == 0 {
+		return nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(privateJSON, &fields); err != nil {
+		return fmt.Errorf("private metadata must be a JSON object: %v", err)
+	}
+	salt := transient[privateSaltTransientKey]
+	if len(salt) < minPrivateSaltSize {
+		return fmt.Errorf("transient data %s must contain at least %d random bytes", privateSaltTransientKey, minPrivateSaltSize)
+	}
+
+	private := PrivateMedicalData{ID: record.ID, Metadata: string(privateJSON), Salt: hex.EncodeToString(salt)}
+	data, err := json.Marshal(private)
+	if err != nil {
+		return fmt.Errorf("failed to marshal private data: %v", err)
+	}
+
+	collection := privateCollectionName(record.OwnerMSP)
+	if err := ctx.GetStub().PutPrivateData(collection, record.ID, data); err != nil {
+		return fmt.Errorf("failed to put private data in collection %s: %v", collection, err)
+	}
+
+	sum := sha256.Sum256(append(append([]byte{}, salt...), privateJSON...))
+	record.PrivateCollection = collection
+	record.PrivateMetadataHash = hex.EncodeToString(sum[:])
+	return nil
+}
+
+// 从私有数据集合读取敏感元数据并合并到记录的元数据中
+func (s *MedicalData) mergePrivateMetadata(ctx contractapi.TransactionContextInterface, record *MedicalRecord) error {
+	data, err := ctx.GetStub().GetPrivateData(record.PrivateCollection, record.ID)
+	if err != nil {
+		return fmt.Errorf("failed to read private data from collection %s: %v", record.PrivateCollection, err)
+	}
+	if data == nil {
+		// 本节点没有该私有数据（如数据已过期清除），只返回公开字段
+		return nil
+	}
+
+	var private PrivateMedicalData
+	if err := json.Unmarshal(data, &private); err != nil {
+		return fmt.Errorf("failed to unmarshal private data: %v", err)
+	}
+
+	merged := make(map[string]interface{})
+	if record.Metadata != "" {
+		if err := json.Unmarshal([]byte(record.Metadata), &merged); err != nil {
+			return fmt.Errorf("failed to unmarshal metadata: %v", err)
+		}
+	}
+	var privateFields map[string]interface{}
+	if err := json.Unmarshal([]byte(private.Metadata), &privateFields); err != nil {
+		return fmt.Errorf("failed to unmarshal private metadata: %v", err)
+	}
+	for name, value := range privateFields {
+		merged[name] = value
+	}
+
+	metadata, err := json.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %v", err)
+	}
+	record.Metadata = string(metadata)
+	return nil
+}
+
+// 从账本中读取医疗数据，不检查权限
+func (s *MedicalData) readRecord(ctx contractapi.TransactionContextInterface, id string) (*MedicalRecord, error) {
 	// 从账本中获取数据
 	recordJSON, err := ctx.GetStub().GetState(id)
 	if err != nil {
@@ -106,8 +385,324 @@ func (s *MedicalData) GetData(ctx contractapi.TransactionContextInterface, id st
 	return &record, nil
 }
 
-// GetDataByOwner 获取特定所有者的所有数据
+// 将医疗数据写回账本
+func (s *MedicalData) putRecord(ctx contractapi.TransactionContextInterface, record *MedicalRecord) error {
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(record.ID, recordJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put data in world state: %v", err)
+	}
+
+	return nil
+}
+
+// UpdateData 所有者更正医疗数据，生成指向上一版本的新版本
+// 新的敏感元数据同样通过瞬态数据传入，未传入时保留原有的私有元数据
+func (s *MedicalData) UpdateData(ctx contractapi.TransactionContextInterface, id string, dataHash string, dataType string, metadata string, keywords string, reason string) error {
+	record, err := s.ownedRecord(ctx, id)
+	if err != nil {
+		return err
+	}
+	if record.Deleted {
+		return fmt.Errorf("data has been deleted: %s", id)
+	}
+	if err := checkPublicMetadata(metadata); err != nil {
+		return err
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 数据类型变更时更新类型索引
+	if dataType != record.DataType {
+		if err := s.moveTypeIndex(ctx, id, record.DataType, dataType); err != nil {
+			return err
+		}
+	}
+
+	record.DataHash = dataHash
+	record.DataType = dataType
+	record.Metadata = metadata
+	record.Keywords = keywords
+	s.nextVersion(ctx, record, now, reason)
+
+	if err := s.putPrivateMetadata(ctx, record); err != nil {
+		return err
+	}
+	if err := s.putRecord(ctx, record); err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent("DataUpdated", []byte(fmt.Sprintf(`{"id":%q,"version":%d,"previousTxId":%q}`, id, record.Version, record.PreviousTxID)))
+}
+
+// DeleteData 所有者删除医疗数据
+// 世界状态中保留墓碑记录作为新版本，所有者和类型索引仍指向该记录以便审计，查询时跳过；
+// 公开元数据和关键词被清除，私有数据集合中的敏感元数据被删除，数据哈希保留用于审计
+func (s *MedicalData) DeleteData(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	record, err := s.ownedRecord(ctx, id)
+	if err != nil {
+		return err
+	}
+	if record.Deleted {
+		return fmt.Errorf("data has been deleted: %s", id)
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	if record.PrivateCollection != "" {
+		if err := ctx.GetStub().DelPrivateData(record.PrivateCollection, id); err != nil {
+			return fmt.Errorf("failed to delete private data from collection %s: %v", record.PrivateCollection, err)
+		}
+	}
+
+	record.Metadata = ""
+	record.Keywords = ""
+	record.Deleted = true
+	record.DeletedAt = &now
+	s.nextVersion(ctx, record, now, reason)
+
+	if err := s.putRecord(ctx, record); err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent("DataDeleted", []byte(fmt.Sprintf(`{"id":%q,"version":%d,"previousTxId":%q}`, id, record.Version, record.PreviousTxID)))
+}
+
+// GetDataHistory 基于账本的键历史获取医疗数据的全部版本，包括访问授权变更和删除
+// 有权读取当前记录的调用者和链上登记的审计员可以查询，已删除的数据仍可查询
+func (s *MedicalData) GetDataHistory(ctx contractapi.TransactionContextInterface, id string) ([]*DataVersion, error) {
+	client, err := getClientIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := s.readRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	auditor, err := s.isAuditor(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if !client.canRead(record) && !auditor {
+		return nil, fmt.Errorf("permission denied: client %s is not authorized to read the history of data %s", client.ownerName(), id)
+	}
+
+	iterator, err := ctx.GetStub().GetHistoryForKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history for data %s: %v", id, err)
+	}
+	defer iterator.Close()
+
+	var versions []*DataVersion
+
+	// 遍历历史版本，账本按提交顺序返回
+	for iterator.HasNext() {
+		modification, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate: %v", err)
+		}
+
+		version := &DataVersion{
+			TxID:     modification.TxId,
+			IsDelete: modification.IsDelete,
+		}
+		if modification.Timestamp != nil {
+			version.Timestamp = modification.Timestamp.AsTime()
+		}
+		if !modification.IsDelete && len(modification.Value) > 0 {
+			var historical MedicalRecord
+			if err := json.Unmarshal(modification.Value, &historical); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal data: %v", err)
+			}
+			version.Record = &historical
+		}
+
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// 记录生成新版本：版本号加一，指向上一版本的交易
+func (s *MedicalData) nextVersion(ctx contractapi.TransactionContextInterface, record *MedicalRecord, now time.Time, reason string) {
+	// 版本功能上线前上传的记录没有版本号，视为第1版
+	if record.Version == 0 {
+		record.Version = 1
+	}
+	record.Version++
+	record.PreviousTxID = record.TxID
+	record.TxID = ctx.GetStub().GetTxID()
+	record.UpdatedAt = &now
+	record.ChangeReason = reason
+}
+
+// 数据类型变更时把类型索引从旧类型移到新类型
+func (s *MedicalData) moveTypeIndex(ctx contractapi.TransactionContextInterface, id string, oldType string, newType string) error {
+	oldKey, err := ctx.GetStub().CreateCompositeKey("type~id", []string{oldType, id})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+	if err := ctx.GetStub().DelState(oldKey); err != nil {
+		return fmt.Errorf("failed to delete type composite key: %v", err)
+	}
+
+	newKey, err := ctx.GetStub().CreateCompositeKey("type~id", []string{newType, id})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(newKey, []byte{0}); err != nil {
+		return fmt.Errorf("failed to put type composite key: %v", err)
+	}
+
+	return nil
+}
+
+// 获取交易时间戳，所有背书节点得到相同的值
+func txTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	return timestamp.AsTime(), nil
+}
+
+// GrantAccess 所有者授权其他身份（DID或客户端身份）读取数据
+func (s *MedicalData) GrantAccess(ctx contractapi.TransactionContextInterface, id string, grantee string) error {
+	if grantee == "" {
+		return fmt.Errorf("grantee is required")
+	}
+
+	record, err := s.changeAccess(ctx, id, "access granted: "+grantee, func(record *MedicalRecord) {
+		record.Grantees = addUnique(record.Grantees, grantee)
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent("DataAccessGranted", []byte(fmt.Sprintf(`{"id":%q,"grantee":%q,"version":%d}`, id, grantee, record.Version)))
+}
+
+// RevokeAccess 所有者撤销其他身份的读取授权
+func (s *MedicalData) RevokeAccess(ctx contractapi.TransactionContextInterface, id string, grantee string) error {
+	record, err := s.changeAccess(ctx, id, "access revoked: "+grantee, func(record *MedicalRecord) {
+		record.Grantees = removeValue(record.Grantees, grantee)
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent("DataAccessRevoked", []byte(fmt.Sprintf(`{"id":%q,"grantee":%q,"version":%d}`, id, grantee, record.Version)))
+}
+
+// AuthorizeMSP 所有者授权一个组织的成员读取数据
+func (s *MedicalData) AuthorizeMSP(ctx contractapi.TransactionContextInterface, id string, mspID string) error {
+	if mspID == "" {
+		return fmt.Errorf("MSP ID is required")
+	}
+
+	record, err := s.changeAccess(ctx, id, "msp authorized: "+mspID, func(record *MedicalRecord) {
+		record.AuthorizedMSPs = addUnique(record.AuthorizedMSPs, mspID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent("DataMSPAuthorized", []byte(fmt.Sprintf(`{"id":%q,"mspId":%q,"version":%d}`, id, mspID, record.Version)))
+}
+
+// RevokeMSP 所有者撤销组织的读取授权
+func (s *MedicalData) RevokeMSP(ctx contractapi.TransactionContextInterface, id string, mspID string) error {
+	record, err := s.changeAccess(ctx, id, "msp revoked: "+mspID, func(record *MedicalRecord) {
+		record.AuthorizedMSPs = removeValue(record.AuthorizedMSPs, mspID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent("DataMSPRevoked", []byte(fmt.Sprintf(`{"id":%q,"mspId":%q,"version":%d}`, id, mspID, record.Version)))
+}
+
+// 所有者变更访问授权，作为新版本写入，previousTxId 链因此覆盖授权变更；已删除的数据不能再授权
+func (s *MedicalData) changeAccess(ctx contractapi.TransactionContextInterface, id string, reason string, change func(record *MedicalRecord)) (*MedicalRecord, error) {
+	record, err := s.ownedRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if record.Deleted {
+		return nil, fmt.Errorf("data has been deleted: %s", id)
+	}
+
+	now, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	change(record)
+	s.nextVersion(ctx, record, now, reason)
+	if err := s.putRecord(ctx, record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// 获取交易创建者作为所有者的数据，不是所有者时返回权限错误
+func (s *MedicalData) ownedRecord(ctx contractapi.TransactionContextInterface, id string) (*MedicalRecord, error) {
+	client, err := getClientIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := s.readRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !client.owns(record) {
+		return nil, fmt.Errorf("permission denied: only the owner can manage access to data %s", id)
+	}
+
+	return record, nil
+}
+
+// 列表中不存在时追加
+func addUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// 从列表中删除值
+func removeValue(values []string, value string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// GetDataByOwner 获取特定所有者的所有数据，只返回调用者有权读取的记录
 func (s *MedicalData) GetDataByOwner(ctx contractapi.TransactionContextInterface, owner string) ([]*MedicalRecord, error) {
+	client, err := getClientIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	// 创建复合键迭代器
 	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("owner~id", []string{owner})
 	if err != nil {
@@ -132,20 +727,27 @@ func (s *MedicalData) GetDataByOwner(ctx contractapi.TransactionContextInterface
 
 		if len(compositeKeyParts) > 1 {
 			id := compositeKeyParts[1]
-			// 获取数据记录
-			record, err := s.GetData(ctx, id)
+			// 获取数据记录，跳过无权读取的记录
+			record, err := s.readRecord(ctx, id)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get data: %v", err)
 			}
-			records = append(records, record)
+			if client.canList(record) {
+				records = append(records, record)
+			}
 		}
 	}
 
 	return records, nil
 }
 
-// GetDataByType 获取特定类型的所有数据
+// GetDataByType 获取特定类型的所有数据，只返回调用者有权读取的记录
 func (s *MedicalData) GetDataByType(ctx contractapi.TransactionContextInterface, dataType string) ([]*MedicalRecord, error) {
+	client, err := getClientIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	// 创建复合键迭代器
 	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey("type~id", []string{dataType})
 	if err != nil {
@@ -170,22 +772,38 @@ func (s *MedicalData) GetDataByType(ctx contractapi.TransactionContextInterface,
 
 		if len(compositeKeyParts) > 1 {
 			id := compositeKeyParts[1]
-			// 获取数据记录
-			record, err := s.GetData(ctx, id)
+			// 获取数据记录，跳过无权读取的记录
+			record, err := s.readRecord(ctx, id)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get data: %v", err)
 			}
-			records = append(records, record)
+			if client.canList(record) {
+				records = append(records, record)
+			}
 		}
 	}
 
 	return records, nil
 }
 
-// QueryDataByKeywords 根据关键词查询数据
+// QueryDataByKeywords 根据关键词查询数据，只返回调用者有权读取的记录
 func (s *MedicalData) QueryDataByKeywords(ctx contractapi.TransactionContextInterface, keyword string) ([]*MedicalRecord, error) {
-	// 构建富查询
-	queryString := fmt.Sprintf(`{"selector":{"keywords":{"$regex":".*%s.*"}}}", keyword)
+	client, err := getClientIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 构建富查询，关键词按字面匹配并转义为JSON字符串
+	selector := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"keywords": map[string]string{"$regex": ".*" + regexp.QuoteMeta(keyword) + ".*"},
+		},
+	}
+	queryJSON, err := json.Marshal(selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %v", err)
+	}
+	queryString := string(queryJSON)
 
 	// 执行查询
 	iterator, err := ctx.GetStub().GetQueryResult(queryString)
@@ -209,12 +827,275 @@ func (s *MedicalData) QueryDataByKeywords(ctx contractapi.TransactionContextInte
 			return nil, fmt.Errorf("failed to unmarshal data: %v", err)
 		}
 
-		records = append(records, &record)
+		if client.canList(&record) {
+			records = append(records, &record)
+		}
 	}
 
 	return records, nil
 }
 
+// ConsentRecord 结构定义链上知情同意记录
+// 链上只保存患者标识的哈希，不保存明文患者信息
+type ConsentRecord struct {
+	ConsentID   string   `json:"consentId"`
+	Action      string   `json:"action"`      // 最近一次操作: "grant" 或 "revoke"
+	PatientHash string   `json:"patientHash"` // 患者标识的SHA-256哈希
+	GranteeID   string   `json:"granteeId"`
+	Purpose     string   `json:"purpose"`
+	DataTypes   []string `json:"dataTypes"`
+	ValidFrom   int64    `json:"validFrom"`  // Unix时间戳
+	ValidUntil  int64    `json:"validUntil"` // Unix时间戳
+	Status      string   `json:"status"`
+	RecorderID  string   `json:"recorderId"`  // 首次记录者的客户端身份，由链码根据创建者证书设置
+	RecorderMSP string   `json:"recorderMsp"` // 首次记录者所属组织的MSP ID
+}
+
+// RecordConsent 记录或更新知情同意
+// 首次记录时保存记录者的身份，之后只有同一组织的同一身份可以更新
+func (s *MedicalData) RecordConsent(ctx contractapi.TransactionContextInterface, consentJSON string) error {
+	var consent ConsentRecord
+	if err := json.Unmarshal([]byte(consentJSON), &consent); err != nil {
+		return fmt.Errorf("failed to unmarshal consent: %v", err)
+	}
+	if consent.ConsentID == "" || consent.PatientHash == "" {
+		return fmt.Errorf("consent id and patient hash are required")
+	}
+
+	// 使用复合键存储，避免与医疗数据记录混淆
+	consentKey, err := ctx.GetStub().CreateCompositeKey("consent", []string{consent.ConsentID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	client, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	existingJSON, err := ctx.GetStub().GetState(consentKey)
+	if err != nil {
+		return fmt.Errorf("failed to read consent from world state: %v", err)
+	}
+	if existingJSON != nil {
+		var existing ConsentRecord
+		if err := json.Unmarshal(existingJSON, &existing); err != nil {
+			return fmt.Errorf("failed to unmarshal consent: %v", err)
+		}
+		if existing.RecorderID != client.ID || existing.RecorderMSP != client.MSPID {
+			return fmt.Errorf("only the original recorder can update consent")
+		}
+	}
+	consent.RecorderID = client.ID
+	consent.RecorderMSP = client.MSPID
+
+	recordJSON, err := json.Marshal(consent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal consent: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(consentKey, recordJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put consent in world state: %v", err)
+	}
+
+	return ctx.GetStub().SetEvent("ConsentRecorded", recordJSON)
+}
+
+// GetConsent 根据ID获取知情同意记录
+func (s *MedicalData) GetConsent(ctx contractapi.TransactionContextInterface, consentID string) (*ConsentRecord, error) {
+	consentKey, err := ctx.GetStub().CreateCompositeKey("consent", []string{consentID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	recordJSON, err := ctx.GetStub().GetState(consentKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read consent from world state: %v", err)
+	}
+	if recordJSON == nil {
+		return nil, fmt.Errorf("consent does not exist: %s", consentID)
+	}
+
+	var consent ConsentRecord
+	err = json.Unmarshal(recordJSON, &consent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal consent: %v", err)
+	}
+
+	return &consent, nil
+}
+
+// AuditCheckpoint 结构定义链上审计日志检查点
+type AuditCheckpoint struct {
+	CheckpointID string `json:"checkpointId"`
+	MerkleRoot   string `json:"merkleRoot"`   // 十六进制Merkle根
+	FromSequence int64  `json:"fromSequence"` // 起始审计记录序号
+	ToSequence   int64  `json:"toSequence"`   // 结束审计记录序号
+	TreeSize     int64  `json:"treeSize"`
+	CreatedAt    int64  `json:"createdAt"` // Unix时间戳
+}
+
+// AnchorAuditCheckpoint 锚定审计日志检查点，检查点写入后不可修改
+func (s *MedicalData) AnchorAuditCheckpoint(ctx contractapi.TransactionContextInterface, checkpointJSON string) error {
+	var checkpoint AuditCheckpoint
+	if err := json.Unmarshal([]byte(checkpointJSON), &checkpoint); err != nil {
+		return fmt.Errorf("failed to unmarshal checkpoint: %v", err)
+	}
+	if checkpoint.CheckpointID == "" || checkpoint.MerkleRoot == "" {
+		return fmt.Errorf("checkpoint id and merkle root are required")
+	}
+
+	checkpointKey, err := ctx.GetStub().CreateCompositeKey("auditCheckpoint", []string{checkpoint.CheckpointID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	existing, err := ctx.GetStub().GetState(checkpointKey)
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("checkpoint already anchored: %s", checkpoint.CheckpointID)
+	}
+
+	recordJSON, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(checkpointKey, recordJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put checkpoint in world state: %v", err)
+	}
+
+	return ctx.GetStub().SetEvent("AuditCheckpointAnchored", recordJSON)
+}
+
+// GetAuditCheckpoint 根据ID获取审计日志检查点
+func (s *MedicalData) GetAuditCheckpoint(ctx contractapi.TransactionContextInterface, checkpointID string) (*AuditCheckpoint, error) {
+	checkpointKey, err := ctx.GetStub().CreateCompositeKey("auditCheckpoint", []string{checkpointID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	recordJSON, err := ctx.GetStub().GetState(checkpointKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint from world state: %v", err)
+	}
+	if recordJSON == nil {
+		return nil, fmt.Errorf("checkpoint does not exist: %s", checkpointID)
+	}
+
+	var checkpoint AuditCheckpoint
+	err = json.Unmarshal(recordJSON, &checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal checkpoint: %v", err)
+	}
+
+	return &checkpoint, nil
+}
+
+// AuditorAdmin 审计员登记表的管理员，即初始化账本的平台网关身份
+type AuditorAdmin struct {
+	ID    string `json:"id"`
+	MSPID string `json:"mspId"`
+}
+
+// InitLedger 初始化账本，调用者成为审计员登记表的管理员，只能调用一次
+// 链码定义需使用 --init-required，由平台网关的身份在提交链码定义后首先调用
+func (s *MedicalData) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	existing, err := ctx.GetStub().GetState(auditorAdminKey)
+	if err != nil {
+		return fmt.Errorf("failed to read auditor admin from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("ledger already initialized")
+	}
+
+	client, err := getClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	adminJSON, err := json.Marshal(AuditorAdmin{ID: client.ID, MSPID: client.MSPID})
+	if err != nil {
+		return fmt.Errorf("failed to marshal auditor admin: %v", err)
+	}
+
+	return ctx.GetStub().PutState(auditorAdminKey, adminJSON)
+}
+
+// SetAuditor 登记审计员，审计员可以查询任意数据的历史版本；只有审计员登记表的管理员可以调用
+// auditor 为审计员的客户端身份（x509::subject::issuer）
+func (s *MedicalData) SetAuditor(ctx contractapi.TransactionContextInterface, auditor string) error {
+	key, err := s.auditorKey(ctx, auditor)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(key, []byte{0x00}); err != nil {
+		return fmt.Errorf("failed to put auditor in world state: %v", err)
+	}
+	return ctx.GetStub().SetEvent("AuditorAdded", []byte(auditor))
+}
+
+// RemoveAuditor 移除审计员，用户角色调整或停用后由平台后端调用；只有审计员登记表的管理员可以调用
+func (s *MedicalData) RemoveAuditor(ctx contractapi.TransactionContextInterface, auditor string) error {
+	key, err := s.auditorKey(ctx, auditor)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().DelState(key); err != nil {
+		return fmt.Errorf("failed to delete auditor from world state: %v", err)
+	}
+	return ctx.GetStub().SetEvent("AuditorRemoved", []byte(auditor))
+}
+
+// 检查调用者是否为审计员登记表的管理员，并返回审计员的键
+func (s *MedicalData) auditorKey(ctx contractapi.TransactionContextInterface, auditor string) (string, error) {
+	if auditor == "" {
+		return "", fmt.Errorf("auditor identity is required")
+	}
+
+	client, err := getClientIdentity(ctx)
+	if err != nil {
+		return "", err
+	}
+	adminJSON, err := ctx.GetStub().GetState(auditorAdminKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to read auditor admin from world state: %v", err)
+	}
+	if adminJSON == nil {
+		return "", fmt.Errorf("ledger is not initialized")
+	}
+	var admin AuditorAdmin
+	if err := json.Unmarshal(adminJSON, &admin); err != nil {
+		return "", fmt.Errorf("failed to unmarshal auditor admin: %v", err)
+	}
+	if client.ID != admin.ID || client.MSPID != admin.MSPID {
+		return "", fmt.Errorf("permission denied: only the auditor admin can manage auditors")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(auditorObjectType, []string{auditor})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// 检查创建者是否为链上登记的审计员
+func (s *MedicalData) isAuditor(ctx contractapi.TransactionContextInterface, client *clientIdentity) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(auditorObjectType, []string{client.ID})
+	if err != nil {
+		return false, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	value, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read auditor from world state: %v", err)
+	}
+	return value != nil, nil
+}
+
 // DataExists 检查数据是否存在
 func (s *MedicalData) DataExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
 	recordJSON, err := ctx.GetStub().GetState(id)
@@ -225,8 +1106,13 @@ func (s *MedicalData) DataExists(ctx contractapi.TransactionContextInterface, id
 	return recordJSON != nil, nil
 }
 
-// GetAllData 获取所有医疗数据
+// GetAllData 获取调用者有权读取的所有医疗数据
 func (s *MedicalData) GetAllData(ctx contractapi.TransactionContextInterface) ([]*MedicalRecord, error) {
+	client, err := getClientIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	// 获取所有数据的迭代器
 	iterator, err := ctx.GetStub().GetStateByRange("", "")
 	if err != nil {
@@ -252,7 +1138,9 @@ func (s *MedicalData) GetAllData(ctx contractapi.TransactionContextInterface) ([
 				continue
 			}
 
-			records = append(records, &record)
+			if client.canList(&record) {
+				records = append(records, &record)
+			}
 		}
 	}
 
@@ -271,4 +1159,4 @@ func main() {
 	if err := cc.Start(); err != nil {
 		fmt.Printf("Error starting MedicalData chaincode: %v\n", err)
 	}
-}
\ No newline at end of file
+}
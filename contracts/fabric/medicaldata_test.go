@@ -0,0 +1,465 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeStub 内存中的账本，只实现链码用到的方法，其余方法调用时panic
+// 与真实节点不同，同一交易内的写入立即可读
+type fakeStub struct {
+	shim.ChaincodeStubInterface
+
+	state     map[string][]byte
+	private   map[string]map[string][]byte // 集合 -> 键 -> 值
+	history   map[string][]*queryresult.KeyModification
+	transient map[string][]byte
+	events    map[string][]byte
+	txID      string
+	txTime    time.Time
+	txCount   int
+}
+
+func newFakeStub() *fakeStub {
+	return &fakeStub{
+		state:   make(map[string][]byte),
+		private: make(map[string]map[string][]byte),
+		history: make(map[string][]*queryresult.KeyModification),
+		events:  make(map[string][]byte),
+		txTime:  time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC),
+	}
+}
+
+// 开始新的交易：新的交易ID和时间戳，清空瞬态数据
+func (s *fakeStub) nextTx() {
+	s.txCount++
+	s.txID = fmt.Sprintf("tx-%d", s.txCount)
+	s.txTime = s.txTime.Add(time.Minute)
+	s.transient = nil
+}
+
+func (s *fakeStub) GetTxID() string {
+	return s.txID
+}
+
+func (s *fakeStub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	return timestamppb.New(s.txTime), nil
+}
+
+func (s *fakeStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+func (s *fakeStub) GetState(key string) ([]byte, error) {
+	return s.state[key], nil
+}
+
+func (s *fakeStub) PutState(key string, value []byte) error {
+	s.state[key] = value
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{
+		TxId:      s.txID,
+		Value:     value,
+		Timestamp: timestamppb.New(s.txTime),
+	})
+	return nil
+}
+
+func (s *fakeStub) DelState(key string) error {
+	delete(s.state, key)
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{
+		TxId:      s.txID,
+		Timestamp: timestamppb.New(s.txTime),
+		IsDelete:  true,
+	})
+	return nil
+}
+
+// 与shim相同的复合键格式
+func (s *fakeStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	key := "\x00" + objectType + "\x00"
+	for _, attribute := range attributes {
+		key += attribute + "\x00"
+	}
+	return key, nil
+}
+
+func (s *fakeStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	parts := strings.Split(strings.Trim(compositeKey, "\x00"), "\x00")
+	return parts[0], parts[1:], nil
+}
+
+func (s *fakeStub) GetPrivateData(collection, key string) ([]byte, error) {
+	return s.private[collection][key], nil
+}
+
+func (s *fakeStub) PutPrivateData(collection string, key string, value []byte) error {
+	if s.private[collection] == nil {
+		s.private[collection] = make(map[string][]byte)
+	}
+	s.private[collection][key] = value
+	return nil
+}
+
+func (s *fakeStub) DelPrivateData(collection, key string) error {
+	delete(s.private[collection], key)
+	return nil
+}
+
+func (s *fakeStub) SetEvent(name string, payload []byte) error {
+	s.events[name] = payload
+	return nil
+}
+
+func (s *fakeStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &fakeHistoryIterator{items: s.history[key]}, nil
+}
+
+// fakeHistoryIterator 键历史迭代器
+type fakeHistoryIterator struct {
+	items []*queryresult.KeyModification
+	next  int
+}
+
+func (it *fakeHistoryIterator) HasNext() bool {
+	return it.next < len(it.items)
+}
+
+func (it *fakeHistoryIterator) Next() (*queryresult.KeyModification, error) {
+	item := it.items[it.next]
+	it.next++
+	return item, nil
+}
+
+func (it *fakeHistoryIterator) Close() error {
+	return nil
+}
+
+// fakeIdentity 交易创建者的身份
+type fakeIdentity struct {
+	cid.ClientIdentity
+
+	id    string
+	mspID string
+	did   string
+}
+
+func (c *fakeIdentity) GetID() (string, error) {
+	return c.id, nil
+}
+
+func (c *fakeIdentity) GetMSPID() (string, error) {
+	return c.mspID, nil
+}
+
+func (c *fakeIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	if attrName == didAttribute && c.did != "" {
+		return c.did, true, nil
+	}
+	return "", false, nil
+}
+
+// fakeContext 交易上下文
+type fakeContext struct {
+	stub     *fakeStub
+	identity *fakeIdentity
+}
+
+func (c *fakeContext) GetStub() shim.ChaincodeStubInterface {
+	return c.stub
+}
+
+func (c *fakeContext) GetClientIdentity() cid.ClientIdentity {
+	return c.identity
+}
+
+// 测试用的身份：Org1的医生和同组织的其他成员、Org2的研究人员、审计员和平台网关
+var (
+	org1Doctor     = &fakeIdentity{id: "x509::CN=doctor,OU=client::CN=ca.org1", mspID: "Org1MSP", did: "did:web:localhost:users:doctor"}
+	org1Nurse      = &fakeIdentity{id: "x509::CN=nurse,OU=client::CN=ca.org1", mspID: "Org1MSP"}
+	org2Researcher = &fakeIdentity{id: "x509::CN=researcher,OU=client::CN=ca.org2", mspID: "Org2MSP"}
+	org2Auditor    = &fakeIdentity{id: "x509::CN=auditor,OU=client::CN=ca.org2", mspID: "Org2MSP"}
+	gateway        = &fakeIdentity{id: "x509::CN=gateway,OU=client::CN=ca.org1", mspID: "Org1MSP"}
+)
+
+// fakeLedger 多个身份共用的账本，每次调用as开始一个新交易
+type fakeLedger struct {
+	stub *fakeStub
+}
+
+func newFakeLedger() *fakeLedger {
+	return &fakeLedger{stub: newFakeStub()}
+}
+
+func (l *fakeLedger) as(identity *fakeIdentity) *fakeContext {
+	l.stub.nextTx()
+	return &fakeContext{stub: l.stub, identity: identity}
+}
+
+// 以org1Doctor的身份上传一条带私有元数据的记录
+func uploadRecord(t *testing.T, contract *MedicalData, ledger *fakeLedger, id string) {
+	t.Helper()
+
+	ctx := ledger.as(org1Doctor)
+	ctx.stub.transient = map[string][]byte{
+		privateMetadataTransientKey: []byte(`{"patientId":"P12345","hospital":"协和医院"}`),
+		privateSaltTransientKey:     []byte("0123456789abcdef"),
+	}
+	if err := contract.UploadData(ctx, id, "", "QmHash", "影像数据", `{"description":"胸部CT"}`, "CT,肺部"); err != nil {
+		t.Fatalf("上传数据失败: %v", err)
+	}
+}
+
+func TestNonOwnerCannotModifyData(t *testing.T) {
+	tests := []struct {
+		name    string
+		call    func(contract *MedicalData, ctx *fakeContext) error
+		wantErr string
+	}{
+		{
+			name: "以他人身份上传",
+			call: func(contract *MedicalData, ctx *fakeContext) error {
+				return contract.UploadData(ctx, "data-2", org1Doctor.did, "QmOther", "影像数据", "", "")
+			},
+			wantErr: "permission denied",
+		},
+		{
+			name: "覆盖他人的记录",
+			call: func(contract *MedicalData, ctx *fakeContext) error {
+				return contract.UploadData(ctx, "data-1", "", "QmOther", "影像数据", "", "")
+			},
+			wantErr: "data already exists",
+		},
+		{
+			name: "更正他人的记录",
+			call: func(contract *MedicalData, ctx *fakeContext) error {
+				return contract.UpdateData(ctx, "data-1", "QmOther", "影像数据", "", "", "更正")
+			},
+			wantErr: "permission denied",
+		},
+		{
+			name: "删除他人的记录",
+			call: func(contract *MedicalData, ctx *fakeContext) error {
+				return contract.DeleteData(ctx, "data-1", "删除")
+			},
+			wantErr: "permission denied",
+		},
+		{
+			name: "授权他人的记录",
+			call: func(contract *MedicalData, ctx *fakeContext) error {
+				return contract.GrantAccess(ctx, "data-1", org2Researcher.id)
+			},
+			wantErr: "permission denied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contract := new(MedicalData)
+			ledger := newFakeLedger()
+			uploadRecord(t, contract, ledger, "data-1")
+			before := string(ledger.stub.state["data-1"])
+
+			err := tt.call(contract, ledger.as(org2Researcher))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("错误 = %v, 期望包含 %q", err, tt.wantErr)
+			}
+			if string(ledger.stub.state["data-1"]) != before {
+				t.Errorf("被拒绝的调用修改了记录")
+			}
+		})
+	}
+}
+
+func TestGetDataMergesPrivateMetadataOnlyForOwnerMSP(t *testing.T) {
+	contract := new(MedicalData)
+	ledger := newFakeLedger()
+	uploadRecord(t, contract, ledger, "data-1")
+
+	if err := contract.GrantAccess(ledger.as(org1Doctor), "data-1", org1Nurse.id); err != nil {
+		t.Fatalf("授权失败: %v", err)
+	}
+	if err := contract.AuthorizeMSP(ledger.as(org1Doctor), "data-1", "Org2MSP"); err != nil {
+		t.Fatalf("授权组织失败: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		identity    *fakeIdentity
+		wantPrivate bool
+	}{
+		{name: "所有者", identity: org1Doctor, wantPrivate: true},
+		{name: "同组织的被授权人", identity: org1Nurse, wantPrivate: true},
+		{name: "其他组织的成员", identity: org2Researcher, wantPrivate: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := contract.GetData(ledger.as(tt.identity), "data-1")
+			if err != nil {
+				t.Fatalf("读取数据失败: %v", err)
+			}
+			var metadata map[string]interface{}
+			if err := json.Unmarshal([]byte(record.Metadata), &metadata); err != nil {
+				t.Fatalf("解析元数据失败: %v", err)
+			}
+			if _, hasPatient := metadata["patientId"]; hasPatient != tt.wantPrivate {
+				t.Errorf("元数据包含patientId = %v, 期望 %v: %s", hasPatient, tt.wantPrivate, record.Metadata)
+			}
+			if metadata["description"] != "胸部CT" {
+				t.Errorf("公开元数据丢失: %s", record.Metadata)
+			}
+			if record.PrivateMetadataHash == "" {
+				t.Errorf("缺少私有元数据哈希")
+			}
+		})
+	}
+
+	// 公开记录中不包含私有元数据
+	if strings.Contains(string(ledger.stub.state["data-1"]), "P12345") {
+		t.Errorf("世界状态中包含患者标识: %s", ledger.stub.state["data-1"])
+	}
+}
+
+func TestDeleteDataKeepsIndexes(t *testing.T) {
+	contract := new(MedicalData)
+	ledger := newFakeLedger()
+	uploadRecord(t, contract, ledger, "data-1")
+
+	if err := contract.DeleteData(ledger.as(org1Doctor), "data-1", "重复上传"); err != nil {
+		t.Fatalf("删除数据失败: %v", err)
+	}
+
+	ownerKey, _ := ledger.stub.CreateCompositeKey("owner~id", []string{org1Doctor.did, "data-1"})
+	typeKey, _ := ledger.stub.CreateCompositeKey("type~id", []string{"影像数据", "data-1"})
+	for name, key := range map[string]string{"owner~id": ownerKey, "type~id": typeKey} {
+		if ledger.stub.state[key] == nil {
+			t.Errorf("删除后%s索引被移除", name)
+		}
+	}
+
+	record, err := contract.readRecord(ledger.as(org1Doctor), "data-1")
+	if err != nil {
+		t.Fatalf("读取墓碑记录失败: %v", err)
+	}
+	if !record.Deleted || record.Metadata != "" || record.Keywords != "" || record.DataHash == "" {
+		t.Errorf("墓碑记录不正确: %+v", record)
+	}
+	if ledger.stub.private[record.PrivateCollection]["data-1"] != nil {
+		t.Errorf("私有元数据没有删除")
+	}
+
+	if _, err := contract.GetData(ledger.as(org1Doctor), "data-1"); err == nil || !strings.Contains(err.Error(), "data has been deleted") {
+		t.Errorf("读取已删除的数据: 错误 = %v", err)
+	}
+}
+
+func TestGetDataHistoryRequiresReadAccessOrAuditor(t *testing.T) {
+	contract := new(MedicalData)
+	ledger := newFakeLedger()
+
+	if err := contract.InitLedger(ledger.as(gateway)); err != nil {
+		t.Fatalf("初始化账本失败: %v", err)
+	}
+	if err := contract.SetAuditor(ledger.as(gateway), org2Auditor.id); err != nil {
+		t.Fatalf("登记审计员失败: %v", err)
+	}
+
+	uploadRecord(t, contract, ledger, "data-1")
+	if err := contract.GrantAccess(ledger.as(org1Doctor), "data-1", org1Nurse.id); err != nil {
+		t.Fatalf("授权失败: %v", err)
+	}
+	if err := contract.DeleteData(ledger.as(org1Doctor), "data-1", "重复上传"); err != nil {
+		t.Fatalf("删除数据失败: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		identity *fakeIdentity
+		wantErr  bool
+	}{
+		{name: "所有者", identity: org1Doctor},
+		{name: "被授权人", identity: org1Nurse},
+		{name: "登记的审计员", identity: org2Auditor},
+		{name: "无关的其他组织成员", identity: org2Researcher, wantErr: true},
+		{name: "未登记为审计员的网关", identity: gateway, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions, err := contract.GetDataHistory(ledger.as(tt.identity), "data-1")
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "permission denied") {
+					t.Fatalf("错误 = %v, 期望权限错误", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("查询历史失败: %v", err)
+			}
+			if len(versions) != 3 {
+				t.Fatalf("历史版本数 = %d, 期望 3（上传、授权、删除）", len(versions))
+			}
+			if last := versions[len(versions)-1].Record; last == nil || !last.Deleted {
+				t.Errorf("最后一个版本应为墓碑记录")
+			}
+		})
+	}
+
+	// 移除审计员后不能再查询
+	if err := contract.RemoveAuditor(ledger.as(gateway), org2Auditor.id); err != nil {
+		t.Fatalf("移除审计员失败: %v", err)
+	}
+	if _, err := contract.GetDataHistory(ledger.as(org2Auditor), "data-1"); err == nil {
+		t.Errorf("已移除的审计员仍可查询历史")
+	}
+}
+
+func TestAccessChangesCreateVersionsAndRejectDeletedData(t *testing.T) {
+	contract := new(MedicalData)
+	ledger := newFakeLedger()
+	uploadRecord(t, contract, ledger, "data-1")
+
+	changes := []struct {
+		name   string
+		call   func(ctx *fakeContext) error
+		reason string
+	}{
+		{name: "授权身份", call: func(ctx *fakeContext) error { return contract.GrantAccess(ctx, "data-1", org1Nurse.id) }, reason: "access granted: " + org1Nurse.id},
+		{name: "授权组织", call: func(ctx *fakeContext) error { return contract.AuthorizeMSP(ctx, "data-1", "Org2MSP") }, reason: "msp authorized: Org2MSP"},
+		{name: "撤销组织", call: func(ctx *fakeContext) error { return contract.RevokeMSP(ctx, "data-1", "Org2MSP") }, reason: "msp revoked: Org2MSP"},
+		{name: "撤销身份", call: func(ctx *fakeContext) error { return contract.RevokeAccess(ctx, "data-1", org1Nurse.id) }, reason: "access revoked: " + org1Nurse.id},
+	}
+
+	previous, _ := contract.readRecord(ledger.as(org1Doctor), "data-1")
+	for _, change := range changes {
+		ctx := ledger.as(org1Doctor)
+		if err := change.call(ctx); err != nil {
+			t.Fatalf("%s失败: %v", change.name, err)
+		}
+		record, _ := contract.readRecord(ctx, "data-1")
+		if record.Version != previous.Version+1 || record.PreviousTxID != previous.TxID || record.TxID != ctx.stub.txID {
+			t.Errorf("%s后版本 = %d/%s/%s, 期望 %d/%s/%s", change.name, record.Version, record.PreviousTxID, record.TxID, previous.Version+1, previous.TxID, ctx.stub.txID)
+		}
+		if record.ChangeReason != change.reason {
+			t.Errorf("%s后变更原因 = %q, 期望 %q", change.name, record.ChangeReason, change.reason)
+		}
+		previous = record
+	}
+
+	if err := contract.DeleteData(ledger.as(org1Doctor), "data-1", "重复上传"); err != nil {
+		t.Fatalf("删除数据失败: %v", err)
+	}
+	for _, change := range changes {
+		if err := change.call(ledger.as(org1Doctor)); err == nil || !strings.Contains(err.Error(), "data has been deleted") {
+			t.Errorf("对已删除的数据%s: 错误 = %v", change.name, err)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -318,6 +317,14 @@ func main() {
 	r.GET("/api/data-types", getDataTypes)       // 获取数据类型列表
 	r.GET("/api/data/:id", getDataDetail)        // 获取数据详情
 
+	// 用户签名交易
+	r.GET("/api/tx-params/ethereum/:address", getEthereumTxParams) // 以太坊交易参数
+	r.GET("/api/tx-params/fabric", getFabricTxParams)              // Fabric链码调用参数
+	r.POST("/api/relay", relayTransaction)                         // 转发用户签名的交易
+
+	// 后端提交的链上记录
+	r.POST("/api/blockchain/transaction/:chain/:txType", submitTransaction)
+
 	// 获取端口配置
 	port := os.Getenv("PORT")
 	if port == "" {